@@ -1,97 +1,508 @@
 package main
 
 import (
+        "archive/zip"
+        "bytes"
+        "context"
+        "crypto/hmac"
+        "crypto/rand"
+        "crypto/sha256"
         "database/sql"
         "database/sql/driver"
+        "encoding/base64"
+        "encoding/csv"
+        "encoding/hex"
         "encoding/json"
+        "errors"
+        "flag"
         "fmt"
+        "io"
         "log"
+        "math/big"
+        "net"
         "net/http"
+        "net/mail"
         "os"
         "strconv"
         "strings"
         "time"
+        "unicode/utf8"
         "path/filepath"
+        "reflect"
+
+        "4SaleBackendSkeleton/internal/arabic"
+        "4SaleBackendSkeleton/internal/broker"
+        "4SaleBackendSkeleton/internal/fieldcrypt"
+        "4SaleBackendSkeleton/internal/formdef"
+        "4SaleBackendSkeleton/internal/jsonpatch"
+        "4SaleBackendSkeleton/internal/phone"
+        "4SaleBackendSkeleton/internal/slug"
 
         _ "github.com/go-sql-driver/mysql"
         "github.com/joho/godotenv"
 )
 
-// Multi-language text type
-type MultiLanguageText map[string]string
+// MultiLanguageText and FormField live in formdef so the definition validator can share them
+type MultiLanguageText = formdef.MultiLanguageText
+
+// FormField represents a field in a form
+type FormField = formdef.Field
+
+// FormPage is one page of a multi-page form
+type FormPage = formdef.Page
+
+// FormQuiz holds the score bands of a form in quiz mode
+type FormQuiz = formdef.Quiz
+
+// Form represents a form definition
+type Form struct {
+        ID               int                `json:"id"`
+        WorkspaceID      int                `json:"workspaceId"`
+        Slug             string             `json:"slug,omitempty"`
+        PublicToken      string             `json:"publicToken,omitempty"`
+        Version          int                `json:"version"`
+        Title            MultiLanguageText  `json:"title"`
+        Description      MultiLanguageText  `json:"description,omitempty"`
+        Fields           []FormField        `json:"fields"`
+        Pages            []FormPage         `json:"pages,omitempty"`
+        Quiz             *FormQuiz          `json:"quiz,omitempty"`
+        SubmitButtonText MultiLanguageText  `json:"submitButtonText,omitempty"`
+        HeroImageUrl     string             `json:"heroImageUrl,omitempty"`
+        IsActive         bool               `json:"isActive"`
+        CreatedAt        time.Time          `json:"createdAt"`
+        UpdatedAt        time.Time          `json:"updatedAt"`
+        DeletedAt        *time.Time         `json:"deletedAt,omitempty"`
+        PurgeAt          *time.Time         `json:"purgeAt,omitempty"`
+        ClosesAt         *time.Time         `json:"closesAt,omitempty"`
+        Settings         FormSettings       `json:"settings"`
+        // Seats reports the options with a capacity and how full they are
+        Seats            []OptionSeats      `json:"seats,omitempty"`
+}
+
+// OptionSeats is how full an option with a capacity is. Respondents only see Full.
+type OptionSeats struct {
+	FieldID   string `json:"fieldId"`
+	Option    string `json:"option"`
+	Capacity  int    `json:"capacity,omitempty"`
+	Remaining *int   `json:"remaining,omitempty"`
+	Full      bool   `json:"full"`
+}
+
+// FormSettings holds per-form behavior that isn't part of the field definitions
+type FormSettings struct {
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// Identity is how respondents identify themselves: "phone" (the default), "email",
+	// "phone+email" or "anonymous"
+	Identity string `json:"identity,omitempty"`
+	// PreventDuplicates rejects a second response from the same phone number or email
+	PreventDuplicates bool `json:"preventDuplicates,omitempty"`
+	// DisableNumericAccess hides the form from public routes addressed by numeric ID,
+	// leaving only its slug and share token
+	DisableNumericAccess bool `json:"disableNumericAccess,omitempty"`
+	// Prefill lists the fields the public form may fill from query parameters named
+	// after their IDs; every hidden field must be listed
+	Prefill []string `json:"prefill,omitempty"`
+	// IPAddresses says how the respondent's address is kept with each response:
+	// "hash" (the default; needs field encryption or IP_HASH_SALT), "full" or "none".
+	// Anonymous forms are always "none".
+	IPAddresses string `json:"ipAddresses,omitempty"`
+	// Receipt is emailed to the respondent once their response is stored, in the
+	// language they answered in; the form must ask for an email
+	Receipt *EmailTemplate `json:"receipt,omitempty"`
+	// Notification is emailed to NotifyEmails for every stored response
+	Notification *EmailTemplate `json:"notification,omitempty"`
+	NotifyEmails []string       `json:"notifyEmails,omitempty"`
+}
+
+// EmailTemplate is an email sent when a response is stored. Its subject and body show
+// answers with {{fieldId}}, like the form's other texts.
+type EmailTemplate struct {
+	Subject MultiLanguageText `json:"subject"`
+	Body    MultiLanguageText `json:"body"`
+}
+
+// blank reports whether a text has no content in any language
+func blank(text MultiLanguageText) bool {
+	for _, s := range text {
+		if strings.TrimSpace(s) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// RetentionPolicy deletes or anonymizes responses once they are older than Days,
+// counted from submission or from the form's close date
+type RetentionPolicy struct {
+	Action string `json:"action"` // "delete" or "anonymize"
+	Days   int    `json:"days"`
+	From   string `json:"from"` // "submission" or "close"
+}
 
-// Scan implements the sql.Scanner interface for MultiLanguageText
-func (m *MultiLanguageText) Scan(value interface{}) error {
+// Scan implements the sql.Scanner interface for FormSettings
+func (s *FormSettings) Scan(value interface{}) error {
+	*s = FormSettings{}
 	if value == nil {
-		*m = make(map[string]string)
 		return nil
 	}
-	
 	bytes, ok := value.([]byte)
 	if !ok {
-		return fmt.Errorf("cannot scan %T into MultiLanguageText", value)
+		return fmt.Errorf("cannot scan %T into FormSettings", value)
 	}
-	
 	if len(bytes) == 0 {
-		*m = make(map[string]string)
-		return nil
-	}
-	
-	// First check if it's a string (backward compatibility)
-	var str string
-	if err := json.Unmarshal(bytes, &str); err == nil {
-		*m = MultiLanguageText{"en": str, "ar": ""}
 		return nil
 	}
-	
-	// Otherwise unmarshal as object
-	return json.Unmarshal(bytes, m)
+	return json.Unmarshal(bytes, s)
+}
+
+// Value implements the driver.Valuer interface for FormSettings
+func (s FormSettings) Value() (driver.Value, error) {
+	return json.Marshal(s)
 }
 
-// Value implements the driver.Valuer interface for MultiLanguageText
-func (m MultiLanguageText) Value() (driver.Value, error) {
-	if m == nil {
-		return "{}", nil
+// validateFormSettings checks settings sent on create and update and fills in defaults
+func validateFormSettings(settings FormSettings, closesAt *time.Time) error {
+	if p := settings.Retention; p != nil {
+		if p.Action != "delete" && p.Action != "anonymize" {
+			return errors.New("retention action must be delete or anonymize")
+		}
+		if p.Days <= 0 {
+			return errors.New("retention days must be positive")
+		}
+		if p.From == "" {
+			p.From = "submission"
+		}
+		if p.From != "submission" && p.From != "close" {
+			return errors.New("retention must count from submission or close")
+		}
+		if p.From == "close" && closesAt == nil {
+			return errors.New("retention from close requires closesAt")
+		}
 	}
-	return json.Marshal(m)
+	switch settings.IPAddresses {
+	case "", "hash", "full", "none":
+	default:
+		return errors.New("ipAddresses must be hash, full or none")
+	}
+	switch settings.Identity {
+	case "", "phone", "email", "phone+email", "anonymous":
+	default:
+		return errors.New("identity must be anonymous, phone, email or phone+email")
+	}
+	if settings.PreventDuplicates && settings.Identity == "anonymous" {
+		return errors.New("preventDuplicates needs respondents to give a phone number or email")
+	}
+	if t := settings.Receipt; t != nil {
+		if !settings.asksEmail() {
+			return errors.New("receipt needs respondents to give an email")
+		}
+		if blank(t.Subject) || blank(t.Body) {
+			return errors.New("receipt needs a subject and body")
+		}
+	}
+	if t := settings.Notification; t != nil {
+		if len(settings.NotifyEmails) == 0 {
+			return errors.New("notification needs notifyEmails")
+		}
+		if blank(t.Subject) || blank(t.Body) {
+			return errors.New("notification needs a subject and body")
+		}
+	}
+	for _, address := range settings.NotifyEmails {
+		if !validEmail(address) {
+			return fmt.Errorf("notifyEmails: %q is not an email address", address)
+		}
+	}
+	return nil
 }
 
-// FormField represents a field in a form
-type FormField struct {
-        ID          string            `json:"id"`
-        Type        string            `json:"type"`
-        Label       MultiLanguageText `json:"label"`
-        Placeholder MultiLanguageText `json:"placeholder,omitempty"`
-        Required    bool              `json:"required"`
-        Options     []MultiLanguageText `json:"options,omitempty"`
-        Validation  map[string]interface{} `json:"validation,omitempty"`
+// asksPhone reports whether respondents give a phone number, as they do unless the
+// identity mode says otherwise
+func (s FormSettings) asksPhone() bool {
+	return s.Identity == "" || s.Identity == "phone" || s.Identity == "phone+email"
 }
 
-// Form represents a form definition
-type Form struct {
-        ID               int                `json:"id"`
-        Title            MultiLanguageText  `json:"title"`
-        Description      MultiLanguageText  `json:"description,omitempty"`
-        Fields           []FormField        `json:"fields"`
-        SubmitButtonText MultiLanguageText  `json:"submitButtonText,omitempty"`
-        HeroImageUrl     string             `json:"heroImageUrl,omitempty"`
-        IsActive         bool               `json:"isActive"`
-        CreatedAt        time.Time          `json:"createdAt"`
-        UpdatedAt        time.Time          `json:"updatedAt"`
+// asksEmail reports whether respondents give an email address
+func (s FormSettings) asksEmail() bool {
+	return s.Identity == "email" || s.Identity == "phone+email"
+}
+
+// validatePrefill checks that the prefill allowlist names top-level fields once each
+// and covers every hidden field, which has no other way to be filled
+func validatePrefill(fields []FormField, prefill []string, errs *formdef.Errors) {
+	listed := make(map[string]bool, len(prefill))
+	for i, id := range prefill {
+		path := fmt.Sprintf("settings.prefill[%d]", i)
+		switch {
+		case listed[id]:
+			errs.Add(path, "%q is listed twice", id)
+		case !hasField(fields, id):
+			errs.Add(path, "no field has the ID %q", id)
+		}
+		listed[id] = true
+	}
+	for i, field := range fields {
+		if field.Type == "hidden" && !listed[field.ID] {
+			errs.Add(fmt.Sprintf("fields[%d].id", i), "hidden fields are filled from the link and must be listed in settings.prefill")
+		}
+	}
+}
+
+// hasField reports whether a top-level field has the given ID
+func hasField(fields []FormField, id string) bool {
+	for _, field := range fields {
+		if field.ID == id {
+			return true
+		}
+	}
+	return false
 }
 
 // FormResponse represents a form submission
 type FormResponse struct {
         ID           int                    `json:"id"`
         FormID       int                    `json:"formId"`
-        PhoneNumber  string                 `json:"phoneNumber"`
+        WorkspaceID  int                    `json:"workspaceId"`
+        // PhoneNumber and Email are set as the form's identity mode asks for them
+        PhoneNumber  string                 `json:"phoneNumber,omitempty"`
+        Email        string                 `json:"email,omitempty"`
         ResponseData map[string]interface{} `json:"responseData"`
         SubmittedAt  time.Time              `json:"submittedAt"`
+        Status       string                 `json:"status"`
+        Assignee     string                 `json:"assignee,omitempty"`
+        Tags         []string               `json:"tags"`
+        Metadata     ResponseMetadata       `json:"metadata"`
+        // Score is set on responses to quizzes
+        Score        *float64               `json:"score,omitempty"`
+        // Result is only sent back to the respondent who submitted a quiz
+        Result       *formdef.QuizResult    `json:"result,omitempty"`
+        // DisplayOrder is the order the respondent saw shuffled questions and options in
+        DisplayOrder *formdef.Order         `json:"displayOrder,omitempty"`
+}
+
+// ResponseMetadata records where a response came from. The public form sends the
+// utm_* parameters and referrer of the page it was opened from; the server adds the
+// user agent and client address.
+type ResponseMetadata struct {
+	UTMSource   string `json:"utm_source,omitempty"`
+	UTMMedium   string `json:"utm_medium,omitempty"`
+	UTMCampaign string `json:"utm_campaign,omitempty"`
+	UTMTerm     string `json:"utm_term,omitempty"`
+	UTMContent  string `json:"utm_content,omitempty"`
+	Referrer    string `json:"referrer,omitempty"`
+	UserAgent   string `json:"userAgent,omitempty"`
+	IP          string `json:"ip,omitempty"`
+	// IPHashed is set when IP holds a keyed hash rather than the address itself
+	IPHashed bool `json:"ipHashed,omitempty"`
+}
+
+// Scan implements the sql.Scanner interface for ResponseMetadata
+func (m *ResponseMetadata) Scan(value interface{}) error {
+	*m = ResponseMetadata{}
+	if value == nil {
+		return nil
+	}
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into ResponseMetadata", value)
+	}
+	if len(bytes) == 0 {
+		return nil
+	}
+	return json.Unmarshal(bytes, m)
+}
+
+// Value implements the driver.Valuer interface for ResponseMetadata
+func (m ResponseMetadata) Value() (driver.Value, error) {
+	return json.Marshal(m)
+}
+
+// attributionParams are the metadata keys respondents' browsers may send and admins
+// may filter on, mapped to the fields that hold them
+func (m *ResponseMetadata) attributionParams() map[string]*string {
+	return map[string]*string{
+		"utm_source":   &m.UTMSource,
+		"utm_medium":   &m.UTMMedium,
+		"utm_campaign": &m.UTMCampaign,
+		"utm_term":     &m.UTMTerm,
+		"utm_content":  &m.UTMContent,
+		"referrer":     &m.Referrer,
+	}
+}
+
+// maxMetadataLength bounds each metadata value; they all come from the browser
+const maxMetadataLength = 512
+
+// collectMetadata builds the metadata stored with a submission from the attribution
+// the public form sent and the request itself, keeping the client address as the
+// form's settings allow. Anonymous forms keep neither the address nor the user agent,
+// which together could point back to the respondent.
+func collectMetadata(r *http.Request, settings FormSettings, attribution map[string]string) ResponseMetadata {
+	var meta ResponseMetadata
+	for key, target := range meta.attributionParams() {
+		*target = truncateRunes(strings.TrimSpace(attribution[key]), maxMetadataLength)
+	}
+	if settings.Identity == "anonymous" {
+		return meta
+	}
+	meta.UserAgent = truncateRunes(r.UserAgent(), maxMetadataLength)
+	switch settings.IPAddresses {
+	case "none":
+	case "full":
+		meta.IP = clientIP(r)
+	default:
+		if hash, ok := ipHash(clientIP(r)); ok {
+			meta.IP, meta.IPHashed = hash, true
+		}
+	}
+	return meta
+}
+
+// ipHash keys a client address like phoneKey keys a phone number: the blind index
+// when encryption is on, otherwise a hash salted with IP_HASH_SALT. Without either an
+// IPv4 hash could be reversed by trying every address, so ok is false and no address
+// should be kept.
+func ipHash(ip string) (hash string, ok bool) {
+	if keyring != nil {
+		return keyring.BlindIndex(ip), true
+	}
+	salt := os.Getenv("IP_HASH_SALT")
+	if salt == "" {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(salt + ip))
+	return hex.EncodeToString(sum[:]), true
+}
+
+// truncateRunes cuts s to at most n characters without splitting one
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// revealMetadata hides a stored client address from callers who may not read PII;
+// a hash is left in place as it only groups responses from the same address
+func revealMetadata(meta *ResponseMetadata, allowed bool) {
+	if !allowed && !meta.IPHashed {
+		meta.IP = ""
+	}
 }
 
 // Database connection
 var db *sql.DB
 
+// formColumns lists the forms columns read by scanForm, in scan order
+const formColumns = "id, title, description, fields, submit_button_text, hero_image_url, is_active, created_at, updated_at, deleted_at, closes_at, settings, workspace_id, slug, public_token, version, pages, quiz"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanForm reads a forms row selected with formColumns and decodes its JSON columns.
+// sql.ErrNoRows is returned unwrapped so callers can map it to a 404.
+func scanForm(row rowScanner) (Form, error) {
+	var form Form
+	var heroImageUrl, formSlug, publicToken sql.NullString
+	var deletedAt, closesAt sql.NullTime
+	var titleJSON, descriptionJSON, fieldsJSON, submitButtonTextJSON, pagesJSON, quizJSON []byte
+
+	err := row.Scan(
+		&form.ID, &titleJSON, &descriptionJSON, &fieldsJSON, &submitButtonTextJSON, &heroImageUrl,
+		&form.IsActive, &form.CreatedAt, &form.UpdatedAt, &deletedAt, &closesAt, &form.Settings, &form.WorkspaceID, &formSlug, &publicToken, &form.Version, &pagesJSON, &quizJSON,
+	)
+	if err != nil {
+		return form, err
+	}
+
+	if err := json.Unmarshal(titleJSON, &form.Title); err != nil {
+		return form, fmt.Errorf("parsing title: %w", err)
+	}
+	if err := json.Unmarshal(descriptionJSON, &form.Description); err != nil {
+		return form, fmt.Errorf("parsing description: %w", err)
+	}
+	if err := json.Unmarshal(submitButtonTextJSON, &form.SubmitButtonText); err != nil {
+		return form, fmt.Errorf("parsing submitButtonText: %w", err)
+	}
+	if err := json.Unmarshal(fieldsJSON, &form.Fields); err != nil {
+		return form, fmt.Errorf("parsing fields: %w", err)
+	}
+	// Older forms may keep rules their field types no longer accept; drop them so the
+	// definition the editor loads can be saved again
+	formdef.DropUnknownRules(form.Fields)
+	if len(pagesJSON) > 0 {
+		if err := json.Unmarshal(pagesJSON, &form.Pages); err != nil {
+			return form, fmt.Errorf("parsing pages: %w", err)
+		}
+	}
+	if len(quizJSON) > 0 {
+		if err := json.Unmarshal(quizJSON, &form.Quiz); err != nil {
+			return form, fmt.Errorf("parsing quiz: %w", err)
+		}
+	}
+
+	// Handle NULL hero_image_url
+	if heroImageUrl.Valid {
+		form.HeroImageUrl = heroImageUrl.String
+	}
+	if deletedAt.Valid {
+		form.DeletedAt = &deletedAt.Time
+	}
+	if closesAt.Valid {
+		form.ClosesAt = &closesAt.Time
+	}
+	form.Slug = formSlug.String
+	form.PublicToken = publicToken.String
+	return form, nil
+}
+
+// responseColumns lists the form_responses columns read by scanResponse, in scan order
+const responseColumns = "id, form_id, phone_number, email, response_data, submitted_at, status, assignee, tags, workspace_id, metadata, score, display_order"
+
+// scanResponse reads a form_responses row selected with responseColumns. Stored values
+// are returned as-is; callers reveal PII with revealContact and revealAnswers.
+func scanResponse(row rowScanner) (FormResponse, error) {
+	var response FormResponse
+	var responseDataJSON, tagsJSON, orderJSON []byte
+	var phoneNumber, email, assignee sql.NullString
+	var score sql.NullFloat64
+	err := row.Scan(
+		&response.ID, &response.FormID, &phoneNumber, &email, &responseDataJSON, &response.SubmittedAt,
+		&response.Status, &assignee, &tagsJSON, &response.WorkspaceID, &response.Metadata, &score, &orderJSON,
+	)
+	if err != nil {
+		return response, err
+	}
+	response.PhoneNumber = phoneNumber.String
+	response.Email = email.String
+	if score.Valid {
+		response.Score = &score.Float64
+	}
+	if err := json.Unmarshal(responseDataJSON, &response.ResponseData); err != nil {
+		return response, fmt.Errorf("parsing response data: %w", err)
+	}
+	response.Assignee = assignee.String
+	response.Tags = []string{}
+	if len(tagsJSON) > 0 {
+		if err := json.Unmarshal(tagsJSON, &response.Tags); err != nil {
+			return response, fmt.Errorf("parsing tags: %w", err)
+		}
+	}
+	if len(orderJSON) > 0 {
+		if err := json.Unmarshal(orderJSON, &response.DisplayOrder); err != nil {
+			return response, fmt.Errorf("parsing display order: %w", err)
+		}
+	}
+	return response, nil
+}
+
+// loadForm fetches a form by ID whether or not it is in the trash
+func loadForm(formID int) (Form, error) {
+	return scanForm(db.QueryRow("SELECT "+formColumns+" FROM forms WHERE id = ?", formID))
+}
+
 // Initialize database connection
 func initDB() {
         var err error
@@ -122,8 +533,9 @@ func initDB() {
 func corsMiddleware(next http.Handler) http.Handler {
         return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
                 w.Header().Set("Access-Control-Allow-Origin", "*")
-                w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-                w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+                w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+                w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Admin-User, X-Admin-Role, X-Workspace-ID, X-Request-ID, Last-Event-ID, If-Match, If-None-Match")
+                w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, ETag")
 
                 if r.Method == "OPTIONS" {
                         w.WriteHeader(http.StatusOK)
@@ -147,19 +559,78 @@ func createFormHandler(w http.ResponseWriter, r *http.Request) {
                 http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
                 return
         }
+        workspaceID, ok := requestWorkspace(w, r)
+        if !ok {
+                return
+        }
+
+        var formData formDefinition
+        if err := json.NewDecoder(r.Body).Decode(&formData); err != nil {
+                http.Error(w, "Invalid JSON", http.StatusBadRequest)
+                return
+        }
+        insertForm(w, r, workspaceID, formData, "form.create")
+}
 
-        var formData struct {
-                Title            MultiLanguageText  `json:"title"`
-                Description      MultiLanguageText  `json:"description"`
-                Fields           []FormField        `json:"fields"`
-                SubmitButtonText MultiLanguageText  `json:"submitButtonText"`
-                HeroImageUrl     string             `json:"heroImageUrl"`
+// Import a form, typically one exported with GET /api/forms/{id}. Only the definition is
+// taken; IDs, tokens, versions and timestamps of the original are ignored, and a slug
+// that is invalid or already used here is replaced with a generated one.
+func importFormHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != "POST" {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+        workspaceID, ok := requestWorkspace(w, r)
+        if !ok {
+                return
         }
 
+        var formData formDefinition
         if err := json.NewDecoder(r.Body).Decode(&formData); err != nil {
                 http.Error(w, "Invalid JSON", http.StatusBadRequest)
                 return
         }
+        if s := strings.TrimSpace(formData.Slug); s != "" {
+                taken, err := slugTaken(db, s, 0)
+                if err != nil {
+                        log.Printf("Error checking slug: %v", err)
+                        http.Error(w, "Error checking slug", http.StatusInternalServerError)
+                        return
+                }
+                if taken || !slug.Valid(s) {
+                        formData.Slug = ""
+                }
+        }
+        insertForm(w, r, workspaceID, formData, "form.import")
+}
+
+// insertForm validates formData and stores it as a new form in the workspace, counting
+// against its form quota, then answers with the stored form
+func insertForm(w http.ResponseWriter, r *http.Request, workspaceID int, formData formDefinition, action string) {
+        if errs := validateFormDefinition(formData, validationOptions(r)); len(errs) > 0 {
+                writeValidationErrors(w, errs)
+                return
+        }
+
+        // Respondents reach the form by slug or share token rather than its sequential ID
+        formSlug := strings.TrimSpace(formData.Slug)
+        if formSlug != "" {
+                if !checkCustomSlug(w, formSlug, 0) {
+                        return
+                }
+        } else {
+                var err error
+                if formSlug, err = uniqueSlug(db, formData.Title, 0); err != nil {
+                        log.Printf("Error generating slug: %v", err)
+                        http.Error(w, "Error creating form", http.StatusInternalServerError)
+                        return
+                }
+        }
+        publicToken, err := newPublicToken()
+        if err != nil {
+                http.Error(w, "Error creating form", http.StatusInternalServerError)
+                return
+        }
 
         // Marshal MultiLanguageText fields as JSON
         titleJSON, err := json.Marshal(formData.Title)
@@ -182,12 +653,37 @@ func createFormHandler(w http.ResponseWriter, r *http.Request) {
                 http.Error(w, "Error encoding fields", http.StatusInternalServerError)
                 return
         }
+        pagesJSON, err := json.Marshal(formData.Pages)
+        if err != nil {
+                http.Error(w, "Error encoding pages", http.StatusInternalServerError)
+                return
+        }
+        quizJSON, err := json.Marshal(formData.Quiz)
+        if err != nil {
+                http.Error(w, "Error encoding quiz", http.StatusInternalServerError)
+                return
+        }
+
+        tx, err := db.Begin()
+        if err != nil {
+                http.Error(w, "Error creating form", http.StatusInternalServerError)
+                return
+        }
+        defer tx.Rollback()
+        if err := reserveFormSlot(tx, workspaceID); err == errQuotaExceeded {
+                http.Error(w, "Form quota exceeded for this workspace", http.StatusForbidden)
+                return
+        } else if err != nil {
+                log.Printf("Error checking form quota: %v", err)
+                http.Error(w, "Error creating form", http.StatusInternalServerError)
+                return
+        }
 
         // Insert form (MySQL compatible)
-        result, err := db.Exec(`
-                INSERT INTO forms (title, description, fields, submit_button_text, hero_image_url, is_active, created_at, updated_at)
-                VALUES (?, ?, ?, ?, ?, true, NOW(), NOW())
-        `, titleJSON, descriptionJSON, fieldsJSON, submitButtonTextJSON, formData.HeroImageUrl)
+        result, err := tx.Exec(`
+                INSERT INTO forms (workspace_id, slug, public_token, title, description, fields, pages, quiz, submit_button_text, hero_image_url, closes_at, settings, is_active, created_at, updated_at)
+                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, true, NOW(), NOW())
+        `, workspaceID, formSlug, publicToken, titleJSON, descriptionJSON, fieldsJSON, pagesJSON, quizJSON, submitButtonTextJSON, formData.HeroImageUrl, formData.ClosesAt, formData.Settings)
         if err != nil {
                 log.Printf("Error creating form: %v", err)
                 http.Error(w, "Error creating form", http.StatusInternalServerError)
@@ -199,52 +695,20 @@ func createFormHandler(w http.ResponseWriter, r *http.Request) {
                 http.Error(w, "Error getting inserted ID", http.StatusInternalServerError)
                 return
         }
+        if err := tx.Commit(); err != nil {
+                log.Printf("Error creating form: %v", err)
+                http.Error(w, "Error creating form", http.StatusInternalServerError)
+                return
+        }
         // Fetch the inserted row
-        var form Form
-        var heroImageUrl sql.NullString
-        var fieldsJSONResult []byte
-        var titleJSONResult, descriptionJSONResult, submitButtonTextJSONResult []byte
-        err = db.QueryRow(`
-                SELECT id, title, description, fields, submit_button_text, hero_image_url, is_active, created_at, updated_at
-                FROM forms WHERE id = ?
-        `, insertedID).Scan(
-                &form.ID, &titleJSONResult, &descriptionJSONResult, &fieldsJSONResult, &submitButtonTextJSONResult, &heroImageUrl, &form.IsActive, &form.CreatedAt, &form.UpdatedAt,
-        )
+        form, err := scanForm(db.QueryRow("SELECT "+formColumns+" FROM forms WHERE id = ?", insertedID))
         if err != nil {
                 log.Printf("Error fetching created form: %v", err)
                 http.Error(w, "Error fetching created form", http.StatusInternalServerError)
                 return
         }
-        
-        // Unmarshal JSON fields
-        if err := json.Unmarshal(titleJSONResult, &form.Title); err != nil {
-                log.Printf("Error unmarshaling title: %v", err)
-                http.Error(w, "Error parsing title", http.StatusInternalServerError)
-                return
-        }
-        if err := json.Unmarshal(descriptionJSONResult, &form.Description); err != nil {
-                log.Printf("Error unmarshaling description: %v", err)
-                http.Error(w, "Error parsing description", http.StatusInternalServerError)
-                return
-        }
-        if err := json.Unmarshal(submitButtonTextJSONResult, &form.SubmitButtonText); err != nil {
-                log.Printf("Error unmarshaling submitButtonText: %v", err)
-                http.Error(w, "Error parsing submitButtonText", http.StatusInternalServerError)
-                return
-        }
-        
-        if heroImageUrl.Valid {
-                form.HeroImageUrl = heroImageUrl.String
-        } else {
-                form.HeroImageUrl = ""
-        }
-        if err := json.Unmarshal(fieldsJSONResult, &form.Fields); err != nil {
-                log.Printf("Error parsing fields: %v", err)
-                http.Error(w, "Error parsing fields", http.StatusInternalServerError)
-                return
-        }
-        w.Header().Set("Content-Type", "application/json")
-        json.NewEncoder(w).Encode(form)
+        recordAudit(r, action, "form", form.ID, nil, form, nil)
+        writeForm(w, r, form)
 }
 
 // PaginatedResponse represents a paginated API response
@@ -281,10 +745,14 @@ func getFormsHandler(w http.ResponseWriter, r *http.Request) {
                         pageSize = ps
                 }
         }
+        workspaceID, ok := requestWorkspace(w, r)
+        if !ok {
+                return
+        }
 
         // Get total count for pagination
         var totalCount int
-        err := db.QueryRow("SELECT COUNT(*) FROM forms WHERE is_active = true").Scan(&totalCount)
+        err := db.QueryRow("SELECT COUNT(*) FROM forms WHERE is_active = true AND workspace_id = ?", workspaceID).Scan(&totalCount)
         if err != nil {
                 http.Error(w, "Error counting forms", http.StatusInternalServerError)
                 return
@@ -298,12 +766,12 @@ func getFormsHandler(w http.ResponseWriter, r *http.Request) {
 
         // Query forms with pagination
         rows, err := db.Query(`
-                SELECT id, title, description, fields, submit_button_text, hero_image_url, is_active, created_at, updated_at
+                SELECT `+formColumns+`
                 FROM forms
-                WHERE is_active = true
+                WHERE is_active = true AND workspace_id = ?
                 ORDER BY created_at DESC
                 LIMIT ? OFFSET ?
-        `, pageSize, offset)
+        `, workspaceID, pageSize, offset)
         if err != nil {
                 http.Error(w, "Error fetching forms", http.StatusInternalServerError)
                 return
@@ -312,51 +780,12 @@ func getFormsHandler(w http.ResponseWriter, r *http.Request) {
 
         var forms []Form
         for rows.Next() {
-                var form Form
-                var fieldsJSON []byte
-                var heroImageUrl sql.NullString
-                var titleJSON, descriptionJSON, submitButtonTextJSON []byte
-
-                err := rows.Scan(
-                        &form.ID, &titleJSON, &descriptionJSON, &fieldsJSON, &submitButtonTextJSON, &heroImageUrl,
-                        &form.IsActive, &form.CreatedAt, &form.UpdatedAt,
-                )
-                
+                form, err := scanForm(rows)
                 if err != nil {
                         log.Printf("Error scanning form: %v", err)
                         http.Error(w, "Error scanning form", http.StatusInternalServerError)
                         return
                 }
-                
-                // Unmarshal JSON fields
-                if err := json.Unmarshal(titleJSON, &form.Title); err != nil {
-                        log.Printf("Error unmarshaling title: %v", err)
-                        http.Error(w, "Error parsing title", http.StatusInternalServerError)
-                        return
-                }
-                if err := json.Unmarshal(descriptionJSON, &form.Description); err != nil {
-                        log.Printf("Error unmarshaling description: %v", err)
-                        http.Error(w, "Error parsing description", http.StatusInternalServerError)
-                        return
-                }
-                if err := json.Unmarshal(submitButtonTextJSON, &form.SubmitButtonText); err != nil {
-                        log.Printf("Error unmarshaling submitButtonText: %v", err)
-                        http.Error(w, "Error parsing submitButtonText", http.StatusInternalServerError)
-                        return
-                }
-                
-                // Handle NULL hero_image_url
-                if heroImageUrl.Valid {
-                        form.HeroImageUrl = heroImageUrl.String
-                } else {
-                        form.HeroImageUrl = ""
-                }
-
-                if err := json.Unmarshal(fieldsJSON, &form.Fields); err != nil {
-                        log.Printf("Error unmarshaling fields: %v", err)
-                        http.Error(w, "Error parsing fields", http.StatusInternalServerError)
-                        return
-                }
 
                 forms = append(forms, form)
         }
@@ -374,12 +803,22 @@ func getFormsHandler(w http.ResponseWriter, r *http.Request) {
         json.NewEncoder(w).Encode(response)
 }
 
-// Get a specific form by ID
+// Get a specific form by ID. Respondents use /api/public/forms/{ref} instead.
 func getFormHandler(w http.ResponseWriter, r *http.Request) {
         if r.Method != "GET" {
                 http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
                 return
         }
+        // Respondents load forms from /api/public/forms; this one is for signed-in admins
+        actor := adminActor(r)
+        if actor == "" {
+                http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+                return
+        }
+        workspaceID, ok := requestWorkspace(w, r)
+        if !ok {
+                return
+        }
 
         // Extract form ID from URL path
         path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
@@ -389,19 +828,11 @@ func getFormHandler(w http.ResponseWriter, r *http.Request) {
                 return
         }
 
-        var form Form
-        var fieldsJSON []byte
-        var heroImageUrl sql.NullString
-        var titleJSON, descriptionJSON, submitButtonTextJSON []byte
-
-        err = db.QueryRow(`
-                SELECT id, title, description, fields, submit_button_text, hero_image_url, is_active, created_at, updated_at
+        form, err := scanForm(db.QueryRow(`
+                SELECT `+formColumns+`
                 FROM forms
-                WHERE id = ? AND is_active = true
-        `, formID).Scan(
-                &form.ID, &titleJSON, &descriptionJSON, &fieldsJSON, &submitButtonTextJSON, &heroImageUrl,
-                &form.IsActive, &form.CreatedAt, &form.UpdatedAt,
-        )
+                WHERE id = ? AND is_active = true AND workspace_id = ?
+        `, formID, workspaceID))
 
         if err == sql.ErrNoRows {
                 http.Error(w, "Form not found", http.StatusNotFound)
@@ -411,38 +842,28 @@ func getFormHandler(w http.ResponseWriter, r *http.Request) {
                 http.Error(w, "Error fetching form", http.StatusInternalServerError)
                 return
         }
-        
-        // Unmarshal JSON fields
-        if err := json.Unmarshal(titleJSON, &form.Title); err != nil {
-                log.Printf("Error unmarshaling title: %v", err)
-                http.Error(w, "Error parsing title", http.StatusInternalServerError)
-                return
-        }
-        if err := json.Unmarshal(descriptionJSON, &form.Description); err != nil {
-                log.Printf("Error unmarshaling description: %v", err)
-                http.Error(w, "Error parsing description", http.StatusInternalServerError)
-                return
-        }
-        if err := json.Unmarshal(submitButtonTextJSON, &form.SubmitButtonText); err != nil {
-                log.Printf("Error unmarshaling submitButtonText: %v", err)
-                http.Error(w, "Error parsing submitButtonText", http.StatusInternalServerError)
-                return
-        }
-        
-        // Handle NULL hero_image_url
-        if heroImageUrl.Valid {
-                form.HeroImageUrl = heroImageUrl.String
-        } else {
-                form.HeroImageUrl = ""
+        if form.Seats, err = optionSeats(form.ID, form.Fields); err != nil {
+                log.Printf("Error counting seats for form %d: %v", form.ID, err)
         }
-
-        if err := json.Unmarshal(fieldsJSON, &form.Fields); err != nil {
-                http.Error(w, "Error parsing fields", http.StatusInternalServerError)
-                return
+        // The default workspace is open to every admin, but only its members see the
+        // share token, the answer key and how calculated fields and bands are worked out
+        if !isPlatformAdmin(r) {
+                role, err := workspaceRole(workspaceID, actor)
+                if err != nil {
+                        log.Printf("Error checking workspace membership: %v", err)
+                        http.Error(w, "Error fetching form", http.StatusInternalServerError)
+                        return
+                }
+                if role == "" {
+                        form.PublicToken = ""
+                        form.Fields = formdef.RespondentView(form.Fields)
+                        if form.Quiz != nil {
+                                form.Quiz = &FormQuiz{}
+                        }
+                }
         }
 
-        w.Header().Set("Content-Type", "application/json")
-        json.NewEncoder(w).Encode(form)
+        writeForm(w, r, form)
 }
 
 // Submit form response
@@ -454,9 +875,16 @@ func submitFormHandler(w http.ResponseWriter, r *http.Request) {
 
         var submission struct {
                 FormID       int                    `json:"formId"`
+                FormRef      string                 `json:"formRef"`
                 PhoneNumber  string                 `json:"phoneNumber"`
+                Email        string                 `json:"email"`
                 ResponseData map[string]interface{} `json:"responseData"`
                 Language     string                 `json:"language"`
+                ResumeToken  string                 `json:"resumeToken"`
+                // SessionID ties the response to the funnel session of the public form
+                SessionID    string                 `json:"sessionId"`
+                // Attribution holds the utm_* parameters and referrer of the form's page
+                Attribution  map[string]string      `json:"attribution"`
         }
 
         if err := json.NewDecoder(r.Body).Decode(&submission); err != nil {
@@ -464,27 +892,163 @@ func submitFormHandler(w http.ResponseWriter, r *http.Request) {
                 return
         }
 
-        if submission.PhoneNumber == "" {
-                http.Error(w, "Phone number is required", http.StatusBadRequest)
+        form, err := loadPublicForm(publicFormRef(submission.FormRef, submission.FormID))
+        if !checkOpenForm(w, form, err) {
                 return
         }
 
-        // Default to English if language not specified
-        if submission.Language == "" {
-                submission.Language = "en"
-        }
-
-        responseDataJSON, err := json.Marshal(submission.ResponseData)
-        if err != nil {
-                http.Error(w, "Error encoding response data", http.StatusInternalServerError)
+        // Submitting a draft lays the submitted answers over the saved ones; the draft is
+        // deleted in the same transaction that stores the response
+        var draft FormDraft
+        if submission.ResumeToken != "" {
+                draft, err = loadDraft(db, submission.ResumeToken)
+                if err == sql.ErrNoRows || (err == nil && draft.FormID != form.ID) {
+                        http.Error(w, "Draft not found", http.StatusNotFound)
+                        return
+                } else if err != nil {
+                        log.Printf("Error fetching draft: %v", err)
+                        http.Error(w, "Error fetching draft", http.StatusInternalServerError)
+                        return
+                }
+                revealDraft(&draft, form.Fields)
+                for key, value := range submission.ResponseData {
+                        draft.ResponseData[key] = value
+                }
+                submission.ResponseData = draft.ResponseData
+                if submission.PhoneNumber == "" {
+                        submission.PhoneNumber = draft.PhoneNumber
+                }
+                if submission.Language == "" {
+                        submission.Language = draft.Language
+                }
+        }
+
+        // Only the contact details the form's identity mode asks for are kept
+        if !form.Settings.asksPhone() {
+                submission.PhoneNumber = ""
+        }
+        if !form.Settings.asksEmail() {
+                submission.Email = ""
+        }
+        submission.Email = strings.TrimSpace(submission.Email)
+        if form.Settings.asksPhone() && submission.PhoneNumber == "" {
+                http.Error(w, "Phone number is required", http.StatusBadRequest)
+                return
+        }
+        if form.Settings.asksEmail() && submission.Email == "" {
+                http.Error(w, "Email is required", http.StatusBadRequest)
+                return
+        }
+        if submission.Email != "" && !validEmail(submission.Email) {
+                http.Error(w, "Email is not a valid address", http.StatusBadRequest)
                 return
         }
 
-        // Insert response (MySQL compatible) with language support
-        result, err := db.Exec(`
-                INSERT INTO form_responses (form_id, phone_number, response_data, language, submitted_at)
-                VALUES (?, ?, ?, ?, NOW())
-        `, submission.FormID, submission.PhoneNumber, responseDataJSON, submission.Language)
+        // Default to English if language not specified
+        if submission.Language == "" {
+                submission.Language = "en"
+        }
+
+        // Each field type checks its answer and converts it to the stored form
+        if submission.ResponseData == nil {
+                submission.ResponseData = make(map[string]interface{})
+        }
+        if errs := formdef.NormalizeResponse(form.Fields, submission.ResponseData); len(errs) > 0 {
+                writeValidationErrors(w, errs)
+                return
+        }
+        // Calculated fields and the quiz score come from the answers, never from the client.
+        // A calculation that fails on these answers (e.g. dividing by zero) rejects them,
+        // so the respondent can correct them instead of the response silently missing it.
+        if errs := formdef.Calculate(form.Fields, submission.ResponseData); len(errs) > 0 {
+                writeValidationErrors(w, errs)
+                return
+        }
+        quizResult := formdef.Score(form.Quiz, form.Fields, submission.ResponseData)
+        bookings := formdef.Bookings(form.Fields, submission.ResponseData)
+        // The order this session was shown, recomputed rather than taken from the client
+        var displayOrder []byte
+        if _, order := formdef.Shuffle(form.Fields, form.Pages, submission.SessionID); order != nil {
+                if displayOrder, err = json.Marshal(order); err != nil {
+                        http.Error(w, "Error encoding response data", http.StatusInternalServerError)
+                        return
+                }
+        }
+        var score sql.NullFloat64
+        if quizResult != nil {
+                score = sql.NullFloat64{Float64: quizResult.Score, Valid: true}
+        }
+
+        // Encrypt the contact details and sensitive answers before they reach the database
+        storedPhone, err := protectPhone(submission.PhoneNumber)
+        if err != nil {
+                log.Printf("Error encrypting phone number: %v", err)
+                http.Error(w, "Error encoding response data", http.StatusInternalServerError)
+                return
+        }
+        storedEmail, err := protectEmail(submission.Email)
+        if err != nil {
+                log.Printf("Error encrypting email: %v", err)
+                http.Error(w, "Error encoding response data", http.StatusInternalServerError)
+                return
+        }
+        if err := encryptSensitiveAnswers(form.Fields, submission.ResponseData); err != nil {
+                log.Printf("Error encrypting answers: %v", err)
+                http.Error(w, "Error encoding response data", http.StatusInternalServerError)
+                return
+        }
+
+        responseDataJSON, err := json.Marshal(submission.ResponseData)
+        if err != nil {
+                http.Error(w, "Error encoding response data", http.StatusInternalServerError)
+                return
+        }
+
+        tx, err := db.Begin()
+        if err != nil {
+                http.Error(w, "Error submitting form", http.StatusInternalServerError)
+                return
+        }
+        defer tx.Rollback()
+        if draft.ID != 0 {
+                // Of two submits racing on one draft, the second finds nothing to delete
+                result, err := tx.Exec("DELETE FROM form_drafts WHERE id = ?", draft.ID)
+                if err != nil {
+                        log.Printf("Error promoting draft %d: %v", draft.ID, err)
+                        http.Error(w, "Error submitting form", http.StatusInternalServerError)
+                        return
+                }
+                if n, _ := result.RowsAffected(); n == 0 {
+                        http.Error(w, "This draft was already submitted", http.StatusConflict)
+                        return
+                }
+        }
+        if err := reserveResponse(tx, form.WorkspaceID); err == errQuotaExceeded {
+                http.Error(w, "This form is not accepting responses right now", http.StatusForbidden)
+                return
+        } else if err != nil {
+                log.Printf("Error checking response quota: %v", err)
+                http.Error(w, "Error submitting form", http.StatusInternalServerError)
+                return
+        }
+        if full, err := reserveSeats(tx, form.ID, bookings); full != "" {
+                writeValidationErrors(w, formdef.Errors{{Path: "responseData." + full, Message: "is full"}})
+                return
+        } else if err != nil {
+                log.Printf("Error reserving seats: %v", err)
+                http.Error(w, "Error submitting form", http.StatusInternalServerError)
+                return
+        }
+
+        // Insert response (MySQL compatible) with language support; the normalized phone
+        // (or its blind index) lets privacy requests find every response from the same person
+        result, err := tx.Exec(`
+                INSERT INTO form_responses (form_id, workspace_id, phone_number, phone_normalized, phone_blind_index, email, email_normalized, email_blind_index,
+                        response_data, language, metadata, score, display_order, submitted_at)
+                VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
+        `, form.ID, form.WorkspaceID, nullString(storedPhone.Number), storedPhone.Normalized, storedPhone.BlindIndex,
+                nullString(storedEmail.Address), storedEmail.Normalized, storedEmail.BlindIndex, responseDataJSON, submission.Language,
+                collectMetadata(r, form.Settings, submission.Attribution), score, displayOrder)
         if err != nil {
                 log.Printf("Error submitting form: %v", err)
                 http.Error(w, "Error submitting form", http.StatusInternalServerError)
@@ -496,242 +1060,6547 @@ func submitFormHandler(w http.ResponseWriter, r *http.Request) {
                 http.Error(w, "Error getting inserted ID", http.StatusInternalServerError)
                 return
         }
+        // Dedupe on the normalized phone and email. The unique key on the identities table
+        // settles concurrent submissions from the same person inside the transaction.
+        if form.Settings.PreventDuplicates {
+                duplicate, err := claimIdentities(tx, form.ID, int(insertedID), phone.Normalize(submission.PhoneNumber), normalizeEmail(submission.Email))
+                if err != nil {
+                        log.Printf("Error checking duplicate response: %v", err)
+                        http.Error(w, "Error submitting form", http.StatusInternalServerError)
+                        return
+                }
+                if duplicate {
+                        http.Error(w, "A response with this "+contactNoun(form.Settings)+" was already submitted", http.StatusConflict)
+                        return
+                }
+        }
+        if err := tx.Commit(); err != nil {
+                log.Printf("Error submitting form: %v", err)
+                http.Error(w, "Error submitting form", http.StatusInternalServerError)
+                return
+        }
+        // A missing search document only affects search; the reindex endpoint repairs it
+        if err := indexResponse(db, int(insertedID), form.ID, form.Fields, submission.ResponseData); err != nil {
+                log.Printf("Error indexing response %d: %v", insertedID, err)
+        }
+        if submission.SessionID != "" {
+                markSessionSubmitted(form.ID, submission.SessionID, insertedID)
+        }
         // Fetch the inserted row
-        var response FormResponse
-        var responseDataJSONResult []byte
-        err = db.QueryRow(`
-                SELECT id, form_id, phone_number, response_data, submitted_at
-                FROM form_responses WHERE id = ?
-        `, insertedID).Scan(
-                &response.ID, &response.FormID, &response.PhoneNumber, &responseDataJSONResult, &response.SubmittedAt,
-        )
+        response, err := scanResponse(db.QueryRow("SELECT "+responseColumns+" FROM form_responses WHERE id = ?", insertedID))
         if err != nil {
                 log.Printf("Error fetching submitted response: %v", err)
                 http.Error(w, "Error fetching submitted response", http.StatusInternalServerError)
                 return
         }
-        if err := json.Unmarshal(responseDataJSONResult, &response.ResponseData); err != nil {
-                log.Printf("Error parsing response data: %v", err)
-                http.Error(w, "Error parsing response data", http.StatusInternalServerError)
-                return
-        }
+        publishResponseCreated(response)
+        // The respondent just sent these values, so echo them back decrypted
+        revealContact(&response, true)
+        revealAnswers(form.Fields, response.ResponseData, true)
+        response.Result = quizResult
+        sendResponseEmails(form, response, submission.Language)
         w.Header().Set("Content-Type", "application/json")
         json.NewEncoder(w).Encode(response)
 }
 
-// Update form
-func updateFormHandler(w http.ResponseWriter, r *http.Request) {
-        if r.Method != "PUT" {
-                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-                return
-        }
+// optionKey identifies an option in form_option_counts by a hash of its label, which
+// may be longer than an index allows
+func optionKey(label string) string {
+	sum := sha256.Sum256([]byte(label))
+	return hex.EncodeToString(sum[:])
+}
+
+// reserveSeats takes a place in each booked option inside tx, returning the ID of the
+// first field whose option is full. The conditional update locks the option's counter
+// row, so concurrent submits can't overfill it.
+func reserveSeats(tx *sql.Tx, formID int, bookings []formdef.Seats) (string, error) {
+	for _, b := range bookings {
+		key := optionKey(b.Option)
+		if _, err := tx.Exec("INSERT IGNORE INTO form_option_counts (form_id, field_id, option_key, taken) VALUES (?, ?, ?, 0)", formID, b.FieldID, key); err != nil {
+			return "", err
+		}
+		result, err := tx.Exec("UPDATE form_option_counts SET taken = taken + 1 WHERE form_id = ? AND field_id = ? AND option_key = ? AND taken < ?",
+			formID, b.FieldID, key, b.Capacity)
+		if err != nil {
+			return "", err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return b.FieldID, nil
+		}
+	}
+	return "", nil
+}
+
+// releaseSeats gives back the places a deleted response held
+func releaseSeats(tx *sql.Tx, formID int, fields []FormField, data map[string]interface{}) error {
+	for _, b := range formdef.Bookings(fields, data) {
+		if _, err := tx.Exec("UPDATE form_option_counts SET taken = taken - 1 WHERE form_id = ? AND field_id = ? AND option_key = ? AND taken > 0",
+			formID, b.FieldID, optionKey(b.Option)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// optionSeats reports how full every option with a capacity is
+func optionSeats(formID int, fields []FormField) ([]OptionSeats, error) {
+	capacities := formdef.Capacities(fields)
+	if len(capacities) == 0 {
+		return nil, nil
+	}
+	rows, err := db.Query("SELECT field_id, option_key, taken FROM form_option_counts WHERE form_id = ?", formID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	taken := make(map[string]int)
+	for rows.Next() {
+		var fieldID, key string
+		var n int
+		if err := rows.Scan(&fieldID, &key, &n); err != nil {
+			return nil, err
+		}
+		taken[fieldID+"/"+key] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	seats := make([]OptionSeats, len(capacities))
+	for i, c := range capacities {
+		remaining := c.Capacity - taken[c.FieldID+"/"+optionKey(c.Option)]
+		if remaining < 0 {
+			remaining = 0 // the capacity was lowered below the places already taken
+		}
+		seats[i] = OptionSeats{FieldID: c.FieldID, Option: c.Option, Capacity: c.Capacity, Remaining: &remaining, Full: remaining == 0}
+	}
+	return seats, nil
+}
+
+// formDefinition is the editable part of a form: the body of PUT and the document
+// PATCH operates on
+type formDefinition struct {
+        Title            MultiLanguageText `json:"title"`
+        Description      MultiLanguageText `json:"description"`
+        Fields           []FormField       `json:"fields"`
+        Pages            []FormPage        `json:"pages"`
+        Quiz             *FormQuiz         `json:"quiz"`
+        SubmitButtonText MultiLanguageText `json:"submitButtonText"`
+        HeroImageUrl     string            `json:"heroImageUrl"`
+        ClosesAt         *time.Time        `json:"closesAt"`
+        Settings         FormSettings      `json:"settings"`
+        Slug             string            `json:"slug"`
+}
+
+// definitionOf returns the editable part of a stored form
+func definitionOf(form Form) formDefinition {
+        return formDefinition{
+                Title:            form.Title,
+                Description:      form.Description,
+                Fields:           form.Fields,
+                Pages:            form.Pages,
+                Quiz:             form.Quiz,
+                SubmitButtonText: form.SubmitButtonText,
+                HeroImageUrl:     form.HeroImageUrl,
+                ClosesAt:         form.ClosesAt,
+                Settings:         form.Settings,
+                Slug:             form.Slug,
+        }
+}
+
+// supportedLocales lists the languages strict validation requires translations for,
+// from SUPPORTED_LOCALES (default "en,ar")
+func supportedLocales() []string {
+	spec := os.Getenv("SUPPORTED_LOCALES")
+	if spec == "" {
+		spec = "en,ar"
+	}
+	var locales []string
+	for _, locale := range strings.Split(spec, ",") {
+		if locale = strings.TrimSpace(locale); locale != "" {
+			locales = append(locales, locale)
+		}
+	}
+	return locales
+}
+
+// validationOptions reads strict mode from ?strict=true, falling back to
+// FORM_VALIDATION_STRICT so a deployment can require translations on every publish
+func validationOptions(r *http.Request) formdef.Options {
+	strict, err := strconv.ParseBool(r.URL.Query().Get("strict"))
+	if err != nil {
+		strict, _ = strconv.ParseBool(os.Getenv("FORM_VALIDATION_STRICT"))
+	}
+	return formdef.Options{Strict: strict, Locales: supportedLocales()}
+}
+
+// validateFormDefinition collects every structural problem in def, including its
+// settings, so the editor can show them all at once
+func validateFormDefinition(def formDefinition, opts formdef.Options) formdef.Errors {
+	errs := formdef.Validate(formdef.Definition{
+		Title:            def.Title,
+		Description:      def.Description,
+		SubmitButtonText: def.SubmitButtonText,
+		Fields:           def.Fields,
+		Pages:            def.Pages,
+		Quiz:             def.Quiz,
+		Messages:         emailMessages(def.Settings),
+	}, opts)
+	if err := validateFormSettings(def.Settings, def.ClosesAt); err != nil {
+		errs.Add("settings", "%s", err.Error())
+	}
+	validatePrefill(def.Fields, def.Settings.Prefill, &errs)
+	return errs
+}
+
+// emailMessages lists the email texts of settings so their answer references are
+// checked like the rest of the form
+func emailMessages(settings FormSettings) []formdef.Message {
+	var messages []formdef.Message
+	add := func(name string, t *EmailTemplate) {
+		if t != nil {
+			messages = append(messages,
+				formdef.Message{Path: "settings." + name + ".subject", Text: t.Subject},
+				formdef.Message{Path: "settings." + name + ".body", Text: t.Body})
+		}
+	}
+	add("receipt", settings.Receipt)
+	add("notification", settings.Notification)
+	return messages
+}
+
+// writeValidationErrors answers 422 with the path-addressed problems
+func writeValidationErrors(w http.ResponseWriter, errs formdef.Errors) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+}
+
+// loadEditableForm loads the active form an edit targets, in the caller's workspace, and
+// the version the edit must apply to. With requireIfMatch unset a missing If-Match
+// applies the edit to the current version. Errors are written to w.
+func loadEditableForm(w http.ResponseWriter, r *http.Request, formID int, requireIfMatch bool) (Form, int, bool) {
+        workspaceID, ok := requestWorkspace(w, r)
+        if !ok {
+                return Form{}, 0, false
+        }
+        ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+        if ifMatch == "" && requireIfMatch {
+                http.Error(w, "If-Match header is required", http.StatusPreconditionRequired)
+                return Form{}, 0, false
+        }
+
+        before, err := loadForm(formID)
+        if err == sql.ErrNoRows || (err == nil && (!before.IsActive || before.WorkspaceID != workspaceID)) {
+                http.Error(w, "Form not found", http.StatusNotFound)
+                return Form{}, 0, false
+        } else if err != nil {
+                log.Printf("Error fetching form: %v", err)
+                http.Error(w, "Error fetching form", http.StatusInternalServerError)
+                return Form{}, 0, false
+        }
+        if ifMatch == "" || ifMatch == "*" {
+                return before, before.Version, true
+        }
+        expectedVersion, ok := parseFormETag(ifMatch)
+        if !ok || expectedVersion != before.Version {
+                writeVersionConflict(w, before)
+                return Form{}, 0, false
+        }
+        return before, expectedVersion, true
+}
+
+// storeFormDefinition validates def and writes it over before as one compare-and-swap
+// on expectedVersion, then answers with the stored form. Nothing is written when
+// validation fails.
+func storeFormDefinition(w http.ResponseWriter, r *http.Request, before Form, def formDefinition, expectedVersion int, metadata map[string]interface{}) {
+        if errs := validateFormDefinition(def, validationOptions(r)); len(errs) > 0 {
+                writeValidationErrors(w, errs)
+                return
+        }
+
+        // Marshal MultiLanguageText fields as JSON
+        titleJSON, err := json.Marshal(def.Title)
+        if err != nil {
+                http.Error(w, "Error encoding title", http.StatusInternalServerError)
+                return
+        }
+        descriptionJSON, err := json.Marshal(def.Description)
+        if err != nil {
+                http.Error(w, "Error encoding description", http.StatusInternalServerError)
+                return
+        }
+        submitButtonTextJSON, err := json.Marshal(def.SubmitButtonText)
+        if err != nil {
+                http.Error(w, "Error encoding submit button text", http.StatusInternalServerError)
+                return
+        }
+        fieldsJSON, err := json.Marshal(def.Fields)
+        if err != nil {
+                http.Error(w, "Error encoding fields", http.StatusInternalServerError)
+                return
+        }
+        pagesJSON, err := json.Marshal(def.Pages)
+        if err != nil {
+                http.Error(w, "Error encoding pages", http.StatusInternalServerError)
+                return
+        }
+        quizJSON, err := json.Marshal(def.Quiz)
+        if err != nil {
+                http.Error(w, "Error encoding quiz", http.StatusInternalServerError)
+                return
+        }
+
+        // Slugs stay stable across title edits; only an explicit new slug changes them
+        formSlug := before.Slug
+        if s := strings.TrimSpace(def.Slug); s != "" && s != before.Slug {
+                if !checkCustomSlug(w, s, before.ID) {
+                        return
+                }
+                formSlug = s
+        }
+
+        // Update form (MySQL compatible); the version check makes the compare-and-swap atomic
+        result, err := db.Exec(`
+                UPDATE forms 
+                SET title = ?, description = ?, fields = ?, pages = ?, quiz = ?, submit_button_text = ?, hero_image_url = ?, closes_at = ?, settings = ?, slug = ?, version = version + 1, updated_at = NOW()
+                WHERE id = ? AND is_active = true AND version = ?
+        `, titleJSON, descriptionJSON, fieldsJSON, pagesJSON, quizJSON, submitButtonTextJSON, def.HeroImageUrl, def.ClosesAt, def.Settings, formSlug, before.ID, expectedVersion)
+        if err != nil {
+                log.Printf("Error updating form: %v", err)
+                http.Error(w, "Error updating form", http.StatusInternalServerError)
+                return
+        }
+        if n, _ := result.RowsAffected(); n == 0 {
+                // Someone saved between our read and this write
+                current, err := loadForm(before.ID)
+                if err != nil || !current.IsActive {
+                        http.Error(w, "Form not found", http.StatusNotFound)
+                        return
+                }
+                writeVersionConflict(w, current)
+                return
+        }
+        // Fetch the updated row
+        form, err := scanForm(db.QueryRow("SELECT "+formColumns+" FROM forms WHERE id = ?", before.ID))
+        if err != nil {
+                log.Printf("Error fetching updated form: %v", err)
+                http.Error(w, "Error fetching updated form", http.StatusInternalServerError)
+                return
+        }
+        if form.Settings.PreventDuplicates && !before.Settings.PreventDuplicates {
+                if err := indexFormIdentities(db, form.ID); err != nil {
+                        log.Printf("Error indexing respondents of form %d: %v", form.ID, err)
+                }
+        }
+        recordAudit(r, "form.update", "form", form.ID, before, form, metadata)
+        writeForm(w, r, form)
+}
+
+// Update form
+func updateFormHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != "PUT" {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        // Extract form ID from URL path
+        path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
+        formID, err := strconv.Atoi(path)
+        if err != nil {
+                http.Error(w, "Invalid form ID", http.StatusBadRequest)
+                return
+        }
+
+        // Saves must name the version they were based on so concurrent edits can't
+        // silently overwrite each other
+        before, expectedVersion, ok := loadEditableForm(w, r, formID, true)
+        if !ok {
+                return
+        }
+
+        body, err := io.ReadAll(r.Body)
+        if err != nil {
+                http.Error(w, "Error reading request body", http.StatusBadRequest)
+                return
+        }
+        var formData formDefinition
+        if err := json.Unmarshal(body, &formData); err != nil {
+                http.Error(w, "Invalid JSON", http.StatusBadRequest)
+                return
+        }
+        var present map[string]json.RawMessage
+        json.Unmarshal(body, &present)
+
+        // Leaving out the close date, settings, pages or quiz keeps them, so clients that
+        // don't know about them can't drop them by accident
+        if _, ok := present["pages"]; !ok {
+                formData.Pages = before.Pages
+        }
+        if _, ok := present["quiz"]; !ok {
+                formData.Quiz = before.Quiz
+        }
+        if _, ok := present["closesAt"]; !ok {
+                formData.ClosesAt = before.ClosesAt
+        }
+        if _, ok := present["settings"]; !ok {
+                formData.Settings = before.Settings
+        }
+        storeFormDefinition(w, r, before, formData, expectedVersion, nil)
+}
+
+// Apply an RFC 6902 JSON Patch or RFC 7396 merge patch, chosen by Content-Type, to a
+// form's definition. The patched form is validated as a whole before anything is stored.
+func patchFormHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != "PATCH" {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
+        formID, err := strconv.Atoi(path)
+        if err != nil {
+                http.Error(w, "Invalid form ID", http.StatusBadRequest)
+                return
+        }
+        contentType := strings.TrimSpace(strings.Split(r.Header.Get("Content-Type"), ";")[0])
+        if contentType != jsonpatch.JSONPatchType && contentType != jsonpatch.MergePatchType {
+                w.Header().Set("Accept-Patch", jsonpatch.JSONPatchType+", "+jsonpatch.MergePatchType)
+                http.Error(w, "Content-Type must be "+jsonpatch.JSONPatchType+" or "+jsonpatch.MergePatchType, http.StatusUnsupportedMediaType)
+                return
+        }
+
+        before, expectedVersion, ok := loadEditableForm(w, r, formID, false)
+        if !ok {
+                return
+        }
+        patch, err := io.ReadAll(r.Body)
+        if err != nil {
+                http.Error(w, "Error reading request body", http.StatusBadRequest)
+                return
+        }
+        doc, err := json.Marshal(definitionOf(before))
+        if err != nil {
+                http.Error(w, "Error encoding form", http.StatusInternalServerError)
+                return
+        }
+
+        var patched []byte
+        if contentType == jsonpatch.JSONPatchType {
+                patched, err = jsonpatch.Apply(doc, patch)
+        } else {
+                patched, err = jsonpatch.MergePatch(doc, patch)
+        }
+        var opErr *jsonpatch.OperationError
+        if errors.Is(err, jsonpatch.ErrTestFailed) {
+                http.Error(w, err.Error(), http.StatusConflict)
+                return
+        } else if errors.As(err, &opErr) {
+                http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+                return
+        } else if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+        }
+
+        // Unknown members mean the patch targeted something that isn't part of a form
+        var def formDefinition
+        decoder := json.NewDecoder(bytes.NewReader(patched))
+        decoder.DisallowUnknownFields()
+        if err := decoder.Decode(&def); err != nil {
+                http.Error(w, "Patched form is invalid: "+err.Error(), http.StatusUnprocessableEntity)
+                return
+        }
+        storeFormDefinition(w, r, before, def, expectedVersion, map[string]interface{}{"patch": contentType})
+}
+
+// Edit a single field by ID without re-sending the form:
+//   POST   /api/forms/{id}/fields[?position=n]  add a field
+//   PUT    /api/forms/{id}/fields/{fieldId}     replace a field
+//   DELETE /api/forms/{id}/fields/{fieldId}     remove a field
+//   POST   /api/forms/{id}/fields/reorder       {"order": [fieldIds...]}
+// If-Match is optional; without it the edit applies to the current version.
+func formFieldsHandler(w http.ResponseWriter, r *http.Request) {
+        parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/")
+        if len(parts) < 2 || len(parts) > 3 || parts[1] != "fields" {
+                http.Error(w, "Invalid URL format", http.StatusBadRequest)
+                return
+        }
+        formID, err := strconv.Atoi(parts[0])
+        if err != nil {
+                http.Error(w, "Invalid form ID", http.StatusBadRequest)
+                return
+        }
+        fieldID := ""
+        if len(parts) == 3 {
+                fieldID = parts[2]
+        }
+
+        var op string
+        switch {
+        case fieldID == "" && r.Method == "POST":
+                op = "add"
+        case fieldID == "reorder" && r.Method == "POST":
+                op = "reorder"
+        case fieldID != "" && r.Method == "PUT":
+                op = "update"
+        case fieldID != "" && r.Method == "DELETE":
+                op = "remove"
+        default:
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        before, expectedVersion, ok := loadEditableForm(w, r, formID, false)
+        if !ok {
+                return
+        }
+        def := definitionOf(before)
+        index := -1
+        for i, field := range def.Fields {
+                if field.ID == fieldID {
+                        index = i
+                }
+        }
+        if (op == "update" || op == "remove") && index < 0 {
+                http.Error(w, "Field not found", http.StatusNotFound)
+                return
+        }
+
+        // Work on a copy so the audit diff still sees the original fields
+        fields := append([]FormField(nil), def.Fields...)
+        switch op {
+        case "add":
+                var field FormField
+                if err := json.NewDecoder(r.Body).Decode(&field); err != nil {
+                        http.Error(w, "Invalid JSON", http.StatusBadRequest)
+                        return
+                }
+                position := len(fields)
+                if p := r.URL.Query().Get("position"); p != "" {
+                        position, err = strconv.Atoi(p)
+                        if err != nil || position < 0 || position > len(fields) {
+                                http.Error(w, "Invalid position", http.StatusBadRequest)
+                                return
+                        }
+                }
+                fields = append(fields[:position], append([]FormField{field}, fields[position:]...)...)
+                fieldID = field.ID
+        case "update":
+                var field FormField
+                if err := json.NewDecoder(r.Body).Decode(&field); err != nil {
+                        http.Error(w, "Invalid JSON", http.StatusBadRequest)
+                        return
+                }
+                if field.ID == "" {
+                        field.ID = fieldID
+                } else if field.ID != fieldID {
+                        http.Error(w, "Field id cannot be changed", http.StatusBadRequest)
+                        return
+                }
+                fields[index] = field
+        case "remove":
+                fields = append(fields[:index], fields[index+1:]...)
+        case "reorder":
+                var request struct {
+                        Order []string `json:"order"`
+                }
+                if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+                        http.Error(w, "Invalid JSON", http.StatusBadRequest)
+                        return
+                }
+                byID := make(map[string]FormField, len(fields))
+                for _, field := range fields {
+                        byID[field.ID] = field
+                }
+                if len(request.Order) != len(fields) {
+                        http.Error(w, "order must list every field exactly once", http.StatusBadRequest)
+                        return
+                }
+                reordered := make([]FormField, 0, len(fields))
+                for _, id := range request.Order {
+                        field, ok := byID[id]
+                        if !ok {
+                                http.Error(w, "order must list every field exactly once", http.StatusBadRequest)
+                                return
+                        }
+                        delete(byID, id)
+                        reordered = append(reordered, field)
+                }
+                fields = reordered
+                fieldID = ""
+        }
+        def.Fields = fields
+
+        metadata := map[string]interface{}{"fieldOp": op}
+        if fieldID != "" {
+                metadata["fieldId"] = fieldID
+        }
+        storeFormDefinition(w, r, before, def, expectedVersion, metadata)
+}
+
+// Delete form
+func deleteFormHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != "DELETE" {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+        workspaceID, ok := requestWorkspace(w, r)
+        if !ok {
+                return
+        }
+
+        // Extract form ID from URL path
+        path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
+        formID, err := strconv.Atoi(path)
+        if err != nil {
+                http.Error(w, "Invalid form ID", http.StatusBadRequest)
+                return
+        }
+
+        // Soft delete by setting is_active to false; deleted_at starts the trash retention clock
+        result, err := db.Exec(`
+                UPDATE forms 
+                SET is_active = false, deleted_at = NOW(), version = version + 1, updated_at = NOW()
+                WHERE id = ? AND is_active = true AND workspace_id = ?
+        `, formID, workspaceID)
+
+        if err != nil {
+                http.Error(w, "Error deleting form", http.StatusInternalServerError)
+                return
+        }
+
+        rowsAffected, err := result.RowsAffected()
+        if err != nil {
+                http.Error(w, "Error checking deletion result", http.StatusInternalServerError)
+                return
+        }
+
+        if rowsAffected == 0 {
+                http.Error(w, "Form not found", http.StatusNotFound)
+                return
+        }
+        recordAudit(r, "form.delete", "form", formID,
+                map[string]interface{}{"isActive": true}, map[string]interface{}{"isActive": false}, nil)
+
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusOK)
+        fmt.Fprintf(w, `{"message": "Form deleted successfully"}`)
+}
+
+// errFormNotInTrash is returned by purgeForm when the form is missing or still active
+var errFormNotInTrash = errors.New("form not found in trash")
+
+// trashRetentionDays returns how long soft-deleted forms stay in the trash before the
+// purge scheduler removes them. TRASH_RETENTION_DAYS=0 disables automatic purging.
+func trashRetentionDays() int {
+	days := 30
+	if v := os.Getenv("TRASH_RETENTION_DAYS"); v != "" {
+		if d, err := strconv.Atoi(v); err == nil && d >= 0 {
+			days = d
+		}
+	}
+	return days
+}
+
+// envDuration parses a Go duration from the environment, falling back to def
+func envDuration(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("Ignoring invalid %s=%q, using %s", key, v, def)
+	}
+	return def
+}
+
+// formUploadDir returns the directory holding files uploaded to a form (UPLOAD_DIR/forms/{id})
+func formUploadDir(formID int) string {
+	root := os.Getenv("UPLOAD_DIR")
+	if root == "" {
+		root = "uploads"
+	}
+	return filepath.Join(root, "forms", strconv.Itoa(formID))
+}
+
+// Get soft-deleted forms with pagination
+func getTrashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	page := 1
+	pageSize := 5
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && ps > 0 && ps <= 50 {
+		pageSize = ps
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+
+	var totalCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM forms WHERE is_active = false AND workspace_id = ?", workspaceID).Scan(&totalCount); err != nil {
+		http.Error(w, "Error counting forms", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT `+formColumns+`
+		FROM forms
+		WHERE is_active = false AND workspace_id = ?
+		ORDER BY deleted_at DESC
+		LIMIT ? OFFSET ?
+	`, workspaceID, pageSize, (page-1)*pageSize)
+	if err != nil {
+		log.Printf("Error fetching trashed forms: %v", err)
+		http.Error(w, "Error fetching forms", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	retention := trashRetentionDays()
+	forms := []Form{}
+	for rows.Next() {
+		form, err := scanForm(rows)
+		if err != nil {
+			log.Printf("Error scanning form: %v", err)
+			http.Error(w, "Error scanning form", http.StatusInternalServerError)
+			return
+		}
+		if form.DeletedAt != nil && retention > 0 {
+			purgeAt := form.DeletedAt.AddDate(0, 0, retention)
+			form.PurgeAt = &purgeAt
+		}
+		forms = append(forms, form)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PaginatedResponse{
+		Data:       forms,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (totalCount + pageSize - 1) / pageSize,
+	})
+}
+
+// Restore a soft-deleted form from the trash
+func restoreFormHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/restore")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+
+	// A restored form counts against the workspace's form quota again
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Error restoring form", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+	if err := reserveFormSlot(tx, workspaceID); err == errQuotaExceeded {
+		http.Error(w, "Form quota exceeded for this workspace", http.StatusForbidden)
+		return
+	} else if err != nil {
+		log.Printf("Error checking form quota: %v", err)
+		http.Error(w, "Error restoring form", http.StatusInternalServerError)
+		return
+	}
+	result, err := tx.Exec(`
+		UPDATE forms
+		SET is_active = true, deleted_at = NULL, version = version + 1, updated_at = NOW()
+		WHERE id = ? AND is_active = false AND workspace_id = ?
+	`, formID, workspaceID)
+	if err != nil {
+		log.Printf("Error restoring form %d: %v", formID, err)
+		http.Error(w, "Error restoring form", http.StatusInternalServerError)
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		http.Error(w, "Form not found in trash", http.StatusNotFound)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error restoring form %d: %v", formID, err)
+		http.Error(w, "Error restoring form", http.StatusInternalServerError)
+		return
+	}
+
+	recordAudit(r, "form.restore", "form", formID,
+		map[string]interface{}{"isActive": false}, map[string]interface{}{"isActive": true}, nil)
+
+	form, err := loadForm(formID)
+	if err != nil {
+		log.Printf("Error fetching restored form: %v", err)
+		http.Error(w, "Error fetching restored form", http.StatusInternalServerError)
+		return
+	}
+	writeForm(w, r, form)
+}
+
+// Permanently delete a trashed form, its responses and its uploaded files
+func purgeFormHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/permanent")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+	if owner, err := formWorkspace(db, formID); err == sql.ErrNoRows || (err == nil && owner != workspaceID) {
+		http.Error(w, "Form not found in trash", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching form %d: %v", formID, err)
+		http.Error(w, "Error deleting form", http.StatusInternalServerError)
+		return
+	}
+
+	deletedResponses, err := purgeForm(formID)
+	if err == errFormNotInTrash {
+		http.Error(w, "Form not found in trash", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error purging form %d: %v", formID, err)
+		http.Error(w, "Error deleting form", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "form.purge", "form", formID, nil, nil, map[string]interface{}{"deletedResponses": deletedResponses})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"message":          "Form permanently deleted",
+		"deletedResponses": deletedResponses,
+	})
+}
+
+// purgeForm permanently removes a trashed form together with its responses and uploaded
+// files, returning the number of responses deleted. Only forms already in the trash can
+// be purged.
+func purgeForm(formID int) (int64, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	// Lock the form row so a concurrent restore cannot race the purge
+	var isActive bool
+	err = tx.QueryRow("SELECT is_active FROM forms WHERE id = ? FOR UPDATE", formID).Scan(&isActive)
+	if err == sql.ErrNoRows || (err == nil && isActive) {
+		return 0, errFormNotInTrash
+	} else if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("DELETE FROM form_responses WHERE form_id = ?", formID)
+	if err != nil {
+		return 0, fmt.Errorf("deleting responses: %w", err)
+	}
+	deletedResponses, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM form_drafts WHERE form_id = ?", formID); err != nil {
+		return 0, fmt.Errorf("deleting drafts: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM form_sessions WHERE form_id = ?", formID); err != nil {
+		return 0, fmt.Errorf("deleting funnel sessions: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM form_option_counts WHERE form_id = ?", formID); err != nil {
+		return 0, fmt.Errorf("deleting option counts: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM forms WHERE id = ?", formID); err != nil {
+		return 0, fmt.Errorf("deleting form: %w", err)
+	}
+
+	// The filesystem can't join the transaction, so move the uploads aside before
+	// committing and put them back if the commit fails
+	dir := formUploadDir(formID)
+	staged := ""
+	if _, err := os.Stat(dir); err == nil {
+		staged = dir + ".purging"
+		if err := os.Rename(dir, staged); err != nil {
+			return 0, fmt.Errorf("staging uploads: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		if staged != "" {
+			if rerr := os.Rename(staged, dir); rerr != nil {
+				log.Printf("Error restoring uploads for form %d: %v", formID, rerr)
+			}
+		}
+		return 0, err
+	}
+	if staged != "" {
+		if err := os.RemoveAll(staged); err != nil {
+			log.Printf("Error removing uploads for form %d: %v", formID, err)
+		}
+	}
+	return deletedResponses, nil
+}
+
+// purgeExpiredTrash permanently deletes forms that have been in the trash longer than
+// the retention period
+func purgeExpiredTrash() error {
+	rows, err := db.Query(`
+		SELECT id, workspace_id FROM forms
+		WHERE is_active = false AND deleted_at IS NOT NULL AND deleted_at < NOW() - INTERVAL ? DAY
+	`, trashRetentionDays())
+	if err != nil {
+		return err
+	}
+	var formIDs []int
+	workspaces := make(map[int]int)
+	for rows.Next() {
+		var id, workspaceID int
+		if err := rows.Scan(&id, &workspaceID); err != nil {
+			rows.Close()
+			return err
+		}
+		formIDs = append(formIDs, id)
+		workspaces[id] = workspaceID
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range formIDs {
+		deleted, err := purgeForm(id)
+		if err == errFormNotInTrash {
+			// Restored since we listed it
+			continue
+		} else if err != nil {
+			log.Printf("Error purging expired form %d: %v", id, err)
+			continue
+		}
+		log.Printf("Purged expired form %d from trash (%d responses)", id, deleted)
+		recordSystemAudit(workspaces[id], "form.purge", "form", id, map[string]interface{}{
+			"reason":           "trash retention",
+			"deletedResponses": deleted,
+		})
+	}
+	return nil
+}
+
+// retentionBatchSize bounds how many responses a single retention statement touches so
+// purges never hold long locks on form_responses
+func retentionBatchSize() int {
+	if v, err := strconv.Atoi(os.Getenv("RETENTION_BATCH_SIZE")); err == nil && v > 0 {
+		return v
+	}
+	return 500
+}
+
+// retentionCondition returns the WHERE clause selecting a form's responses that the
+// policy has expired. closes_at is written from Go in UTC, submitted_at by NOW().
+func retentionCondition(formID int, policy RetentionPolicy) (string, []interface{}) {
+	where := "form_id = ?"
+	args := []interface{}{formID}
+	if policy.From == "close" {
+		where += " AND EXISTS (SELECT 1 FROM forms f WHERE f.id = form_responses.form_id AND f.closes_at < UTC_TIMESTAMP() - INTERVAL ? DAY)"
+	} else {
+		where += " AND submitted_at < NOW() - INTERVAL ? DAY"
+	}
+	args = append(args, policy.Days)
+	if policy.Action == "anonymize" {
+		where += " AND anonymized_at IS NULL"
+	}
+	return where, args
+}
+
+// countExpiredResponses reports how many responses a policy would delete or anonymize now
+func countExpiredResponses(formID int, policy RetentionPolicy) (int, error) {
+	where, args := retentionCondition(formID, policy)
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM form_responses WHERE "+where, args...).Scan(&count)
+	return count, err
+}
+
+// applyRetentionPolicy deletes or anonymizes a form's expired responses in batches,
+// pausing between batches so live submissions aren't starved of the table
+func applyRetentionPolicy(form Form) (int, error) {
+	policy := *form.Settings.Retention
+	where, args := retentionCondition(form.ID, policy)
+	batchSize := retentionBatchSize()
+	pause := envDuration("RETENTION_BATCH_PAUSE", 100*time.Millisecond)
+
+	total := 0
+	for {
+		rows, err := db.Query("SELECT id, response_data FROM form_responses WHERE "+where+" ORDER BY id LIMIT ?", append(args, batchSize)...)
+		if err != nil {
+			return total, err
+		}
+		ids := []int{}
+		data := make(map[int]map[string]interface{})
+		for rows.Next() {
+			var id int
+			var dataJSON []byte
+			if err := rows.Scan(&id, &dataJSON); err != nil {
+				rows.Close()
+				return total, err
+			}
+			var responseData map[string]interface{}
+			if err := json.Unmarshal(dataJSON, &responseData); err != nil {
+				log.Printf("Error parsing response %d for retention: %v", id, err)
+			}
+			ids = append(ids, id)
+			data[id] = responseData
+		}
+		rows.Close()
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return total, err
+		}
+		for _, id := range ids {
+			if policy.Action == "anonymize" {
+				err = anonymizeResponse(tx, id, form.Fields, data[id])
+			} else {
+				_, err = tx.Exec("DELETE FROM form_responses WHERE id = ?", id)
+				if err == nil {
+					// Sensitive answers are stored encrypted, but seats are booked by the plain option
+					revealAnswers(form.Fields, data[id], true)
+					err = releaseSeats(tx, form.ID, form.Fields, data[id])
+				}
+			}
+			if err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("applying retention to response %d: %w", id, err)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return total, err
+		}
+		for _, id := range ids {
+			if err := os.RemoveAll(responseUploadDir(form.ID, id)); err != nil {
+				log.Printf("Error removing uploads for response %d: %v", id, err)
+			}
+		}
+		total += len(ids)
+
+		if len(ids) < batchSize {
+			return total, nil
+		}
+		time.Sleep(pause)
+	}
+}
+
+// applyRetentionPolicies runs every form's retention policy, including forms in the trash
+func applyRetentionPolicies() error {
+	rows, err := db.Query("SELECT " + formColumns + " FROM forms WHERE settings IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	var forms []Form
+	for rows.Next() {
+		form, err := scanForm(rows)
+		if err != nil {
+			log.Printf("Error scanning form for retention: %v", err)
+			continue
+		}
+		if form.Settings.Retention != nil {
+			forms = append(forms, form)
+		}
+	}
+	rows.Close()
+
+	for _, form := range forms {
+		affected, err := applyRetentionPolicy(form)
+		if err != nil {
+			log.Printf("Error applying retention policy to form %d: %v", form.ID, err)
+		}
+		if affected > 0 {
+			log.Printf("Retention policy on form %d: %s %d responses", form.ID, form.Settings.Retention.Action, affected)
+			recordSystemAudit(form.WorkspaceID, "response.retention", "form", form.ID, map[string]interface{}{
+				"action":   form.Settings.Retention.Action,
+				"affected": affected,
+			})
+		}
+	}
+	return nil
+}
+
+// Report how many responses a retention policy would affect without changing anything.
+// The body may carry a proposed policy; otherwise the form's saved policy is used.
+func retentionDryRunHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/retention/dry-run")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+
+	form, err := scanForm(db.QueryRow("SELECT "+formColumns+" FROM forms WHERE id = ? AND workspace_id = ?", formID, workspaceID))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching form: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+
+	// Policies counting from close are previewed against the saved closesAt
+	var proposed struct {
+		Retention *RetentionPolicy `json:"retention"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&proposed); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	policy := form.Settings.Retention
+	if proposed.Retention != nil {
+		if err := validateFormSettings(FormSettings{Retention: proposed.Retention}, form.ClosesAt); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		policy = proposed.Retention
+	}
+	if policy == nil {
+		http.Error(w, "Form has no retention policy", http.StatusBadRequest)
+		return
+	}
+
+	affected, err := countExpiredResponses(formID, *policy)
+	if err != nil {
+		log.Printf("Error counting expired responses: %v", err)
+		http.Error(w, "Error counting responses", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"formId":    formID,
+		"retention": policy,
+		"affected":  affected,
+		"dryRun":    true,
+	})
+}
+
+// runPeriodically runs job immediately and then every interval in a background goroutine
+func runPeriodically(name string, interval time.Duration, job func() error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := job(); err != nil {
+				log.Printf("Scheduled job %q failed: %v", name, err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// schemaHas reports whether the database has a column, or with column "" a table
+func schemaHas(table, column string) (bool, error) {
+	var exists bool
+	var err error
+	if column == "" {
+		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?)", table).Scan(&exists)
+	} else {
+		err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ? AND COLUMN_NAME = ?)", table, column).Scan(&exists)
+	}
+	return exists, err
+}
+
+// afterMigration wraps a scheduled job so it is skipped until the migration that
+// creates table.column (or table, with column "") has run, instead of failing on every
+// tick of an unmigrated database
+func afterMigration(migration, table, column string, job func() error) func() error {
+	warned := false
+	return func() error {
+		ready, err := schemaHas(table, column)
+		if err != nil {
+			return err
+		}
+		if !ready {
+			if !warned {
+				log.Printf("Skipping scheduled job until POST %s has run", migration)
+				warned = true
+			}
+			return nil
+		}
+		return job()
+	}
+}
+
+// Get form responses
+func getFormResponsesHandler(w http.ResponseWriter, r *http.Request) {
+        if r.Method != "GET" {
+                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+                return
+        }
+
+        // Extract form ID from URL path
+        path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
+        parts := strings.Split(path, "/")
+        if len(parts) != 2 || parts[1] != "responses" {
+                http.Error(w, "Invalid URL format", http.StatusBadRequest)
+                return
+        }
+
+        formID, err := strconv.Atoi(parts[0])
+        if err != nil {
+                http.Error(w, "Invalid form ID", http.StatusBadRequest)
+                return
+        }
+        workspaceID, ok := requestWorkspace(w, r)
+        if !ok {
+                return
+        }
+
+        fields, err := workspaceFormFields(workspaceID, formID)
+        if err == sql.ErrNoRows {
+                http.Error(w, "Form not found", http.StatusNotFound)
+                return
+        } else if err != nil {
+                log.Printf("Error fetching form fields: %v", err)
+                http.Error(w, "Error fetching form", http.StatusInternalServerError)
+                return
+        }
+        allowPII := canReadPII(r)
+
+        where, args := responseFilter(r, formID)
+        rows, err := db.Query(`
+                SELECT `+responseColumns+`
+                FROM form_responses
+                WHERE `+where+`
+                ORDER BY submitted_at DESC
+        `, args...)
+        if err != nil {
+                http.Error(w, "Error fetching responses", http.StatusInternalServerError)
+                return
+        }
+        defer rows.Close()
+
+        var responses []FormResponse
+        for rows.Next() {
+                response, err := scanResponse(rows)
+                if err != nil {
+                        log.Printf("Error scanning response: %v", err)
+                        http.Error(w, "Error scanning response", http.StatusInternalServerError)
+                        return
+                }
+                revealContact(&response, allowPII)
+                revealAnswers(fields, response.ResponseData, allowPII)
+                revealMetadata(&response.Metadata, allowPII)
+
+                responses = append(responses, response)
+        }
+        recordAudit(r, "response.view", "form", formID, nil, nil, map[string]interface{}{
+                "count":     len(responses),
+                "decrypted": allowPII,
+        })
+
+        w.Header().Set("Content-Type", "application/json")
+        json.NewEncoder(w).Encode(responses)
+}
+
+// responseFilter builds the WHERE clause shared by the responses list and export from
+// the optional filters: status, assignee ("unassigned" for none), tag, the utm_*
+// parameters and referrer (matched anywhere in the URL)
+func responseFilter(r *http.Request, formID int) (string, []interface{}) {
+	query := r.URL.Query()
+	where := "form_id = ?"
+	args := []interface{}{formID}
+	if status := query.Get("status"); status != "" {
+		where += " AND status = ?"
+		args = append(args, status)
+	}
+	if assignee := query.Get("assignee"); assignee == "unassigned" {
+		where += " AND assignee IS NULL"
+	} else if assignee != "" {
+		where += " AND assignee = ?"
+		args = append(args, assignee)
+	}
+	if tag := query.Get("tag"); tag != "" {
+		where += " AND JSON_CONTAINS(tags, JSON_QUOTE(?))"
+		args = append(args, strings.ToLower(strings.TrimSpace(tag)))
+	}
+	for _, key := range []string{"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content"} {
+		if value := query.Get(key); value != "" {
+			where += " AND JSON_UNQUOTE(JSON_EXTRACT(metadata, '$." + key + "')) = ?"
+			args = append(args, value)
+		}
+	}
+	if referrer := query.Get("referrer"); referrer != "" {
+		where += " AND JSON_UNQUOTE(JSON_EXTRACT(metadata, '$.referrer')) LIKE ?"
+		args = append(args, "%"+likeEscape(referrer)+"%")
+	}
+	return where, args
+}
+
+// likeEscape makes s match itself literally inside a LIKE pattern
+func likeEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// csvSafe stops spreadsheet apps from evaluating respondent input as a formula
+func csvSafe(cell string) string {
+	if cell == "" || !strings.ContainsAny(cell[:1], "=+-@\t\r") {
+		return cell
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil {
+		return cell
+	}
+	return "'" + cell
+}
+
+// Export a form's responses as CSV. Each field type decides how its answers are
+// flattened into columns; phone numbers and sensitive answers are redacted unless the
+// caller may read PII. Column labels use ?language= (default en), and the list's
+// filters narrow the export the same way.
+//
+// Repeatable groups are exported wide by default, with room for each group's maximum
+// number of entries side by side. ?layout=long gives every entry of one group
+// (?group=<fieldId>, default the first) its own row instead.
+func exportResponsesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/responses/export")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+	fields, err := workspaceFormFields(workspaceID, formID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching form fields: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+	language := r.URL.Query().Get("language")
+	if language == "" {
+		language = "en"
+	}
+	layout := r.URL.Query().Get("layout")
+	if layout == "" {
+		layout = "wide"
+	}
+	var group FormField
+	switch layout {
+	case "wide":
+	case "long":
+		if group, ok = formdef.GroupField(fields, r.URL.Query().Get("group")); !ok {
+			http.Error(w, "Long layout needs a repeatable group field", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "layout must be wide or long", http.StatusBadRequest)
+		return
+	}
+	allowPII := canReadPII(r)
+	form, err := loadForm(formID)
+	if err != nil {
+		log.Printf("Error fetching form: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+
+	where, args := responseFilter(r, formID)
+	// The contact details the form asks for get a column, and so do any the exported
+	// responses hold from before the identity mode changed
+	withPhone, withEmail := form.Settings.asksPhone(), form.Settings.asksEmail()
+	if !withPhone || !withEmail {
+		err = db.QueryRow(`
+			SELECT
+				EXISTS(SELECT 1 FROM form_responses WHERE `+where+` AND phone_number IS NOT NULL),
+				EXISTS(SELECT 1 FROM form_responses WHERE `+where+` AND email IS NOT NULL)
+		`, append(append([]interface{}{}, args...), args...)...).Scan(&withPhone, &withEmail)
+		if err != nil {
+			log.Printf("Error checking contact columns: %v", err)
+			http.Error(w, "Error fetching responses", http.StatusInternalServerError)
+			return
+		}
+		withPhone = withPhone || form.Settings.asksPhone()
+		withEmail = withEmail || form.Settings.asksEmail()
+	}
+	rows, err := db.Query("SELECT "+responseColumns+" FROM form_responses WHERE "+where+" ORDER BY submitted_at", args...)
+	if err != nil {
+		log.Printf("Error fetching responses: %v", err)
+		http.Error(w, "Error fetching responses", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="form-%d-responses.csv"`, formID))
+	// The BOM makes Excel read the file as UTF-8 so Arabic answers survive
+	io.WriteString(w, "\ufeff")
+	out := csv.NewWriter(w)
+	header := []string{"id", "submittedAt"}
+	if withPhone {
+		header = append(header, "phoneNumber")
+	}
+	if withEmail {
+		header = append(header, "email")
+	}
+	header = append(header, "status", "assignee", "tags",
+		"utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content", "referrer", "userAgent", "ip")
+	quiz := formdef.HasQuestions(fields)
+	if quiz {
+		header = append(header, "score")
+	}
+	shuffled := formdef.Shuffled(fields, form.Pages)
+	if shuffled {
+		header = append(header, "displayOrder")
+	}
+	if layout == "long" {
+		header = append(header, formdef.ExportLongHeader(fields, group, language)...)
+	} else {
+		header = append(header, formdef.ExportHeader(fields, language)...)
+	}
+	out.Write(header)
+
+	count := 0
+	for rows.Next() {
+		response, err := scanResponse(rows)
+		if err != nil {
+			// Headers are already sent; all we can do is stop short
+			log.Printf("Error scanning response: %v", err)
+			break
+		}
+		revealContact(&response, allowPII)
+		revealAnswers(fields, response.ResponseData, allowPII)
+		revealMetadata(&response.Metadata, allowPII)
+
+		source := response.Metadata
+		meta := []string{strconv.Itoa(response.ID), response.SubmittedAt.UTC().Format(time.RFC3339)}
+		if withPhone {
+			meta = append(meta, response.PhoneNumber)
+		}
+		if withEmail {
+			meta = append(meta, response.Email)
+		}
+		meta = append(meta,
+			response.Status,
+			response.Assignee,
+			strings.Join(response.Tags, "; "),
+			source.UTMSource, source.UTMMedium, source.UTMCampaign, source.UTMTerm, source.UTMContent,
+			source.Referrer, source.UserAgent, source.IP,
+		)
+		if quiz {
+			score := ""
+			if response.Score != nil {
+				score = strconv.FormatFloat(*response.Score, 'f', -1, 64)
+			}
+			meta = append(meta, score)
+		}
+		if shuffled {
+			order := ""
+			if response.DisplayOrder != nil {
+				b, _ := json.Marshal(response.DisplayOrder)
+				order = string(b)
+			}
+			meta = append(meta, order)
+		}
+		var answers [][]string
+		if layout == "long" {
+			answers = formdef.ExportLongRows(fields, group, response.ResponseData)
+		} else {
+			answers = [][]string{formdef.ExportRow(fields, response.ResponseData)}
+		}
+		for _, cells := range answers {
+			row := append(append([]string{}, meta...), cells...)
+			for i := range row {
+				row[i] = csvSafe(row[i])
+			}
+			out.Write(row)
+		}
+		count++
+	}
+	out.Flush()
+	if err := out.Error(); err != nil {
+		log.Printf("Error writing export: %v", err)
+	}
+	recordAudit(r, "response.export", "form", formID, nil, nil, map[string]interface{}{
+		"format":    "csv",
+		"layout":    layout,
+		"count":     count,
+		"decrypted": allowPII,
+	})
+}
+
+// FormAnalytics summarizes every field of a form across its responses
+type FormAnalytics struct {
+	FormID    int                    `json:"formId"`
+	Responses int                    `json:"responses"`
+	Fields    []formdef.FieldSummary `json:"fields"`
+}
+
+// Per-field analytics for a form, aggregated by each field's type. Sensitive fields are
+// only aggregated for callers who may read PII.
+func formAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/analytics")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+	fields, err := workspaceFormFields(workspaceID, formID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching form fields: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+	allowPII := canReadPII(r)
+
+	rows, err := db.Query("SELECT "+responseColumns+" FROM form_responses WHERE form_id = ?", formID)
+	if err != nil {
+		log.Printf("Error fetching responses: %v", err)
+		http.Error(w, "Error fetching responses", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	var answers []map[string]interface{}
+	for rows.Next() {
+		response, err := scanResponse(rows)
+		if err != nil {
+			log.Printf("Error scanning response: %v", err)
+			http.Error(w, "Error scanning response", http.StatusInternalServerError)
+			return
+		}
+		revealAnswers(fields, response.ResponseData, allowPII)
+		answers = append(answers, response.ResponseData)
+	}
+
+	withhold := make(map[string]bool)
+	for _, field := range fields {
+		if field.Sensitive && !allowPII {
+			withhold[field.ID] = true
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(FormAnalytics{
+		FormID:    formID,
+		Responses: len(answers),
+		Fields:    formdef.Analyze(fields, answers, withhold),
+	})
+}
+
+// funnelEvents are the beacon events: a view of the form, the first interaction with it
+// and reaching a field
+var funnelEvents = map[string]bool{"view": true, "start": true, "field": true}
+
+// validSessionID accepts the random IDs the public form generates (8–64 letters, digits,
+// dashes or underscores), which carry nothing about the respondent
+func validSessionID(id string) bool {
+	if len(id) < 8 || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldPosition returns where a top-level field comes in the order the session's
+// respondent meets them, shuffled pages included, or -1
+func fieldPosition(form Form, sessionID, fieldID string) int {
+	fields, _ := formdef.Shuffle(form.Fields, form.Pages, sessionID)
+	for i, field := range formdef.DisplayOrder(fields, form.Pages) {
+		if field.ID == fieldID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Record a funnel event from the public form (POST /api/events). Only the form, a random
+// session ID and the furthest field reached are stored; no IP, user agent or answers.
+// navigator.sendBeacon posts text/plain, so the body is read as JSON whatever its type.
+func formEventHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var event struct {
+		FormID    int    `json:"formId"`
+		FormRef   string `json:"formRef"`
+		SessionID string `json:"sessionId"`
+		Type      string `json:"type"`
+		FieldID   string `json:"fieldId"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&event); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !funnelEvents[event.Type] {
+		http.Error(w, "type must be view, start or field", http.StatusBadRequest)
+		return
+	}
+	if !validSessionID(event.SessionID) {
+		http.Error(w, "Invalid session ID", http.StatusBadRequest)
+		return
+	}
+	form, err := loadPublicForm(publicFormRef(event.FormRef, event.FormID))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching form: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+
+	// One row per session: the first view and start are kept, the field only moves forward
+	switch event.Type {
+	case "view":
+		_, err = db.Exec(`
+			INSERT INTO form_sessions (form_id, workspace_id, session_id, viewed_at, updated_at)
+			VALUES (?, ?, ?, NOW(), NOW())
+			ON DUPLICATE KEY UPDATE updated_at = NOW()
+		`, form.ID, form.WorkspaceID, event.SessionID)
+	case "start":
+		_, err = db.Exec(`
+			INSERT INTO form_sessions (form_id, workspace_id, session_id, viewed_at, started_at, updated_at)
+			VALUES (?, ?, ?, NOW(), NOW(), NOW())
+			ON DUPLICATE KEY UPDATE started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+		`, form.ID, form.WorkspaceID, event.SessionID)
+	case "field":
+		position := fieldPosition(form, event.SessionID, event.FieldID)
+		if position < 0 {
+			http.Error(w, "Unknown field", http.StatusBadRequest)
+			return
+		}
+		// Assignments run left to right, so last_field_id still sees the old position
+		_, err = db.Exec(`
+			INSERT INTO form_sessions (form_id, workspace_id, session_id, viewed_at, started_at, last_field_id, last_field_position, updated_at)
+			VALUES (?, ?, ?, NOW(), NOW(), ?, ?, NOW())
+			ON DUPLICATE KEY UPDATE
+				started_at = COALESCE(started_at, NOW()),
+				last_field_id = IF(? > COALESCE(last_field_position, -1), ?, last_field_id),
+				last_field_position = GREATEST(COALESCE(last_field_position, -1), ?),
+				updated_at = NOW()
+		`, form.ID, form.WorkspaceID, event.SessionID, event.FieldID, position, position, event.FieldID, position)
+	}
+	if err != nil {
+		log.Printf("Error recording %s event for form %d: %v", event.Type, form.ID, err)
+		http.Error(w, "Error recording event", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// markSessionSubmitted closes a funnel session with the response it produced
+func markSessionSubmitted(formID int, sessionID string, responseID int64) {
+	if !validSessionID(sessionID) {
+		return
+	}
+	_, err := db.Exec(`
+		UPDATE form_sessions SET started_at = COALESCE(started_at, NOW()), submitted_at = NOW(), response_id = ?, updated_at = NOW()
+		WHERE form_id = ? AND session_id = ? AND submitted_at IS NULL
+	`, responseID, formID, sessionID)
+	if err != nil {
+		log.Printf("Error marking session submitted for form %d: %v", formID, err)
+	}
+}
+
+// FieldDropOff counts the sessions abandoned after reaching a field
+type FieldDropOff struct {
+	FieldID   string            `json:"fieldId"`
+	Label     MultiLanguageText `json:"label"`
+	Abandoned int               `json:"abandoned"`
+}
+
+// FormFunnel is the view → start → submit funnel of a form over a date range
+type FormFunnel struct {
+	FormID int    `json:"formId"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Views  int    `json:"views"`
+	Starts int    `json:"starts"`
+	// Submissions counts responses from tracked sessions only
+	Submissions    int     `json:"submissions"`
+	StartRate      float64 `json:"startRate"`
+	CompletionRate float64 `json:"completionRate"`
+	ConversionRate float64 `json:"conversionRate"`
+	// MedianCompletionSeconds runs from the first interaction to submission
+	MedianCompletionSeconds *float64 `json:"medianCompletionSeconds"`
+	// Abandoned sessions started but didn't submit and have been idle for
+	// FUNNEL_ABANDON_AFTER; those without a field abandoned before reaching one
+	Abandoned          int            `json:"abandoned"`
+	DropOff            []FieldDropOff `json:"dropOff"`
+	MostAbandonedField *FieldDropOff  `json:"mostAbandonedField"`
+}
+
+// rate divides without failing on an empty funnel
+func rate(part, whole int) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
+
+// Funnel report for a form (GET /api/forms/{id}/funnel?from=YYYY-MM-DD&to=YYYY-MM-DD),
+// counting sessions by the day they first viewed the form. The range defaults to the
+// last 30 days and includes both ends.
+func formFunnelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/funnel")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+	form, err := loadForm(formID)
+	if err == sql.ErrNoRows || (err == nil && form.WorkspaceID != workspaceID) {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching form: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+
+	to := time.Now().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -29)
+	if s := r.URL.Query().Get("from"); s != "" {
+		if from, err = time.Parse("2006-01-02", s); err != nil {
+			http.Error(w, "from must be a date (YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := r.URL.Query().Get("to"); s != "" {
+		if to, err = time.Parse("2006-01-02", s); err != nil {
+			http.Error(w, "to must be a date (YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+	}
+	if to.Before(from) {
+		http.Error(w, "to must not be before from", http.StatusBadRequest)
+		return
+	}
+	rangeArgs := []interface{}{formID, from, to.AddDate(0, 0, 1)}
+	const inRange = "form_id = ? AND viewed_at >= ? AND viewed_at < ?"
+
+	funnel := FormFunnel{FormID: formID, From: from.Format("2006-01-02"), To: to.Format("2006-01-02"), DropOff: []FieldDropOff{}}
+	err = db.QueryRow(`
+		SELECT COUNT(*), COUNT(started_at), COUNT(submitted_at)
+		FROM form_sessions WHERE `+inRange, rangeArgs...).Scan(&funnel.Views, &funnel.Starts, &funnel.Submissions)
+	if err != nil {
+		log.Printf("Error counting funnel sessions: %v", err)
+		http.Error(w, "Error building funnel", http.StatusInternalServerError)
+		return
+	}
+	funnel.StartRate = rate(funnel.Starts, funnel.Views)
+	funnel.CompletionRate = rate(funnel.Submissions, funnel.Starts)
+	funnel.ConversionRate = rate(funnel.Submissions, funnel.Views)
+
+	rows, err := db.Query(`
+		SELECT TIMESTAMPDIFF(SECOND, started_at, submitted_at) AS seconds
+		FROM form_sessions WHERE `+inRange+` AND submitted_at IS NOT NULL AND started_at IS NOT NULL
+		ORDER BY seconds
+	`, rangeArgs...)
+	if err != nil {
+		log.Printf("Error fetching completion times: %v", err)
+		http.Error(w, "Error building funnel", http.StatusInternalServerError)
+		return
+	}
+	var durations []float64
+	for rows.Next() {
+		var seconds float64
+		if err := rows.Scan(&seconds); err == nil {
+			durations = append(durations, seconds)
+		}
+	}
+	rows.Close()
+	if n := len(durations); n > 0 {
+		median := durations[n/2]
+		if n%2 == 0 {
+			median = (durations[n/2-1] + durations[n/2]) / 2
+		}
+		funnel.MedianCompletionSeconds = &median
+	}
+
+	idle := envDuration("FUNNEL_ABANDON_AFTER", 30*time.Minute)
+	rows, err = db.Query(`
+		SELECT COALESCE(last_field_id, ''), COUNT(*)
+		FROM form_sessions WHERE `+inRange+` AND started_at IS NOT NULL AND submitted_at IS NULL AND updated_at < ?
+		GROUP BY last_field_id
+	`, append(rangeArgs, time.Now().Add(-idle))...)
+	if err != nil {
+		log.Printf("Error fetching drop-off: %v", err)
+		http.Error(w, "Error building funnel", http.StatusInternalServerError)
+		return
+	}
+	abandoned := make(map[string]int)
+	for rows.Next() {
+		var fieldID string
+		var count int
+		if err := rows.Scan(&fieldID, &count); err == nil {
+			abandoned[fieldID] = count
+			funnel.Abandoned += count
+		}
+	}
+	rows.Close()
+
+	// Report fields in the order respondents meet them; ties go to the earlier field
+	for _, field := range formdef.DisplayOrder(form.Fields, form.Pages) {
+		drop := FieldDropOff{FieldID: field.ID, Label: field.Label, Abandoned: abandoned[field.ID]}
+		funnel.DropOff = append(funnel.DropOff, drop)
+		if drop.Abandoned > 0 && (funnel.MostAbandonedField == nil || drop.Abandoned > funnel.MostAbandonedField.Abandoned) {
+			most := drop
+			funnel.MostAbandonedField = &most
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(funnel)
+}
+
+// keyring encrypts PII at rest; nil when ENCRYPTION_KEYS is not configured
+var keyring *fieldcrypt.Keyring
+
+// redactedValue replaces PII for callers who may not read it
+const redactedValue = "[redacted]"
+
+// initEncryption loads the field encryption keyring from the environment.
+// ENCRYPTION_KEYS is a comma-separated list of id:base64key KEKs.
+func initEncryption() {
+	spec := os.Getenv("ENCRYPTION_KEYS")
+	if spec == "" {
+		fmt.Println("ENCRYPTION_KEYS not set; PII is stored in plaintext")
+		return
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(os.Getenv("BLIND_INDEX_KEY"))
+	if err != nil {
+		log.Fatalf("Invalid BLIND_INDEX_KEY: %v", err)
+	}
+	keyring, err = fieldcrypt.ParseKeyring(spec, os.Getenv("ENCRYPTION_ACTIVE_KEY"), indexKey)
+	if err != nil {
+		log.Fatalf("Error loading encryption keys: %v", err)
+	}
+	fmt.Printf("Field encryption enabled (active key %s)\n", keyring.ActiveKeyID())
+}
+
+// canReadPII reports whether the caller may see decrypted PII. The gateway forwards the
+// signed-in user's role in X-Admin-Role; PII_READER_ROLES lists the allowed roles.
+// Without encryption configured nothing is hidden.
+func canReadPII(r *http.Request) bool {
+	if keyring == nil {
+		return true
+	}
+	return roleAllowed(r, "PII_READER_ROLES", "admin")
+}
+
+// storedPhone holds the columns a respondent's phone number is written to
+type storedPhone struct {
+	Number     string         // phone_number: ciphertext when encryption is on
+	Normalized sql.NullString // phone_normalized: only kept while encryption is off
+	BlindIndex sql.NullString // phone_blind_index: only set while encryption is on
+}
+
+// protectPhone prepares a raw phone number for storage; an empty one stores nothing
+func protectPhone(raw string) (storedPhone, error) {
+	if raw == "" {
+		return storedPhone{}, nil
+	}
+	normalized := phone.Normalize(raw)
+	if keyring == nil {
+		return storedPhone{Number: raw, Normalized: sql.NullString{String: normalized, Valid: normalized != ""}}, nil
+	}
+	ciphertext, err := keyring.Encrypt([]byte(raw))
+	if err != nil {
+		return storedPhone{}, err
+	}
+	stored := storedPhone{Number: ciphertext}
+	if normalized != "" {
+		stored.BlindIndex = sql.NullString{String: keyring.BlindIndex(normalized), Valid: true}
+	}
+	return stored, nil
+}
+
+// phoneMatch returns a condition matching a normalized phone against both plaintext rows
+// and encrypted rows. prefix qualifies the columns, e.g. "r.".
+func phoneMatch(prefix, normalized string) (string, []interface{}) {
+	var blindIndex interface{}
+	if keyring != nil {
+		blindIndex = keyring.BlindIndex(normalized)
+	}
+	return fmt.Sprintf("(%sphone_normalized = ? OR %sphone_blind_index = ?)", prefix, prefix), []interface{}{normalized, blindIndex}
+}
+
+// revealStored decrypts a stored phone number or email, or returns it as is if it was
+// stored in the clear
+func revealStored(stored string) (string, error) {
+	if !fieldcrypt.IsEncrypted(stored) {
+		return stored, nil
+	}
+	plaintext, err := keyring.Decrypt(stored)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// nullString stores an empty contact detail as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// storedEmail holds the columns a respondent's email is written to, protected like
+// the phone number
+type storedEmail struct {
+	Address    string         // email: ciphertext when encryption is on
+	Normalized sql.NullString // email_normalized: only kept while encryption is off
+	BlindIndex sql.NullString // email_blind_index: only set while encryption is on
+}
+
+// normalizeEmail is the form of an email address lookups and dedupe compare
+func normalizeEmail(raw string) string {
+	return strings.ToLower(strings.TrimSpace(raw))
+}
+
+// validEmail reports whether s is a bare email address
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// protectEmail prepares a raw email address for storage; an empty one stores nothing
+func protectEmail(raw string) (storedEmail, error) {
+	if raw == "" {
+		return storedEmail{}, nil
+	}
+	normalized := normalizeEmail(raw)
+	if keyring == nil {
+		return storedEmail{Address: raw, Normalized: sql.NullString{String: normalized, Valid: true}}, nil
+	}
+	ciphertext, err := keyring.Encrypt([]byte(raw))
+	if err != nil {
+		return storedEmail{}, err
+	}
+	return storedEmail{Address: ciphertext, BlindIndex: sql.NullString{String: keyring.BlindIndex("email:" + normalized), Valid: true}}, nil
+}
+
+// contactMatch returns a condition matching rows with the normalized phone or the
+// normalized email, whichever are given; with neither it matches nothing
+func contactMatch(prefix, normalizedPhone, normalizedEmail string) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if normalizedPhone != "" {
+		match, phoneArgs := phoneMatch(prefix, normalizedPhone)
+		conditions = append(conditions, match)
+		args = append(args, phoneArgs...)
+	}
+	if normalizedEmail != "" {
+		var blindIndex interface{}
+		if keyring != nil {
+			blindIndex = keyring.BlindIndex("email:" + normalizedEmail)
+		}
+		conditions = append(conditions, fmt.Sprintf("(%semail_normalized = ? OR %semail_blind_index = ?)", prefix, prefix))
+		args = append(args, normalizedEmail, blindIndex)
+	}
+	if len(conditions) == 0 {
+		return "FALSE", nil
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// identityKey is how form_response_identities records one contact detail of a
+// respondent: the blind index while encryption is on, otherwise a hash of the normalized
+// value. indexFormIdentities derives the same keys in SQL.
+func identityKey(kind, normalized string) string {
+	if keyring != nil {
+		if kind == "phone" {
+			return keyring.BlindIndex(normalized)
+		}
+		return keyring.BlindIndex(kind + ":" + normalized)
+	}
+	sum := sha256.Sum256([]byte(kind + ":" + normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// claimIdentities records the phone number and email of a new response on a form that
+// prevents duplicates, reporting whether another response already holds either
+func claimIdentities(tx *sql.Tx, formID, responseID int, normalizedPhone, normalizedEmail string) (bool, error) {
+	for kind, normalized := range map[string]string{"phone": normalizedPhone, "email": normalizedEmail} {
+		if normalized == "" {
+			continue
+		}
+		result, err := tx.Exec("INSERT IGNORE INTO form_response_identities (form_id, identity_key, response_id) VALUES (?, ?, ?)",
+			formID, identityKey(kind, normalized), responseID)
+		if err != nil {
+			return false, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// indexFormIdentities records the contact details of a form's existing responses, for
+// when it starts preventing duplicates. Where several responses share one, the oldest
+// keeps it.
+func indexFormIdentities(q querier, formID int) error {
+	statements := []string{
+		`INSERT IGNORE INTO form_response_identities (form_id, identity_key, response_id)
+			SELECT form_id, COALESCE(phone_blind_index, SHA2(CONCAT('phone:', phone_normalized), 256)), id
+			FROM form_responses
+			WHERE form_id = ? AND (phone_blind_index IS NOT NULL OR phone_normalized IS NOT NULL)
+			ORDER BY id`,
+		`INSERT IGNORE INTO form_response_identities (form_id, identity_key, response_id)
+			SELECT form_id, COALESCE(email_blind_index, SHA2(CONCAT('email:', email_normalized), 256)), id
+			FROM form_responses
+			WHERE form_id = ? AND (email_blind_index IS NOT NULL OR email_normalized IS NOT NULL)
+			ORDER BY id`,
+	}
+	for _, stmt := range statements {
+		if _, err := q.Exec(stmt, formID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contactNoun names the contact details a form's respondents give, for messages
+func contactNoun(settings FormSettings) string {
+	switch {
+	case settings.asksPhone() && settings.asksEmail():
+		return "phone number or email"
+	case settings.asksEmail():
+		return "email"
+	}
+	return "phone number"
+}
+
+// encryptSensitiveAnswers replaces answers to sensitive fields with ciphertext in place.
+// Every answer is encrypted, including one that looks like ciphertext already: it came
+// from the respondent, who must not be able to store a value we never sealed.
+func encryptSensitiveAnswers(fields []FormField, data map[string]interface{}) error {
+	return sealAnswers(fields, data, false)
+}
+
+// encryptStoredAnswers is encryptSensitiveAnswers for answers read back from the
+// database, where ciphertext is ours and is left as it is
+func encryptStoredAnswers(fields []FormField, data map[string]interface{}) error {
+	return sealAnswers(fields, data, true)
+}
+
+func sealAnswers(fields []FormField, data map[string]interface{}, keepCiphertext bool) error {
+	if keyring == nil {
+		return nil
+	}
+	for _, field := range fields {
+		value, ok := data[field.ID]
+		if !ok || !field.Sensitive {
+			continue
+		}
+		if s, isString := value.(string); keepCiphertext && isString && fieldcrypt.IsEncrypted(s) {
+			continue
+		}
+		plaintext, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		ciphertext, err := keyring.Encrypt(plaintext)
+		if err != nil {
+			return fmt.Errorf("encrypting field %s: %w", field.ID, err)
+		}
+		data[field.ID] = ciphertext
+	}
+	return nil
+}
+
+// revealContact decrypts the phone number and email of a response, or redacts them
+// when the caller may not read PII. Details the respondent didn't give stay empty.
+func revealContact(response *FormResponse, allowed bool) {
+	if response.PhoneNumber != "" {
+		response.PhoneNumber = revealStoredContact(response.PhoneNumber, "phone number", allowed)
+	}
+	if response.Email != "" {
+		response.Email = revealStoredContact(response.Email, "email", allowed)
+	}
+}
+
+// revealStoredContact decrypts a stored phone number or email, or redacts it when the
+// caller may not read PII. kind names the value in logs.
+func revealStoredContact(stored, kind string, allowed bool) string {
+	if !allowed {
+		return redactedValue
+	}
+	if !fieldcrypt.IsEncrypted(stored) {
+		return stored
+	}
+	if keyring == nil {
+		return redactedValue
+	}
+	plaintext, err := keyring.Decrypt(stored)
+	if err != nil {
+		log.Printf("Error decrypting %s: %v", kind, err)
+		return redactedValue
+	}
+	return string(plaintext)
+}
+
+// revealAnswers decrypts encrypted answers in place, or redacts them together with any
+// plaintext answers to sensitive fields when the caller may not read PII
+func revealAnswers(fields []FormField, data map[string]interface{}, allowed bool) {
+	if !allowed {
+		for _, field := range fields {
+			if _, ok := data[field.ID]; ok && field.Sensitive {
+				data[field.ID] = redactedValue
+			}
+		}
+	}
+	for key, value := range data {
+		s, ok := value.(string)
+		if !ok || !fieldcrypt.IsEncrypted(s) {
+			continue
+		}
+		if !allowed || keyring == nil {
+			data[key] = redactedValue
+			continue
+		}
+		plaintext, err := keyring.Decrypt(s)
+		if err == nil {
+			var decoded interface{}
+			if err = json.Unmarshal(plaintext, &decoded); err == nil {
+				data[key] = decoded
+				continue
+			}
+		}
+		log.Printf("Error decrypting answer %s: %v", key, err)
+		data[key] = redactedValue
+	}
+}
+
+// formFields loads just the field definitions of a form
+func formFields(q querier, formID int) ([]FormField, error) {
+	var fieldsJSON []byte
+	if err := q.QueryRow("SELECT fields FROM forms WHERE id = ?", formID).Scan(&fieldsJSON); err != nil {
+		return nil, err
+	}
+	var fields []FormField
+	err := json.Unmarshal(fieldsJSON, &fields)
+	return fields, err
+}
+
+// rotateEncryptionKeys implements the rotate-keys command. It walks form_responses and
+// form_drafts in batches and re-encrypts under the active key every value wrapped with
+// an older KEK, plus plaintext phones and plaintext answers to fields since marked
+// sensitive.
+func rotateEncryptionKeys(args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ExitOnError)
+	batchSize := flags.Int("batch-size", 500, "rows re-encrypted per transaction")
+	dryRun := flags.Bool("dry-run", false, "count rows that would change without writing")
+	flags.Parse(args)
+
+	if keyring == nil {
+		return errors.New("ENCRYPTION_KEYS is not configured")
+	}
+
+	fieldsByForm := make(map[int][]FormField)
+	formRows, err := db.Query("SELECT id, fields FROM forms")
+	if err != nil {
+		return err
+	}
+	for formRows.Next() {
+		var id int
+		var fieldsJSON []byte
+		if err := formRows.Scan(&id, &fieldsJSON); err != nil {
+			formRows.Close()
+			return err
+		}
+		var fields []FormField
+		if err := json.Unmarshal(fieldsJSON, &fields); err != nil {
+			log.Printf("Skipping sensitive fields of form %d: %v", id, err)
+		}
+		fieldsByForm[id] = fields
+	}
+	formRows.Close()
+
+	scanned, rotated, err := rotateResponses(fieldsByForm, *batchSize, *dryRun)
+	if err != nil {
+		return err
+	}
+	// Drafts hold a phone and sensitive answers too, and resuming one needs its key
+	var draftsScanned, draftsRotated int
+	hasDrafts, err := schemaHas("form_drafts", "")
+	if err != nil {
+		return err
+	}
+	if hasDrafts {
+		if draftsScanned, draftsRotated, err = rotateDrafts(fieldsByForm, *batchSize, *dryRun); err != nil {
+			return err
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run: %d of %d responses and %d of %d drafts would be re-encrypted\n", rotated, scanned, draftsRotated, draftsScanned)
+	} else {
+		fmt.Printf("Re-encrypted %d of %d responses and %d of %d drafts under key %s\n", rotated, scanned, draftsRotated, draftsScanned, keyring.ActiveKeyID())
+	}
+	return nil
+}
+
+// rotatePhone re-protects a stored phone number that is in the clear or wrapped with
+// an older KEK. changed is false when it is fine as it is.
+func rotatePhone(number string) (stored storedPhone, changed bool, err error) {
+	if number == "" || (fieldcrypt.IsEncrypted(number) && !keyring.NeedsRotation(number)) {
+		// A NULL blind index keeps the stored one; it doesn't depend on the KEK
+		return storedPhone{Number: number}, false, nil
+	}
+	raw, err := revealStored(number)
+	if err != nil {
+		return stored, false, err
+	}
+	stored, err = protectPhone(raw)
+	return stored, err == nil, err
+}
+
+// rotateAnswers re-encrypts answers wrapped with an older KEK and encrypts plaintext
+// answers to fields marked sensitive since they were stored. It returns the answers to
+// write back, or nil when nothing changed.
+func rotateAnswers(fields []FormField, dataJSON []byte) ([]byte, error) {
+	var data map[string]interface{}
+	if err := json.Unmarshal(dataJSON, &data); err != nil {
+		return nil, err
+	}
+	changed := false
+	for key, value := range data {
+		s, ok := value.(string)
+		if !ok || !keyring.NeedsRotation(s) {
+			continue
+		}
+		plaintext, err := keyring.Decrypt(s)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting answer %s: %w", key, err)
+		}
+		if data[key], err = keyring.Encrypt(plaintext); err != nil {
+			return nil, err
+		}
+		changed = true
+	}
+	for _, field := range fields {
+		value, present := data[field.ID]
+		if s, isString := value.(string); field.Sensitive && present && (!isString || !fieldcrypt.IsEncrypted(s)) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil, nil
+	}
+	if err := encryptStoredAnswers(fields, data); err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
+
+// rotateResponses is rotateEncryptionKeys for form_responses
+func rotateResponses(fieldsByForm map[int][]FormField, batchSize int, dryRun bool) (scanned, rotated int, err error) {
+	type rotation struct {
+		id    int
+		phone storedPhone
+		email storedEmail
+		data  []byte
+	}
+
+	lastID := 0
+	for {
+		rows, err := db.Query(`
+			SELECT id, form_id, phone_number, email, response_data
+			FROM form_responses
+			WHERE id > ?
+			ORDER BY id
+			LIMIT ?
+		`, lastID, batchSize)
+		if err != nil {
+			return scanned, rotated, err
+		}
+
+		var batch []rotation
+		count := 0
+		for rows.Next() {
+			var id, formID int
+			var storedNumber, storedAddress sql.NullString
+			var dataJSON []byte
+			if err := rows.Scan(&id, &formID, &storedNumber, &storedAddress, &dataJSON); err != nil {
+				rows.Close()
+				return scanned, rotated, err
+			}
+			count++
+			lastID = id
+
+			update := rotation{id: id}
+			// Anonymized rows and forms that don't ask have no contact details to protect
+			phoneChanged := false
+			if update.phone, phoneChanged, err = rotatePhone(storedNumber.String); err != nil {
+				rows.Close()
+				return scanned, rotated, fmt.Errorf("rotating phone of response %d: %w", id, err)
+			}
+			emailChanged := false
+			if address := storedAddress.String; address != "" && (!fieldcrypt.IsEncrypted(address) || keyring.NeedsRotation(address)) {
+				raw, err := revealStored(address)
+				if err != nil {
+					rows.Close()
+					return scanned, rotated, fmt.Errorf("decrypting email of response %d: %w", id, err)
+				}
+				if update.email, err = protectEmail(raw); err != nil {
+					rows.Close()
+					return scanned, rotated, err
+				}
+				emailChanged = true
+			} else {
+				update.email.Address = address
+			}
+			if update.data, err = rotateAnswers(fieldsByForm[formID], dataJSON); err != nil {
+				rows.Close()
+				return scanned, rotated, fmt.Errorf("rotating response %d: %w", id, err)
+			}
+			if !phoneChanged && !emailChanged && update.data == nil {
+				continue
+			}
+			if update.data == nil {
+				update.data = dataJSON
+			}
+			batch = append(batch, update)
+		}
+		rows.Close()
+		if count == 0 {
+			return scanned, rotated, nil
+		}
+		scanned += count
+		rotated += len(batch)
+
+		if !dryRun && len(batch) > 0 {
+			tx, err := db.Begin()
+			if err != nil {
+				return scanned, rotated, err
+			}
+			for _, update := range batch {
+				_, err := tx.Exec(`
+					UPDATE form_responses
+					SET phone_number = ?, phone_normalized = ?, phone_blind_index = COALESCE(?, phone_blind_index),
+						email = ?, email_normalized = ?, email_blind_index = COALESCE(?, email_blind_index), response_data = ?
+					WHERE id = ?
+				`, nullString(update.phone.Number), update.phone.Normalized, update.phone.BlindIndex,
+					nullString(update.email.Address), update.email.Normalized, update.email.BlindIndex, update.data, update.id)
+				if err != nil {
+					tx.Rollback()
+					return scanned, rotated, fmt.Errorf("updating response %d: %w", update.id, err)
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				return scanned, rotated, err
+			}
+		}
+		log.Printf("rotate-keys: scanned %d responses, %d re-encrypted", scanned, rotated)
+	}
+}
+
+// rotateDrafts is rotateEncryptionKeys for form_drafts, which encodeDraft protects the
+// same way as responses
+func rotateDrafts(fieldsByForm map[int][]FormField, batchSize int, dryRun bool) (scanned, rotated int, err error) {
+	type rotation struct {
+		id    int
+		phone storedPhone
+		data  []byte
+	}
+
+	lastID := 0
+	for {
+		rows, err := db.Query(`
+			SELECT id, form_id, phone_number, response_data
+			FROM form_drafts
+			WHERE id > ?
+			ORDER BY id
+			LIMIT ?
+		`, lastID, batchSize)
+		if err != nil {
+			return scanned, rotated, err
+		}
+
+		var batch []rotation
+		count := 0
+		for rows.Next() {
+			var id, formID int
+			var storedNumber sql.NullString
+			var dataJSON []byte
+			if err := rows.Scan(&id, &formID, &storedNumber, &dataJSON); err != nil {
+				rows.Close()
+				return scanned, rotated, err
+			}
+			count++
+			lastID = id
+
+			update := rotation{id: id}
+			phoneChanged := false
+			if update.phone, phoneChanged, err = rotatePhone(storedNumber.String); err != nil {
+				rows.Close()
+				return scanned, rotated, fmt.Errorf("rotating phone of draft %d: %w", id, err)
+			}
+			if update.data, err = rotateAnswers(fieldsByForm[formID], dataJSON); err != nil {
+				rows.Close()
+				return scanned, rotated, fmt.Errorf("rotating draft %d: %w", id, err)
+			}
+			if !phoneChanged && update.data == nil {
+				continue
+			}
+			if update.data == nil {
+				update.data = dataJSON
+			}
+			batch = append(batch, update)
+		}
+		rows.Close()
+		if count == 0 {
+			return scanned, rotated, nil
+		}
+		scanned += count
+		rotated += len(batch)
+
+		if !dryRun && len(batch) > 0 {
+			tx, err := db.Begin()
+			if err != nil {
+				return scanned, rotated, err
+			}
+			for _, update := range batch {
+				_, err := tx.Exec(`
+					UPDATE form_drafts
+					SET phone_number = ?, phone_normalized = ?, phone_blind_index = COALESCE(?, phone_blind_index), response_data = ?
+					WHERE id = ?
+				`, nullString(update.phone.Number), update.phone.Normalized, update.phone.BlindIndex, update.data, update.id)
+				if err != nil {
+					tx.Rollback()
+					return scanned, rotated, fmt.Errorf("updating draft %d: %w", update.id, err)
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				return scanned, rotated, err
+			}
+		}
+		log.Printf("rotate-keys: scanned %d drafts, %d re-encrypted", scanned, rotated)
+	}
+}
+
+// responseStatuses are the triage states a response moves through
+var responseStatuses = map[string]bool{
+	"new":         true,
+	"in-progress": true,
+	"contacted":   true,
+	"closed":      true,
+}
+
+// ResponseNote is an internal note on a response; replies reference their parent
+type ResponseNote struct {
+	ID         int             `json:"id"`
+	ResponseID int             `json:"responseId"`
+	ParentID   *int            `json:"parentId,omitempty"`
+	Author     string          `json:"author"`
+	Body       string          `json:"body"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	Replies    []*ResponseNote `json:"replies"`
+}
+
+// StatusChange is one entry in a response's status history
+type StatusChange struct {
+	ID         int       `json:"id"`
+	ResponseID int       `json:"responseId"`
+	FromStatus string    `json:"fromStatus"`
+	ToStatus   string    `json:"toStatus"`
+	Actor      string    `json:"actor"`
+	ChangedAt  time.Time `json:"changedAt"`
+}
+
+// normalizeTags trims, lowercases and dedupes tags, keeping their first-seen order
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool)
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// Route /api/responses/{id}/{status|assignee|tags|notes|history}
+func responseTriageHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/responses/"), "/")
+	if len(parts) != 2 {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+	responseID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid response ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+	var owner int
+	err = db.QueryRow("SELECT workspace_id FROM form_responses WHERE id = ?", responseID).Scan(&owner)
+	if err == sql.ErrNoRows || (err == nil && owner != workspaceID) {
+		http.Error(w, "Response not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching response %d: %v", responseID, err)
+		http.Error(w, "Error fetching response", http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case parts[1] == "status" && r.Method == "PUT":
+		updateResponseStatusHandler(w, r, responseID)
+	case parts[1] == "assignee" && r.Method == "PUT":
+		updateResponseAssigneeHandler(w, r, responseID)
+	case parts[1] == "tags" && r.Method == "PUT":
+		updateResponseTagsHandler(w, r, responseID)
+	case parts[1] == "notes" && r.Method == "GET":
+		getResponseNotesHandler(w, r, responseID)
+	case parts[1] == "notes" && r.Method == "POST":
+		createResponseNoteHandler(w, r, responseID)
+	case parts[1] == "history" && r.Method == "GET":
+		getResponseHistoryHandler(w, r, responseID)
+	case parts[1] == "status" || parts[1] == "assignee" || parts[1] == "tags" || parts[1] == "notes" || parts[1] == "history":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+	}
+}
+
+// writeTriagedResponse returns the response after a triage change, with PII revealed
+// according to the caller's role
+func writeTriagedResponse(w http.ResponseWriter, r *http.Request, responseID int) {
+	response, err := scanResponse(db.QueryRow("SELECT "+responseColumns+" FROM form_responses WHERE id = ?", responseID))
+	if err != nil {
+		log.Printf("Error fetching response %d: %v", responseID, err)
+		http.Error(w, "Error fetching response", http.StatusInternalServerError)
+		return
+	}
+	fields, err := formFields(db, response.FormID)
+	if err != nil {
+		log.Printf("Error fetching form fields: %v", err)
+	}
+	allowPII := canReadPII(r)
+	revealContact(&response, allowPII)
+	revealAnswers(fields, response.ResponseData, allowPII)
+	revealMetadata(&response.Metadata, allowPII)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Move a response to a new triage status and record the change in its history
+func updateResponseStatusHandler(w http.ResponseWriter, r *http.Request, responseID int) {
+	actor := adminActor(r)
+	if actor == "" {
+		http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Status string `json:"status"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if !responseStatuses[request.Status] {
+		http.Error(w, "Status must be one of new, in-progress, contacted, closed", http.StatusBadRequest)
+		return
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Error updating status", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var current string
+	err = tx.QueryRow("SELECT status FROM form_responses WHERE id = ? FOR UPDATE", responseID).Scan(&current)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Response not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching response status: %v", err)
+		http.Error(w, "Error updating status", http.StatusInternalServerError)
+		return
+	}
+
+	if current != request.Status {
+		if _, err := tx.Exec("UPDATE form_responses SET status = ? WHERE id = ?", request.Status, responseID); err != nil {
+			log.Printf("Error updating response status: %v", err)
+			http.Error(w, "Error updating status", http.StatusInternalServerError)
+			return
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO response_status_history (response_id, from_status, to_status, actor, changed_at)
+			VALUES (?, ?, ?, ?, NOW())
+		`, responseID, current, request.Status, actor); err != nil {
+			log.Printf("Error recording status history: %v", err)
+			http.Error(w, "Error updating status", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Error updating status", http.StatusInternalServerError)
+		return
+	}
+	if current != request.Status {
+		recordAudit(r, "response.status", "response", responseID,
+			map[string]interface{}{"status": current}, map[string]interface{}{"status": request.Status}, nil)
+	}
+
+	writeTriagedResponse(w, r, responseID)
+}
+
+// Assign a response to a team member; an empty assignee unassigns it
+func updateResponseAssigneeHandler(w http.ResponseWriter, r *http.Request, responseID int) {
+	if adminActor(r) == "" {
+		http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Assignee string `json:"assignee"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	assignee := sql.NullString{String: strings.TrimSpace(request.Assignee), Valid: strings.TrimSpace(request.Assignee) != ""}
+
+	// The row lock keeps the recorded previous assignee true under concurrent edits
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Error updating assignee", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var previous sql.NullString
+	err = tx.QueryRow("SELECT assignee FROM form_responses WHERE id = ? FOR UPDATE", responseID).Scan(&previous)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Response not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching response assignee: %v", err)
+		http.Error(w, "Error updating assignee", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec("UPDATE form_responses SET assignee = ? WHERE id = ?", assignee, responseID); err != nil {
+		log.Printf("Error updating response assignee: %v", err)
+		http.Error(w, "Error updating assignee", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Error updating assignee", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "response.assign", "response", responseID,
+		map[string]interface{}{"assignee": previous.String}, map[string]interface{}{"assignee": assignee.String}, nil)
+
+	writeTriagedResponse(w, r, responseID)
+}
+
+// Replace a response's tags
+func updateResponseTagsHandler(w http.ResponseWriter, r *http.Request, responseID int) {
+	if adminActor(r) == "" {
+		http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	tags := normalizeTags(request.Tags)
+	tagsJSON, err := json.Marshal(tags)
+	if err != nil {
+		http.Error(w, "Error encoding tags", http.StatusInternalServerError)
+		return
+	}
+
+	// The row lock keeps the recorded previous tags true under concurrent edits
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Error updating tags", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var previousJSON []byte
+	err = tx.QueryRow("SELECT tags FROM form_responses WHERE id = ? FOR UPDATE", responseID).Scan(&previousJSON)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Response not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching response tags: %v", err)
+		http.Error(w, "Error updating tags", http.StatusInternalServerError)
+		return
+	}
+	previous := []string{}
+	if len(previousJSON) > 0 {
+		json.Unmarshal(previousJSON, &previous)
+	}
+
+	if _, err := tx.Exec("UPDATE form_responses SET tags = ? WHERE id = ?", tagsJSON, responseID); err != nil {
+		log.Printf("Error updating response tags: %v", err)
+		http.Error(w, "Error updating tags", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		http.Error(w, "Error updating tags", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "response.tag", "response", responseID,
+		map[string]interface{}{"tags": previous}, map[string]interface{}{"tags": tags}, nil)
+
+	writeTriagedResponse(w, r, responseID)
+}
+
+// List a response's notes as threads, oldest first
+func getResponseNotesHandler(w http.ResponseWriter, r *http.Request, responseID int) {
+	rows, err := db.Query(`
+		SELECT id, response_id, parent_id, author, body, created_at
+		FROM response_notes
+		WHERE response_id = ?
+		ORDER BY created_at, id
+	`, responseID)
+	if err != nil {
+		log.Printf("Error fetching response notes: %v", err)
+		http.Error(w, "Error fetching notes", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	byID := make(map[int]*ResponseNote)
+	var ordered []*ResponseNote
+	for rows.Next() {
+		note := &ResponseNote{Replies: []*ResponseNote{}}
+		var parentID sql.NullInt64
+		if err := rows.Scan(&note.ID, &note.ResponseID, &parentID, &note.Author, &note.Body, &note.CreatedAt); err != nil {
+			http.Error(w, "Error scanning note", http.StatusInternalServerError)
+			return
+		}
+		if parentID.Valid {
+			id := int(parentID.Int64)
+			note.ParentID = &id
+		}
+		byID[note.ID] = note
+		ordered = append(ordered, note)
+	}
+
+	threads := []*ResponseNote{}
+	for _, note := range ordered {
+		if note.ParentID != nil && byID[*note.ParentID] != nil {
+			parent := byID[*note.ParentID]
+			parent.Replies = append(parent.Replies, note)
+		} else {
+			threads = append(threads, note)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(threads)
+}
+
+// Add a note to a response, optionally as a reply to another note
+func createResponseNoteHandler(w http.ResponseWriter, r *http.Request, responseID int) {
+	actor := adminActor(r)
+	if actor == "" {
+		http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+		return
+	}
+
+	var request struct {
+		Body     string `json:"body"`
+		ParentID *int   `json:"parentId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.Body = strings.TrimSpace(request.Body)
+	if request.Body == "" {
+		http.Error(w, "Note body is required", http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM form_responses WHERE id = ?)", responseID).Scan(&exists); err != nil || !exists {
+		http.Error(w, "Response not found", http.StatusNotFound)
+		return
+	}
+	if request.ParentID != nil {
+		var parentResponseID int
+		err := db.QueryRow("SELECT response_id FROM response_notes WHERE id = ?", *request.ParentID).Scan(&parentResponseID)
+		if err != nil || parentResponseID != responseID {
+			http.Error(w, "Parent note not found on this response", http.StatusBadRequest)
+			return
+		}
+	}
+
+	result, err := db.Exec(`
+		INSERT INTO response_notes (response_id, parent_id, author, body, created_at)
+		VALUES (?, ?, ?, ?, NOW())
+	`, responseID, request.ParentID, actor, request.Body)
+	if err != nil {
+		log.Printf("Error creating note: %v", err)
+		http.Error(w, "Error creating note", http.StatusInternalServerError)
+		return
+	}
+	noteID, _ := result.LastInsertId()
+
+	note := ResponseNote{Replies: []*ResponseNote{}}
+	var parentID sql.NullInt64
+	err = db.QueryRow("SELECT id, response_id, parent_id, author, body, created_at FROM response_notes WHERE id = ?", noteID).
+		Scan(&note.ID, &note.ResponseID, &parentID, &note.Author, &note.Body, &note.CreatedAt)
+	if err != nil {
+		log.Printf("Error fetching created note: %v", err)
+		http.Error(w, "Error fetching created note", http.StatusInternalServerError)
+		return
+	}
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		note.ParentID = &id
+	}
+	recordAudit(r, "response.note", "response", responseID, nil, nil, map[string]interface{}{"noteId": note.ID})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(note)
+}
+
+// List every status change of a response, oldest first
+func getResponseHistoryHandler(w http.ResponseWriter, r *http.Request, responseID int) {
+	rows, err := db.Query(`
+		SELECT id, response_id, from_status, to_status, actor, changed_at
+		FROM response_status_history
+		WHERE response_id = ?
+		ORDER BY changed_at, id
+	`, responseID)
+	if err != nil {
+		log.Printf("Error fetching status history: %v", err)
+		http.Error(w, "Error fetching history", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	history := []StatusChange{}
+	for rows.Next() {
+		var change StatusChange
+		if err := rows.Scan(&change.ID, &change.ResponseID, &change.FromStatus, &change.ToStatus, &change.Actor, &change.ChangedAt); err != nil {
+			http.Error(w, "Error scanning history", http.StatusInternalServerError)
+			return
+		}
+		history = append(history, change)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(history)
+}
+
+// events carries live notifications such as new submissions to SSE subscribers
+var events broker.Broker
+
+// streamTokenKey signs the tokens SSE clients open a stream with
+var streamTokenKey []byte
+
+// initEvents sets up the in-process broker. SSE_REPLAY_BUFFER bounds how many recent
+// events a reconnecting client can resume from. Without STREAM_TOKEN_KEY stream tokens
+// are signed with a random key, so they only work on the instance that issued them.
+func initEvents() {
+	retain := 1000
+	if v, err := strconv.Atoi(os.Getenv("SSE_REPLAY_BUFFER")); err == nil && v > 0 {
+		retain = v
+	}
+	events = broker.NewMemory(retain)
+
+	streamTokenKey = []byte(os.Getenv("STREAM_TOKEN_KEY"))
+	if len(streamTokenKey) == 0 {
+		streamTokenKey = make([]byte, 32)
+		if _, err := rand.Read(streamTokenKey); err != nil {
+			log.Fatalf("Error generating stream token key: %v", err)
+		}
+	}
+}
+
+// streamClaims is the gateway identity a stream token vouches for
+type streamClaims struct {
+	User    string `json:"user"`
+	Role    string `json:"role"`
+	Expires int64  `json:"exp"`
+}
+
+// signStreamToken encodes claims as base64(JSON).base64(HMAC-SHA256)
+func signStreamToken(claims streamClaims) string {
+	payload, _ := json.Marshal(claims)
+	mac := hmac.New(sha256.New, streamTokenKey)
+	mac.Write(payload)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verifyStreamToken returns the claims of an authentic, unexpired stream token
+func verifyStreamToken(token string) (streamClaims, bool) {
+	var claims streamClaims
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return claims, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return claims, false
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return claims, false
+	}
+	mac := hmac.New(sha256.New, streamTokenKey)
+	mac.Write(payload)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return claims, false
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || time.Now().Unix() > claims.Expires {
+		return claims, false
+	}
+	return claims, true
+}
+
+// Issue a short-lived token for opening a response stream. EventSource cannot send the
+// gateway's X-Admin-* headers, so the client fetches this first and passes ?token=.
+func streamTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actor := adminActor(r)
+	if actor == "" {
+		http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+		return
+	}
+	expires := time.Now().Add(envDuration("STREAM_TOKEN_TTL", time.Minute))
+	token := signStreamToken(streamClaims{
+		User:    actor,
+		Role:    strings.TrimSpace(r.Header.Get("X-Admin-Role")),
+		Expires: expires.Unix(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"token": token, "expiresAt": expires.UTC()})
+}
+
+// formTopic is the broker topic carrying a single form's events
+func formTopic(formID int) string {
+	return "form:" + strconv.Itoa(formID)
+}
+
+// publishResponseCreated announces a stored response. The payload keeps the stored
+// (possibly encrypted) values; each stream reveals them for its own reader.
+func publishResponseCreated(response FormResponse) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		log.Printf("Error encoding response event: %v", err)
+		return
+	}
+	if _, err := events.Publish(formTopic(response.FormID), "response.created", data); err != nil {
+		log.Printf("Error publishing response event: %v", err)
+	}
+}
+
+// Stream new submissions as Server-Sent Events, for one form or (with formID 0) for
+// all of them (/api/responses/stream). Reconnecting clients send Last-Event-ID to receive what they missed.
+// A ?token= from /api/responses/stream-token stands in for the X-Admin-* headers.
+func streamResponsesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if token := r.URL.Query().Get("token"); token != "" {
+		claims, ok := verifyStreamToken(token)
+		if !ok {
+			http.Error(w, "Invalid or expired stream token", http.StatusUnauthorized)
+			return
+		}
+		r = r.Clone(r.Context())
+		r.Header.Set("X-Admin-User", claims.User)
+		r.Header.Set("X-Admin-Role", claims.Role)
+	}
+
+	formID := 0
+	if r.URL.Path != "/api/responses/stream" {
+		path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/responses/stream")
+		id, err := strconv.Atoi(path)
+		if err != nil {
+			http.Error(w, "Invalid form ID", http.StatusBadRequest)
+			return
+		}
+		formID = id
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	fieldsByForm := make(map[int][]FormField)
+	topic := ""
+	if formID != 0 {
+		fields, err := workspaceFormFields(workspaceID, formID)
+		if err == sql.ErrNoRows {
+			http.Error(w, "Form not found", http.StatusNotFound)
+			return
+		} else if err != nil {
+			log.Printf("Error fetching form fields: %v", err)
+			http.Error(w, "Error fetching form", http.StatusInternalServerError)
+			return
+		}
+		fieldsByForm[formID] = fields
+		topic = formTopic(formID)
+	}
+
+	// EventSource cannot set headers on the first connection, so accept a query too
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	stream, err := events.Subscribe(r.Context(), topic, lastEventID)
+	if err != nil {
+		log.Printf("Error subscribing to events: %v", err)
+		http.Error(w, "Error opening stream", http.StatusServiceUnavailable)
+		return
+	}
+	recordAudit(r, "response.stream", "form", formID, nil, nil, nil)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Stop nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", envDuration("SSE_RETRY", 3*time.Second).Milliseconds())
+	flusher.Flush()
+
+	allowPII := canReadPII(r)
+	heartbeat := time.NewTicker(envDuration("SSE_HEARTBEAT_INTERVAL", 15*time.Second))
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case event, ok := <-stream:
+			if !ok {
+				// Dropped for lagging behind; the client resumes from its last event ID
+				return
+			}
+			var response FormResponse
+			if err := json.Unmarshal(event.Data, &response); err != nil {
+				log.Printf("Error decoding response event %s: %v", event.ID, err)
+				continue
+			}
+			if response.WorkspaceID != workspaceID {
+				continue
+			}
+			fields, cached := fieldsByForm[response.FormID]
+			if !cached {
+				fields, _ = formFields(db, response.FormID)
+				fieldsByForm[response.FormID] = fields
+			}
+			revealContact(&response, allowPII)
+			revealAnswers(fields, response.ResponseData, allowPII)
+			revealMetadata(&response.Metadata, allowPII)
+			data, _ := json.Marshal(response)
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// formETag is the entity tag of a form, derived from its version counter
+func formETag(form Form) string {
+	return fmt.Sprintf(`"v%d"`, form.Version)
+}
+
+// parseFormETag returns the version a form entity tag refers to
+func parseFormETag(tag string) (int, bool) {
+	tag = strings.TrimSpace(tag)
+	if !strings.HasPrefix(tag, `"v`) || !strings.HasSuffix(tag, `"`) {
+		return 0, false
+	}
+	version, err := strconv.Atoi(tag[2 : len(tag)-1])
+	return version, err == nil
+}
+
+// etagListed reports whether an If-None-Match header lists etag, using the weak
+// comparison that header calls for
+func etagListed(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeForm sends a form with its ETag, or 304 Not Modified when a GET already holds
+// the current version
+func writeForm(w http.ResponseWriter, r *http.Request, form Form) {
+	etag := formETag(form)
+	w.Header().Set("ETag", etag)
+	if r.Method == "GET" && etagListed(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(form)
+}
+
+// writeVersionConflict answers 412 with the form's current version so the editor can
+// reload or merge before saving again
+func writeVersionConflict(w http.ResponseWriter, current Form) {
+	w.Header().Set("ETag", formETag(current))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":          "Form was changed by someone else",
+		"currentVersion": current.Version,
+		"form":           current,
+	})
+}
+
+// newPublicToken returns a random token for an unguessable share link
+func newPublicToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// slugTaken reports whether another form already uses the slug
+func slugTaken(q querier, candidate string, formID int) (bool, error) {
+	var taken bool
+	err := q.QueryRow("SELECT EXISTS(SELECT 1 FROM forms WHERE slug = ? AND id <> ?)", candidate, formID).Scan(&taken)
+	return taken, err
+}
+
+// uniqueSlug derives an unused slug from a form title, preferring the English title and
+// transliterating the Arabic one otherwise
+func uniqueSlug(q querier, title MultiLanguageText, formID int) (string, error) {
+	base := slug.Make(title["en"])
+	if base == "" {
+		base = slug.Make(title["ar"])
+	}
+	if base == "" {
+		base = "form"
+	} else if !slug.Valid(base) {
+		// All digits would read as a numeric form ID
+		base = "form-" + base
+	}
+	for n := 1; ; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		taken, err := slugTaken(q, candidate, formID)
+		if err != nil || !taken {
+			return candidate, err
+		}
+	}
+}
+
+// checkCustomSlug validates an admin-chosen slug, writing the error response when it
+// cannot be used
+func checkCustomSlug(w http.ResponseWriter, candidate string, formID int) bool {
+	if !slug.Valid(candidate) {
+		http.Error(w, "Slug must be lowercase letters, digits and single hyphens, and not only digits", http.StatusBadRequest)
+		return false
+	}
+	taken, err := slugTaken(db, candidate, formID)
+	if err != nil {
+		log.Printf("Error checking slug: %v", err)
+		http.Error(w, "Error checking slug", http.StatusInternalServerError)
+		return false
+	}
+	if taken {
+		http.Error(w, "Slug is already in use", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// loadPublicForm resolves the reference respondents use to reach a form: its public
+// token, its slug or, unless the form disabled it, its numeric ID
+func loadPublicForm(ref string) (Form, error) {
+	if id, err := strconv.Atoi(ref); err == nil {
+		form, err := scanForm(db.QueryRow("SELECT "+formColumns+" FROM forms WHERE id = ? AND is_active = true", id))
+		if err == nil && form.Settings.DisableNumericAccess {
+			return Form{}, sql.ErrNoRows
+		}
+		return form, err
+	}
+	return scanForm(db.QueryRow("SELECT "+formColumns+" FROM forms WHERE (public_token = ? OR slug = ?) AND is_active = true", ref, ref))
+}
+
+// Get a form for respondents by token, slug or numeric ID (/api/public/forms/{ref})
+func getPublicFormHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ref := strings.TrimPrefix(r.URL.Path, "/api/public/forms/")
+	if ref == "" || strings.Contains(ref, "/") {
+		http.Error(w, "Invalid form reference", http.StatusBadRequest)
+		return
+	}
+	form, err := loadPublicForm(ref)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching public form: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+	// Whoever holds only the slug must not learn the share token, and respondents must
+	// not see the answer key or how calculated fields and score bands are worked out
+	form.PublicToken = ""
+	form.Fields = formdef.RespondentView(form.Fields)
+	// Nor who is notified of responses, or how the emails are worded
+	form.Settings.Receipt, form.Settings.Notification, form.Settings.NotifyEmails = nil, nil, nil
+	// Each funnel session (?session=) sees its own, stable order of shuffled questions
+	// and options; the same order is stored with its response
+	form.Fields, _ = formdef.Shuffle(form.Fields, form.Pages, r.URL.Query().Get("session"))
+	if form.Quiz != nil {
+		form.Quiz = &FormQuiz{}
+	}
+	seats, err := optionSeats(form.ID, form.Fields)
+	if err != nil {
+		log.Printf("Error counting seats for form %d: %v", form.ID, err)
+	}
+	for _, s := range seats {
+		form.Seats = append(form.Seats, OptionSeats{FieldID: s.FieldID, Option: s.Option, Full: s.Full})
+	}
+	// Availability changes without a new version, so forms with capacities aren't cached
+	if len(form.Seats) > 0 {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(form)
+		return
+	}
+
+	// Let clients keep a cached copy and revalidate it with If-None-Match
+	w.Header().Set("Cache-Control", "no-cache")
+	writeForm(w, r, form)
+}
+
+// Replace a form's public token, invalidating links that use the old one
+func rotateFormTokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/token/rotate")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+
+	token, err := newPublicToken()
+	if err != nil {
+		http.Error(w, "Error generating token", http.StatusInternalServerError)
+		return
+	}
+	result, err := db.Exec("UPDATE forms SET public_token = ?, version = version + 1, updated_at = NOW() WHERE id = ? AND workspace_id = ?", token, formID, workspaceID)
+	if err != nil {
+		log.Printf("Error rotating token of form %d: %v", formID, err)
+		http.Error(w, "Error rotating token", http.StatusInternalServerError)
+		return
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	}
+	// The token itself is a secret and stays out of the audit log
+	recordAudit(r, "form.token_rotate", "form", formID, nil, nil, nil)
+
+	form, err := loadForm(formID)
+	if err != nil {
+		log.Printf("Error fetching form: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+	writeForm(w, r, form)
+}
+
+// FormDraft is a respondent's unfinished response, saved page by page so it can be
+// resumed with its resume token or, when it has a phone number, by verifying that number
+type FormDraft struct {
+	ID           int                    `json:"-"`
+	FormID       int                    `json:"formId"`
+	WorkspaceID  int                    `json:"-"`
+	PhoneNumber  string                 `json:"phoneNumber,omitempty"`
+	PageID       string                 `json:"pageId"`
+	ResponseData map[string]interface{} `json:"responseData"`
+	Language     string                 `json:"language"`
+	UpdatedAt    time.Time              `json:"updatedAt"`
+	ExpiresAt    time.Time              `json:"expiresAt"`
+	// ResumeToken is only sent when it is issued; the database keeps its hash
+	ResumeToken string `json:"resumeToken,omitempty"`
+}
+
+// draftColumns lists the form_drafts columns read by scanDraft, in scan order
+const draftColumns = "id, form_id, workspace_id, phone_number, page_id, response_data, language, updated_at, expires_at"
+
+// scanDraft reads a form_drafts row selected with draftColumns. Like scanResponse it
+// returns stored values; revealDraft decrypts them for the respondent.
+func scanDraft(row rowScanner) (FormDraft, error) {
+	var draft FormDraft
+	var phoneNumber, pageID sql.NullString
+	var responseDataJSON []byte
+	err := row.Scan(
+		&draft.ID, &draft.FormID, &draft.WorkspaceID, &phoneNumber, &pageID, &responseDataJSON,
+		&draft.Language, &draft.UpdatedAt, &draft.ExpiresAt,
+	)
+	if err != nil {
+		return draft, err
+	}
+	if err := json.Unmarshal(responseDataJSON, &draft.ResponseData); err != nil {
+		return draft, fmt.Errorf("parsing draft data: %w", err)
+	}
+	if draft.ResponseData == nil {
+		draft.ResponseData = make(map[string]interface{})
+	}
+	draft.PhoneNumber = phoneNumber.String
+	draft.PageID = pageID.String
+	return draft, nil
+}
+
+// revealDraft decrypts a draft for the respondent who holds it
+func revealDraft(draft *FormDraft, fields []FormField) {
+	if draft.PhoneNumber != "" {
+		draft.PhoneNumber = revealStoredContact(draft.PhoneNumber, "phone number", true)
+	}
+	revealAnswers(fields, draft.ResponseData, true)
+}
+
+// draftTTL is how long a draft is kept after it was last saved (DRAFT_TTL, default a week)
+func draftTTL() time.Duration {
+	return envDuration("DRAFT_TTL", 7*24*time.Hour)
+}
+
+// draftTokenHash is what the database stores instead of a resume token, so a leaked
+// table can't be used to resume anyone's draft
+func draftTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// loadDraft finds the unexpired draft a resume token belongs to
+func loadDraft(q querier, token string) (FormDraft, error) {
+	return scanDraft(q.QueryRow("SELECT "+draftColumns+" FROM form_drafts WHERE resume_token_hash = ? AND expires_at > NOW()", draftTokenHash(token)))
+}
+
+// checkOpenForm writes the error for a form respondents can't reach or that no longer
+// accepts responses, given the result of loading it
+func checkOpenForm(w http.ResponseWriter, form Form, err error) bool {
+	if err == sql.ErrNoRows || (err == nil && !form.IsActive) {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return false
+	} else if err != nil {
+		log.Printf("Error fetching form: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return false
+	}
+	// Closed forms no longer accept responses
+	if form.ClosesAt != nil && time.Now().After(*form.ClosesAt) {
+		http.Error(w, "Form is closed", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// publicFormRef is the form a public request names: formRef is the slug or token from
+// the public link, formId the legacy numeric ID
+func publicFormRef(formRef string, formID int) string {
+	if ref := strings.TrimSpace(formRef); ref != "" {
+		return ref
+	}
+	return strconv.Itoa(formID)
+}
+
+// draftRequest is the body that starts or saves a draft: the answers to one page
+type draftRequest struct {
+	FormID       int                    `json:"formId"`
+	FormRef      string                 `json:"formRef"`
+	PageID       string                 `json:"pageId"`
+	ResponseData map[string]interface{} `json:"responseData"`
+	Language     string                 `json:"language"`
+	PhoneNumber  string                 `json:"phoneNumber"`
+}
+
+// applyDraftPage validates the answers to the fields on the requested page, and only
+// those, and stores them in draft in place of the page's previous answers. Answers to
+// other pages are checked when the draft is submitted. Errors are written to w.
+func applyDraftPage(w http.ResponseWriter, form Form, draft *FormDraft, request draftRequest) bool {
+	pageFields, ok := formdef.PageFields(form.Fields, form.Pages, request.PageID)
+	if !ok {
+		writeValidationErrors(w, formdef.Errors{{Path: "pageId", Message: fmt.Sprintf("unknown page %q", request.PageID)}})
+		return false
+	}
+	answers := make(map[string]interface{}, len(pageFields))
+	for _, field := range pageFields {
+		if value, ok := request.ResponseData[field.ID]; ok {
+			answers[field.ID] = value
+		}
+	}
+	if errs := formdef.NormalizeResponse(pageFields, answers); len(errs) > 0 {
+		writeValidationErrors(w, errs)
+		return false
+	}
+	for _, field := range pageFields {
+		if value, ok := answers[field.ID]; ok {
+			draft.ResponseData[field.ID] = value
+		} else {
+			delete(draft.ResponseData, field.ID)
+		}
+	}
+	draft.PageID = request.PageID
+	if request.Language != "" {
+		draft.Language = request.Language
+	}
+	// Forms that don't ask for a phone number can only be resumed by token
+	if request.PhoneNumber != "" && form.Settings.asksPhone() {
+		draft.PhoneNumber = request.PhoneNumber
+	}
+	return true
+}
+
+// encodeDraft encrypts a copy of the draft's phone number and sensitive answers for storage
+func encodeDraft(fields []FormField, draft FormDraft) (storedPhone, []byte, error) {
+	var stored storedPhone
+	if draft.PhoneNumber != "" {
+		var err error
+		if stored, err = protectPhone(draft.PhoneNumber); err != nil {
+			return stored, nil, err
+		}
+	}
+	data := make(map[string]interface{}, len(draft.ResponseData))
+	for key, value := range draft.ResponseData {
+		data[key] = value
+	}
+	if err := encryptSensitiveAnswers(fields, data); err != nil {
+		return stored, nil, err
+	}
+	dataJSON, err := json.Marshal(data)
+	return stored, dataJSON, err
+}
+
+// Start a draft with the answers to its first page (POST /api/drafts). The response
+// carries the resume token, which is never shown again.
+func createDraftHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request draftRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	form, err := loadPublicForm(publicFormRef(request.FormRef, request.FormID))
+	if !checkOpenForm(w, form, err) {
+		return
+	}
+
+	draft := FormDraft{FormID: form.ID, WorkspaceID: form.WorkspaceID, ResponseData: make(map[string]interface{}), Language: "en"}
+	if !applyDraftPage(w, form, &draft, request) {
+		return
+	}
+	stored, dataJSON, err := encodeDraft(form.Fields, draft)
+	if err != nil {
+		log.Printf("Error encoding draft: %v", err)
+		http.Error(w, "Error saving draft", http.StatusInternalServerError)
+		return
+	}
+	token, err := newPublicToken()
+	if err != nil {
+		http.Error(w, "Error saving draft", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	draft.UpdatedAt = now
+	draft.ExpiresAt = now.Add(draftTTL())
+	_, err = db.Exec(`
+		INSERT INTO form_drafts (form_id, workspace_id, resume_token_hash, phone_number, phone_normalized, phone_blind_index, page_id, response_data, language, created_at, updated_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, form.ID, form.WorkspaceID, draftTokenHash(token), sql.NullString{String: stored.Number, Valid: stored.Number != ""},
+		stored.Normalized, stored.BlindIndex, draft.PageID, dataJSON, draft.Language, now, now, draft.ExpiresAt)
+	if err != nil {
+		log.Printf("Error saving draft: %v", err)
+		http.Error(w, "Error saving draft", http.StatusInternalServerError)
+		return
+	}
+
+	draft.ResumeToken = token
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// Drafts addressed by resume token, and resuming by phone:
+//   GET    /api/drafts/{token}      the saved answers
+//   PUT    /api/drafts/{token}      save a page, extending the draft's expiry
+//   DELETE /api/drafts/{token}      discard the draft
+//   POST   /api/drafts/otp          send a code to a phone number with drafts
+//   POST   /api/drafts/otp/verify   exchange the code for the latest draft
+func draftHandler(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/drafts/")
+	switch path {
+	case "otp":
+		requestDraftCodeHandler(w, r)
+		return
+	case "otp/verify":
+		verifyDraftCodeHandler(w, r)
+		return
+	}
+	if path == "" || strings.Contains(path, "/") {
+		http.Error(w, "Invalid resume token", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		if _, err := db.Exec("DELETE FROM form_drafts WHERE resume_token_hash = ?", draftTokenHash(path)); err != nil {
+			log.Printf("Error deleting draft: %v", err)
+			http.Error(w, "Error deleting draft", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if r.Method != "GET" && r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	draft, err := loadDraft(db, path)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Draft not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching draft: %v", err)
+		http.Error(w, "Error fetching draft", http.StatusInternalServerError)
+		return
+	}
+	form, err := loadForm(draft.FormID)
+	if !checkOpenForm(w, form, err) {
+		return
+	}
+	revealDraft(&draft, form.Fields)
+
+	if r.Method == "PUT" {
+		var request draftRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if !applyDraftPage(w, form, &draft, request) {
+			return
+		}
+		stored, dataJSON, err := encodeDraft(form.Fields, draft)
+		if err != nil {
+			log.Printf("Error encoding draft: %v", err)
+			http.Error(w, "Error saving draft", http.StatusInternalServerError)
+			return
+		}
+		now := time.Now()
+		draft.UpdatedAt = now
+		draft.ExpiresAt = now.Add(draftTTL())
+		result, err := db.Exec(`
+			UPDATE form_drafts
+			SET phone_number = ?, phone_normalized = ?, phone_blind_index = ?, page_id = ?, response_data = ?, language = ?, updated_at = ?, expires_at = ?
+			WHERE id = ?
+		`, sql.NullString{String: stored.Number, Valid: stored.Number != ""}, stored.Normalized, stored.BlindIndex,
+			draft.PageID, dataJSON, draft.Language, now, draft.ExpiresAt, draft.ID)
+		if err != nil {
+			log.Printf("Error saving draft %d: %v", draft.ID, err)
+			http.Error(w, "Error saving draft", http.StatusInternalServerError)
+			return
+		}
+		// The draft was submitted or expired since we read it
+		if n, _ := result.RowsAffected(); n == 0 {
+			http.Error(w, "Draft not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(draft)
+}
+
+// maxVerificationAttempts is how many wrong codes end a verification
+const maxVerificationAttempts = 5
+
+// errVerificationUnavailable means no way of delivering codes is configured
+var errVerificationUnavailable = errors.New("phone verification is not configured")
+
+// verificationConfigured reports whether codes can be delivered
+func verificationConfigured() bool {
+	logCodes, _ := strconv.ParseBool(os.Getenv("OTP_LOG_CODES"))
+	return os.Getenv("OTP_WEBHOOK_URL") != "" || logCodes
+}
+
+// sendVerificationCode delivers a code through the SMS gateway at OTP_WEBHOOK_URL, which
+// is sent {"phoneNumber", "code", "formId"}. For development OTP_LOG_CODES=true writes
+// codes to the log instead.
+func sendVerificationCode(phoneNumber, code string, formID int) error {
+	url := os.Getenv("OTP_WEBHOOK_URL")
+	if url == "" {
+		if !verificationConfigured() {
+			return errVerificationUnavailable
+		}
+		log.Printf("Verification code for %s on form %d: %s", phoneNumber, formID, code)
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{"phoneNumber": phoneNumber, "code": code, "formId": formID})
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("SMS gateway answered %s", resp.Status)
+	}
+	return nil
+}
+
+// errMailUnavailable is returned when no mail gateway is configured
+var errMailUnavailable = errors.New("MAIL_WEBHOOK_URL is not configured")
+
+// outgoingEmail is a rendered receipt or notification
+type outgoingEmail struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Body    string   `json:"body"`
+}
+
+// renderEmail renders a template in language with the answers piped in. Line breaks are
+// taken out of the subject, which answers could otherwise use to add mail headers.
+func renderEmail(t *EmailTemplate, fields []FormField, answers map[string]interface{}, language string, to []string) outgoingEmail {
+	subject := formdef.Render(formdef.TextIn(t.Subject, language), fields, answers, language)
+	return outgoingEmail{
+		To:      to,
+		Subject: strings.Join(strings.Fields(subject), " "),
+		Body:    formdef.Render(formdef.TextIn(t.Body, language), fields, answers, language),
+	}
+}
+
+// sendResponseEmails sends the form's receipt to the respondent and its notification to
+// NotifyEmails for a stored response, whose answers must already be decrypted. They use
+// the same {{fieldId}} interpolation as the form, with {{score}} and {{maxScore}} on
+// quizzes. Delivery happens in the background; failures are only logged.
+func sendResponseEmails(form Form, response FormResponse, language string) {
+	settings := form.Settings
+	if settings.Receipt == nil && settings.Notification == nil {
+		return
+	}
+	answers := make(map[string]interface{}, len(response.ResponseData)+2)
+	for id, value := range response.ResponseData {
+		answers[id] = value
+	}
+	if response.Result != nil {
+		answers["score"], answers["maxScore"] = response.Result.Score, response.Result.MaxScore
+	}
+	var emails []outgoingEmail
+	if settings.Receipt != nil && response.Email != "" {
+		emails = append(emails, renderEmail(settings.Receipt, form.Fields, answers, language, []string{response.Email}))
+	}
+	if settings.Notification != nil && len(settings.NotifyEmails) > 0 {
+		emails = append(emails, renderEmail(settings.Notification, form.Fields, answers, supportedLocales()[0], settings.NotifyEmails))
+	}
+	go func() {
+		for _, email := range emails {
+			if err := sendEmail(form.ID, response.ID, email); err != nil {
+				log.Printf("Error emailing response %d of form %d: %v", response.ID, form.ID, err)
+			}
+		}
+	}()
+}
+
+// sendEmail delivers an email through the mail gateway at MAIL_WEBHOOK_URL, which is sent
+// {"to", "subject", "body", "formId", "responseId"}
+func sendEmail(formID, responseID int, email outgoingEmail) error {
+	url := os.Getenv("MAIL_WEBHOOK_URL")
+	if url == "" {
+		return errMailUnavailable
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"to":         email.To,
+		"subject":    email.Subject,
+		"body":       email.Body,
+		"formId":     formID,
+		"responseId": responseID,
+	})
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("mail gateway answered %s", resp.Status)
+	}
+	return nil
+}
+
+// newVerificationCode returns a random six-digit code
+func newVerificationCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// phoneKey identifies a normalized phone number in draft_verifications without storing
+// it: the blind index when encryption is on, a plain hash otherwise
+func phoneKey(normalized string) string {
+	if keyring != nil {
+		return keyring.BlindIndex(normalized)
+	}
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// verificationCodeHash is what the database stores instead of a code
+func verificationCodeHash(key, code string) string {
+	sum := sha256.Sum256([]byte(key + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
+
+// verificationRequest names the form and phone number a code is for
+type verificationRequest struct {
+	FormID      int    `json:"formId"`
+	FormRef     string `json:"formRef"`
+	PhoneNumber string `json:"phoneNumber"`
+	Code        string `json:"code"`
+}
+
+// Send a verification code to a phone number that saved drafts on the form. The answer
+// is the same whether or not there are drafts so numbers can't be probed.
+func requestDraftCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !verificationConfigured() {
+		http.Error(w, "Resuming by phone is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var request verificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	normalized := phone.Normalize(request.PhoneNumber)
+	if normalized == "" {
+		http.Error(w, "Phone number is required", http.StatusBadRequest)
+		return
+	}
+	form, err := loadPublicForm(publicFormRef(request.FormRef, request.FormID))
+	if !checkOpenForm(w, form, err) {
+		return
+	}
+	key := phoneKey(normalized)
+
+	// One code a minute per number keeps the SMS gateway from being used to spam it
+	var recent bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM draft_verifications WHERE form_id = ? AND phone_key = ? AND created_at > NOW() - INTERVAL 1 MINUTE)",
+		form.ID, key).Scan(&recent)
+	if err != nil {
+		log.Printf("Error checking verification codes: %v", err)
+		http.Error(w, "Error sending code", http.StatusInternalServerError)
+		return
+	}
+	if recent {
+		http.Error(w, "Please wait a minute before requesting another code", http.StatusTooManyRequests)
+		return
+	}
+
+	match, args := phoneMatch("", normalized)
+	var hasDraft bool
+	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM form_drafts WHERE form_id = ? AND expires_at > NOW() AND "+match+")",
+		append([]interface{}{form.ID}, args...)...).Scan(&hasDraft)
+	if err != nil {
+		log.Printf("Error checking drafts: %v", err)
+		http.Error(w, "Error sending code", http.StatusInternalServerError)
+		return
+	}
+	code, codeHash := "", ""
+	if hasDraft {
+		if code, err = newVerificationCode(); err != nil {
+			http.Error(w, "Error sending code", http.StatusInternalServerError)
+			return
+		}
+		codeHash = verificationCodeHash(key, code)
+	}
+	// Every request is recorded, with an empty code_hash when there is no draft to send a
+	// code for, so the rate limit answers alike whether or not the number has a draft.
+	// A new row replaces any earlier code.
+	if _, err := db.Exec("DELETE FROM draft_verifications WHERE form_id = ? AND phone_key = ?", form.ID, key); err != nil {
+		log.Printf("Error replacing verification code: %v", err)
+		http.Error(w, "Error sending code", http.StatusInternalServerError)
+		return
+	}
+	_, err = db.Exec(`
+		INSERT INTO draft_verifications (form_id, phone_key, code_hash, attempts, created_at, expires_at)
+		VALUES (?, ?, ?, 0, NOW(), ?)
+	`, form.ID, key, codeHash, time.Now().Add(envDuration("OTP_TTL", 10*time.Minute)))
+	if err != nil {
+		log.Printf("Error storing verification code: %v", err)
+		http.Error(w, "Error sending code", http.StatusInternalServerError)
+		return
+	}
+	if hasDraft {
+		if err := sendVerificationCode(request.PhoneNumber, code, form.ID); err != nil {
+			log.Printf("Error sending verification code: %v", err)
+			http.Error(w, "Error sending code", http.StatusBadGateway)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "sent"})
+}
+
+// Exchange a verification code for the phone number's most recently saved draft. The
+// draft gets a new resume token, which replaces the one it was saved with.
+func verifyDraftCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request verificationRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	normalized := phone.Normalize(request.PhoneNumber)
+	if normalized == "" || strings.TrimSpace(request.Code) == "" {
+		http.Error(w, "Phone number and code are required", http.StatusBadRequest)
+		return
+	}
+	form, err := loadPublicForm(publicFormRef(request.FormRef, request.FormID))
+	if !checkOpenForm(w, form, err) {
+		return
+	}
+	key := phoneKey(normalized)
+
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Error verifying code", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Lock the code so concurrent guesses are counted one at a time
+	var verificationID, attempts int
+	var codeHash string
+	err = tx.QueryRow(`
+		SELECT id, code_hash, attempts FROM draft_verifications
+		WHERE form_id = ? AND phone_key = ? AND expires_at > NOW()
+		FOR UPDATE
+	`, form.ID, key).Scan(&verificationID, &codeHash, &attempts)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("Error fetching verification code: %v", err)
+		http.Error(w, "Error verifying code", http.StatusInternalServerError)
+		return
+	}
+	// An empty code_hash only records a request for a number without a draft
+	if err == sql.ErrNoRows || codeHash == "" || attempts >= maxVerificationAttempts {
+		http.Error(w, "Invalid or expired code", http.StatusUnauthorized)
+		return
+	}
+	if !hmac.Equal([]byte(codeHash), []byte(verificationCodeHash(key, strings.TrimSpace(request.Code)))) {
+		if _, err := tx.Exec("UPDATE draft_verifications SET attempts = attempts + 1 WHERE id = ?", verificationID); err == nil {
+			tx.Commit()
+		}
+		http.Error(w, "Invalid or expired code", http.StatusUnauthorized)
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM draft_verifications WHERE id = ?", verificationID); err != nil {
+		log.Printf("Error consuming verification code: %v", err)
+		http.Error(w, "Error verifying code", http.StatusInternalServerError)
+		return
+	}
+
+	match, args := phoneMatch("", normalized)
+	draft, err := scanDraft(tx.QueryRow("SELECT "+draftColumns+" FROM form_drafts WHERE form_id = ? AND expires_at > NOW() AND "+match+" ORDER BY updated_at DESC LIMIT 1 FOR UPDATE",
+		append([]interface{}{form.ID}, args...)...))
+	if err == sql.ErrNoRows {
+		tx.Commit()
+		http.Error(w, "No saved draft for this phone number", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching draft: %v", err)
+		http.Error(w, "Error fetching draft", http.StatusInternalServerError)
+		return
+	}
+	token, err := newPublicToken()
+	if err != nil {
+		http.Error(w, "Error verifying code", http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec("UPDATE form_drafts SET resume_token_hash = ? WHERE id = ?", draftTokenHash(token), draft.ID); err != nil {
+		log.Printf("Error issuing resume token for draft %d: %v", draft.ID, err)
+		http.Error(w, "Error verifying code", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error verifying code: %v", err)
+		http.Error(w, "Error verifying code", http.StatusInternalServerError)
+		return
+	}
+
+	revealDraft(&draft, form.Fields)
+	draft.ResumeToken = token
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(draft)
+}
+
+// purgeExpiredDrafts deletes drafts and verification codes past their expiry
+func purgeExpiredDrafts() error {
+	result, err := db.Exec("DELETE FROM form_drafts WHERE expires_at < NOW()")
+	if err != nil {
+		return fmt.Errorf("deleting expired drafts: %w", err)
+	}
+	if _, err := db.Exec("DELETE FROM draft_verifications WHERE expires_at < NOW()"); err != nil {
+		return fmt.Errorf("deleting expired verification codes: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n > 0 {
+		log.Printf("Purged %d expired drafts", n)
+	}
+	return nil
+}
+
+// searchMinTokenLength mirrors innodb_ft_min_token_size; shorter terms are matched with
+// LIKE because FULLTEXT never indexes them
+func searchMinTokenLength() int {
+	if v, err := strconv.Atoi(os.Getenv("SEARCH_FT_MIN_TOKEN_SIZE")); err == nil && v > 0 {
+		return v
+	}
+	return 3
+}
+
+// searchDocument builds the normalized text indexed for a response. Sensitive fields are
+// left out so encrypted answers never leak into the index.
+func searchDocument(fields []FormField, data map[string]interface{}) string {
+	var tokens []string
+	for _, field := range fields {
+		if !formdef.TypeOf(field).Describe().Searchable || field.Sensitive {
+			continue
+		}
+		if s, ok := data[field.ID].(string); ok {
+			tokens = append(tokens, arabic.Tokenize(s)...)
+		}
+	}
+	if len(tokens) == 0 {
+		return ""
+	}
+	// The leading space lets short terms match word prefixes with LIKE '% term%'
+	return " " + strings.Join(tokens, " ")
+}
+
+// indexResponse stores or replaces the search document of a response
+func indexResponse(q querier, responseID, formID int, fields []FormField, data map[string]interface{}) error {
+	document := searchDocument(fields, data)
+	if document == "" {
+		_, err := q.Exec("DELETE FROM response_search_documents WHERE response_id = ?", responseID)
+		return err
+	}
+	_, err := q.Exec(`
+		INSERT INTO response_search_documents (response_id, form_id, content)
+		VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE content = VALUES(content)
+	`, responseID, formID, document)
+	return err
+}
+
+// SearchResult is a matching response with its relevance and highlighted answers
+type SearchResult struct {
+	Response   FormResponse      `json:"response"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// Search a form's text answers. Arabic spelling variants and digits are normalized on
+// both sides, every term must match a word prefix, and results are ranked by relevance.
+func searchResponsesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/responses/search")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+	terms := arabic.Tokenize(r.URL.Query().Get("q"))
+	if len(terms) == 0 {
+		http.Error(w, "Search query is required", http.StatusBadRequest)
+		return
+	}
+	page := 1
+	pageSize := 20
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(r.URL.Query().Get("pageSize")); err == nil && ps > 0 && ps <= 100 {
+		pageSize = ps
+	}
+
+	fields, err := workspaceFormFields(workspaceID, formID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching form fields: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+
+	where := "form_id = ?"
+	whereArgs := []interface{}{formID}
+	var booleanTerms []string
+	minLength := searchMinTokenLength()
+	for _, term := range terms {
+		if utf8.RuneCountInString(term) >= minLength {
+			booleanTerms = append(booleanTerms, "+"+term+"*")
+		} else {
+			where += " AND content LIKE ?"
+			whereArgs = append(whereArgs, "% "+term+"%")
+		}
+	}
+	score := "0"
+	var scoreArgs []interface{}
+	if len(booleanTerms) > 0 {
+		booleanQuery := strings.Join(booleanTerms, " ")
+		where += " AND MATCH(content) AGAINST(? IN BOOLEAN MODE)"
+		whereArgs = append(whereArgs, booleanQuery)
+		score = "MATCH(content) AGAINST(? IN BOOLEAN MODE)"
+		scoreArgs = append(scoreArgs, booleanQuery)
+	}
+
+	var totalCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM response_search_documents WHERE "+where, whereArgs...).Scan(&totalCount); err != nil {
+		log.Printf("Error counting search results: %v", err)
+		http.Error(w, "Error searching responses", http.StatusInternalServerError)
+		return
+	}
+
+	args := append(append(scoreArgs, whereArgs...), pageSize, (page-1)*pageSize)
+	rows, err := db.Query(`
+		SELECT response_id, `+score+` AS score
+		FROM response_search_documents
+		WHERE `+where+`
+		ORDER BY score DESC, response_id DESC
+		LIMIT ? OFFSET ?
+	`, args...)
+	if err != nil {
+		log.Printf("Error searching responses: %v", err)
+		http.Error(w, "Error searching responses", http.StatusInternalServerError)
+		return
+	}
+	var ids []interface{}
+	scores := make(map[int]float64)
+	for rows.Next() {
+		var id int
+		var s float64
+		if err := rows.Scan(&id, &s); err != nil {
+			rows.Close()
+			http.Error(w, "Error scanning search results", http.StatusInternalServerError)
+			return
+		}
+		ids = append(ids, id)
+		scores[id] = s
+	}
+	rows.Close()
+
+	results := []SearchResult{}
+	if len(ids) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+		rows, err := db.Query("SELECT "+responseColumns+" FROM form_responses WHERE id IN ("+placeholders+")", ids...)
+		if err != nil {
+			log.Printf("Error fetching search results: %v", err)
+			http.Error(w, "Error searching responses", http.StatusInternalServerError)
+			return
+		}
+		byID := make(map[int]FormResponse)
+		for rows.Next() {
+			response, err := scanResponse(rows)
+			if err != nil {
+				rows.Close()
+				log.Printf("Error scanning response: %v", err)
+				http.Error(w, "Error scanning response", http.StatusInternalServerError)
+				return
+			}
+			byID[response.ID] = response
+		}
+		rows.Close()
+
+		allowPII := canReadPII(r)
+		for _, id := range ids {
+			response, ok := byID[id.(int)]
+			if !ok {
+				continue
+			}
+			revealContact(&response, allowPII)
+			revealAnswers(fields, response.ResponseData, allowPII)
+			revealMetadata(&response.Metadata, allowPII)
+
+			highlights := make(map[string]string)
+			for _, field := range fields {
+				if !formdef.TypeOf(field).Describe().Searchable || field.Sensitive {
+					continue
+				}
+				if s, ok := response.ResponseData[field.ID].(string); ok {
+					if highlighted, matched := arabic.Highlight(s, terms, "<mark>", "</mark>"); matched {
+						highlights[field.ID] = highlighted
+					}
+				}
+			}
+			results = append(results, SearchResult{Response: response, Score: scores[response.ID], Highlights: highlights})
+		}
+	}
+	recordAudit(r, "response.view", "form", formID, nil, nil, map[string]interface{}{
+		"search": true,
+		"count":  len(results),
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"query":      r.URL.Query().Get("q"),
+		"data":       results,
+		"totalCount": totalCount,
+		"page":       page,
+		"pageSize":   pageSize,
+		"totalPages": (totalCount + pageSize - 1) / pageSize,
+	})
+}
+
+// reindexBatchSize bounds how many responses the reindex loads per query; it is separate
+// from the retention batch as rebuilding documents is far heavier than a delete
+func reindexBatchSize() int {
+	if v, err := strconv.Atoi(os.Getenv("SEARCH_REINDEX_BATCH_SIZE")); err == nil && v > 0 {
+		return v
+	}
+	return 200
+}
+
+// Rebuild the search index of a form, e.g. after the migration or after changing which
+// fields are sensitive. Responses that fail are logged and counted in "failed".
+func reindexResponsesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/forms/"), "/responses/search/reindex")
+	formID, err := strconv.Atoi(path)
+	if err != nil {
+		http.Error(w, "Invalid form ID", http.StatusBadRequest)
+		return
+	}
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+	fields, err := workspaceFormFields(workspaceID, formID)
+	if err == sql.ErrNoRows {
+		http.Error(w, "Form not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching form fields: %v", err)
+		http.Error(w, "Error fetching form", http.StatusInternalServerError)
+		return
+	}
+
+	indexed, failed, lastID := 0, 0, 0
+	batchSize := reindexBatchSize()
+	for {
+		rows, err := db.Query("SELECT id, response_data FROM form_responses WHERE form_id = ? AND id > ? ORDER BY id LIMIT ?", formID, lastID, batchSize)
+		if err != nil {
+			log.Printf("Error fetching responses to reindex: %v", err)
+			http.Error(w, "Error reindexing responses", http.StatusInternalServerError)
+			return
+		}
+		batch := make(map[int]map[string]interface{})
+		read := 0
+		for rows.Next() {
+			read++
+			var id int
+			var dataJSON []byte
+			if err := rows.Scan(&id, &dataJSON); err != nil {
+				log.Printf("Error reading response to reindex: %v", err)
+				failed++
+				continue
+			}
+			if id > lastID {
+				lastID = id
+			}
+			var data map[string]interface{}
+			if err := json.Unmarshal(dataJSON, &data); err != nil {
+				log.Printf("Error decoding response %d to reindex: %v", id, err)
+				failed++
+				continue
+			}
+			batch[id] = data
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			log.Printf("Error fetching responses to reindex: %v", err)
+			http.Error(w, "Error reindexing responses", http.StatusInternalServerError)
+			return
+		}
+		if read == 0 {
+			break
+		}
+		for id, data := range batch {
+			if err := indexResponse(db, id, formID, fields, data); err != nil {
+				log.Printf("Error indexing response %d: %v", id, err)
+				failed++
+				continue
+			}
+			indexed++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"formId": formID, "indexed": indexed, "failed": failed})
+}
+
+// adminActor identifies the admin making a request. Authentication happens upstream;
+// the gateway forwards the signed-in user in the X-Admin-User header.
+func adminActor(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get("X-Admin-User"))
+}
+
+// defaultWorkspaceID owns every form created before workspaces existed. Requests
+// without X-Workspace-ID act on it, which keeps the existing admin UI working.
+const defaultWorkspaceID = 1
+
+// errQuotaExceeded is returned when a workspace has used up a quota
+var errQuotaExceeded = errors.New("workspace quota exceeded")
+
+// workspaceRoles are the membership roles; owners also manage the member list
+var workspaceRoles = map[string]bool{
+	"owner":  true,
+	"member": true,
+}
+
+// Workspace is a business unit owning its own forms and responses. A nil quota is
+// unlimited.
+type Workspace struct {
+	ID                  int             `json:"id"`
+	Name                string          `json:"name"`
+	MaxForms            *int            `json:"maxForms"`
+	MaxMonthlyResponses *int            `json:"maxMonthlyResponses"`
+	CreatedAt           time.Time       `json:"createdAt"`
+	UpdatedAt           time.Time       `json:"updatedAt"`
+	Usage               *WorkspaceUsage `json:"usage,omitempty"`
+}
+
+// WorkspaceUsage is how much of its quotas a workspace has used
+type WorkspaceUsage struct {
+	Forms              int    `json:"forms"`
+	Period             string `json:"period"`
+	ResponsesThisMonth int    `json:"responsesThisMonth"`
+}
+
+// WorkspaceMember grants an admin user access to a workspace
+type WorkspaceMember struct {
+	WorkspaceID int       `json:"workspaceId"`
+	User        string    `json:"user"`
+	Role        string    `json:"role"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+const workspaceColumns = "id, name, max_forms, max_monthly_responses, created_at, updated_at"
+
+func scanWorkspace(row rowScanner) (Workspace, error) {
+	var ws Workspace
+	var maxForms, maxResponses sql.NullInt64
+	if err := row.Scan(&ws.ID, &ws.Name, &maxForms, &maxResponses, &ws.CreatedAt, &ws.UpdatedAt); err != nil {
+		return ws, err
+	}
+	if maxForms.Valid {
+		n := int(maxForms.Int64)
+		ws.MaxForms = &n
+	}
+	if maxResponses.Valid {
+		n := int(maxResponses.Int64)
+		ws.MaxMonthlyResponses = &n
+	}
+	return ws, nil
+}
+
+// usagePeriod is the monthly quota period a time falls in, in UTC
+func usagePeriod(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// workspaceUsage counts a workspace's live forms and this month's submissions
+func workspaceUsage(workspaceID int) (WorkspaceUsage, error) {
+	usage := WorkspaceUsage{Period: usagePeriod(time.Now())}
+	if err := db.QueryRow("SELECT COUNT(*) FROM forms WHERE workspace_id = ? AND is_active = true", workspaceID).Scan(&usage.Forms); err != nil {
+		return usage, err
+	}
+	err := db.QueryRow("SELECT responses FROM workspace_usage WHERE workspace_id = ? AND period = ?", workspaceID, usage.Period).Scan(&usage.ResponsesThisMonth)
+	if err != nil && err != sql.ErrNoRows {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// reserveFormSlot checks the form quota inside tx. The workspace row is locked so two
+// concurrent creates cannot both take the last slot.
+func reserveFormSlot(tx *sql.Tx, workspaceID int) error {
+	var maxForms sql.NullInt64
+	if err := tx.QueryRow("SELECT max_forms FROM workspaces WHERE id = ? FOR UPDATE", workspaceID).Scan(&maxForms); err != nil {
+		return err
+	}
+	if !maxForms.Valid {
+		return nil
+	}
+	var count int64
+	if err := tx.QueryRow("SELECT COUNT(*) FROM forms WHERE workspace_id = ? AND is_active = true", workspaceID).Scan(&count); err != nil {
+		return err
+	}
+	if count >= maxForms.Int64 {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// reserveResponse counts a submission against the monthly quota inside tx. The
+// conditional increment is atomic, so the quota holds under concurrent submissions.
+func reserveResponse(tx *sql.Tx, workspaceID int) error {
+	period := usagePeriod(time.Now())
+	if _, err := tx.Exec("INSERT IGNORE INTO workspace_usage (workspace_id, period, responses) VALUES (?, ?, 0)", workspaceID, period); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`
+		UPDATE workspace_usage u
+		JOIN workspaces w ON w.id = u.workspace_id
+		SET u.responses = u.responses + 1
+		WHERE u.workspace_id = ? AND u.period = ?
+			AND (w.max_monthly_responses IS NULL OR u.responses < w.max_monthly_responses)
+	`, workspaceID, period)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// roleAllowed reports whether the gateway-supplied X-Admin-Role is listed in the
+// comma-separated env var (def when unset)
+func roleAllowed(r *http.Request, envKey, def string) bool {
+	role := strings.TrimSpace(r.Header.Get("X-Admin-Role"))
+	allowed := os.Getenv(envKey)
+	if allowed == "" {
+		allowed = def
+	}
+	for _, candidate := range strings.Split(allowed, ",") {
+		if role != "" && strings.TrimSpace(candidate) == role {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlatformAdmin reports whether the caller may manage every workspace
+func isPlatformAdmin(r *http.Request) bool {
+	return roleAllowed(r, "WORKSPACE_ADMIN_ROLES", "admin")
+}
+
+// workspaceHeader returns the workspace named by X-Workspace-ID, or the default one.
+// EventSource cannot send headers, so a workspaceId query parameter is accepted too.
+func workspaceHeader(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("X-Workspace-ID"))
+	if header == "" {
+		header = strings.TrimSpace(r.URL.Query().Get("workspaceId"))
+	}
+	if header == "" {
+		return defaultWorkspaceID, nil
+	}
+	id, err := strconv.Atoi(header)
+	if err != nil || id <= 0 {
+		return 0, errors.New("invalid workspace ID")
+	}
+	return id, nil
+}
+
+// workspaceRole returns the caller's role in a workspace, or "" if not a member
+func workspaceRole(workspaceID int, user string) (string, error) {
+	var role string
+	err := db.QueryRow("SELECT role FROM workspace_members WHERE workspace_id = ? AND user = ?", workspaceID, user).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return role, err
+}
+
+// requestWorkspace resolves the workspace an admin request acts on and checks the
+// caller belongs to it, writing the error response otherwise. The default workspace
+// stays open to every admin; other workspaces require membership or a platform admin.
+func requestWorkspace(w http.ResponseWriter, r *http.Request) (int, bool) {
+	workspaceID, err := workspaceHeader(r)
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return 0, false
+	}
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM workspaces WHERE id = ?)", workspaceID).Scan(&exists); err != nil {
+		log.Printf("Error checking workspace %d: %v", workspaceID, err)
+		http.Error(w, "Error checking workspace", http.StatusInternalServerError)
+		return 0, false
+	}
+	if !exists {
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+		return 0, false
+	}
+	if workspaceID == defaultWorkspaceID || isPlatformAdmin(r) {
+		return workspaceID, true
+	}
+
+	actor := adminActor(r)
+	if actor == "" {
+		http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+		return 0, false
+	}
+	role, err := workspaceRole(workspaceID, actor)
+	if err != nil {
+		log.Printf("Error checking workspace membership: %v", err)
+		http.Error(w, "Error checking workspace", http.StatusInternalServerError)
+		return 0, false
+	}
+	if role == "" {
+		http.Error(w, "Not a member of this workspace", http.StatusForbidden)
+		return 0, false
+	}
+	return workspaceID, true
+}
+
+// formWorkspace returns the workspace owning a form, or sql.ErrNoRows
+func formWorkspace(q querier, formID int) (int, error) {
+	var workspaceID int
+	err := q.QueryRow("SELECT workspace_id FROM forms WHERE id = ?", formID).Scan(&workspaceID)
+	return workspaceID, err
+}
+
+// workspaceFormFields is formFields restricted to the caller's workspace; forms of
+// other workspaces report sql.ErrNoRows so they are indistinguishable from missing ones
+func workspaceFormFields(workspaceID, formID int) ([]FormField, error) {
+	owner, err := formWorkspace(db, formID)
+	if err != nil {
+		return nil, err
+	}
+	if owner != workspaceID {
+		return nil, sql.ErrNoRows
+	}
+	return formFields(db, formID)
+}
+
+// Manage workspaces: list and create. Platform admins only.
+func workspacesHandler(w http.ResponseWriter, r *http.Request) {
+	if !isPlatformAdmin(r) {
+		http.Error(w, "Platform admin role required", http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		rows, err := db.Query("SELECT " + workspaceColumns + " FROM workspaces ORDER BY id")
+		if err != nil {
+			log.Printf("Error fetching workspaces: %v", err)
+			http.Error(w, "Error fetching workspaces", http.StatusInternalServerError)
+			return
+		}
+		workspaces := []Workspace{}
+		for rows.Next() {
+			ws, err := scanWorkspace(rows)
+			if err != nil {
+				rows.Close()
+				http.Error(w, "Error scanning workspace", http.StatusInternalServerError)
+				return
+			}
+			workspaces = append(workspaces, ws)
+		}
+		rows.Close()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(workspaces)
+
+	case "POST":
+		var request struct {
+			Name                string `json:"name"`
+			MaxForms            *int   `json:"maxForms"`
+			MaxMonthlyResponses *int   `json:"maxMonthlyResponses"`
+			Owner               string `json:"owner"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		request.Name = strings.TrimSpace(request.Name)
+		if request.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if (request.MaxForms != nil && *request.MaxForms < 0) || (request.MaxMonthlyResponses != nil && *request.MaxMonthlyResponses < 0) {
+			http.Error(w, "Quotas cannot be negative", http.StatusBadRequest)
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			http.Error(w, "Error creating workspace", http.StatusInternalServerError)
+			return
+		}
+		defer tx.Rollback()
+		result, err := tx.Exec(`
+			INSERT INTO workspaces (name, max_forms, max_monthly_responses, created_at, updated_at)
+			VALUES (?, ?, ?, NOW(), NOW())
+		`, request.Name, request.MaxForms, request.MaxMonthlyResponses)
+		if err != nil {
+			log.Printf("Error creating workspace: %v", err)
+			http.Error(w, "Error creating workspace", http.StatusInternalServerError)
+			return
+		}
+		id, _ := result.LastInsertId()
+		if owner := strings.TrimSpace(request.Owner); owner != "" {
+			if _, err := tx.Exec("INSERT INTO workspace_members (workspace_id, user, role, created_at) VALUES (?, ?, 'owner', NOW())", id, owner); err != nil {
+				log.Printf("Error adding workspace owner: %v", err)
+				http.Error(w, "Error creating workspace", http.StatusInternalServerError)
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			http.Error(w, "Error creating workspace", http.StatusInternalServerError)
+			return
+		}
+
+		ws, err := scanWorkspace(db.QueryRow("SELECT "+workspaceColumns+" FROM workspaces WHERE id = ?", id))
+		if err != nil {
+			http.Error(w, "Error fetching created workspace", http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, "workspace.create", "workspace", ws.ID, nil, ws, nil)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ws)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// Manage one workspace (/api/admin/workspaces/{id}) and its members
+// (/api/admin/workspaces/{id}/members[/{user}]). Platform admins manage everything;
+// workspace owners may view their workspace and manage its members.
+func workspaceHandler(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/workspaces/"), "/")
+	workspaceID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, "Invalid workspace ID", http.StatusBadRequest)
+		return
+	}
+	ws, err := scanWorkspace(db.QueryRow("SELECT "+workspaceColumns+" FROM workspaces WHERE id = ?", workspaceID))
+	if err == sql.ErrNoRows {
+		http.Error(w, "Workspace not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Printf("Error fetching workspace: %v", err)
+		http.Error(w, "Error fetching workspace", http.StatusInternalServerError)
+		return
+	}
+
+	platformAdmin := isPlatformAdmin(r)
+	owner := false
+	if actor := adminActor(r); actor != "" && !platformAdmin {
+		role, err := workspaceRole(workspaceID, actor)
+		if err != nil {
+			http.Error(w, "Error checking workspace", http.StatusInternalServerError)
+			return
+		}
+		owner = role == "owner"
+	}
+
+	if len(parts) >= 2 && parts[1] == "members" {
+		if !platformAdmin && !owner {
+			http.Error(w, "Workspace owner role required", http.StatusForbidden)
+			return
+		}
+		if len(parts) == 3 && r.Method == "DELETE" {
+			removeWorkspaceMember(w, r, workspaceID, parts[2])
+		} else if len(parts) == 2 && r.Method == "GET" {
+			listWorkspaceMembers(w, workspaceID)
+		} else if len(parts) == 2 && r.Method == "PUT" {
+			putWorkspaceMember(w, r, workspaceID)
+		} else {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if len(parts) != 1 {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		if !platformAdmin && !owner {
+			http.Error(w, "Workspace owner role required", http.StatusForbidden)
+			return
+		}
+		usage, err := workspaceUsage(workspaceID)
+		if err != nil {
+			log.Printf("Error fetching workspace usage: %v", err)
+			http.Error(w, "Error fetching workspace usage", http.StatusInternalServerError)
+			return
+		}
+		ws.Usage = &usage
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ws)
+
+	case "PUT":
+		if !platformAdmin {
+			http.Error(w, "Platform admin role required", http.StatusForbidden)
+			return
+		}
+		var request struct {
+			Name                string `json:"name"`
+			MaxForms            *int   `json:"maxForms"`
+			MaxMonthlyResponses *int   `json:"maxMonthlyResponses"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		request.Name = strings.TrimSpace(request.Name)
+		if request.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if (request.MaxForms != nil && *request.MaxForms < 0) || (request.MaxMonthlyResponses != nil && *request.MaxMonthlyResponses < 0) {
+			http.Error(w, "Quotas cannot be negative", http.StatusBadRequest)
+			return
+		}
+		_, err := db.Exec(`
+			UPDATE workspaces SET name = ?, max_forms = ?, max_monthly_responses = ?, updated_at = NOW()
+			WHERE id = ?
+		`, request.Name, request.MaxForms, request.MaxMonthlyResponses, workspaceID)
+		if err != nil {
+			log.Printf("Error updating workspace: %v", err)
+			http.Error(w, "Error updating workspace", http.StatusInternalServerError)
+			return
+		}
+		updated, err := scanWorkspace(db.QueryRow("SELECT "+workspaceColumns+" FROM workspaces WHERE id = ?", workspaceID))
+		if err != nil {
+			http.Error(w, "Error fetching updated workspace", http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, "workspace.update", "workspace", workspaceID, ws, updated, nil)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(updated)
+
+	case "DELETE":
+		if !platformAdmin {
+			http.Error(w, "Platform admin role required", http.StatusForbidden)
+			return
+		}
+		if workspaceID == defaultWorkspaceID {
+			http.Error(w, "The default workspace cannot be deleted", http.StatusConflict)
+			return
+		}
+		// Forms in the trash still belong to the workspace until purged
+		var hasForms bool
+		if err := db.QueryRow("SELECT EXISTS(SELECT 1 FROM forms WHERE workspace_id = ?)", workspaceID).Scan(&hasForms); err != nil {
+			http.Error(w, "Error checking workspace forms", http.StatusInternalServerError)
+			return
+		}
+		if hasForms {
+			http.Error(w, "Workspace still has forms", http.StatusConflict)
+			return
+		}
+		if _, err := db.Exec("DELETE FROM workspaces WHERE id = ?", workspaceID); err != nil {
+			log.Printf("Error deleting workspace: %v", err)
+			http.Error(w, "Error deleting workspace", http.StatusInternalServerError)
+			return
+		}
+		recordAudit(r, "workspace.delete", "workspace", workspaceID, ws, nil, nil)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func listWorkspaceMembers(w http.ResponseWriter, workspaceID int) {
+	rows, err := db.Query("SELECT workspace_id, user, role, created_at FROM workspace_members WHERE workspace_id = ? ORDER BY user", workspaceID)
+	if err != nil {
+		log.Printf("Error fetching workspace members: %v", err)
+		http.Error(w, "Error fetching workspace members", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+	members := []WorkspaceMember{}
+	for rows.Next() {
+		var m WorkspaceMember
+		if err := rows.Scan(&m.WorkspaceID, &m.User, &m.Role, &m.CreatedAt); err != nil {
+			http.Error(w, "Error scanning workspace member", http.StatusInternalServerError)
+			return
+		}
+		members = append(members, m)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// putWorkspaceMember adds a member or changes their role
+func putWorkspaceMember(w http.ResponseWriter, r *http.Request, workspaceID int) {
+	var request struct {
+		User string `json:"user"`
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	request.User = strings.TrimSpace(request.User)
+	if request.User == "" {
+		http.Error(w, "user is required", http.StatusBadRequest)
+		return
+	}
+	if request.Role == "" {
+		request.Role = "member"
+	}
+	if !workspaceRoles[request.Role] {
+		http.Error(w, "Invalid role", http.StatusBadRequest)
+		return
+	}
+
+	previous, err := workspaceRole(workspaceID, request.User)
+	if err != nil {
+		http.Error(w, "Error checking workspace member", http.StatusInternalServerError)
+		return
+	}
+	_, err = db.Exec(`
+		INSERT INTO workspace_members (workspace_id, user, role, created_at)
+		VALUES (?, ?, ?, NOW())
+		ON DUPLICATE KEY UPDATE role = VALUES(role)
+	`, workspaceID, request.User, request.Role)
+	if err != nil {
+		log.Printf("Error saving workspace member: %v", err)
+		http.Error(w, "Error saving workspace member", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "workspace.member", "workspace", workspaceID, nil, nil, map[string]interface{}{
+		"user": request.User,
+		"from": previous,
+		"to":   request.Role,
+	})
+	listWorkspaceMembers(w, workspaceID)
+}
+
+func removeWorkspaceMember(w http.ResponseWriter, r *http.Request, workspaceID int, user string) {
+	result, err := db.Exec("DELETE FROM workspace_members WHERE workspace_id = ? AND user = ?", workspaceID, user)
+	if err != nil {
+		log.Printf("Error removing workspace member: %v", err)
+		http.Error(w, "Error removing workspace member", http.StatusInternalServerError)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		http.Error(w, "Member not found", http.StatusNotFound)
+		return
+	}
+	recordAudit(r, "workspace.member", "workspace", workspaceID, nil, nil, map[string]interface{}{
+		"user": user,
+		"to":   "",
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// anonymizeResponseData keeps only answers to known, non-sensitive fields that cannot
+// identify the respondent, including calculations made only from such answers
+func anonymizeResponseData(fields []FormField, data map[string]interface{}) map[string]interface{} {
+	kept := make(map[string]interface{})
+	for _, field := range fields {
+		if value, ok := data[field.ID]; ok && !formdef.Identifies(field, fields) && !field.Sensitive {
+			kept[field.ID] = value
+		}
+	}
+	return kept
+}
+
+// anonymizeResponse strips the contact details, identifying answers and the device
+// details of the metadata from a stored response; the campaign it came from is kept.
+// Internal notes and the triage history go too, as they often quote the respondent.
+func anonymizeResponse(tx *sql.Tx, responseID int, fields []FormField, data map[string]interface{}) error {
+	anonymizedJSON, err := json.Marshal(anonymizeResponseData(fields, data))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		UPDATE form_responses
+		SET phone_number = NULL, phone_normalized = NULL, phone_blind_index = NULL,
+			email = NULL, email_normalized = NULL, email_blind_index = NULL, response_data = ?,
+			metadata = JSON_REMOVE(metadata, '$.ip', '$.ipHashed', '$.userAgent', '$.referrer'), anonymized_at = NOW()
+		WHERE id = ?
+	`, anonymizedJSON, responseID)
+	if err != nil {
+		return err
+	}
+	// Only free-text answers are indexed and none of them survive anonymization
+	for _, stmt := range []string{
+		"DELETE FROM response_search_documents WHERE response_id = ?",
+		"DELETE FROM response_notes WHERE response_id = ?",
+		"DELETE FROM response_status_history WHERE response_id = ?",
+	} {
+		if _, err = tx.Exec(stmt, responseID); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("DELETE FROM form_response_identities WHERE response_id = ?", responseID)
+	return err
+}
+
+// responseUploadDir returns the directory holding files uploaded with a single response
+func responseUploadDir(formID, responseID int) string {
+	return filepath.Join(formUploadDir(formID), "responses", strconv.Itoa(responseID))
+}
+
+// subjectResponse is a stored response belonging to a data subject
+type subjectResponse struct {
+	ID           int                          `json:"id"`
+	FormID       int                          `json:"formId"`
+	FormTitle    MultiLanguageText            `json:"formTitle"`
+	Questions    map[string]MultiLanguageText `json:"questions"`
+	PhoneNumber  string                       `json:"phoneNumber,omitempty"`
+	Email        string                       `json:"email,omitempty"`
+	ResponseData map[string]interface{}       `json:"responseData"`
+	Language     string                       `json:"language"`
+	SubmittedAt  time.Time                    `json:"submittedAt"`
+
+	fields []FormField
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// findSubjectResponses returns every response, across all forms, submitted with the
+// subject's phone number or email. lock adds FOR UPDATE when called inside an erasure.
+func findSubjectResponses(q querier, subject dataSubject, lock bool) ([]subjectResponse, error) {
+	match, args := contactMatch("r.", subject.Phone, subject.Email)
+	query := `
+		SELECT r.id, r.form_id, f.title, f.fields, r.phone_number, r.email, r.response_data, COALESCE(r.language, 'en'), r.submitted_at
+		FROM form_responses r
+		JOIN forms f ON f.id = r.form_id
+		WHERE ` + match + `
+		ORDER BY r.submitted_at`
+	if lock {
+		query += " FOR UPDATE"
+	}
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	responses := []subjectResponse{}
+	for rows.Next() {
+		var resp subjectResponse
+		var titleJSON, fieldsJSON, dataJSON []byte
+		var phoneNumber, email sql.NullString
+		if err := rows.Scan(&resp.ID, &resp.FormID, &titleJSON, &fieldsJSON, &phoneNumber, &email, &dataJSON, &resp.Language, &resp.SubmittedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(titleJSON, &resp.FormTitle); err != nil {
+			return nil, fmt.Errorf("parsing title of form %d: %w", resp.FormID, err)
+		}
+		if err := json.Unmarshal(fieldsJSON, &resp.fields); err != nil {
+			return nil, fmt.Errorf("parsing fields of form %d: %w", resp.FormID, err)
+		}
+		if err := json.Unmarshal(dataJSON, &resp.ResponseData); err != nil {
+			return nil, fmt.Errorf("parsing response %d: %w", resp.ID, err)
+		}
+		// Data subjects are entitled to their own data in the clear
+		if phoneNumber.String != "" {
+			resp.PhoneNumber = revealStoredContact(phoneNumber.String, "phone number", true)
+		}
+		if email.String != "" {
+			resp.Email = revealStoredContact(email.String, "email", true)
+		}
+		revealAnswers(resp.fields, resp.ResponseData, true)
+		resp.Questions = make(map[string]MultiLanguageText, len(resp.fields))
+		for _, field := range resp.fields {
+			resp.Questions[field.ID] = field.Label
+		}
+		responses = append(responses, resp)
+	}
+	return responses, rows.Err()
+}
+
+// privacyGenesisHash is the prev_hash of the first entry in the privacy log
+var privacyGenesisHash = strings.Repeat("0", 64)
+
+// errNoPrivacyLogKey is returned when PRIVACY_LOG_KEY is unset. Phone numbers are few
+// enough to brute-force an unkeyed hash, so nothing is logged without the key.
+var errNoPrivacyLogKey = errors.New("PRIVACY_LOG_KEY is not configured")
+
+// privacyReasons are the recorded grounds for an erasure. The log keeps a code rather
+// than free text, which would end up quoting the person it is about.
+var privacyReasons = map[string]bool{
+	"subject_request":   true,
+	"consent_withdrawn": true,
+	"legal_obligation":  true,
+	"other":             true,
+}
+
+// privacySubjectHashes pseudonymizes a data subject for the privacy log so the log
+// itself holds no PII: an HMAC keyed with PRIVACY_LOG_KEY of each identifier they gave,
+// their normalized phone number and their email. A subject found by either is found.
+func privacySubjectHashes(subject dataSubject) ([]string, error) {
+	key := os.Getenv("PRIVACY_LOG_KEY")
+	if key == "" {
+		return nil, errNoPrivacyLogKey
+	}
+	var ids []string
+	if subject.Phone != "" {
+		ids = append(ids, subject.Phone)
+	}
+	if subject.Email != "" {
+		ids = append(ids, "email:"+subject.Email)
+	}
+	hashes := make([]string, len(ids))
+	for i, id := range ids {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(id))
+		hashes[i] = hex.EncodeToString(mac.Sum(nil))
+	}
+	return hashes, nil
+}
+
+// requirePrivacyOfficer checks the caller may handle privacy requests and read the
+// privacy log: a signed-in admin whose X-Admin-Role is listed in PRIVACY_ROLES. These
+// are platform roles, as a subject's responses span every workspace. It also refuses to
+// start while the log can't be written. Errors are written to w.
+func requirePrivacyOfficer(w http.ResponseWriter, r *http.Request) (string, bool) {
+	actor := adminActor(r)
+	if actor == "" {
+		http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+		return "", false
+	}
+	if !roleAllowed(r, "PRIVACY_ROLES", "privacy") {
+		http.Error(w, "Privacy role required", http.StatusForbidden)
+		return "", false
+	}
+	if os.Getenv("PRIVACY_LOG_KEY") == "" {
+		log.Printf("Refusing privacy request: %v", errNoPrivacyLogKey)
+		http.Error(w, "Privacy log is not configured", http.StatusServiceUnavailable)
+		return "", false
+	}
+	return actor, true
+}
+
+// privacyEntryHash chains a log entry to its predecessor
+func privacyEntryHash(prevHash, action, subjectHash, actor, details string, createdAt time.Time) string {
+	h := sha256.New()
+	for _, part := range []string{prevHash, action, subjectHash, actor, details, createdAt.UTC().Format(time.RFC3339Nano)} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// appendPrivacyLog adds hash-chained entries about subject to the privacy log inside tx,
+// one per identifier, so a later lookup by either the phone or the email finds them.
+// The head row is locked so concurrent requests extend the chain one at a time.
+func appendPrivacyLog(tx *sql.Tx, action string, subject dataSubject, actor string, details interface{}) error {
+	subjectHashes, err := privacySubjectHashes(subject)
+	if err != nil {
+		return err
+	}
+	detailsJSON, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+
+	var prevHash string
+	if err := tx.QueryRow("SELECT head_hash FROM privacy_log_head WHERE id = 1 FOR UPDATE").Scan(&prevHash); err != nil {
+		return fmt.Errorf("reading privacy log head: %w", err)
+	}
+
+	createdAt := time.Now().UTC().Truncate(time.Microsecond)
+	for _, subjectHash := range subjectHashes {
+		entryHash := privacyEntryHash(prevHash, action, subjectHash, actor, string(detailsJSON), createdAt)
+		if _, err := tx.Exec(`
+			INSERT INTO privacy_log (action, subject_hash, actor, details, created_at, prev_hash, entry_hash)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, action, subjectHash, actor, string(detailsJSON), createdAt, prevHash, entryHash); err != nil {
+			return fmt.Errorf("writing privacy log: %w", err)
+		}
+		prevHash = entryHash
+	}
+	if _, err := tx.Exec("UPDATE privacy_log_head SET head_hash = ? WHERE id = 1", prevHash); err != nil {
+		return fmt.Errorf("updating privacy log head: %w", err)
+	}
+	return nil
+}
+
+// recordPrivacyAction logs an action that doesn't change data in its own transaction
+func recordPrivacyAction(action string, subject dataSubject, actor string, details interface{}) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := appendPrivacyLog(tx, action, subject, actor, details); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// dataSubject is the person a privacy request is about, by normalized phone number,
+// email or both; responses with either belong to them
+type dataSubject struct {
+	Phone string
+	Email string
+}
+
+// privacySubject reads and normalizes the phone number and email identifying a data
+// subject; at least one is required
+func privacySubject(w http.ResponseWriter, rawPhone, rawEmail string) (dataSubject, bool) {
+	subject := dataSubject{Phone: phone.Normalize(rawPhone), Email: normalizeEmail(rawEmail)}
+	if subject.Phone == "" && subject.Email == "" {
+		http.Error(w, "Phone number or email is required", http.StatusBadRequest)
+		return subject, false
+	}
+	return subject, true
+}
+
+// Find every response submitted by a phone number or email
+func privacyLookupHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actor, ok := requirePrivacyOfficer(w, r)
+	if !ok {
+		return
+	}
+	subject, ok := privacySubject(w, r.URL.Query().Get("phoneNumber"), r.URL.Query().Get("email"))
+	if !ok {
+		return
+	}
+	responses, err := findSubjectResponses(db, subject, false)
+	if err != nil {
+		log.Printf("Error finding subject responses: %v", err)
+		http.Error(w, "Error fetching responses", http.StatusInternalServerError)
+		return
+	}
+	if err := recordPrivacyAction("lookup", subject, actor, map[string]interface{}{
+		"responses": len(responses),
+	}); err != nil {
+		log.Printf("Error recording privacy lookup: %v", err)
+		http.Error(w, "Error recording privacy action", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "response.view", "privacy_subject", 0, nil, nil, map[string]interface{}{"responses": len(responses)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"phoneNumber": subject.Phone,
+		"email":       subject.Email,
+		"count":       len(responses),
+		"responses":   responses,
+	})
+}
+
+// Export every response of a phone number or email as a JSON or ZIP bundle for an
+// access request
+func privacyAccessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actor, ok := requirePrivacyOfficer(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		PhoneNumber string `json:"phoneNumber"`
+		Email       string `json:"email"`
+		Format      string `json:"format"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.Format == "" {
+		request.Format = "json"
+	}
+	if request.Format != "json" && request.Format != "zip" {
+		http.Error(w, "Format must be json or zip", http.StatusBadRequest)
+		return
+	}
+	subject, ok := privacySubject(w, request.PhoneNumber, request.Email)
+	if !ok {
+		return
+	}
+	responses, err := findSubjectResponses(db, subject, false)
+	if err != nil {
+		log.Printf("Error finding subject responses: %v", err)
+		http.Error(w, "Error fetching responses", http.StatusInternalServerError)
+		return
+	}
+
+	// The export only leaves the building once it is on the record
+	if err := recordPrivacyAction("access_export", subject, actor, map[string]interface{}{
+		"format":    request.Format,
+		"responses": len(responses),
+	}); err != nil {
+		log.Printf("Error recording privacy export: %v", err)
+		http.Error(w, "Error recording privacy action", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "response.export", "privacy_subject", 0, nil, nil, map[string]interface{}{
+		"format":    request.Format,
+		"responses": len(responses),
+	})
+
+	manifest := map[string]interface{}{
+		"phoneNumber": subject.Phone,
+		"email":       subject.Email,
+		"generatedAt": time.Now().UTC(),
+		"count":       len(responses),
+	}
+	filename := "access-request-" + time.Now().UTC().Format("20060102-150405")
+
+	if request.Format == "json" {
+		manifest["responses"] = responses
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+		json.NewEncoder(w).Encode(manifest)
+		return
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := map[string]interface{}{"manifest.json": manifest}
+	for _, resp := range responses {
+		files[fmt.Sprintf("responses/form-%d-response-%d.json", resp.FormID, resp.ID)] = resp
+	}
+	for name, content := range files {
+		entry, err := archive.Create(name)
+		if err == nil {
+			enc := json.NewEncoder(entry)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(content)
+		}
+		if err != nil {
+			log.Printf("Error writing %s to access bundle: %v", name, err)
+			http.Error(w, "Error building export", http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("Error closing access bundle: %v", err)
+		http.Error(w, "Error building export", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.zip"`)
+	w.Write(buf.Bytes())
+}
+
+// Erase or anonymize every response of a phone number or email for an erasure request
+func privacyErasureHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	actor, ok := requirePrivacyOfficer(w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		PhoneNumber string `json:"phoneNumber"`
+		Email       string `json:"email"`
+		Mode        string `json:"mode"`
+		Reason      string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.Mode != "delete" && request.Mode != "anonymize" {
+		http.Error(w, "Mode must be delete or anonymize", http.StatusBadRequest)
+		return
+	}
+	if request.Reason == "" {
+		request.Reason = "subject_request"
+	}
+	if !privacyReasons[request.Reason] {
+		http.Error(w, "reason must be subject_request, consent_withdrawn, legal_obligation or other", http.StatusBadRequest)
+		return
+	}
+	subject, ok := privacySubject(w, request.PhoneNumber, request.Email)
+	if !ok {
+		return
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		http.Error(w, "Error starting erasure", http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	responses, err := findSubjectResponses(tx, subject, true)
+	if err != nil {
+		log.Printf("Error finding subject responses: %v", err)
+		http.Error(w, "Error fetching responses", http.StatusInternalServerError)
+		return
+	}
+
+	for _, resp := range responses {
+		if request.Mode == "delete" {
+			_, err = tx.Exec("DELETE FROM form_responses WHERE id = ?", resp.ID)
+			if err == nil {
+				err = releaseSeats(tx, resp.FormID, resp.fields, resp.ResponseData)
+			}
+		} else {
+			err = anonymizeResponse(tx, resp.ID, resp.fields, resp.ResponseData)
+		}
+		if err != nil {
+			log.Printf("Error erasing response %d: %v", resp.ID, err)
+			http.Error(w, "Error erasing responses", http.StatusInternalServerError)
+			return
+		}
+	}
+	// Unfinished drafts are deleted in either mode. They only keep a phone number; the
+	// email is asked on submit.
+	var drafts int64
+	if subject.Phone != "" {
+		match, args := phoneMatch("", subject.Phone)
+		result, err := tx.Exec("DELETE FROM form_drafts WHERE "+match, args...)
+		if err != nil {
+			log.Printf("Error erasing drafts: %v", err)
+			http.Error(w, "Error erasing responses", http.StatusInternalServerError)
+			return
+		}
+		drafts, _ = result.RowsAffected()
+	}
+
+	if err := appendPrivacyLog(tx, "erasure_"+request.Mode, subject, actor, map[string]interface{}{
+		"responses": len(responses),
+		"drafts":    drafts,
+		"reason":    request.Reason,
+	}); err != nil {
+		log.Printf("Error recording privacy erasure: %v", err)
+		http.Error(w, "Error recording privacy action", http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("Error committing erasure: %v", err)
+		http.Error(w, "Error erasing responses", http.StatusInternalServerError)
+		return
+	}
+	recordAudit(r, "response.erase", "privacy_subject", 0, nil, nil, map[string]interface{}{
+		"mode":      request.Mode,
+		"responses": len(responses),
+		"drafts":    drafts,
+	})
+
+	// Uploaded files go either way; they can't be anonymized
+	for _, resp := range responses {
+		if err := os.RemoveAll(responseUploadDir(resp.FormID, resp.ID)); err != nil {
+			log.Printf("Error removing uploads for response %d: %v", resp.ID, err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"mode":     request.Mode,
+		"affected": len(responses),
+		"drafts":   drafts,
+	})
+}
+
+// privacyLogEntry is one hash-chained record of a privacy action
+type privacyLogEntry struct {
+	ID          int64           `json:"id"`
+	Action      string          `json:"action"`
+	SubjectHash string          `json:"subjectHash"`
+	Actor       string          `json:"actor"`
+	Details     json.RawMessage `json:"details"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	PrevHash    string          `json:"prevHash"`
+	EntryHash   string          `json:"entryHash"`
+}
+
+// List privacy log entries, optionally for a single phone number or email, and verify
+// the hash chain
+func privacyLogHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requirePrivacyOfficer(w, r); !ok {
+		return
+	}
+
+	query := "SELECT id, action, subject_hash, actor, details, created_at, prev_hash, entry_hash FROM privacy_log"
+	var args []interface{}
+	subject := dataSubject{Phone: phone.Normalize(r.URL.Query().Get("phoneNumber")), Email: normalizeEmail(r.URL.Query().Get("email"))}
+	if subject.Phone != "" || subject.Email != "" {
+		subjectHashes, err := privacySubjectHashes(subject)
+		if err != nil {
+			http.Error(w, "Privacy log is not configured", http.StatusServiceUnavailable)
+			return
+		}
+		query += " WHERE subject_hash IN (?" + strings.Repeat(", ?", len(subjectHashes)-1) + ")"
+		for _, subjectHash := range subjectHashes {
+			args = append(args, subjectHash)
+		}
+	}
+	rows, err := db.Query(query+" ORDER BY id", args...)
+	if err != nil {
+		log.Printf("Error fetching privacy log: %v", err)
+		http.Error(w, "Error fetching privacy log", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []privacyLogEntry{}
+	for rows.Next() {
+		var entry privacyLogEntry
+		var details string
+		if err := rows.Scan(&entry.ID, &entry.Action, &entry.SubjectHash, &entry.Actor, &details, &entry.CreatedAt, &entry.PrevHash, &entry.EntryHash); err != nil {
+			http.Error(w, "Error scanning privacy log", http.StatusInternalServerError)
+			return
+		}
+		entry.Details = json.RawMessage(details)
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// Walk the whole privacy log and report the first entry whose hash doesn't chain
+func privacyLogVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := requirePrivacyOfficer(w, r); !ok {
+		return
+	}
+
+	rows, err := db.Query("SELECT id, action, subject_hash, actor, details, created_at, prev_hash, entry_hash FROM privacy_log ORDER BY id")
+	if err != nil {
+		log.Printf("Error fetching privacy log: %v", err)
+		http.Error(w, "Error fetching privacy log", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	result := map[string]interface{}{"valid": true}
+	prevHash := privacyGenesisHash
+	count := 0
+	for rows.Next() {
+		var entry privacyLogEntry
+		var details string
+		if err := rows.Scan(&entry.ID, &entry.Action, &entry.SubjectHash, &entry.Actor, &details, &entry.CreatedAt, &entry.PrevHash, &entry.EntryHash); err != nil {
+			http.Error(w, "Error scanning privacy log", http.StatusInternalServerError)
+			return
+		}
+		count++
+		expected := privacyEntryHash(entry.PrevHash, entry.Action, entry.SubjectHash, entry.Actor, details, entry.CreatedAt)
+		if entry.PrevHash != prevHash || entry.EntryHash != expected {
+			result["valid"] = false
+			result["brokenAt"] = entry.ID
+			break
+		}
+		prevHash = entry.EntryHash
+	}
+
+	// A matching head also catches entries deleted from the end of the log
+	if result["valid"] == true {
+		var headHash string
+		if err := db.QueryRow("SELECT head_hash FROM privacy_log_head WHERE id = 1").Scan(&headHash); err != nil {
+			http.Error(w, "Error reading privacy log head", http.StatusInternalServerError)
+			return
+		}
+		if headHash != prevHash {
+			result["valid"] = false
+			result["brokenAt"] = "head"
+		}
+	}
+	result["entries"] = count
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// requestIDKey is the context key holding the request ID
+type requestIDKey struct{}
+
+// requestIDMiddleware tags every request with an ID, reusing a sane incoming X-Request-ID,
+// and echoes it back so clients and audit events can be correlated
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 64 {
+			buf := make([]byte, 16)
+			rand.Read(buf)
+			id = hex.EncodeToString(buf)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID assigned by requestIDMiddleware
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+// trustedProxies are the networks (TRUSTED_PROXIES) whose X-Forwarded-For is believed
+var trustedProxies []*net.IPNet
+
+// initTrustedProxies parses TRUSTED_PROXIES, a comma-separated list of CIDRs or
+// addresses of the load balancers in front of the server
+func initTrustedProxies() {
+	for _, entry := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			log.Fatalf("Invalid TRUSTED_PROXIES entry %q: %v", entry, err)
+		}
+		trustedProxies = append(trustedProxies, network)
+	}
+}
+
+// trustedProxy reports whether addr belongs to one of the trusted proxies
+func trustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the caller's address. X-Forwarded-For is only believed when the
+// connection comes from a trusted proxy, and then the client is the nearest hop that
+// is not one: anything further left could have been sent by the client itself.
+func clientIP(r *http.Request) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		remote = host
+	}
+	if !trustedProxy(remote) {
+		return remote
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !trustedProxy(hop) {
+			return hop
+		}
+		remote = hop
+	}
+	return remote
+}
+
+// auditChange is the before and after value of one changed top-level property
+type auditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEvent is one append-only record of an admin action
+type AuditEvent struct {
+	ID          int64                  `json:"id"`
+	WorkspaceID int                    `json:"workspaceId"`
+	Actor       string                 `json:"actor"`
+	Action      string                 `json:"action"`
+	TargetType  string                 `json:"targetType"`
+	TargetID    int                    `json:"targetId"`
+	Changes     map[string]auditChange `json:"changes,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	IP          string                 `json:"ip,omitempty"`
+	RequestID   string                 `json:"requestId,omitempty"`
+	CreatedAt   time.Time              `json:"createdAt"`
+}
+
+// auditIgnoredKeys are left out of diffs: updatedAt is bumped on every write and the
+// public token is a secret
+var auditIgnoredKeys = map[string]bool{"updatedAt": true, "publicToken": true}
+
+// auditDiff compares the JSON forms of before and after and returns the top-level
+// properties that differ. Either side may be nil for creations and deletions.
+func auditDiff(before, after interface{}) map[string]auditChange {
+	toMap := func(v interface{}) map[string]interface{} {
+		m := map[string]interface{}{}
+		if v == nil {
+			return m
+		}
+		if b, err := json.Marshal(v); err == nil {
+			json.Unmarshal(b, &m)
+		}
+		return m
+	}
+	beforeMap, afterMap := toMap(before), toMap(after)
+
+	changes := make(map[string]auditChange)
+	for key, b := range beforeMap {
+		if a, ok := afterMap[key]; (!ok || !reflect.DeepEqual(a, b)) && !auditIgnoredKeys[key] {
+			changes[key] = auditChange{Before: b, After: afterMap[key]}
+		}
+	}
+	for key, a := range afterMap {
+		if _, ok := beforeMap[key]; !ok && !auditIgnoredKeys[key] {
+			changes[key] = auditChange{Before: nil, After: a}
+		}
+	}
+	return changes
+}
+
+// recordAudit appends an audit event for an action taken through r. A failure to audit
+// is logged rather than undoing an action that already happened.
+func recordAudit(r *http.Request, action, targetType string, targetID int, before, after interface{}, metadata map[string]interface{}) {
+	actor := adminActor(r)
+	if actor == "" {
+		actor = "anonymous"
+	}
+	// Handlers have already validated the header through requestWorkspace
+	workspaceID, err := workspaceHeader(r)
+	if err != nil {
+		workspaceID = defaultWorkspaceID
+	}
+	writeAuditEvent(AuditEvent{
+		WorkspaceID: workspaceID,
+		Actor:       actor,
+		Action:      action,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Changes:     auditDiff(before, after),
+		Metadata:    metadata,
+		IP:          clientIP(r),
+		RequestID:   requestID(r),
+	})
+}
+
+// recordSystemAudit appends an audit event for an action taken by a background job
+func recordSystemAudit(workspaceID int, action, targetType string, targetID int, metadata map[string]interface{}) {
+	writeAuditEvent(AuditEvent{
+		WorkspaceID: workspaceID,
+		Actor:       "system",
+		Action:      action,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Metadata:    metadata,
+	})
+}
+
+func writeAuditEvent(event AuditEvent) {
+	var changesJSON, metadataJSON []byte
+	if len(event.Changes) > 0 {
+		changesJSON, _ = json.Marshal(event.Changes)
+	}
+	if len(event.Metadata) > 0 {
+		metadataJSON, _ = json.Marshal(event.Metadata)
+	}
+	_, err := db.Exec(`
+		INSERT INTO audit_events (workspace_id, actor, action, target_type, target_id, changes, metadata, ip, request_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, NOW())
+	`, event.WorkspaceID, event.Actor, event.Action, event.TargetType, event.TargetID, changesJSON, metadataJSON, event.IP, event.RequestID)
+	if err != nil {
+		log.Printf("Error recording audit event %s on %s %d: %v", event.Action, event.TargetType, event.TargetID, err)
+	}
+}
+
+// AuditPage is a page of audit events
+type AuditPage struct {
+	Data       []AuditEvent `json:"data"`
+	TotalCount int          `json:"totalCount"`
+	Page       int          `json:"page"`
+	PageSize   int          `json:"pageSize"`
+	TotalPages int          `json:"totalPages"`
+}
+
+// Query audit events, newest first. Filters: actor, action, targetType, targetId,
+// from and to (RFC 3339 or YYYY-MM-DD). Only admins whose X-Admin-Role is listed in
+// AUDIT_READER_ROLES may read the history.
+func getAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if adminActor(r) == "" {
+		http.Error(w, "X-Admin-User header is required", http.StatusUnauthorized)
+		return
+	}
+	if !roleAllowed(r, "AUDIT_READER_ROLES", "admin") {
+		http.Error(w, "Admin role required", http.StatusForbidden)
+		return
+	}
+
+	query := r.URL.Query()
+	page := 1
+	pageSize := 50
+	if p, err := strconv.Atoi(query.Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if ps, err := strconv.Atoi(query.Get("pageSize")); err == nil && ps > 0 && ps <= 200 {
+		pageSize = ps
+	}
+
+	workspaceID, ok := requestWorkspace(w, r)
+	if !ok {
+		return
+	}
+
+	conditions := []string{"workspace_id = ?"}
+	args := []interface{}{workspaceID}
+	for param, column := range map[string]string{"actor": "actor", "action": "action", "targetType": "target_type"} {
+		if v := query.Get(param); v != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, v)
+		}
+	}
+	if v := query.Get("targetId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "Invalid targetId", http.StatusBadRequest)
+			return
+		}
+		conditions = append(conditions, "target_id = ?")
+		args = append(args, id)
+	}
+	for param, op := range map[string]string{"from": ">=", "to": "<"} {
+		v := query.Get(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.Parse("2006-01-02", v); err == nil && param == "to" {
+				// A bare "to" date includes that whole day
+				t = t.AddDate(0, 0, 1)
+			}
+		}
+		if err != nil {
+			http.Error(w, "Invalid "+param+" date", http.StatusBadRequest)
+			return
+		}
+		conditions = append(conditions, "created_at "+op+" ?")
+		args = append(args, t)
+	}
+	where := " WHERE " + strings.Join(conditions, " AND ")
+
+	var totalCount int
+	if err := db.QueryRow("SELECT COUNT(*) FROM audit_events"+where, args...).Scan(&totalCount); err != nil {
+		log.Printf("Error counting audit events: %v", err)
+		http.Error(w, "Error counting audit events", http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := db.Query(`
+		SELECT id, workspace_id, actor, action, target_type, target_id, changes, metadata, COALESCE(ip, ''), COALESCE(request_id, ''), created_at
+		FROM audit_events`+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, pageSize, (page-1)*pageSize)...)
+	if err != nil {
+		log.Printf("Error fetching audit events: %v", err)
+		http.Error(w, "Error fetching audit events", http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	events := []AuditEvent{}
+	for rows.Next() {
+		var event AuditEvent
+		var changesJSON, metadataJSON []byte
+		if err := rows.Scan(&event.ID, &event.WorkspaceID, &event.Actor, &event.Action, &event.TargetType, &event.TargetID,
+			&changesJSON, &metadataJSON, &event.IP, &event.RequestID, &event.CreatedAt); err != nil {
+			http.Error(w, "Error scanning audit event", http.StatusInternalServerError)
+			return
+		}
+		if len(changesJSON) > 0 {
+			json.Unmarshal(changesJSON, &event.Changes)
+		}
+		if len(metadataJSON) > 0 {
+			json.Unmarshal(metadataJSON, &event.Metadata)
+		}
+		events = append(events, event)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AuditPage{
+		Data:       events,
+		TotalCount: totalCount,
+		Page:       page,
+		PageSize:   pageSize,
+		TotalPages: (totalCount + pageSize - 1) / pageSize,
+	})
+}
+
+// migrateHeroImageHandler migrates the hero_image_url column from VARCHAR(512) to TEXT
+func migrateHeroImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Execute the ALTER TABLE statement
+	_, err := db.Exec("ALTER TABLE forms MODIFY COLUMN hero_image_url TEXT")
+	if err != nil {
+		log.Printf("Error migrating hero_image_url column: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully migrated hero_image_url column to TEXT")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status": "success",
+		"message": "hero_image_url column migrated to TEXT",
+	})
+}
+
+// migrateMultiLanguageHandler migrates forms to support multi-language content
+func migrateMultiLanguageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	// Add language column to form_responses table
+	_, err := db.Exec("ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS language VARCHAR(2) DEFAULT 'en'")
+	if err != nil {
+		log.Printf("Error adding language column to form_responses: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully migrated database for multi-language support")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Multi-language migration completed successfully",
+	})
+}
+
+// migrateTrashHandler adds the deleted_at column used for trash listing and retention
+func migrateTrashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := db.Exec("ALTER TABLE forms ADD COLUMN IF NOT EXISTS deleted_at DATETIME NULL"); err != nil {
+		log.Printf("Error adding deleted_at column to forms: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Forms deleted before this migration start their retention period from their last update
+	result, err := db.Exec("UPDATE forms SET deleted_at = updated_at WHERE is_active = false AND deleted_at IS NULL")
+	if err != nil {
+		log.Printf("Error backfilling deleted_at: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+	backfilled, _ := result.RowsAffected()
+
+	log.Printf("Successfully migrated forms for trash support (%d trashed forms backfilled)", backfilled)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "success",
+		"message":    "Trash migration completed successfully",
+		"backfilled": backfilled,
+	})
+}
+
+// migratePrivacyHandler adds normalized phone lookup to form_responses and creates the
+// hash-chained privacy log
+func migratePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	statements := []string{
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS phone_normalized VARCHAR(32) NULL",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS anonymized_at DATETIME NULL",
+		"CREATE INDEX IF NOT EXISTS idx_form_responses_phone_normalized ON form_responses (phone_normalized)",
+		`CREATE TABLE IF NOT EXISTS privacy_log (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			action VARCHAR(32) NOT NULL,
+			subject_hash CHAR(64) NOT NULL,
+			actor VARCHAR(255) NOT NULL,
+			details TEXT NOT NULL,
+			created_at DATETIME(6) NOT NULL,
+			prev_hash CHAR(64) NOT NULL,
+			entry_hash CHAR(64) NOT NULL,
+			INDEX idx_privacy_log_subject (subject_hash)
+		)`,
+		`CREATE TABLE IF NOT EXISTS privacy_log_head (
+			id TINYINT PRIMARY KEY,
+			head_hash CHAR(64) NOT NULL
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running privacy migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := db.Exec("INSERT IGNORE INTO privacy_log_head (id, head_hash) VALUES (1, ?)", privacyGenesisHash); err != nil {
+		log.Printf("Error seeding privacy log head: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Backfill normalized phones; normalization happens in Go so it matches new submissions
+	rows, err := db.Query("SELECT id, phone_number FROM form_responses WHERE phone_normalized IS NULL AND phone_number IS NOT NULL AND anonymized_at IS NULL")
+	if err != nil {
+		log.Printf("Error selecting responses for phone backfill: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+	normalized := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			log.Printf("Error scanning response for phone backfill: %v", err)
+			continue
+		}
+		// Encrypted phones are found through their blind index instead
+		if fieldcrypt.IsEncrypted(raw) {
+			continue
+		}
+		if n := phone.Normalize(raw); n != "" {
+			normalized[id] = n
+		}
+	}
+	rows.Close()
+
+	backfilled := 0
+	for id, n := range normalized {
+		if _, err := db.Exec("UPDATE form_responses SET phone_normalized = ? WHERE id = ?", n, id); err != nil {
+			log.Printf("Error backfilling phone for response %d: %v", id, err)
+			continue
+		}
+		backfilled++
+	}
+
+	log.Printf("Successfully migrated database for privacy requests (%d phones normalized)", backfilled)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "success",
+		"message":    "Privacy migration completed successfully",
+		"backfilled": backfilled,
+	})
+}
+
+// migrateRetentionHandler adds close dates and per-form settings used by retention policies
+func migrateRetentionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	statements := []string{
+		"ALTER TABLE forms ADD COLUMN IF NOT EXISTS closes_at DATETIME NULL",
+		"ALTER TABLE forms ADD COLUMN IF NOT EXISTS settings JSON NULL",
+		// Retention scans walk a form's responses by age
+		"CREATE INDEX IF NOT EXISTS idx_form_responses_form_submitted ON form_responses (form_id, submitted_at)",
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running retention migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Println("Successfully migrated database for retention policies")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Retention migration completed successfully",
+	})
+}
+
+// migrateEncryptionHandler prepares form_responses for encrypted phone numbers
+func migrateEncryptionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	statements := []string{
+		// Ciphertexts are far longer than a phone number
+		"ALTER TABLE form_responses MODIFY COLUMN phone_number TEXT NULL",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS phone_blind_index CHAR(64) NULL",
+		"CREATE INDEX IF NOT EXISTS idx_form_responses_phone_blind_index ON form_responses (phone_blind_index)",
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running encryption migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Println("Successfully migrated database for field encryption; run rotate-keys to encrypt existing rows")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Encryption migration completed successfully; run rotate-keys to encrypt existing rows",
+	})
+}
+
+// migrateAuditHandler creates the append-only audit_events table
+func migrateAuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS audit_events (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			actor VARCHAR(255) NOT NULL,
+			action VARCHAR(64) NOT NULL,
+			target_type VARCHAR(64) NOT NULL,
+			target_id INT NOT NULL,
+			changes JSON NULL,
+			metadata JSON NULL,
+			ip VARCHAR(64) NULL,
+			request_id VARCHAR(64) NULL,
+			created_at DATETIME NOT NULL,
+			INDEX idx_audit_events_target (target_type, target_id),
+			INDEX idx_audit_events_actor (actor),
+			INDEX idx_audit_events_created (created_at)
+		)`,
+		// The application only ever inserts; refuse edits at the database too
+		`CREATE TRIGGER IF NOT EXISTS audit_events_no_update BEFORE UPDATE ON audit_events
+			FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only'`,
+		`CREATE TRIGGER IF NOT EXISTS audit_events_no_delete BEFORE DELETE ON audit_events
+			FOR EACH ROW SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only'`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running audit migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Println("Successfully migrated database for audit events")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Audit migration completed successfully",
+	})
+}
+
+// migrateTriageHandler adds triage state to form_responses with notes and status history
+func migrateTriageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	statements := []string{
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'new'",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS assignee VARCHAR(255) NULL",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS tags JSON NULL",
+		"CREATE INDEX IF NOT EXISTS idx_form_responses_form_status ON form_responses (form_id, status)",
+		`CREATE TABLE IF NOT EXISTS response_notes (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			response_id INT NOT NULL,
+			parent_id INT NULL,
+			author VARCHAR(255) NOT NULL,
+			body TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (response_id) REFERENCES form_responses(id) ON DELETE CASCADE,
+			FOREIGN KEY (parent_id) REFERENCES response_notes(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS response_status_history (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			response_id INT NOT NULL,
+			from_status VARCHAR(20) NOT NULL,
+			to_status VARCHAR(20) NOT NULL,
+			actor VARCHAR(255) NOT NULL,
+			changed_at DATETIME NOT NULL,
+			FOREIGN KEY (response_id) REFERENCES form_responses(id) ON DELETE CASCADE
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running triage migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Println("Successfully migrated database for response triage")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Triage migration completed successfully",
+	})
+}
+
+// migrateSearchHandler creates the full-text index of normalized response answers
+func migrateSearchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS response_search_documents (
+			response_id INT PRIMARY KEY,
+			form_id INT NOT NULL,
+			content MEDIUMTEXT NOT NULL,
+			INDEX idx_response_search_form (form_id),
+			FULLTEXT INDEX ft_response_search_content (content),
+			FOREIGN KEY (response_id) REFERENCES form_responses(id) ON DELETE CASCADE
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci
+	`)
+	if err != nil {
+		log.Printf("Error creating search index table: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully migrated database for response search")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Search migration completed; POST /api/forms/{id}/responses/search/reindex to backfill each form",
+	})
+}
+
+// migrateWorkspacesHandler adds workspaces and scopes existing forms, responses and
+// audit events to the default workspace
+func migrateWorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS workspaces (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			max_forms INT NULL,
+			max_monthly_responses INT NULL,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+		"INSERT IGNORE INTO workspaces (id, name, created_at, updated_at) VALUES (1, 'Default', NOW(), NOW())",
+		`CREATE TABLE IF NOT EXISTS workspace_members (
+			workspace_id INT NOT NULL,
+			user VARCHAR(255) NOT NULL,
+			role VARCHAR(20) NOT NULL DEFAULT 'member',
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (workspace_id, user),
+			INDEX idx_workspace_members_user (user),
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE IF NOT EXISTS workspace_usage (
+			workspace_id INT NOT NULL,
+			period CHAR(7) NOT NULL,
+			responses INT NOT NULL DEFAULT 0,
+			PRIMARY KEY (workspace_id, period),
+			FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE
+		)`,
+		"ALTER TABLE forms ADD COLUMN IF NOT EXISTS workspace_id INT NOT NULL DEFAULT 1",
+		"CREATE INDEX IF NOT EXISTS idx_forms_workspace ON forms (workspace_id, is_active)",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS workspace_id INT NOT NULL DEFAULT 1",
+		"CREATE INDEX IF NOT EXISTS idx_form_responses_workspace ON form_responses (workspace_id)",
+		// Adding a defaulted column does not fire the append-only triggers
+		"ALTER TABLE audit_events ADD COLUMN IF NOT EXISTS workspace_id INT NOT NULL DEFAULT 1",
+		"CREATE INDEX IF NOT EXISTS idx_audit_events_workspace ON audit_events (workspace_id, created_at)",
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running workspace migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Println("Successfully migrated database for workspaces")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Workspace migration completed successfully",
+	})
+}
+
+// migrateSlugsHandler adds public slugs and share tokens and backfills them for
+// existing forms
+func migrateSlugsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	statements := []string{
+		"ALTER TABLE forms ADD COLUMN IF NOT EXISTS slug VARCHAR(100) NULL",
+		"ALTER TABLE forms ADD COLUMN IF NOT EXISTS public_token CHAR(32) NULL",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_forms_slug ON forms (slug)",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_forms_public_token ON forms (public_token)",
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running slug migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	rows, err := db.Query("SELECT id, title FROM forms WHERE slug IS NULL OR public_token IS NULL ORDER BY id")
+	if err != nil {
+		log.Printf("Error fetching forms to backfill: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+	titles := make(map[int]MultiLanguageText)
+	var ids []int
+	for rows.Next() {
+		var id int
+		var title MultiLanguageText
+		if err := rows.Scan(&id, &title); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		titles[id] = title
+	}
+	rows.Close()
+
+	backfilled := 0
+	for _, id := range ids {
+		formSlug, err := uniqueSlug(db, titles[id], id)
+		if err != nil {
+			log.Printf("Error generating slug for form %d: %v", id, err)
+			continue
+		}
+		token, err := newPublicToken()
+		if err != nil {
+			continue
+		}
+		_, err = db.Exec(`
+			UPDATE forms SET slug = COALESCE(slug, ?), public_token = COALESCE(public_token, ?)
+			WHERE id = ?
+		`, formSlug, token, id)
+		if err != nil {
+			log.Printf("Error backfilling slug for form %d: %v", id, err)
+			continue
+		}
+		log.Printf("Form %d: slug %q", id, formSlug)
+		backfilled++
+	}
+
+	log.Printf("Successfully migrated database for public slugs (%d forms backfilled)", backfilled)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":     "success",
+		"message":    "Slug migration completed successfully",
+		"backfilled": backfilled,
+	})
+}
+
+// migrateVersionsHandler adds the version counter behind form ETags
+func migrateVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if _, err := db.Exec("ALTER TABLE forms ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1"); err != nil {
+		log.Printf("Error adding version column: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully migrated database for form versions")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Version migration completed successfully",
+	})
+}
+
+// migrateDraftsHandler adds form pages and the tables behind save-and-resume drafts
+func migrateDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-        // Extract form ID from URL path
-        path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
-        formID, err := strconv.Atoi(path)
-        if err != nil {
-                http.Error(w, "Invalid form ID", http.StatusBadRequest)
-                return
-        }
+	w.Header().Set("Content-Type", "application/json")
 
-        var formData struct {
-                Title            MultiLanguageText `json:"title"`
-                Description      MultiLanguageText `json:"description"`
-                Fields           []FormField       `json:"fields"`
-                SubmitButtonText MultiLanguageText `json:"submitButtonText"`
-                HeroImageUrl     string            `json:"heroImageUrl"`
-        }
+	statements := []string{
+		"ALTER TABLE forms ADD COLUMN IF NOT EXISTS pages JSON NULL",
+		`CREATE TABLE IF NOT EXISTS form_drafts (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			form_id INT NOT NULL,
+			workspace_id INT NOT NULL,
+			resume_token_hash CHAR(64) NOT NULL,
+			phone_number TEXT NULL,
+			phone_normalized VARCHAR(32) NULL,
+			phone_blind_index CHAR(64) NULL,
+			page_id VARCHAR(100) NULL,
+			response_data JSON NOT NULL,
+			language VARCHAR(2) NOT NULL DEFAULT 'en',
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			UNIQUE KEY idx_form_drafts_token (resume_token_hash),
+			INDEX idx_form_drafts_phone (form_id, phone_normalized),
+			INDEX idx_form_drafts_blind_index (form_id, phone_blind_index),
+			INDEX idx_form_drafts_expires (expires_at)
+		)`,
+		`CREATE TABLE IF NOT EXISTS draft_verifications (
+			id INT AUTO_INCREMENT PRIMARY KEY,
+			form_id INT NOT NULL,
+			phone_key CHAR(64) NOT NULL,
+			code_hash CHAR(64) NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			INDEX idx_draft_verifications_phone (form_id, phone_key)
+		)`,
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running drafts migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
 
-        if err := json.NewDecoder(r.Body).Decode(&formData); err != nil {
-                http.Error(w, "Invalid JSON", http.StatusBadRequest)
-                return
-        }
+	log.Println("Successfully migrated database for pages and drafts")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Drafts migration completed successfully",
+	})
+}
 
-        // Marshal MultiLanguageText fields as JSON
-        titleJSON, err := json.Marshal(formData.Title)
-        if err != nil {
-                http.Error(w, "Error encoding title", http.StatusInternalServerError)
-                return
-        }
-        descriptionJSON, err := json.Marshal(formData.Description)
-        if err != nil {
-                http.Error(w, "Error encoding description", http.StatusInternalServerError)
-                return
-        }
-        submitButtonTextJSON, err := json.Marshal(formData.SubmitButtonText)
-        if err != nil {
-                http.Error(w, "Error encoding submit button text", http.StatusInternalServerError)
-                return
-        }
-        fieldsJSON, err := json.Marshal(formData.Fields)
-        if err != nil {
-                http.Error(w, "Error encoding fields", http.StatusInternalServerError)
-                return
-        }
+// migrateFunnelHandler adds the per-session table behind funnel analytics
+func migrateFunnelHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-        // Update form (MySQL compatible)
-        _, err = db.Exec(`
-                UPDATE forms 
-                SET title = ?, description = ?, fields = ?, submit_button_text = ?, hero_image_url = ?, updated_at = NOW()
-                WHERE id = ? AND is_active = true
-        `, titleJSON, descriptionJSON, fieldsJSON, submitButtonTextJSON, formData.HeroImageUrl, formID)
-        if err != nil {
-                log.Printf("Error updating form: %v", err)
-                http.Error(w, "Error updating form", http.StatusInternalServerError)
-                return
-        }
-        // Fetch the updated row
-        var form Form
-        var heroImageUrl sql.NullString
-        var fieldsJSONResult []byte
-        var titleJSONResult, descriptionJSONResult, submitButtonTextJSONResult []byte
-        err = db.QueryRow(`
-                SELECT id, title, description, fields, submit_button_text, hero_image_url, is_active, created_at, updated_at
-                FROM forms WHERE id = ?
-        `, formID).Scan(
-                &form.ID, &titleJSONResult, &descriptionJSONResult, &fieldsJSONResult, &submitButtonTextJSONResult, &heroImageUrl, &form.IsActive, &form.CreatedAt, &form.UpdatedAt,
-        )
-        if err != nil {
-                log.Printf("Error fetching updated form: %v", err)
-                http.Error(w, "Error fetching updated form", http.StatusInternalServerError)
-                return
-        }
-        
-        // Unmarshal JSON fields
-        if err := json.Unmarshal(titleJSONResult, &form.Title); err != nil {
-                log.Printf("Error unmarshaling title: %v", err)
-                http.Error(w, "Error parsing title", http.StatusInternalServerError)
-                return
-        }
-        if err := json.Unmarshal(descriptionJSONResult, &form.Description); err != nil {
-                log.Printf("Error unmarshaling description: %v", err)
-                http.Error(w, "Error parsing description", http.StatusInternalServerError)
-                return
-        }
-        if err := json.Unmarshal(submitButtonTextJSONResult, &form.SubmitButtonText); err != nil {
-                log.Printf("Error unmarshaling submitButtonText: %v", err)
-                http.Error(w, "Error parsing submitButtonText", http.StatusInternalServerError)
-                return
-        }
-        
-        if heroImageUrl.Valid {
-                form.HeroImageUrl = heroImageUrl.String
-        } else {
-                form.HeroImageUrl = ""
-        }
-        if err := json.Unmarshal(fieldsJSONResult, &form.Fields); err != nil {
-                log.Printf("Error parsing fields: %v", err)
-                http.Error(w, "Error parsing fields", http.StatusInternalServerError)
-                return
-        }
-        w.Header().Set("Content-Type", "application/json")
-        json.NewEncoder(w).Encode(form)
+	w.Header().Set("Content-Type", "application/json")
+
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS form_sessions (
+		form_id INT NOT NULL,
+		workspace_id INT NOT NULL,
+		session_id VARCHAR(64) NOT NULL,
+		viewed_at DATETIME NOT NULL,
+		started_at DATETIME NULL,
+		last_field_id VARCHAR(255) NULL,
+		last_field_position INT NULL,
+		submitted_at DATETIME NULL,
+		response_id INT NULL,
+		updated_at DATETIME NOT NULL,
+		PRIMARY KEY (form_id, session_id),
+		INDEX idx_form_sessions_viewed (form_id, viewed_at)
+	)`)
+	if err != nil {
+		log.Printf("Error running funnel migration: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("Successfully migrated database for funnel analytics")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Funnel migration completed successfully",
+	})
 }
 
-// Delete form
-func deleteFormHandler(w http.ResponseWriter, r *http.Request) {
-        if r.Method != "DELETE" {
-                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-                return
-        }
+// migrateAttributionHandler adds the metadata column holding where each response came from
+func migrateAttributionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-        // Extract form ID from URL path
-        path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
-        formID, err := strconv.Atoi(path)
-        if err != nil {
-                http.Error(w, "Invalid form ID", http.StatusBadRequest)
-                return
-        }
+	w.Header().Set("Content-Type", "application/json")
 
-        // Soft delete by setting is_active to false
-        result, err := db.Exec(`
-                UPDATE forms 
-                SET is_active = false, updated_at = NOW()
-                WHERE id = ? AND is_active = true
-        `, formID)
+	_, err := db.Exec("ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS metadata JSON NULL")
+	if err != nil {
+		log.Printf("Error running attribution migration: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
 
-        if err != nil {
-                http.Error(w, "Error deleting form", http.StatusInternalServerError)
-                return
-        }
+	log.Println("Successfully migrated database for response attribution")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Attribution migration completed successfully",
+	})
+}
 
-        rowsAffected, err := result.RowsAffected()
-        if err != nil {
-                http.Error(w, "Error checking deletion result", http.StatusInternalServerError)
-                return
-        }
+// migrateQuizHandler adds quiz settings to forms and the score to form_responses
+func migrateQuizHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-        if rowsAffected == 0 {
-                http.Error(w, "Form not found", http.StatusNotFound)
-                return
-        }
+	w.Header().Set("Content-Type", "application/json")
 
-        w.Header().Set("Content-Type", "application/json")
-        w.WriteHeader(http.StatusOK)
-        fmt.Fprintf(w, `{"message": "Form deleted successfully"}`)
-}
+	statements := []string{
+		"ALTER TABLE forms ADD COLUMN IF NOT EXISTS quiz JSON NULL",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS score DOUBLE NULL",
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running quiz migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
 
-// Get form responses
-func getFormResponsesHandler(w http.ResponseWriter, r *http.Request) {
-        if r.Method != "GET" {
-                http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-                return
-        }
+	log.Println("Successfully migrated database for quizzes")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Quiz migration completed successfully",
+	})
+}
 
-        // Extract form ID from URL path
-        path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
-        parts := strings.Split(path, "/")
-        if len(parts) != 2 || parts[1] != "responses" {
-                http.Error(w, "Invalid URL format", http.StatusBadRequest)
-                return
-        }
+// migrateCapacityHandler creates the counters of places taken in options with a capacity
+func migrateCapacityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-        formID, err := strconv.Atoi(parts[0])
-        if err != nil {
-                http.Error(w, "Invalid form ID", http.StatusBadRequest)
-                return
-        }
+	w.Header().Set("Content-Type", "application/json")
 
-        rows, err := db.Query(`
-                SELECT id, form_id, phone_number, response_data, submitted_at
-                FROM form_responses
-                WHERE form_id = ?
-                ORDER BY submitted_at DESC
-        `, formID)
-        if err != nil {
-                http.Error(w, "Error fetching responses", http.StatusInternalServerError)
-                return
-        }
-        defer rows.Close()
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS form_option_counts (
+		form_id INT NOT NULL,
+		field_id VARCHAR(255) NOT NULL,
+		option_key CHAR(64) NOT NULL,
+		taken INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (form_id, field_id, option_key)
+	)`)
+	if err != nil {
+		log.Printf("Error running capacity migration: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
 
-        var responses []FormResponse
-        for rows.Next() {
-                var response FormResponse
-                var responseDataJSON []byte
+	log.Println("Successfully migrated database for option capacities")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Capacity migration completed successfully",
+	})
+}
 
-                err := rows.Scan(
-                        &response.ID, &response.FormID, &response.PhoneNumber,
-                        &responseDataJSON, &response.SubmittedAt,
-                )
-                if err != nil {
-                        http.Error(w, "Error scanning response", http.StatusInternalServerError)
-                        return
-                }
+// migrateShuffleHandler adds the order respondents saw shuffled questions and options in
+// to form_responses
+func migrateShuffleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
 
-                if err := json.Unmarshal(responseDataJSON, &response.ResponseData); err != nil {
-                        http.Error(w, "Error parsing response data", http.StatusInternalServerError)
-                        return
-                }
+	w.Header().Set("Content-Type", "application/json")
 
-                responses = append(responses, response)
-        }
+	_, err := db.Exec("ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS display_order JSON NULL")
+	if err != nil {
+		log.Printf("Error running shuffle migration: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
 
-        w.Header().Set("Content-Type", "application/json")
-        json.NewEncoder(w).Encode(responses)
+	log.Println("Successfully migrated database for shuffled ordering")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "success",
+		"message": "Shuffle migration completed successfully",
+	})
 }
 
-// migrateHeroImageHandler migrates the hero_image_url column from VARCHAR(512) to TEXT
-func migrateHeroImageHandler(w http.ResponseWriter, r *http.Request) {
+// migrateIdentityHandler lets responses be stored without a phone number and adds the
+// email columns for forms that ask for one. Empty phone numbers, left by anonymization,
+// become NULL; existing forms keep asking for a phone number.
+func migrateIdentityHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -739,23 +7608,40 @@ func migrateHeroImageHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	// Execute the ALTER TABLE statement
-	_, err := db.Exec("ALTER TABLE forms MODIFY COLUMN hero_image_url TEXT")
+	statements := []string{
+		"ALTER TABLE form_responses MODIFY COLUMN phone_number TEXT NULL",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS email TEXT NULL",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS email_normalized VARCHAR(320) NULL",
+		"ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS email_blind_index CHAR(64) NULL",
+		"CREATE INDEX IF NOT EXISTS idx_form_responses_email_normalized ON form_responses (email_normalized)",
+		"CREATE INDEX IF NOT EXISTS idx_form_responses_email_blind_index ON form_responses (email_blind_index)",
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("Error running identity migration: %v", err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+	result, err := db.Exec("UPDATE form_responses SET phone_number = NULL WHERE phone_number = ''")
 	if err != nil {
-		log.Printf("Error migrating hero_image_url column: %v", err)
+		log.Printf("Error clearing empty phone numbers: %v", err)
 		http.Error(w, "Migration failed", http.StatusInternalServerError)
 		return
 	}
+	cleared, _ := result.RowsAffected()
 
-	log.Println("Successfully migrated hero_image_url column to TEXT")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "success",
-		"message": "hero_image_url column migrated to TEXT",
+	log.Printf("Successfully migrated database for identity modes; %d empty phone numbers cleared", cleared)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"message": "Identity migration completed successfully",
+		"cleared": cleared,
 	})
 }
 
-// migrateMultiLanguageHandler migrates forms to support multi-language content
-func migrateMultiLanguageHandler(w http.ResponseWriter, r *http.Request) {
+// migrateDedupeHandler creates the table that enforces preventDuplicates and records the
+// respondents of every form that already prevents them
+func migrateDedupeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -763,18 +7649,52 @@ func migrateMultiLanguageHandler(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	// Add language column to form_responses table
-	_, err := db.Exec("ALTER TABLE form_responses ADD COLUMN IF NOT EXISTS language VARCHAR(2) DEFAULT 'en'")
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS form_response_identities (
+		form_id INT NOT NULL,
+		identity_key CHAR(64) NOT NULL,
+		response_id INT NOT NULL,
+		PRIMARY KEY (form_id, identity_key),
+		INDEX idx_form_response_identities_response (response_id),
+		FOREIGN KEY (response_id) REFERENCES form_responses(id) ON DELETE CASCADE
+	)`)
 	if err != nil {
-		log.Printf("Error adding language column to form_responses: %v", err)
+		log.Printf("Error running dedupe migration: %v", err)
 		http.Error(w, "Migration failed", http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Successfully migrated database for multi-language support")
-	json.NewEncoder(w).Encode(map[string]string{
+	rows, err := db.Query("SELECT id, settings FROM forms WHERE settings IS NOT NULL")
+	if err != nil {
+		log.Printf("Error selecting forms for dedupe backfill: %v", err)
+		http.Error(w, "Migration failed", http.StatusInternalServerError)
+		return
+	}
+	var formIDs []int
+	for rows.Next() {
+		var id int
+		var settings FormSettings
+		if err := rows.Scan(&id, &settings); err != nil {
+			log.Printf("Error scanning form for dedupe backfill: %v", err)
+			continue
+		}
+		if settings.PreventDuplicates {
+			formIDs = append(formIDs, id)
+		}
+	}
+	rows.Close()
+	for _, id := range formIDs {
+		if err := indexFormIdentities(db, id); err != nil {
+			log.Printf("Error indexing respondents of form %d: %v", id, err)
+			http.Error(w, "Migration failed", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	log.Printf("Successfully migrated database for duplicate prevention; %d forms indexed", len(formIDs))
+	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":  "success",
-		"message": "Multi-language migration completed successfully",
+		"message": "Dedupe migration completed successfully",
+		"forms":   len(formIDs),
 	})
 }
 
@@ -853,7 +7773,7 @@ func migrateFieldsHandler(w http.ResponseWriter, r *http.Request) {
 				log.Printf("Error marshaling migrated fields for form %d: %v", formID, err)
 				continue
 			}
-			_, err = db.Exec("UPDATE forms SET fields = ? WHERE id = ?", newFieldsJSON, formID)
+			_, err = db.Exec("UPDATE forms SET fields = ?, version = version + 1 WHERE id = ?", newFieldsJSON, formID)
 			if err != nil {
 				log.Printf("Error updating migrated fields for form %d: %v", formID, err)
 				continue
@@ -889,13 +7809,44 @@ func setupRoutes() {
 
         http.HandleFunc("/api/forms/", func(w http.ResponseWriter, r *http.Request) {
                 path := strings.TrimPrefix(r.URL.Path, "/api/forms/")
-                if strings.Contains(path, "/responses") {
+                // {id}/fields[/{fieldId}] goes first so field IDs such as "funnel" can't
+                // be mistaken for the other sub-resources
+                segments := strings.Split(path, "/")
+                if path == "trash" {
+                        getTrashHandler(w, r)
+                } else if path == "import" {
+                        importFormHandler(w, r)
+                } else if len(segments) >= 2 && segments[1] == "fields" {
+                        formFieldsHandler(w, r)
+                } else if strings.HasSuffix(path, "/restore") {
+                        restoreFormHandler(w, r)
+                } else if strings.HasSuffix(path, "/permanent") {
+                        purgeFormHandler(w, r)
+                } else if strings.HasSuffix(path, "/token/rotate") {
+                        rotateFormTokenHandler(w, r)
+                } else if strings.HasSuffix(path, "/retention/dry-run") {
+                        retentionDryRunHandler(w, r)
+                } else if strings.HasSuffix(path, "/responses/stream") {
+                        streamResponsesHandler(w, r)
+                } else if strings.HasSuffix(path, "/responses/search") {
+                        searchResponsesHandler(w, r)
+                } else if strings.HasSuffix(path, "/responses/search/reindex") {
+                        reindexResponsesHandler(w, r)
+                } else if strings.HasSuffix(path, "/responses/export") {
+                        exportResponsesHandler(w, r)
+                } else if strings.HasSuffix(path, "/analytics") {
+                        formAnalyticsHandler(w, r)
+                } else if strings.HasSuffix(path, "/funnel") {
+                        formFunnelHandler(w, r)
+                } else if strings.Contains(path, "/responses") {
                         getFormResponsesHandler(w, r)
                 } else {
                         if r.Method == "GET" {
                                 getFormHandler(w, r)
                         } else if r.Method == "PUT" {
                                 updateFormHandler(w, r)
+                        } else if r.Method == "PATCH" {
+                                patchFormHandler(w, r)
                         } else if r.Method == "DELETE" {
                                 deleteFormHandler(w, r)
                         } else {
@@ -905,9 +7856,47 @@ func setupRoutes() {
         })
 
         http.HandleFunc("/api/submit", submitFormHandler)
+        http.HandleFunc("/api/drafts", createDraftHandler)
+        http.HandleFunc("/api/drafts/", draftHandler)
+        http.HandleFunc("/api/events", formEventHandler)
+        http.HandleFunc("/api/public/forms/", getPublicFormHandler)
+        http.HandleFunc("/api/audit", getAuditHandler)
+        http.HandleFunc("/api/responses/", responseTriageHandler)
+        http.HandleFunc("/api/responses/stream", streamResponsesHandler)
+        http.HandleFunc("/api/responses/stream-token", streamTokenHandler)
+
+        // Workspace administration
+        http.HandleFunc("/api/admin/workspaces", workspacesHandler)
+        http.HandleFunc("/api/admin/workspaces/", workspaceHandler)
+
+        // Data subject requests
+        http.HandleFunc("/api/admin/privacy/responses", privacyLookupHandler)
+        http.HandleFunc("/api/admin/privacy/access", privacyAccessHandler)
+        http.HandleFunc("/api/admin/privacy/erasure", privacyErasureHandler)
+        http.HandleFunc("/api/admin/privacy/log", privacyLogHandler)
+        http.HandleFunc("/api/admin/privacy/log/verify", privacyLogVerifyHandler)
+
         http.HandleFunc("/migrate-hero-image", migrateHeroImageHandler)
         http.HandleFunc("/migrate-multi-language", migrateMultiLanguageHandler)
         http.HandleFunc("/migrate-fields", migrateFieldsHandler)
+        http.HandleFunc("/migrate-trash", migrateTrashHandler)
+        http.HandleFunc("/migrate-privacy", migratePrivacyHandler)
+        http.HandleFunc("/migrate-retention", migrateRetentionHandler)
+        http.HandleFunc("/migrate-encryption", migrateEncryptionHandler)
+        http.HandleFunc("/migrate-audit", migrateAuditHandler)
+        http.HandleFunc("/migrate-triage", migrateTriageHandler)
+        http.HandleFunc("/migrate-search", migrateSearchHandler)
+        http.HandleFunc("/migrate-workspaces", migrateWorkspacesHandler)
+        http.HandleFunc("/migrate-slugs", migrateSlugsHandler)
+        http.HandleFunc("/migrate-versions", migrateVersionsHandler)
+        http.HandleFunc("/migrate-drafts", migrateDraftsHandler)
+        http.HandleFunc("/migrate-funnel", migrateFunnelHandler)
+        http.HandleFunc("/migrate-attribution", migrateAttributionHandler)
+        http.HandleFunc("/migrate-quiz", migrateQuizHandler)
+        http.HandleFunc("/migrate-capacity", migrateCapacityHandler)
+        http.HandleFunc("/migrate-shuffle", migrateShuffleHandler)
+        http.HandleFunc("/migrate-identity", migrateIdentityHandler)
+        http.HandleFunc("/migrate-dedupe", migrateDedupeHandler)
 }
 
 // main is the entry point of the Dynamic Form Creator API
@@ -921,10 +7910,34 @@ func main() {
         // Initialize database
         initDB()
         defer db.Close()
+        initEncryption()
+        initEvents()
+        initTrustedProxies()
+        if keyring == nil && os.Getenv("IP_HASH_SALT") == "" {
+                fmt.Println("Neither field encryption nor IP_HASH_SALT is set; forms hashing IP addresses will not keep them")
+        }
+
+        // rotate-keys re-encrypts stored PII under the active key and exits
+        if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+                if err := rotateEncryptionKeys(os.Args[2:]); err != nil {
+                        log.Fatalf("Key rotation failed: %v", err)
+                }
+                return
+        }
 
         // Setup routes
         setupRoutes()
 
+        // Purge forms that have outlived the trash retention period
+        if trashRetentionDays() > 0 {
+                runPeriodically("trash purge", envDuration("TRASH_PURGE_INTERVAL", time.Hour),
+                        afterMigration("/migrate-trash", "forms", "deleted_at", purgeExpiredTrash))
+        }
+        runPeriodically("response retention", envDuration("RETENTION_INTERVAL", time.Hour),
+                afterMigration("/migrate-retention", "forms", "settings", applyRetentionPolicies))
+        runPeriodically("draft purge", envDuration("DRAFT_PURGE_INTERVAL", time.Hour),
+                afterMigration("/migrate-drafts", "draft_verifications", "", purgeExpiredDrafts))
+
         // Get port from environment variable, default to 5000 for Replit compatibility
         port := os.Getenv("SERVER_PORT")
         if port == "" {
@@ -934,19 +7947,50 @@ func main() {
                 port = "5000"
         }
 
-        // Create server with CORS middleware
-        handler := corsMiddleware(http.DefaultServeMux)
+        // Create server with CORS and request ID middleware
+        handler := corsMiddleware(requestIDMiddleware(http.DefaultServeMux))
 
         // Start the HTTP server
         fmt.Printf("Server starting on port %s...\n", port)
         fmt.Printf("API Documentation:\n")
         fmt.Printf("  POST   /api/forms - Create a new form\n")
+        fmt.Printf("  POST   /api/forms/import - Import a form definition\n")
         fmt.Printf("  GET    /api/forms - Get all forms\n")
         fmt.Printf("  GET    /api/forms/{id} - Get specific form\n")
-        fmt.Printf("  PUT    /api/forms/{id} - Update existing form\n")
+        fmt.Printf("  PUT    /api/forms/{id} - Update existing form (If-Match required)\n")
+        fmt.Printf("  PATCH  /api/forms/{id} - JSON Patch or merge patch a form\n")
+        fmt.Printf("  POST   /api/forms/{id}/fields - Add a field (PUT/DELETE .../fields/{fieldId}, POST .../fields/reorder)\n")
         fmt.Printf("  DELETE /api/forms/{id} - Delete form (soft delete)\n")
-        fmt.Printf("  POST   /api/submit - Submit form response\n")
-        fmt.Printf("  GET    /api/forms/{id}/responses - Get form responses\n")
+        fmt.Printf("  GET    /api/forms/trash - Get soft-deleted forms\n")
+        fmt.Printf("  POST   /api/forms/{id}/restore - Restore form from trash\n")
+        fmt.Printf("  DELETE /api/forms/{id}/permanent - Permanently delete trashed form\n")
+        fmt.Printf("  POST   /api/forms/{id}/retention/dry-run - Preview retention policy\n")
+        fmt.Printf("  GET    /api/public/forms/{slug|token|id} - Get form for respondents\n")
+        fmt.Printf("  POST   /api/forms/{id}/token/rotate - Rotate form share token\n")
+        fmt.Printf("  POST   /api/submit - Submit form response (resumeToken submits a draft)\n")
+        fmt.Printf("  POST   /api/drafts - Save the first page of a draft\n")
+        fmt.Printf("  GET    /api/drafts/{token} - Resume a draft (PUT saves a page, DELETE discards)\n")
+        fmt.Printf("  POST   /api/drafts/otp - Send a code to resume drafts by phone (.../otp/verify)\n")
+        fmt.Printf("  GET    /api/forms/{id}/responses - Get form responses (filter by status, tag, utm_*, referrer)\n")
+        fmt.Printf("  GET    /api/forms/{id}/responses/export - Export responses and their attribution as CSV\n")
+        fmt.Printf("  GET    /api/forms/{id}/analytics - Per-field response analytics\n")
+        fmt.Printf("  GET    /api/forms/{id}/funnel?from=&to= - View, start and submit funnel\n")
+        fmt.Printf("  POST   /api/events - Funnel beacon from the public form (view/start/field)\n")
+        fmt.Printf("  GET    /api/forms/{id}/responses/search?q= - Search form responses\n")
+        fmt.Printf("  GET    /api/forms/{id}/responses/stream - Live form submissions (SSE)\n")
+        fmt.Printf("  GET    /api/responses/stream - Live submissions for all forms (SSE)\n")
+        fmt.Printf("  POST   /api/responses/stream-token - Short-lived token for opening a stream (?token=)\n")
+        fmt.Printf("  GET    /api/admin/privacy/responses?phoneNumber=&email= - Find a person's responses\n")
+        fmt.Printf("  POST   /api/admin/privacy/access - Export a person's responses (json/zip)\n")
+        fmt.Printf("  POST   /api/admin/privacy/erasure - Delete or anonymize a person's responses\n")
+        fmt.Printf("  GET    /api/admin/privacy/log - Privacy action log\n")
+        fmt.Printf("  GET    /api/audit - Query the admin audit log\n")
+        fmt.Printf("  PUT    /api/responses/{id}/status|assignee|tags - Triage a response\n")
+        fmt.Printf("  GET    /api/responses/{id}/notes|history - Response notes and status history\n")
+        fmt.Printf("  POST   /api/responses/{id}/notes - Add a note to a response\n")
+        fmt.Printf("  GET    /api/admin/workspaces - List workspaces (POST to create)\n")
+        fmt.Printf("  GET    /api/admin/workspaces/{id} - Workspace with quota usage (PUT, DELETE)\n")
+        fmt.Printf("  PUT    /api/admin/workspaces/{id}/members - Add or change a member\n")
         
         if err := http.ListenAndServe("0.0.0.0:"+port, handler); err != nil {
                 log.Fatalf("Server failed to start: %v", err)
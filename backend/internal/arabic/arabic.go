@@ -0,0 +1,123 @@
+// Package arabic normalizes Arabic (and mixed Arabic/Latin) text for search, so the
+// spelling variants people type interchangeably match each other.
+package arabic
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// normalizeRune maps a rune to its search form; ok is false for runes that are dropped
+func normalizeRune(r rune) (rune, bool) {
+	switch {
+	// Tashkeel, superscript alef and Quranic annotation marks
+	case r >= 0x064B && r <= 0x065F, r == 0x0670, r >= 0x06D6 && r <= 0x06ED:
+		return 0, false
+	// Tatweel
+	case r == 0x0640:
+		return 0, false
+	// Alef with hamza above/below, madda and wasla
+	case r == 'أ', r == 'إ', r == 'آ', r == 'ٱ':
+		return 'ا', true
+	case r == 'ؤ':
+		return 'و', true
+	case r == 'ئ', r == 'ى', r == 'ی':
+		return 'ي', true
+	case r == 'ة':
+		return 'ه', true
+	case r == 'ک':
+		return 'ك', true
+	// Eastern Arabic and Persian digits
+	case r >= '٠' && r <= '٩':
+		return '0' + (r - '٠'), true
+	case r >= '۰' && r <= '۹':
+		return '0' + (r - '۰'), true
+	}
+	return unicode.ToLower(r), true
+}
+
+// Normalize folds alef/hamza variants, taa marbuta and alef maqsura, removes tashkeel and
+// tatweel, converts Eastern Arabic digits to ASCII and lowercases Latin letters
+func Normalize(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if n, ok := normalizeRune(r); ok {
+			b.WriteRune(n)
+		}
+	}
+	return b.String()
+}
+
+// isTokenRune reports whether r is part of a search token
+func isTokenRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// Tokenize normalizes s and splits it into words
+func Tokenize(s string) []string {
+	return strings.FieldsFunc(Normalize(s), func(r rune) bool { return !isTokenRune(r) })
+}
+
+// span is a byte range of the original text
+type span struct{ start, end int }
+
+// Highlight HTML-escapes text and wraps every word that starts with one of the normalized
+// terms in open/close markers. Matching runs on normalized text so "احمد" highlights
+// "أَحْمَد". matched reports whether anything was highlighted.
+func Highlight(text string, terms []string, open, close string) (highlighted string, matched bool) {
+	// Build the normalized words with the original byte range each one covers
+	type word struct {
+		normalized []rune
+		span       span
+	}
+	var words []word
+	var current *word
+	for i, r := range text {
+		n, ok := normalizeRune(r)
+		width := len(string(r))
+		if !ok {
+			// Dropped marks stay inside the word they decorate
+			if current != nil {
+				current.span.end = i + width
+			}
+			continue
+		}
+		if !isTokenRune(n) {
+			current = nil
+			continue
+		}
+		if current == nil {
+			words = append(words, word{span: span{start: i}})
+			current = &words[len(words)-1]
+		}
+		current.normalized = append(current.normalized, n)
+		current.span.end = i + width
+	}
+
+	var hits []span
+	for _, w := range words {
+		normalized := string(w.normalized)
+		for _, term := range terms {
+			if term != "" && strings.HasPrefix(normalized, term) {
+				hits = append(hits, w.span)
+				break
+			}
+		}
+	}
+	if len(hits) == 0 {
+		return html.EscapeString(text), false
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, hit := range hits {
+		b.WriteString(html.EscapeString(text[last:hit.start]))
+		b.WriteString(open)
+		b.WriteString(html.EscapeString(text[hit.start:hit.end]))
+		b.WriteString(close)
+		last = hit.end
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String(), true
+}
@@ -0,0 +1,85 @@
+package arabic
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"alef with hamza above", "أحمد", "احمد"},
+		{"alef with hamza below", "إسلام", "اسلام"},
+		{"alef madda and wasla", "آمنة ٱلله", "امنه الله"},
+		{"hamza on waw and yaa", "مؤمن قائد", "مومن قايد"},
+		{"alef maqsura and Persian yaa", "مصطفى علی", "مصطفي علي"},
+		{"taa marbuta", "فاطمة", "فاطمه"},
+		{"Persian kaf", "کتاب", "كتاب"},
+		{"tashkeel", "مُحَمَّد", "محمد"},
+		{"tatweel", "محـــمد", "محمد"},
+		{"superscript alef", "هٰذا", "هذا"},
+		{"Eastern Arabic digits", "٠١٢٣٤٥٦٧٨٩", "0123456789"},
+		{"Persian digits", "۱۲۳", "123"},
+		{"Latin is lowercased", "Hello أحمد", "hello احمد"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Normalize(tt.in); got != tt.want {
+				t.Fatalf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := Tokenize("أحمد، رقم ٩٦٥-١٢٣ (Kuwait)!")
+	if want := []string{"احمد", "رقم", "965", "123", "kuwait"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tokenize = %q, want %q", got, want)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		terms     []string
+		want      string
+		wantMatch bool
+	}{
+		{"no hit is still escaped", `<script>alert("x")</script> & co`, []string{"zzz"},
+			`&lt;script&gt;alert(&#34;x&#34;)&lt;/script&gt; &amp; co`, false},
+		{"markup around a hit is escaped", `<script>أحمد</script>`, []string{"احمد"},
+			`&lt;script&gt;[أحمد]&lt;/script&gt;`, true},
+		{"ampersands on both sides", `a&b احمد&c`, []string{"احمد"},
+			`a&amp;b [احمد]&amp;c`, true},
+		{"markup that matches a term is escaped inside the marker", `<b>bold</b>`, []string{"b"},
+			`&lt;[b]&gt;[bold]&lt;/[b]&gt;`, true},
+		{"tashkeel stays inside the word", "قال أَحْمَدُ", []string{"احمد"},
+			"قال [أَحْمَدُ]", true},
+		{"tatweel between letters", "مرحبا محـــمد", []string{"محمد"},
+			"مرحبا [محـــمد]", true},
+		{"taa marbuta folds", "السيدة فاطمة", []string{"فاطمه"},
+			"السيدة [فاطمة]", true},
+		{"hamza folds", "إيمان", []string{"ايمان"},
+			"[إيمان]", true},
+		{"Eastern Arabic digits match ASCII", "رقم ٩٦٥١٢٣", []string{"965"},
+			"رقم [٩٦٥١٢٣]", true},
+		{"prefixes match", "Kuwaiti", []string{"kuw"},
+			"[Kuwaiti]", true},
+		{"only word starts match", "unkuwaiti", []string{"kuw"},
+			"unkuwaiti", false},
+		{"empty terms match nothing", "أحمد", []string{""},
+			"أحمد", false},
+		{"every hit", "Ali and ali", []string{"ali"},
+			"[Ali] and [ali]", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, matched := Highlight(tt.text, tt.terms, "[", "]")
+			if got != tt.want || matched != tt.wantMatch {
+				t.Fatalf("Highlight = %q, %v; want %q, %v", got, matched, tt.want, tt.wantMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+// Package broker fans server events out to live subscribers such as SSE streams.
+//
+// Broker is the seam for running several replicas: the in-memory implementation only
+// reaches subscribers in the same process, a shared bus implementation (Redis streams,
+// NATS, ...) can replace it without touching the handlers.
+package broker
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// ErrClosed is returned when publishing to or subscribing on a closed broker
+var ErrClosed = errors.New("broker: closed")
+
+// Event is a published message. IDs are opaque to subscribers but increase in publish
+// order, so a client can resume after the last ID it saw.
+type Event struct {
+	ID    string
+	Topic string
+	Type  string
+	Data  []byte
+}
+
+// Broker publishes events to topics and streams them to subscribers
+type Broker interface {
+	// Publish assigns the event an ID and delivers it to current subscribers of the topic
+	Publish(topic, eventType string, data []byte) (Event, error)
+	// Subscribe streams events of the topic ("" for every topic) until ctx is done. With
+	// lastEventID set, retained events published after it are replayed first. The
+	// channel is closed when ctx ends or the subscriber falls too far behind.
+	Subscribe(ctx context.Context, topic, lastEventID string) (<-chan Event, error)
+	Close() error
+}
+
+// subscriberBuffer is how many undelivered events a subscriber may lag behind before it
+// is dropped; the client reconnects and resumes from its Last-Event-ID
+const subscriberBuffer = 64
+
+type subscriber struct {
+	topic  string
+	events chan Event
+}
+
+// Memory is an in-process Broker that retains the most recent events for resumption
+type Memory struct {
+	mu          sync.Mutex
+	seq         uint64
+	history     []Event
+	next        int
+	size        int
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+// NewMemory returns an in-process broker retaining up to retain events for replay
+func NewMemory(retain int) *Memory {
+	if retain < 1 {
+		retain = 1
+	}
+	return &Memory{
+		history:     make([]Event, retain),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (m *Memory) Publish(topic, eventType string, data []byte) (Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return Event{}, ErrClosed
+	}
+
+	m.seq++
+	event := Event{ID: strconv.FormatUint(m.seq, 10), Topic: topic, Type: eventType, Data: data}
+	m.history[m.next] = event
+	m.next = (m.next + 1) % len(m.history)
+	if m.size < len(m.history) {
+		m.size++
+	}
+
+	for sub := range m.subscribers {
+		if sub.topic != "" && sub.topic != topic {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			m.drop(sub)
+		}
+	}
+	return event, nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, topic, lastEventID string) (<-chan Event, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	var replay []Event
+	if lastEventID != "" {
+		// An unparseable ID replays everything retained rather than silently nothing
+		after, _ := strconv.ParseUint(lastEventID, 10, 64)
+		for i := 0; i < m.size; i++ {
+			event := m.history[(m.next-m.size+i+len(m.history))%len(m.history)]
+			id, _ := strconv.ParseUint(event.ID, 10, 64)
+			if id > after && (topic == "" || event.Topic == topic) {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	sub := &subscriber{topic: topic, events: make(chan Event, len(replay)+subscriberBuffer)}
+	for _, event := range replay {
+		sub.events <- event
+	}
+	m.subscribers[sub] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.drop(sub)
+	}()
+	return sub.events, nil
+}
+
+// Close disconnects every subscriber and rejects further use
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	for sub := range m.subscribers {
+		m.drop(sub)
+	}
+	return nil
+}
+
+// drop closes a subscriber's channel once; callers must hold m.mu
+func (m *Memory) drop(sub *subscriber) {
+	if _, ok := m.subscribers[sub]; ok {
+		delete(m.subscribers, sub)
+		close(sub.events)
+	}
+}
@@ -0,0 +1,360 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type node interface {
+	eval(answers map[string]interface{}) (interface{}, error)
+}
+
+type literal struct{ value interface{} }
+
+func (n *literal) eval(map[string]interface{}) (interface{}, error) { return n.value, nil }
+
+type ref struct{ id string }
+
+func (n *ref) eval(answers map[string]interface{}) (interface{}, error) {
+	return answers[n.id], nil
+}
+
+type list struct{ items []node }
+
+func (n *list) eval(answers map[string]interface{}) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(answers)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+type mapping struct {
+	keys   []string
+	values []node
+}
+
+func (n *mapping) eval(answers map[string]interface{}) (interface{}, error) {
+	m := make(map[string]interface{}, len(n.keys))
+	for i, key := range n.keys {
+		v, err := n.values[i].eval(answers)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+type conditional struct{ cond, then, otherwise node }
+
+func (n *conditional) eval(answers map[string]interface{}) (interface{}, error) {
+	cond, err := n.cond.eval(answers)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(cond) {
+		return n.then.eval(answers)
+	}
+	return n.otherwise.eval(answers)
+}
+
+type unary struct {
+	op      string
+	operand node
+}
+
+func (n *unary) eval(answers map[string]interface{}) (interface{}, error) {
+	v, err := n.operand.eval(answers)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		return !truthy(v), nil
+	}
+	x, err := toNumber(v)
+	if err != nil {
+		return nil, err
+	}
+	return -x, nil
+}
+
+type binary struct {
+	op          string
+	left, right node
+}
+
+func (n *binary) eval(answers map[string]interface{}) (interface{}, error) {
+	left, err := n.left.eval(answers)
+	if err != nil {
+		return nil, err
+	}
+	// && and || only evaluate their right side when it decides the result
+	switch n.op {
+	case "&&":
+		if !truthy(left) {
+			return false, nil
+		}
+		right, err := n.right.eval(answers)
+		return truthy(right), err
+	case "||":
+		if truthy(left) {
+			return true, nil
+		}
+		right, err := n.right.eval(answers)
+		return truthy(right), err
+	}
+	right, err := n.right.eval(answers)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "<", "<=", ">", ">=":
+		ordered, err := compare(n.op, left, right)
+		if err != nil {
+			return nil, err
+		}
+		return ordered, nil
+	case "+":
+		_, leftText := left.(string)
+		_, rightText := right.(string)
+		if leftText || rightText {
+			return toText(left) + toText(right), nil
+		}
+	}
+	x, err := toNumber(left)
+	if err != nil {
+		return nil, err
+	}
+	y, err := toNumber(right)
+	if err != nil {
+		return nil, err
+	}
+	var result float64
+	switch n.op {
+	case "+":
+		result = x + y
+	case "-":
+		result = x - y
+	case "*":
+		result = x * y
+	case "/":
+		if y == 0 {
+			return nil, errors.New("division by zero")
+		}
+		result = x / y
+	case "%":
+		if y == 0 {
+			return nil, errors.New("division by zero")
+		}
+		result = math.Mod(x, y)
+	}
+	return finite(result)
+}
+
+// finite rejects results that can't be stored as JSON numbers
+func finite(n float64) (interface{}, error) {
+	if math.IsNaN(n) || math.IsInf(n, 0) {
+		return nil, errors.New("result is not a finite number")
+	}
+	return n, nil
+}
+
+type call struct {
+	name string
+	fn   *function
+	args []node
+}
+
+func (n *call) eval(answers map[string]interface{}) (interface{}, error) {
+	// if only evaluates the branch it takes
+	if n.name == "if" {
+		return (&conditional{cond: n.args[0], then: n.args[1], otherwise: n.args[2]}).eval(answers)
+	}
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(answers)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	result, err := n.fn.call(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+	return result, nil
+}
+
+// truthy is false for null, false, 0, "" and empty lists and maps
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case float64:
+		return x != 0
+	case string:
+		return x != ""
+	case []interface{}:
+		return len(x) > 0
+	case map[string]interface{}:
+		return len(x) > 0
+	}
+	return true
+}
+
+// toNumber reads v as a number: null is 0, booleans are 1 and 0 and text must parse
+// as a finite number
+func toNumber(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return x, nil
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		if strings.TrimSpace(x) == "" {
+			return 0, nil
+		}
+		if n, err := strconv.ParseFloat(strings.TrimSpace(x), 64); err == nil && !math.IsNaN(n) && !math.IsInf(n, 0) {
+			return n, nil
+		}
+		return 0, fmt.Errorf("%q is not a number", x)
+	}
+	return 0, fmt.Errorf("a %s is not a number", typeName(v))
+}
+
+// toText renders v for joining to text; list items are separated by ", "
+func toText(v interface{}) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case float64:
+		return formatNumber(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case []interface{}:
+		parts := make([]string, len(x))
+		for i, item := range x {
+			parts[i] = toText(item)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return fmt.Sprint(v)
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case []interface{}:
+		return "list"
+	case map[string]interface{}:
+		return "map"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// numeric reports whether v is a number or text holding one
+func numeric(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return n, err == nil && !math.IsNaN(n) && !math.IsInf(n, 0)
+	}
+	return 0, false
+}
+
+// equal compares answers loosely: null equals "" and the empty list, and a number
+// equals text holding the same number, as answers to text fields often do
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return !truthy(a) && !truthy(b) && typeName(a) != "bool" && typeName(b) != "bool"
+	}
+	switch x := a.(type) {
+	case bool:
+		y, ok := b.(bool)
+		return ok && x == y
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		return false
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return x == y
+		}
+	}
+	x, okA := numeric(a)
+	y, okB := numeric(b)
+	return okA && okB && x == y
+}
+
+// compare orders two numbers or, when either side is text that isn't a number, two
+// texts; dates and times compare correctly as text
+func compare(op string, a, b interface{}) (bool, error) {
+	var c int
+	sa, textA := a.(string)
+	sb, textB := b.(string)
+	_, numA := numeric(a)
+	_, numB := numeric(b)
+	if textA && textB && (!numA || !numB) {
+		c = strings.Compare(sa, sb)
+	} else {
+		x, err := toNumber(a)
+		if err != nil {
+			return false, err
+		}
+		y, err := toNumber(b)
+		if err != nil {
+			return false, err
+		}
+		switch {
+		case x < y:
+			c = -1
+		case x > y:
+			c = 1
+		}
+	}
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	}
+	return c >= 0, nil
+}
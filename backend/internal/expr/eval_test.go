@@ -0,0 +1,143 @@
+package expr
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	answers := map[string]interface{}{
+		"n":      4.0,
+		"text":   "5",
+		"name":   "Sara",
+		"picks":  []interface{}{"a", "b"},
+		"yes":    true,
+		"empty":  "",
+		"nested": map[string]interface{}{"a": 1.0},
+	}
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		// Unanswered fields are null: 0 in arithmetic, "" in text
+		{"missing + 1", 1.0},
+		{"\"a\" + missing", "a"},
+		{"-missing", -0.0},
+		{"text * 2", 10.0},
+		{"yes + 1", 2.0},
+		{"\"n=\" + 2.5", "n=2.5"},
+		{"name + \": \" + picks", "Sara: a, b"},
+		{"7 % 3", 1.0},
+		// Equality is loose between null, "" and numbers held as text
+		{"missing == \"\"", true},
+		{"missing == empty", true},
+		{"missing == false", false},
+		{"text == 5", true},
+		{"text != 5", false},
+		{"yes == 1", false},
+		{"picks == [\"a\", \"b\"]", true},
+		{"picks == [\"b\", \"a\"]", false},
+		{"nested == nested", false},
+		// Text that isn't a number compares as text, so ISO dates order correctly
+		{"\"10\" > \"9\"", true},
+		{"\"b\" > \"a\"", true},
+		{"\"2024-01-02\" > \"2024-01-01\"", true},
+		{"n >= 4 && n <= 4", true},
+		{"!picks", false},
+		{"!empty", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := mustEval(t, tt.source, answers); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("= %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	answers := map[string]interface{}{
+		"zero":  0.0,
+		"big":   math.MaxFloat64,
+		"word":  "abc",
+		"inf":   "Inf",
+		"nan":   "NaN",
+		"picks": []interface{}{"a"},
+	}
+	tests := []struct {
+		source  string
+		wantErr string
+	}{
+		{"1 / 0", "division by zero"},
+		{"1 / zero", "division by zero"},
+		{"1 % zero", "division by zero"},
+		{"big * 10", "not a finite number"},
+		{"big + big", "not a finite number"},
+		{"-big - big", "not a finite number"},
+		// Text spelling out infinity or NaN is not a number either
+		{"inf * 1", "\"Inf\" is not a number"},
+		{"-nan", "\"NaN\" is not a number"},
+		{"word * 2", "\"abc\" is not a number"},
+		{"word < 1", "\"abc\" is not a number"},
+		{"picks - 1", "a list is not a number"},
+		{"{\"a\": 1} * 2", "a map is not a number"},
+		{"1 + (2 * (1 / 0))", "division by zero"},
+		{"[1 / 0]", "division by zero"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := Parse(tt.source)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, err := e.Eval(answers)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Eval = %v, %v; want an error containing %q", got, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// The side that isn't taken is never evaluated, so it can't fail the expression
+func TestShortCircuit(t *testing.T) {
+	tests := []struct {
+		source  string
+		want    interface{}
+		wantErr bool
+	}{
+		{"false && 1 / 0", false, false},
+		{"0 and 1 / 0", false, false},
+		{"true || 1 / 0", true, false},
+		{"1 or 1 / 0", true, false},
+		{"true && 1 / 0", nil, true},
+		{"false || 1 / 0", nil, true},
+		{"true ? 1 : 1 / 0", 1.0, false},
+		{"false ? 1 / 0 : 2", 2.0, false},
+		{"if(true, 1, 1 / 0)", 1.0, false},
+		{"if(false, 1 / 0, 2)", 2.0, false},
+		{"if(1 / 0, 1, 2)", nil, true},
+		// && and || always give booleans
+		{"1 && \"x\"", true, false},
+		{"0 || \"\"", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := Parse(tt.source)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, err := e.Eval(nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Eval = %v, want an error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Eval = %v, %v; want %v", got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+// Package expr is the expression language of calculated fields. Expressions are parsed
+// when a form is saved and evaluated against the answers of each response; they cannot
+// loop, call out or read anything but the answers they name.
+//
+//	price * quantity
+//	age >= 18 && country == "Kuwait" ? "eligible" : "not eligible"
+//	score(colour, {"Red": 1, "Blue": 2}) + score(extras, {"Wifi": 1, "Parking": 3})
+//
+// Fields are referenced by ID, written bare or, for IDs that aren't plain identifiers,
+// between backticks. An unanswered field is null, which counts as 0 in arithmetic and
+// as "" when joined to text.
+package expr
+
+import (
+	"fmt"
+)
+
+// MaxLength bounds the source of an expression
+const MaxLength = 2000
+
+// maxDepth bounds how deeply an expression nests, so parsing and evaluation can't
+// exhaust the stack
+const maxDepth = 64
+
+// Expr is a parsed expression
+type Expr struct {
+	source string
+	root   node
+	refs   []string
+}
+
+// SyntaxError is a problem found while parsing, at a byte offset of the source
+type SyntaxError struct {
+	Pos int
+	Msg string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at position %d", e.Msg, e.Pos+1)
+}
+
+// Parse parses source, checking its syntax and the names and argument counts of the
+// functions it calls
+func Parse(source string) (*Expr, error) {
+	if len(source) > MaxLength {
+		return nil, fmt.Errorf("must be at most %d characters", MaxLength)
+	}
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens, seen: make(map[string]bool)}
+	root, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{source: source, root: root, refs: p.refs}, nil
+}
+
+// String returns the source the expression was parsed from
+func (e *Expr) String() string { return e.source }
+
+// Refs lists the field IDs the expression reads, in order of first use
+func (e *Expr) Refs() []string {
+	return append([]string(nil), e.refs...)
+}
+
+// Eval evaluates the expression with the given answers, keyed by field ID. Answers are
+// null, numbers (float64), text, booleans or lists of them; the result is one of those.
+func (e *Expr) Eval(answers map[string]interface{}) (interface{}, error) {
+	return e.root.eval(answers)
+}
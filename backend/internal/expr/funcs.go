@@ -0,0 +1,210 @@
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// function is a built-in; maxArgs is -1 for functions taking any number of arguments
+type function struct {
+	minArgs, maxArgs int
+	call             func(args []interface{}) (interface{}, error)
+}
+
+func (f *function) arity() string {
+	switch {
+	case f.maxArgs < 0:
+		return fmt.Sprintf("at least %d argument(s)", f.minArgs)
+	case f.minArgs == f.maxArgs:
+		return fmt.Sprintf("%d argument(s)", f.minArgs)
+	}
+	return fmt.Sprintf("%d to %d arguments", f.minArgs, f.maxArgs)
+}
+
+// functions are the built-ins expressions may call. if is evaluated lazily by call.
+var functions = map[string]*function{
+	// if(cond, then, otherwise) is the function form of cond ? then : otherwise
+	"if": {minArgs: 3, maxArgs: 3},
+	// min, max and sum take numbers or lists of them, such as checkbox scores
+	"min": {minArgs: 1, maxArgs: -1, call: func(args []interface{}) (interface{}, error) {
+		return fold(args, math.Min)
+	}},
+	"max": {minArgs: 1, maxArgs: -1, call: func(args []interface{}) (interface{}, error) {
+		return fold(args, math.Max)
+	}},
+	"sum": {minArgs: 1, maxArgs: -1, call: func(args []interface{}) (interface{}, error) {
+		numbers, err := flatten(args)
+		if err != nil {
+			return nil, err
+		}
+		total := 0.0
+		for _, n := range numbers {
+			total += n
+		}
+		return finite(total)
+	}},
+	// count is the number of items picked or entries added; 0 when unanswered
+	"count": {minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		switch x := args[0].(type) {
+		case []interface{}:
+			return float64(len(x)), nil
+		case nil:
+			return 0.0, nil
+		}
+		if truthy(args[0]) {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	}},
+	// round(x) rounds to a whole number, round(x, digits) to that many decimals
+	"round": {minArgs: 1, maxArgs: 2, call: func(args []interface{}) (interface{}, error) {
+		x, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		digits := 0.0
+		if len(args) == 2 {
+			if digits, err = toNumber(args[1]); err != nil {
+				return nil, err
+			}
+		}
+		scale := math.Pow(10, math.Trunc(digits))
+		return finite(math.Round(x*scale) / scale)
+	}},
+	"floor": numeric1(math.Floor),
+	"ceil":  numeric1(math.Ceil),
+	"abs":   numeric1(math.Abs),
+	// contains(list, x) checks a list for an item; contains(text, part) searches text
+	"contains": {minArgs: 2, maxArgs: 2, call: func(args []interface{}) (interface{}, error) {
+		if items, ok := args[0].([]interface{}); ok {
+			for _, item := range items {
+				if equal(item, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return strings.Contains(toText(args[0]), toText(args[1])), nil
+	}},
+	// answered is false for null, "" and empty lists
+	"answered": {minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		switch x := args[0].(type) {
+		case nil:
+			return false, nil
+		case string:
+			return strings.TrimSpace(x) != "", nil
+		case []interface{}:
+			return len(x) > 0, nil
+		}
+		return true, nil
+	}},
+	// score(answer, {"option": points, ...}[, default]) looks up the points of a choice,
+	// adding them up over every pick of a multiple choice. Unlisted options score the
+	// default, or 0.
+	"score": {minArgs: 2, maxArgs: 3, call: func(args []interface{}) (interface{}, error) {
+		table, ok := args[1].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("the second argument must be a map like {\"Yes\": 1}")
+		}
+		fallback := 0.0
+		if len(args) == 3 {
+			var err error
+			if fallback, err = toNumber(args[2]); err != nil {
+				return nil, err
+			}
+		}
+		picks, isList := args[0].([]interface{})
+		if !isList {
+			if args[0] == nil {
+				return 0.0, nil
+			}
+			picks = []interface{}{args[0]}
+		}
+		total := 0.0
+		for _, pick := range picks {
+			points, ok := table[toText(pick)]
+			if !ok {
+				total += fallback
+				continue
+			}
+			n, err := toNumber(points)
+			if err != nil {
+				return nil, err
+			}
+			total += n
+		}
+		return finite(total)
+	}},
+	// lookup(answer, {"key": value, ...}[, default]) maps an answer to any value
+	"lookup": {minArgs: 2, maxArgs: 3, call: func(args []interface{}) (interface{}, error) {
+		table, ok := args[1].(map[string]interface{})
+		if !ok {
+			return nil, errors.New("the second argument must be a map like {\"KW\": \"Kuwait\"}")
+		}
+		if value, ok := table[toText(args[0])]; ok {
+			return value, nil
+		}
+		if len(args) == 3 {
+			return args[2], nil
+		}
+		return nil, nil
+	}},
+	"number": {minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		n, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return n, nil
+	}},
+	"text": {minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		return toText(args[0]), nil
+	}},
+}
+
+// numeric1 adapts a one-argument math function
+func numeric1(f func(float64) float64) *function {
+	return &function{minArgs: 1, maxArgs: 1, call: func(args []interface{}) (interface{}, error) {
+		x, err := toNumber(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return finite(f(x))
+	}}
+}
+
+// flatten reads numbers from args, expanding lists; unanswered values are skipped
+func flatten(args []interface{}) ([]float64, error) {
+	var numbers []float64
+	for _, arg := range args {
+		items, ok := arg.([]interface{})
+		if !ok {
+			items = []interface{}{arg}
+		}
+		for _, item := range items {
+			if item == nil {
+				continue
+			}
+			n, err := toNumber(item)
+			if err != nil {
+				return nil, err
+			}
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers, nil
+}
+
+// fold reduces the numbers in args with f; with none the result is null
+func fold(args []interface{}, f func(a, b float64) float64) (interface{}, error) {
+	numbers, err := flatten(args)
+	if err != nil || len(numbers) == 0 {
+		return nil, err
+	}
+	result := numbers[0]
+	for _, n := range numbers[1:] {
+		result = f(result, n)
+	}
+	return result, nil
+}
@@ -0,0 +1,101 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFunctions(t *testing.T) {
+	answers := map[string]interface{}{
+		"colours": []interface{}{"Red", "Blue"},
+		"colour":  "Green",
+		"country": "KW",
+		"ages":    []interface{}{30.0, nil, "12"},
+	}
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"min(3, [1, 5])", 1.0},
+		{"max(3, [1, 5])", 5.0},
+		{"min(missing)", nil},
+		{"max(ages)", 30.0},
+		{"sum([1, 2], 3)", 6.0},
+		{"sum(ages)", 42.0},
+		{"sum(missing)", 0.0},
+		{"count(colours)", 2.0},
+		{"count(missing)", 0.0},
+		{"count(colour)", 1.0},
+		{"count(\"\")", 0.0},
+		{"round(2.5)", 3.0},
+		{"round(-2.5)", -3.0},
+		{"round(1.2345, 2)", 1.23},
+		{"round(1234, -2)", 1200.0},
+		{"floor(-1.5)", -2.0},
+		{"ceil(1.1)", 2.0},
+		{"abs(-3)", 3.0},
+		{"contains(colours, \"Blue\")", true},
+		{"contains(colours, \"Green\")", false},
+		{"contains(\"hello\", \"ell\")", true},
+		{"contains(ages, 12)", true},
+		{"answered(missing)", false},
+		{"answered(\" \")", false},
+		{"answered([])", false},
+		{"answered(0)", true},
+		{"score(colours, {\"Red\": 1, \"Blue\": 2})", 3.0},
+		{"score(colour, {\"Red\": 1}, 5)", 5.0},
+		{"score(colour, {\"Red\": 1})", 0.0},
+		{"score(missing, {\"Red\": 1}, 5)", 0.0},
+		{"lookup(country, {\"KW\": \"Kuwait\"})", "Kuwait"},
+		{"lookup(\"SA\", {\"KW\": 1}, \"other\")", "other"},
+		{"lookup(\"SA\", {\"KW\": 1})", nil},
+		{"lookup(1, {1: \"one\"})", "one"},
+		{"number(\" 4 \")", 4.0},
+		{"number(missing)", 0.0},
+		{"text(1.5)", "1.5"},
+		{"text([1, \"a\"])", "1, a"},
+		{"text(true)", "true"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := mustEval(t, tt.source, answers); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("= %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFunctionErrors(t *testing.T) {
+	answers := map[string]interface{}{"big": 1e308, "inf": "Infinity"}
+	tests := []struct {
+		source  string
+		wantErr string
+	}{
+		{"score(\"Red\", 1)", "score: the second argument must be a map"},
+		{"lookup(\"KW\", [1])", "lookup: the second argument must be a map"},
+		{"score(\"Red\", {\"Red\": \"x\"})", "\"x\" is not a number"},
+		{"score(\"Red\", {}, \"x\")", "\"x\" is not a number"},
+		{"sum(\"abc\")", "sum: \"abc\" is not a number"},
+		{"min(1, [\"abc\"])", "\"abc\" is not a number"},
+		{"round(1, \"x\")", "\"x\" is not a number"},
+		{"number(\"abc\")", "number: \"abc\" is not a number"},
+		{"number(inf)", "\"Infinity\" is not a number"},
+		{"max(inf)", "\"Infinity\" is not a number"},
+		{"sum(big, big)", "not a finite number"},
+		{"score([\"a\", \"a\"], {\"a\": big})", "not a finite number"},
+		{"round(big, 10)", "not a finite number"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			e, err := Parse(tt.source)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			got, err := e.Eval(answers)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Eval = %v, %v; want an error containing %q", got, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package expr
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenField // a backtick-quoted field ID
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// punctuation lists the operators and delimiters, longest first so "<=" wins over "<"
+var punctuation = []string{"==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "?", ":", "(", ")", "[", "]", "{", "}", ","}
+
+// lex splits source into tokens
+func lex(source string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(source); {
+		r, size := utf8.DecodeRuneInString(source[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r >= '0' && r <= '9' || r == '.' && i+1 < len(source) && source[i+1] >= '0' && source[i+1] <= '9':
+			start := i
+			for i < len(source) && (source[i] >= '0' && source[i] <= '9' || source[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: source[start:i], pos: start})
+		case r == '"' || r == '\'':
+			text, end, err := lexString(source, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: i})
+			i = end
+		case r == '`':
+			end := strings.IndexByte(source[i+1:], '`')
+			if end < 0 {
+				return nil, &SyntaxError{Pos: i, Msg: "unterminated field reference"}
+			}
+			id := source[i+1 : i+1+end]
+			if strings.TrimSpace(id) == "" {
+				return nil, &SyntaxError{Pos: i, Msg: "empty field reference"}
+			}
+			tokens = append(tokens, token{kind: tokenField, text: id, pos: i})
+			i += end + 2
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(source) {
+				r, size := utf8.DecodeRuneInString(source[i:])
+				if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+					break
+				}
+				i += size
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: source[start:i], pos: start})
+		default:
+			matched := false
+			for _, p := range punctuation {
+				if strings.HasPrefix(source[i:], p) {
+					tokens = append(tokens, token{kind: tokenPunct, text: p, pos: i})
+					i += len(p)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, &SyntaxError{Pos: i, Msg: "unexpected " + strconv.QuoteRune(r)}
+			}
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(source)}), nil
+}
+
+// lexString reads the string literal starting at source[start], returning its value
+// and the offset just past the closing quote. Backslash escapes the quote, backslash,
+// n and t.
+func lexString(source string, start int) (string, int, error) {
+	quote := source[start]
+	var b strings.Builder
+	for i := start + 1; i < len(source); i++ {
+		switch c := source[i]; {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(source):
+			i++
+			switch source[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(source[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, &SyntaxError{Pos: start, Msg: "unterminated string"}
+}
+
+// parser is a recursive descent parser over the tokens of one expression. From lowest
+// to highest precedence: ?:, ||, &&, == !=, < <= > >=, + -, * / %, unary - and !.
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+	refs   []string
+	seen   map[string]bool
+}
+
+func (p *parser) parse() (node, error) {
+	n, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	return n, nil
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the given operators or keywords
+func (p *parser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenPunct && t.kind != tokenIdent {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *parser) expect(op string) error {
+	if _, ok := p.accept(op); !ok {
+		t := p.peek()
+		if t.kind == tokenEOF {
+			return &SyntaxError{Pos: t.pos, Msg: "expected " + strconv.Quote(op) + " before the end"}
+		}
+		return &SyntaxError{Pos: t.pos, Msg: "expected " + strconv.Quote(op)}
+	}
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return &SyntaxError{Pos: t.pos, Msg: "unexpected end of expression"}
+	}
+	return &SyntaxError{Pos: t.pos, Msg: "unexpected " + strconv.Quote(t.text)}
+}
+
+// enter guards recursion; every nested sub-expression goes through it
+func (p *parser) enter() error {
+	p.depth++
+	if p.depth > maxDepth {
+		return &SyntaxError{Pos: p.peek().pos, Msg: "expression is nested too deeply"}
+	}
+	return nil
+}
+
+func (p *parser) conditional() (node, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.accept("?"); !ok {
+		return cond, nil
+	}
+	then, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.conditional()
+	if err != nil {
+		return nil, err
+	}
+	return &conditional{cond: cond, then: then, otherwise: otherwise}, nil
+}
+
+// precedence lists the binary operators by level, loosest first; "and", "or" and
+// "not" are spellings of &&, || and !
+var precedence = [][]string{
+	{"||", "or"},
+	{"&&", "and"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+var aliases = map[string]string{"or": "||", "and": "&&", "not": "!"}
+
+func (p *parser) binary(level int) (node, error) {
+	if level == len(precedence) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(precedence[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		if alias, ok := aliases[op]; ok {
+			op = alias
+		}
+		left = &binary{op: op, left: left, right: right}
+	}
+}
+
+func (p *parser) unary() (node, error) {
+	op, ok := p.accept("-", "!", "not")
+	if !ok {
+		return p.primary()
+	}
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { p.depth-- }()
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	if alias, ok := aliases[op]; ok {
+		op = alias
+	}
+	return &unary{op: op, operand: operand}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		n, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "invalid number " + strconv.Quote(t.text)}
+		}
+		return &literal{value: n}, nil
+	case tokenString:
+		return &literal{value: t.text}, nil
+	case tokenField:
+		return p.ref(t.text), nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			return &literal{value: true}, nil
+		case "false":
+			return &literal{value: false}, nil
+		case "null":
+			return &literal{value: nil}, nil
+		}
+		if _, ok := p.accept("("); ok {
+			return p.call(t)
+		}
+		return p.ref(t.text), nil
+	case tokenPunct:
+		switch t.text {
+		case "(":
+			n, err := p.conditional()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			items, err := p.list("]")
+			if err != nil {
+				return nil, err
+			}
+			return &list{items: items}, nil
+		case "{":
+			return p.mapping()
+		}
+	}
+	return nil, p.unexpected(t)
+}
+
+// ref records a field reference
+func (p *parser) ref(id string) node {
+	if !p.seen[id] {
+		p.seen[id] = true
+		p.refs = append(p.refs, id)
+	}
+	return &ref{id: id}
+}
+
+// list parses comma-separated expressions up to the closing delimiter
+func (p *parser) list(closing string) ([]node, error) {
+	var items []node
+	if _, ok := p.accept(closing); ok {
+		return items, nil
+	}
+	for {
+		item, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+		return items, p.expect(closing)
+	}
+}
+
+func (p *parser) call(name token) (node, error) {
+	fn, ok := functions[name.text]
+	if !ok {
+		return nil, &SyntaxError{Pos: name.pos, Msg: "unknown function " + strconv.Quote(name.text)}
+	}
+	args, err := p.list(")")
+	if err != nil {
+		return nil, err
+	}
+	if len(args) < fn.minArgs || (fn.maxArgs >= 0 && len(args) > fn.maxArgs) {
+		return nil, &SyntaxError{Pos: name.pos, Msg: name.text + " takes " + fn.arity()}
+	}
+	return &call{name: name.text, fn: fn, args: args}, nil
+}
+
+// mapping parses a {"key": value, ...} literal; keys are text or numbers
+func (p *parser) mapping() (node, error) {
+	m := &mapping{}
+	if _, ok := p.accept("}"); ok {
+		return m, nil
+	}
+	for {
+		t := p.next()
+		if t.kind != tokenString && t.kind != tokenNumber {
+			return nil, &SyntaxError{Pos: t.pos, Msg: "map keys must be text or numbers"}
+		}
+		key := t.text
+		if t.kind == tokenNumber {
+			n, err := strconv.ParseFloat(t.text, 64)
+			if err != nil {
+				return nil, &SyntaxError{Pos: t.pos, Msg: "invalid number " + strconv.Quote(t.text)}
+			}
+			key = formatNumber(n)
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.conditional()
+		if err != nil {
+			return nil, err
+		}
+		m.keys = append(m.keys, key)
+		m.values = append(m.values, value)
+		if _, ok := p.accept(","); ok {
+			continue
+		}
+		return m, p.expect("}")
+	}
+}
@@ -0,0 +1,151 @@
+package expr
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mustEval parses and evaluates source, failing the test on any error
+func mustEval(t *testing.T, source string, answers map[string]interface{}) interface{} {
+	t.Helper()
+	e, err := Parse(source)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", source, err)
+	}
+	got, err := e.Eval(answers)
+	if err != nil {
+		t.Fatalf("Eval(%q): %v", source, err)
+	}
+	return got
+}
+
+func TestPrecedence(t *testing.T) {
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"10 - 4 - 3", 3.0},
+		{"12 / 3 / 2", 2.0},
+		{"2 * 3 % 4", 2.0},
+		{"-2 * 3", -6.0},
+		{"--2", 2.0},
+		{"1 + 2 == 3", true},
+		{"1 < 2 == 2 < 3", true},
+		{"!0 == true", true},
+		{"true || false && false", true},
+		{"false && false || true", true},
+		{"not 1 or 1 and 0", false},
+		{"1 ? 2 : 3 ? 4 : 5", 2.0},
+		{"0 ? 2 : 0 ? 4 : 5", 5.0},
+		{"1 + 2 > 2 ? \"big\" : \"small\"", "big"},
+		{"a * b + c", 7.0},
+	}
+	answers := map[string]interface{}{"a": 2.0, "b": 3.0, "c": 1.0}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := mustEval(t, tt.source, answers); got != tt.want {
+				t.Fatalf("= %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		wantErr string
+	}{
+		{"", "unexpected end"},
+		{"1 +", "unexpected end"},
+		{"(1", "expected \")\" before the end"},
+		{"1 2", "unexpected \"2\""},
+		{"1..2", "invalid number"},
+		{"`a", "unterminated field reference"},
+		{"` `", "empty field reference"},
+		{"'abc", "unterminated string"},
+		{"1 @ 2", "unexpected '@'"},
+		{"nope(1)", "unknown function \"nope\""},
+		{"round()", "round takes 1 to 2 arguments"},
+		{"if(1, 2)", "if takes 3 argument(s)"},
+		{"{a: 1}", "map keys must be text or numbers"},
+		{"1 ? 2", "expected \":\" before the end"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := Parse(tt.source)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	long := strings.Repeat("1+", MaxLength/2-1) + "1"
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{"longest allowed", long + " ", ""},
+		{"too long", long + "  ", "at most"},
+		// The whole expression is one level, each parenthesis or unary operator another
+		{"deepest parentheses", strings.Repeat("(", maxDepth-1) + "1" + strings.Repeat(")", maxDepth-1), ""},
+		{"parentheses too deep", strings.Repeat("(", maxDepth) + "1" + strings.Repeat(")", maxDepth), "nested too deeply"},
+		{"deepest unary", strings.Repeat("-", maxDepth-1) + "1", ""},
+		{"unary too deep", strings.Repeat("!", maxDepth) + "1", "nested too deeply"},
+		{"conditionals too deep", strings.Repeat("1 ? 1 : ", maxDepth) + "1", "nested too deeply"},
+		// The innermost, empty list holds no sub-expression
+		{"deepest lists", strings.Repeat("[", maxDepth) + strings.Repeat("]", maxDepth), ""},
+		{"lists too deep", strings.Repeat("[", maxDepth+1) + strings.Repeat("]", maxDepth+1), "nested too deeply"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.source)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Parse error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefs(t *testing.T) {
+	e, err := Parse("a + `b c` * a + score(c, {\"a\": 1}) + `a`")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := e.Refs(), []string{"a", "b c", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Refs = %q, want %q", got, want)
+	}
+}
+
+func TestLiterals(t *testing.T) {
+	tests := []struct {
+		source string
+		want   interface{}
+	}{
+		{".5 + 1", 1.5},
+		{`"a\"b" + 'c\'d'`, `a"bc'd`},
+		{`"tab\there"`, "tab\there"},
+		{"null", nil},
+		{"[1, \"a\", true]", []interface{}{1.0, "a", true}},
+		{"{\"a\": 1, 2: \"b\"}", map[string]interface{}{"a": 1.0, "2": "b"}},
+		{"[]", []interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := mustEval(t, tt.source, nil); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("= %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+// Package fieldcrypt implements envelope encryption for individual PII values and keyed
+// blind indexes for looking them up without decrypting.
+//
+// Every value gets its own random data key (DEK). The value is sealed with the DEK using
+// AES-256-GCM and the DEK is wrapped with a key-encryption key (KEK) from the keyring.
+// Rotating keys only requires re-wrapping values under the new active KEK.
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks a string as a fieldcrypt ciphertext; the format version follows it
+const prefix = "enc:1:"
+
+// ErrUnknownKey is returned when a ciphertext was wrapped with a KEK missing from the keyring
+var ErrUnknownKey = errors.New("fieldcrypt: unknown key id")
+
+// Keyring holds the key-encryption keys by ID, the ID used for new values and the
+// separate key for blind indexes
+type Keyring struct {
+	keys     map[string][]byte
+	activeID string
+	indexKey []byte
+}
+
+// ParseKeyring builds a keyring from a comma-separated "id:base64key" list. Keys must
+// decode to 32 bytes. activeID selects the KEK for new encryptions.
+func ParseKeyring(spec, activeID string, indexKey []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string][]byte), activeID: activeID, indexKey: indexKey}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("fieldcrypt: key entry %q is not id:base64key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("fieldcrypt: key %s: %w", parts[0], err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("fieldcrypt: key %s must be 32 bytes, got %d", parts[0], len(key))
+		}
+		k.keys[parts[0]] = key
+	}
+	if _, ok := k.keys[activeID]; !ok {
+		return nil, fmt.Errorf("fieldcrypt: active key %q is not in the keyring", activeID)
+	}
+	if len(indexKey) < 32 {
+		return nil, errors.New("fieldcrypt: blind index key must be at least 32 bytes")
+	}
+	return k, nil
+}
+
+// ActiveKeyID returns the ID of the KEK used for new encryptions
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// IsEncrypted reports whether s is a fieldcrypt ciphertext
+func IsEncrypted(s string) bool {
+	return strings.HasPrefix(s, prefix)
+}
+
+// Encrypt seals plaintext under a fresh data key wrapped with the active KEK
+func (k *Keyring) Encrypt(plaintext []byte) (string, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	sealed, err := seal(dek, plaintext, nil)
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.keys[k.activeID], dek, []byte(k.activeID))
+	if err != nil {
+		return "", err
+	}
+	return prefix + k.activeID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value produced by Encrypt with any KEK still in the keyring
+func (k *Keyring) Decrypt(ciphertext string) ([]byte, error) {
+	keyID, wrapped, sealed, err := split(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	kek, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	dek, err := open(kek, wrapped, []byte(keyID))
+	if err != nil {
+		return nil, fmt.Errorf("fieldcrypt: unwrapping data key: %w", err)
+	}
+	return open(dek, sealed, nil)
+}
+
+// NeedsRotation reports whether ciphertext is wrapped with a KEK other than the active one
+func (k *Keyring) NeedsRotation(ciphertext string) bool {
+	keyID, _, _, err := split(ciphertext)
+	return err == nil && keyID != k.activeID
+}
+
+// BlindIndex returns a keyed, deterministic hash of value so equal values can be found
+// and compared without storing them in plaintext
+func (k *Keyring) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, k.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func split(ciphertext string) (keyID string, wrapped, sealed []byte, err error) {
+	if !IsEncrypted(ciphertext) {
+		return "", nil, nil, errors.New("fieldcrypt: value is not encrypted")
+	}
+	parts := strings.Split(strings.TrimPrefix(ciphertext, prefix), ":")
+	if len(parts) != 3 {
+		return "", nil, nil, errors.New("fieldcrypt: malformed ciphertext")
+	}
+	if wrapped, err = base64.RawStdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, fmt.Errorf("fieldcrypt: malformed data key: %w", err)
+	}
+	if sealed, err = base64.RawStdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, fmt.Errorf("fieldcrypt: malformed payload: %w", err)
+	}
+	return parts[0], wrapped, sealed, nil
+}
+
+// seal encrypts with AES-GCM and prepends the random nonce
+func seal(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+func open(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("fieldcrypt: ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
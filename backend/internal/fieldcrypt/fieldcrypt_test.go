@@ -0,0 +1,174 @@
+package fieldcrypt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func key(b byte) string {
+	return base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))
+}
+
+var indexKey = bytes.Repeat([]byte{9}, 32)
+
+func mustKeyring(t *testing.T, spec, active string) *Keyring {
+	t.Helper()
+	k, err := ParseKeyring(spec, active, indexKey)
+	if err != nil {
+		t.Fatalf("ParseKeyring(%q, %q): %v", spec, active, err)
+	}
+	return k
+}
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     string
+		active   string
+		indexKey []byte
+		wantErr  string
+	}{
+		{"single key", "k1:" + key(1), "k1", indexKey, ""},
+		{"several keys with spaces", " k1:" + key(1) + " , k2:" + key(2) + ",", "k2", indexKey, ""},
+		{"missing id", ":" + key(1), "k1", indexKey, "is not id:base64key"},
+		{"no separator", "k1", "k1", indexKey, "is not id:base64key"},
+		{"bad base64", "k1:***", "k1", indexKey, "k1"},
+		{"short key", "k1:" + base64.StdEncoding.EncodeToString([]byte("short")), "k1", indexKey, "must be 32 bytes"},
+		{"unknown active key", "k1:" + key(1), "k2", indexKey, "active key"},
+		{"short index key", "k1:" + key(1), "k1", []byte("short"), "blind index key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeyring(tt.spec, tt.active, tt.indexKey)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	k := mustKeyring(t, "k1:"+key(1), "k1")
+	for _, plaintext := range []string{"", "+965 9000 0000", "مرحبا", strings.Repeat("x", 10000)} {
+		ciphertext, err := k.Encrypt([]byte(plaintext))
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		if !IsEncrypted(ciphertext) || !strings.HasPrefix(ciphertext, prefix+"k1:") {
+			t.Fatalf("ciphertext %q lacks the format prefix", ciphertext)
+		}
+		if plaintext != "" && strings.Contains(ciphertext, plaintext) {
+			t.Fatalf("ciphertext leaks the plaintext")
+		}
+		got, err := k.Decrypt(ciphertext)
+		if err != nil {
+			t.Fatalf("Decrypt: %v", err)
+		}
+		if string(got) != plaintext {
+			t.Fatalf("Decrypt = %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func TestEncryptIsRandomized(t *testing.T) {
+	k := mustKeyring(t, "k1:"+key(1), "k1")
+	a, _ := k.Encrypt([]byte("same"))
+	b, _ := k.Encrypt([]byte("same"))
+	if a == b {
+		t.Fatal("two encryptions of the same value are identical")
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	k := mustKeyring(t, "k1:"+key(1), "k1")
+	valid, err := k.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(strings.TrimPrefix(valid, prefix), ":")
+	other := mustKeyring(t, "k2:"+key(2), "k2")
+	foreign, _ := other.Encrypt([]byte("secret"))
+	wrongKEK := mustKeyring(t, "k1:"+key(3), "k1")
+
+	tests := []struct {
+		name       string
+		keyring    *Keyring
+		ciphertext string
+	}{
+		{"plaintext", k, "secret"},
+		{"too few parts", k, prefix + "k1:abc"},
+		{"bad data key encoding", k, prefix + "k1:***:" + parts[2]},
+		{"bad payload encoding", k, prefix + "k1:" + parts[1] + ":***"},
+		{"truncated payload", k, prefix + "k1:" + parts[1] + ":AA"},
+		{"tampered payload", k, prefix + "k1:" + parts[1] + ":" + parts[2][:len(parts[2])-2] + "AA"},
+		{"swapped key id", k, prefix + "k2:" + parts[1] + ":" + parts[2]},
+		{"unknown key", k, foreign},
+		{"wrong KEK under the same id", wrongKEK, valid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.keyring.Decrypt(tt.ciphertext); err == nil {
+				t.Fatal("Decrypt succeeded")
+			}
+		})
+	}
+	if _, err := k.Decrypt(foreign); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Decrypt of a foreign key = %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old := mustKeyring(t, "k1:"+key(1), "k1")
+	ciphertext, err := old.Encrypt([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated := mustKeyring(t, "k1:"+key(1)+",k2:"+key(2), "k2")
+	if !rotated.NeedsRotation(ciphertext) {
+		t.Fatal("a value under the old KEK does not need rotation")
+	}
+	if got, err := rotated.Decrypt(ciphertext); err != nil || string(got) != "secret" {
+		t.Fatalf("Decrypt after adding a key = %q, %v", got, err)
+	}
+	fresh, _ := rotated.Encrypt([]byte("secret"))
+	if rotated.NeedsRotation(fresh) {
+		t.Fatal("a value under the active KEK needs rotation")
+	}
+	if rotated.NeedsRotation("secret") {
+		t.Fatal("plaintext needs rotation")
+	}
+}
+
+func TestBlindIndex(t *testing.T) {
+	k := mustKeyring(t, "k1:"+key(1), "k1")
+	rotated := mustKeyring(t, "k1:"+key(1)+",k2:"+key(2), "k2")
+	otherIndex, err := ParseKeyring("k1:"+key(1), "k1", bytes.Repeat([]byte{8}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := k.BlindIndex("96590000000")
+	if len(a) != 64 {
+		t.Fatalf("BlindIndex length = %d, want 64 hex characters", len(a))
+	}
+	if a != k.BlindIndex("96590000000") {
+		t.Fatal("BlindIndex is not deterministic")
+	}
+	if a == k.BlindIndex("96590000001") {
+		t.Fatal("different values share a blind index")
+	}
+	if a != rotated.BlindIndex("96590000000") {
+		t.Fatal("BlindIndex changed with the KEKs")
+	}
+	if a == otherIndex.BlindIndex("96590000000") {
+		t.Fatal("BlindIndex ignores the index key")
+	}
+}
@@ -0,0 +1,436 @@
+package formdef
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func init() {
+	for _, name := range []string{"text", "textarea", "password"} {
+		Register(textType{name: name})
+	}
+	Register(emailType{})
+	Register(numberType{})
+	Register(dateType{})
+	Register(timeType{})
+	Register(choiceType{name: "select"})
+	Register(choiceType{name: "radio"})
+	Register(checkboxType{})
+	Register(fileType{})
+}
+
+// basicType provides the defaults most types share: one export column holding the
+// answer as text and no analytics beyond the answered count
+type basicType struct{}
+
+func (basicType) ExportColumns(field Field, language string) []string { return []string{""} }
+
+func (basicType) Export(field Field, value interface{}) []string {
+	return []string{FormatValue(value)}
+}
+
+func (basicType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	return map[string]interface{}{}
+}
+
+func (basicType) Normalize(field Field, value interface{}) (interface{}, error) {
+	return value, nil
+}
+
+// FormatValue renders an answer as plain text
+func FormatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, item := range v {
+			parts[i] = FormatValue(item)
+		}
+		return strings.Join(parts, "; ")
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// textType covers free text answers; min and max bound the length in characters
+type textType struct {
+	basicType
+	name string
+}
+
+func (t textType) Name() string { return t.name }
+
+func (t textType) Describe() Description {
+	// Passwords are never worth searching
+	return Description{Kind: "text", Searchable: t.name != "password", Identifying: true}
+}
+
+func (t textType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation, "min", "max", "pattern")
+}
+
+func (t textType) Normalize(field Field, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be text")
+	}
+	length := float64(utf8.RuneCountInString(s))
+	if min, ok := ruleNumber(field, "min"); ok && length < min {
+		return nil, fmt.Errorf("must be at least %v characters", min)
+	}
+	if max, ok := ruleNumber(field, "max"); ok && length > max {
+		return nil, fmt.Errorf("must be at most %v characters", max)
+	}
+	if err := matchPattern(field, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type emailType struct{ basicType }
+
+func (emailType) Name() string { return "email" }
+
+func (emailType) Describe() Description {
+	return Description{Kind: "text", Searchable: true, Identifying: true}
+}
+
+func (emailType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation, "pattern")
+}
+
+func (emailType) Normalize(field Field, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be an email address")
+	}
+	s = strings.TrimSpace(s)
+	if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+		return nil, errors.New("must be an email address")
+	}
+	if err := matchPattern(field, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// numberType stores answers as numbers; the public form sends them as strings
+type numberType struct{ basicType }
+
+func (numberType) Name() string { return "number" }
+
+func (numberType) Describe() Description { return Description{Kind: "number"} }
+
+func (numberType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation, "min", "max")
+}
+
+func (numberType) Normalize(field Field, value interface{}) (interface{}, error) {
+	n, ok := toNumber(value)
+	if !ok {
+		return nil, errors.New("must be a number")
+	}
+	if min, ok := ruleNumber(field, "min"); ok && n < min {
+		return nil, fmt.Errorf("must be at least %v", min)
+	}
+	if max, ok := ruleNumber(field, "max"); ok && n > max {
+		return nil, fmt.Errorf("must be at most %v", max)
+	}
+	return n, nil
+}
+
+func (numberType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	return numberStats(values)
+}
+
+// numberStats summarizes numeric answers with their count, range and mean
+func numberStats(values []interface{}) map[string]interface{} {
+	count, sum := 0, 0.0
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, value := range values {
+		n, ok := toNumber(value)
+		if !ok {
+			continue
+		}
+		count++
+		sum += n
+		min = math.Min(min, n)
+		max = math.Max(max, n)
+	}
+	if count == 0 {
+		return map[string]interface{}{"count": 0}
+	}
+	return map[string]interface{}{"count": count, "min": min, "max": max, "mean": sum / float64(count)}
+}
+
+// dateLayout is what HTML date inputs submit
+const dateLayout = "2006-01-02"
+
+type dateType struct{ basicType }
+
+func (dateType) Name() string { return "date" }
+
+func (dateType) Describe() Description { return Description{Kind: "date"} }
+
+func (dateType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation)
+}
+
+func (dateType) Normalize(field Field, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be a date (YYYY-MM-DD)")
+	}
+	d, err := time.Parse(dateLayout, strings.TrimSpace(s))
+	if err != nil {
+		return nil, errors.New("must be a date (YYYY-MM-DD)")
+	}
+	return d.Format(dateLayout), nil
+}
+
+func (dateType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	var dates []string
+	for _, value := range values {
+		if s, ok := value.(string); ok {
+			if _, err := time.Parse(dateLayout, s); err == nil {
+				dates = append(dates, s)
+			}
+		}
+	}
+	if len(dates) == 0 {
+		return map[string]interface{}{}
+	}
+	sort.Strings(dates)
+	return map[string]interface{}{"earliest": dates[0], "latest": dates[len(dates)-1]}
+}
+
+type timeType struct{ basicType }
+
+func (timeType) Name() string { return "time" }
+
+func (timeType) Describe() Description { return Description{Kind: "time"} }
+
+func (timeType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation)
+}
+
+func (timeType) Normalize(field Field, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be a time (HH:MM)")
+	}
+	s = strings.TrimSpace(s)
+	if _, err := time.Parse("15:04", s); err == nil {
+		return s, nil
+	}
+	if t, err := time.Parse("15:04:05", s); err == nil {
+		return t.Format("15:04:05"), nil
+	}
+	return nil, errors.New("must be a time (HH:MM)")
+}
+
+// choiceType is a single choice. Respondents submit the option text in their language,
+// so any translation of an option is accepted.
+type choiceType struct {
+	basicType
+	name string
+}
+
+func (t choiceType) Name() string { return t.name }
+
+func (t choiceType) Describe() Description { return Description{Kind: "choice"} }
+
+func (t choiceType) ValidateDefinition(field Field, c Check) {
+	validateOptions(field, c)
+	c.Rules(field.Validation)
+}
+
+func (t choiceType) Normalize(field Field, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok || optionIndex(field, s) < 0 {
+		return nil, errors.New("must be one of the options")
+	}
+	return s, nil
+}
+
+func (t choiceType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	return optionCounts(field, values)
+}
+
+// checkboxType is a multiple choice; min and max bound how many options are picked
+type checkboxType struct{ basicType }
+
+func (checkboxType) Name() string { return "checkbox" }
+
+func (checkboxType) Describe() Description { return Description{Kind: "multichoice"} }
+
+func (checkboxType) ValidateDefinition(field Field, c Check) {
+	validateOptions(field, c)
+	c.Rules(field.Validation, "min", "max")
+}
+
+func (checkboxType) Normalize(field Field, value interface{}) (interface{}, error) {
+	items, ok := value.([]interface{})
+	if !ok {
+		// A lone choice is accepted as a one-item selection
+		items = []interface{}{value}
+	}
+	seen := make(map[int]bool)
+	picked := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		s, _ := item.(string)
+		i := optionIndex(field, s)
+		if i < 0 {
+			return nil, errors.New("must only contain the options")
+		}
+		if !seen[i] {
+			seen[i] = true
+			picked = append(picked, s)
+		}
+	}
+	if min, ok := ruleNumber(field, "min"); ok && float64(len(picked)) < min {
+		return nil, fmt.Errorf("must pick at least %v options", min)
+	}
+	if max, ok := ruleNumber(field, "max"); ok && float64(len(picked)) > max {
+		return nil, fmt.Errorf("must pick at most %v options", max)
+	}
+	return picked, nil
+}
+
+func (checkboxType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	var flat []interface{}
+	for _, value := range values {
+		if items, ok := value.([]interface{}); ok {
+			flat = append(flat, items...)
+		} else {
+			flat = append(flat, value)
+		}
+	}
+	return optionCounts(field, flat)
+}
+
+type fileType struct{ basicType }
+
+func (fileType) Name() string { return "file" }
+
+func (fileType) Describe() Description { return Description{Kind: "file", Identifying: true} }
+
+func (fileType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation)
+}
+
+// validateOptions requires at least one option, each with some text
+func validateOptions(field Field, c Check) {
+	if len(field.Options) == 0 {
+		c.Errorf(".options", "%s fields need at least one option", field.Type)
+	}
+	for j, option := range field.Options {
+		c.Text(fmt.Sprintf(".options[%d]", j), option, true)
+	}
+}
+
+// optionIndex returns the index of the option with s as one of its translations, or -1
+func optionIndex(field Field, s string) int {
+	s = strings.TrimSpace(s)
+	for i, option := range field.Options {
+		for _, text := range option {
+			if text != "" && strings.TrimSpace(text) == s {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// TextIn returns text in language, falling back to the option label
+func TextIn(text MultiLanguageText, language string) string {
+	if text[language] != "" {
+		return text[language]
+	}
+	return OptionLabel(text)
+}
+
+// OptionLabel is the text an option is reported under: English, else any translation
+func OptionLabel(option MultiLanguageText) string {
+	if option["en"] != "" {
+		return option["en"]
+	}
+	for _, text := range option {
+		if text != "" {
+			return text
+		}
+	}
+	return ""
+}
+
+// optionCounts counts picks per option, merging translations of the same option.
+// Answers matching no current option are counted as "other".
+func optionCounts(field Field, values []interface{}) map[string]interface{} {
+	counts := make(map[string]int, len(field.Options))
+	for _, option := range field.Options {
+		counts[OptionLabel(option)] = 0
+	}
+	other := 0
+	for _, value := range values {
+		s, _ := value.(string)
+		if i := optionIndex(field, s); i >= 0 {
+			counts[OptionLabel(field.Options[i])]++
+		} else {
+			other++
+		}
+	}
+	return map[string]interface{}{"counts": counts, "other": other}
+}
+
+// ruleNumber reads a numeric validation rule
+func ruleNumber(field Field, key string) (float64, bool) {
+	return toNumber(field.Validation[key])
+}
+
+// matchPattern applies the field's pattern rule, which like the HTML pattern attribute
+// must match the whole answer
+func matchPattern(field Field, s string) error {
+	pattern, ok := field.Validation["pattern"].(string)
+	if !ok || pattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil
+	}
+	if !re.MatchString(s) {
+		return errors.New("does not match the required format")
+	}
+	return nil
+}
+
+// toNumber accepts JSON numbers and numeric strings
+func toNumber(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+	}
+	return 0, false
+}
@@ -0,0 +1,209 @@
+package formdef
+
+import (
+	"fmt"
+
+	"4SaleBackendSkeleton/internal/expr"
+)
+
+func init() {
+	Register(calculatedType{})
+}
+
+// calculatedType is never asked; the server evaluates its Expression over the other
+// answers when a response is submitted and stores the result as the field's answer
+type calculatedType struct{ basicType }
+
+func (calculatedType) Name() string { return "calculated" }
+
+func (calculatedType) Describe() Description { return Description{Kind: "calculated"} }
+
+func (calculatedType) ValidateDefinition(field Field, c Check) {
+	if field.Required {
+		c.Errorf(".required", "calculated fields are filled in on submit and cannot be required")
+	}
+	if field.Expression == "" {
+		c.Errorf(".expression", "is required")
+	} else if _, err := expr.Parse(field.Expression); err != nil {
+		c.Errorf(".expression", "%s", err.Error())
+	}
+	c.Rules(field.Validation)
+}
+
+func (calculatedType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	return numberStats(values)
+}
+
+// calculations checks what the expressions of calculated fields refer to: other
+// top-level fields, without cycles. A calculation reading a sensitive answer must be
+// sensitive itself, or it could store that answer in the clear.
+func (v validator) calculations(fields []Field) {
+	byID := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		byID[field.ID] = field
+	}
+	for i, field := range fields {
+		if field.Type != "calculated" {
+			continue
+		}
+		e, err := expr.Parse(field.Expression)
+		if err != nil {
+			continue // reported by the type
+		}
+		path := fmt.Sprintf("fields[%d].expression", i)
+		for _, id := range e.Refs() {
+			ref, ok := byID[id]
+			switch {
+			case !ok:
+				v.errs.Add(path, "unknown field %q", id)
+			case id == field.ID:
+				v.errs.Add(path, "refers to itself")
+			case ref.Sensitive && !field.Sensitive:
+				v.errs.Add(path, "uses the sensitive field %q; mark this field sensitive too", id)
+			}
+		}
+	}
+	if _, cycle := calculationOrder(fields); cycle != "" {
+		for i, field := range fields {
+			if field.ID == cycle {
+				v.errs.Add(fmt.Sprintf("fields[%d].expression", i), "calculations depend on each other in a cycle")
+			}
+		}
+	}
+}
+
+// calculationOrder returns the calculated fields ordered so each comes after the
+// calculated fields it reads. If they form a cycle it returns the ID of a field on it.
+func calculationOrder(fields []Field) ([]Field, string) {
+	calculated := make(map[string]Field)
+	for _, field := range fields {
+		if field.Type == "calculated" {
+			calculated[field.ID] = field
+		}
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var ordered []Field
+	var visit func(field Field) string
+	visit = func(field Field) string {
+		switch state[field.ID] {
+		case visiting:
+			return field.ID
+		case done:
+			return ""
+		}
+		state[field.ID] = visiting
+		if e, err := expr.Parse(field.Expression); err == nil {
+			for _, id := range e.Refs() {
+				if dep, ok := calculated[id]; ok && id != field.ID {
+					if cycle := visit(dep); cycle != "" {
+						return cycle
+					}
+				}
+			}
+		}
+		state[field.ID] = done
+		ordered = append(ordered, field)
+		return ""
+	}
+	for _, field := range fields {
+		if _, ok := calculated[field.ID]; ok {
+			if cycle := visit(field); cycle != "" {
+				return nil, cycle
+			}
+		}
+	}
+	return ordered, ""
+}
+
+// Calculate evaluates the calculated fields of a normalized response and stores their
+// results in data, replacing anything the client sent for them. Choices are seen by
+// their option label, whichever language they were answered in. A calculation that
+// fails, e.g. by dividing by zero, is left unanswered and reported under
+// "responseData.<fieldId>".
+func Calculate(fields []Field, data map[string]interface{}) Errors {
+	answers := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if value, ok := data[field.ID]; ok && field.Type != "calculated" {
+			answers[field.ID] = canonicalAnswer(field, value)
+		}
+	}
+	ordered, _ := calculationOrder(fields)
+	var errs Errors
+	for _, field := range ordered {
+		delete(data, field.ID)
+		e, err := expr.Parse(field.Expression)
+		if err != nil {
+			errs.Add("responseData."+field.ID, "%s", err.Error())
+			continue
+		}
+		value, err := e.Eval(answers)
+		if err != nil {
+			errs.Add("responseData."+field.ID, "%s", err.Error())
+			continue
+		}
+		answers[field.ID] = value
+		if value != nil {
+			data[field.ID] = value
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// canonicalAnswer replaces picked options with their option label so expressions can
+// name options in one language
+func canonicalAnswer(field Field, value interface{}) interface{} {
+	kind := TypeOf(field).Describe().Kind
+	if kind != "choice" && kind != "multichoice" {
+		return value
+	}
+	label := func(item interface{}) interface{} {
+		s, _ := item.(string)
+		if i := optionIndex(field, s); i >= 0 {
+			return OptionLabel(field.Options[i])
+		}
+		return item
+	}
+	if items, ok := value.([]interface{}); ok {
+		labels := make([]interface{}, len(items))
+		for i, item := range items {
+			labels[i] = label(item)
+		}
+		return labels
+	}
+	return label(value)
+}
+
+// Identifies reports whether field's answers can identify the respondent: its type's
+// answers can, or it is calculated from answers that can
+func Identifies(field Field, fields []Field) bool {
+	return identifies(field, fields, make(map[string]bool))
+}
+
+func identifies(field Field, fields []Field, seen map[string]bool) bool {
+	if field.Type != "calculated" {
+		return TypeOf(field).Describe().Identifying
+	}
+	if seen[field.ID] {
+		return false
+	}
+	seen[field.ID] = true
+	e, err := expr.Parse(field.Expression)
+	if err != nil {
+		return true
+	}
+	for _, id := range e.Refs() {
+		for _, ref := range fields {
+			if ref.ID == id && identifies(ref, fields, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
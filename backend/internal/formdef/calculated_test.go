@@ -0,0 +1,197 @@
+package formdef
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func calculated(id, expression string) Field {
+	return Field{ID: id, Type: "calculated", Label: MultiLanguageText{"en": id}, Expression: expression}
+}
+
+func TestCalculate(t *testing.T) {
+	colour := Field{ID: "colour", Type: "select", Label: MultiLanguageText{"en": "Colour"}, Options: []MultiLanguageText{
+		{"en": "Red", "ar": "أحمر"},
+		{"en": "Blue", "ar": "أزرق"},
+	}}
+	tests := []struct {
+		name     string
+		fields   []Field
+		data     map[string]interface{}
+		want     map[string]interface{}
+		errPaths []string
+	}{
+		{
+			name: "later calculations run first when read",
+			fields: []Field{
+				calculated("total", "sub * 2"),
+				calculated("sub", "a + 1"),
+				{ID: "a", Type: "number"},
+			},
+			data: map[string]interface{}{"a": 2.0},
+			want: map[string]interface{}{"a": 2.0, "sub": 3.0, "total": 6.0},
+		},
+		{
+			name:   "client values for calculated fields are replaced",
+			fields: []Field{{ID: "a", Type: "number"}, calculated("double", "a * 2")},
+			data:   map[string]interface{}{"a": 2.0, "double": "hacked"},
+			want:   map[string]interface{}{"a": 2.0, "double": 4.0},
+		},
+		{
+			name:   "choices are seen by their label in any language",
+			fields: []Field{colour, calculated("red", "colour == \"Red\" ? \"yes\" : \"no\"")},
+			data:   map[string]interface{}{"colour": "أحمر"},
+			want:   map[string]interface{}{"colour": "أحمر", "red": "yes"},
+		},
+		{
+			name:   "null results are left unanswered",
+			fields: []Field{{ID: "a", Type: "text"}, calculated("mapped", "lookup(a, {\"x\": 1})")},
+			data:   map[string]interface{}{"a": "y", "mapped": 5.0},
+			want:   map[string]interface{}{"a": "y"},
+		},
+		{
+			name: "a failed calculation is reported and reads as null downstream",
+			fields: []Field{
+				{ID: "a", Type: "number"},
+				{ID: "b", Type: "number"},
+				calculated("ratio", "a / b"),
+				calculated("next", "ratio + 1"),
+			},
+			data:     map[string]interface{}{"a": 1.0, "b": 0.0, "ratio": 9.0},
+			want:     map[string]interface{}{"a": 1.0, "b": 0.0, "next": 1.0},
+			errPaths: []string{"responseData.ratio"},
+		},
+		{
+			name: "non-finite results fail",
+			fields: []Field{
+				{ID: "a", Type: "number"},
+				calculated("huge", "a * a"),
+			},
+			data:     map[string]interface{}{"a": 1e200},
+			want:     map[string]interface{}{"a": 1e200},
+			errPaths: []string{"responseData.huge"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Calculate(tt.fields, tt.data)
+			if !reflect.DeepEqual(tt.data, tt.want) {
+				t.Fatalf("data = %v, want %v", tt.data, tt.want)
+			}
+			var paths []string
+			for _, err := range errs {
+				paths = append(paths, err.Path)
+			}
+			if !reflect.DeepEqual(paths, tt.errPaths) {
+				t.Fatalf("error paths = %v (%v), want %v", paths, errs, tt.errPaths)
+			}
+		})
+	}
+}
+
+func TestCalculationOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		fields    []Field
+		wantOrder []string
+		wantCycle bool
+	}{
+		{
+			name:      "definition order without dependencies",
+			fields:    []Field{calculated("a", "1"), calculated("b", "2")},
+			wantOrder: []string{"a", "b"},
+		},
+		{
+			name:      "chain defined backwards",
+			fields:    []Field{calculated("c", "b + 1"), calculated("b", "a + 1"), calculated("a", "1")},
+			wantOrder: []string{"a", "b", "c"},
+		},
+		{
+			name:      "diamond",
+			fields:    []Field{calculated("d", "b + c"), calculated("b", "a"), calculated("c", "a"), calculated("a", "1")},
+			wantOrder: []string{"a", "b", "c", "d"},
+		},
+		{
+			name:      "self reference is not a cycle here",
+			fields:    []Field{calculated("a", "a + 1")},
+			wantOrder: []string{"a"},
+		},
+		{
+			name:      "two-field cycle",
+			fields:    []Field{calculated("a", "b"), calculated("b", "a")},
+			wantCycle: true,
+		},
+		{
+			name:      "longer cycle behind a valid field",
+			fields:    []Field{calculated("ok", "1"), calculated("x", "y"), calculated("y", "z"), calculated("z", "x + ok")},
+			wantCycle: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ordered, cycle := calculationOrder(tt.fields)
+			if tt.wantCycle {
+				if cycle == "" {
+					t.Fatalf("no cycle found; order %v", ordered)
+				}
+				return
+			}
+			if cycle != "" {
+				t.Fatalf("unexpected cycle at %q", cycle)
+			}
+			var ids []string
+			for _, field := range ordered {
+				ids = append(ids, field.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.wantOrder) {
+				t.Fatalf("order = %v, want %v", ids, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestValidateCalculations(t *testing.T) {
+	number := Field{ID: "n", Type: "number", Label: MultiLanguageText{"en": "N"}}
+	secret := Field{ID: "s", Type: "number", Label: MultiLanguageText{"en": "S"}, Sensitive: true}
+	sensitiveCalc := calculated("sc", "s * 2")
+	sensitiveCalc.Sensitive = true
+	requiredCalc := calculated("r", "n")
+	requiredCalc.Required = true
+	tests := []struct {
+		name   string
+		fields []Field
+		want   []string
+	}{
+		{"valid", []Field{number, calculated("c", "n * 2")}, nil},
+		{"missing expression", []Field{calculated("c", "")}, []string{"fields[0].expression: is required"}},
+		{"syntax error", []Field{calculated("c", "n +")}, []string{"fields[0].expression: unexpected end"}},
+		{"required", []Field{number, requiredCalc}, []string{"fields[1].required: calculated fields"}},
+		{"unknown field", []Field{calculated("c", "nope + 1")}, []string{"fields[0].expression: unknown field \"nope\""}},
+		{"self reference", []Field{calculated("c", "c + 1")}, []string{"fields[0].expression: refers to itself"}},
+		{"cycle", []Field{calculated("a", "b"), calculated("b", "a")}, []string{"expression: calculations depend on each other in a cycle"}},
+		{"reads a sensitive answer", []Field{secret, calculated("c", "s * 2")}, []string{"fields[1].expression: uses the sensitive field \"s\""}},
+		{"sensitive itself", []Field{secret, sensitiveCalc}, nil},
+		{"no validation rules", []Field{number, func() Field {
+			f := calculated("c", "n")
+			f.Validation = map[string]interface{}{"min": 1.0}
+			return f
+		}()}, []string{"fields[1].validation.min: is not a validation rule"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Validate(Definition{Title: MultiLanguageText{"en": "Form"}, Fields: tt.fields}, Options{})
+			if len(tt.want) == 0 {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(errs.Error(), want) {
+					t.Fatalf("errors %q lack %q", errs.Error(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package formdef
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Seats is the capacity of one option. Options are counted by their label, so editing
+// an option's text starts its count afresh while reordering options keeps it.
+type Seats struct {
+	FieldID  string
+	Option   string
+	Capacity int
+}
+
+// capacity checks option capacities: only fields picking a single option can limit
+// them, and each limit names one of the field's options
+func (v validator) capacity(fields []Field) {
+	for i, field := range fields {
+		if len(field.Capacity) == 0 {
+			continue
+		}
+		path := fmt.Sprintf("fields[%d].capacity", i)
+		if TypeOf(field).Describe().Kind != "choice" {
+			v.errs.Add(path, "only select and radio fields can limit their options")
+			continue
+		}
+		limited := make(map[int]string)
+		for _, option := range sortedKeys(field.Capacity) {
+			at := fmt.Sprintf("%s[%q]", path, option)
+			j := optionIndex(field, option)
+			if j < 0 {
+				v.errs.Add(at, "is not one of the options")
+				continue
+			}
+			if other, ok := limited[j]; ok {
+				v.errs.Add(at, "limits the same option as %q", other)
+			}
+			limited[j] = option
+			if field.Capacity[option] < 1 {
+				v.errs.Add(at, "must be at least 1")
+			}
+		}
+	}
+}
+
+// Capacities lists every option with a capacity, in field and option order
+func Capacities(fields []Field) []Seats {
+	var seats []Seats
+	for _, field := range fields {
+		if TypeOf(field).Describe().Kind != "choice" {
+			continue
+		}
+		for i, option := range field.Options {
+			if n := capacityOf(field, i); n > 0 {
+				seats = append(seats, Seats{FieldID: field.ID, Option: OptionLabel(option), Capacity: n})
+			}
+		}
+	}
+	return seats
+}
+
+// Bookings lists the options with a capacity that a normalized response picks
+func Bookings(fields []Field, data map[string]interface{}) []Seats {
+	var seats []Seats
+	for _, field := range fields {
+		s, _ := data[field.ID].(string)
+		if s == "" || len(field.Capacity) == 0 || TypeOf(field).Describe().Kind != "choice" {
+			continue
+		}
+		if i := optionIndex(field, s); i >= 0 {
+			if n := capacityOf(field, i); n > 0 {
+				seats = append(seats, Seats{FieldID: field.ID, Option: OptionLabel(field.Options[i]), Capacity: n})
+			}
+		}
+	}
+	return seats
+}
+
+// capacityOf returns the capacity of option i of field, or 0 when it is unlimited
+func capacityOf(field Field, i int) int {
+	for option, n := range field.Capacity {
+		if optionIndex(field, option) == i {
+			return n
+		}
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
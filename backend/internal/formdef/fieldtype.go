@@ -0,0 +1,140 @@
+package formdef
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// FieldType is the behavior of one kind of field. Submission, export and analytics go
+// through it, so a new type only has to be registered to work end to end.
+type FieldType interface {
+	// Name is the value of Field.Type for this type
+	Name() string
+	// Describe tells the rest of the system how to treat answers of this type
+	Describe() Description
+	// ValidateDefinition reports problems with a field of this type
+	ValidateDefinition(field Field, c Check)
+	// Normalize checks a submitted, non-empty answer and returns the value to store.
+	// Compound types may return Errors addressed relative to the answer (e.g. "row1").
+	Normalize(field Field, value interface{}) (interface{}, error)
+	// ExportColumns names the columns the answer is flattened into, in language, as
+	// suffixes of the field's own column; a single "" means the field's column alone
+	ExportColumns(field Field, language string) []string
+	// Export renders a stored answer into one cell per export column
+	Export(field Field, value interface{}) []string
+	// Aggregate summarizes the non-empty answers of a field for analytics
+	Aggregate(field Field, values []interface{}) map[string]interface{}
+}
+
+// PartsRequirer is implemented by compound types whose parts can be required on their
+// own, so an unanswered field that isn't required can still be missing answers
+type PartsRequirer interface {
+	PartsRequired(field Field) bool
+}
+
+// Description is what a field type says about its answers
+type Description struct {
+	// Kind groups types for analytics: text, number, choice, multichoice, date, time,
+	// file or a type-specific kind
+	Kind string `json:"kind"`
+	// Searchable answers are indexed for full-text search
+	Searchable bool `json:"searchable"`
+	// Identifying answers can hold free-form personal data and are dropped on anonymization
+	Identifying bool `json:"identifying"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]FieldType)
+)
+
+// Register makes a field type available under its name. Registering a name twice panics,
+// as it would silently change how stored answers are read.
+func Register(t FieldType) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[t.Name()]; dup {
+		panic(fmt.Sprintf("formdef: field type %q registered twice", t.Name()))
+	}
+	registry[t.Name()] = t
+}
+
+// Lookup returns the registered field type called name
+func Lookup(name string) (FieldType, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	t, ok := registry[name]
+	return t, ok
+}
+
+// KnownType reports whether t is a registered field type
+func KnownType(t string) bool {
+	_, ok := Lookup(t)
+	return ok
+}
+
+// TypeOf returns the type of field, falling back to a pass-through type for names that
+// are no longer registered so old answers can still be read and exported
+func TypeOf(field Field) FieldType {
+	if t, ok := Lookup(field.Type); ok {
+		return t
+	}
+	return unknownType{name: field.Type}
+}
+
+// Types lists the registered type names in order
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check reports problems found in one field definition under its path
+type Check struct {
+	path string
+	v    validator
+}
+
+// Errorf records a problem at the field's path followed by sub (e.g. ".options")
+func (c Check) Errorf(sub, format string, args ...interface{}) {
+	c.v.errs.Add(c.path+sub, format, args...)
+}
+
+// Text checks a translatable property of the field. Required text needs some language;
+// in strict mode text that is present must be present in every locale.
+func (c Check) Text(sub string, m MultiLanguageText, required bool) {
+	c.v.text(c.path+sub, m, required)
+}
+
+// Rules checks the field's validation rules, allowing only the named ones. min and max
+// must be numbers in order and pattern must compile.
+func (c Check) Rules(rules map[string]interface{}, allowed ...string) {
+	c.v.rules(c.path+".validation", rules, allowed)
+}
+
+// Fields checks child fields listed at sub (e.g. ".group.fields")
+func (c Check) Fields(sub string, fields []Field) {
+	c.v.fields(c.path+sub, fields)
+}
+
+// unknownType stands in for a type that is no longer registered
+type unknownType struct {
+	basicType
+	name string
+}
+
+func (t unknownType) Name() string { return t.name }
+
+func (t unknownType) Describe() Description {
+	return Description{Kind: "unknown", Identifying: true}
+}
+
+func (t unknownType) ValidateDefinition(field Field, c Check) {
+	c.Errorf(".type", "unknown field type %q", field.Type)
+}
@@ -0,0 +1,294 @@
+// Package formdef holds the form definition types shared by the API and checks that a
+// definition is structurally sound before it is stored. Problems are reported with the
+// path of the offending value (e.g. "fields[3].options") so editors can point at them.
+//
+// Each field type is a registered FieldType that validates its definition, normalizes
+// submitted answers, flattens them for export and aggregates them for analytics.
+package formdef
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MultiLanguageText maps a locale code to the text in that language
+type MultiLanguageText map[string]string
+
+// Scan implements the sql.Scanner interface for MultiLanguageText
+func (m *MultiLanguageText) Scan(value interface{}) error {
+	if value == nil {
+		*m = make(map[string]string)
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return fmt.Errorf("cannot scan %T into MultiLanguageText", value)
+	}
+
+	if len(bytes) == 0 {
+		*m = make(map[string]string)
+		return nil
+	}
+
+	// First check if it's a string (backward compatibility)
+	var str string
+	if err := json.Unmarshal(bytes, &str); err == nil {
+		*m = MultiLanguageText{"en": str, "ar": ""}
+		return nil
+	}
+
+	// Otherwise unmarshal as object
+	return json.Unmarshal(bytes, m)
+}
+
+// Value implements the driver.Valuer interface for MultiLanguageText
+func (m MultiLanguageText) Value() (driver.Value, error) {
+	if m == nil {
+		return "{}", nil
+	}
+	return json.Marshal(m)
+}
+
+// empty reports whether no locale has any text
+func (m MultiLanguageText) empty() bool {
+	for _, s := range m {
+		if strings.TrimSpace(s) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Field is one question of a form
+type Field struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	Label       MultiLanguageText      `json:"label"`
+	Placeholder MultiLanguageText      `json:"placeholder,omitempty"`
+	Required    bool                   `json:"required"`
+	Options     []MultiLanguageText    `json:"options,omitempty"`
+	Validation  map[string]interface{} `json:"validation,omitempty"`
+	Sensitive   bool                   `json:"sensitive,omitempty"`
+	// Scale configures rating, scale and NPS fields
+	Scale *Scale `json:"scale,omitempty"`
+	// Matrix configures matrix fields
+	Matrix *Matrix `json:"matrix,omitempty"`
+	// Group holds the child fields of a repeatable group
+	Group *Group `json:"group,omitempty"`
+	// Page is the ID of the page the field is on; fields without one are on the first
+	Page string `json:"page,omitempty"`
+	// Expression computes a calculated field from other answers (see package expr)
+	Expression string `json:"expression,omitempty"`
+	// Quiz makes the field a scored question when the form is a quiz
+	Quiz *Question `json:"quiz,omitempty"`
+	// Capacity limits how many responses may pick an option of a select or radio field,
+	// keyed by the option's text in any language
+	Capacity map[string]int `json:"capacity,omitempty"`
+	// ShuffleOptions shows the options in a different order to each respondent, except
+	// the last PinLast, which stay at the end (e.g. "Other")
+	ShuffleOptions bool `json:"shuffleOptions,omitempty"`
+	PinLast        int  `json:"pinLast,omitempty"`
+}
+
+// Scale is the range of a rating, linear scale or NPS field, with optional labels for
+// its two ends (e.g. "Not likely" / "Very likely")
+type Scale struct {
+	Min      int               `json:"min"`
+	Max      int               `json:"max"`
+	MinLabel MultiLanguageText `json:"minLabel,omitempty"`
+	MaxLabel MultiLanguageText `json:"maxLabel,omitempty"`
+}
+
+// Definition is the part of a form the validator checks
+type Definition struct {
+	Title            MultiLanguageText
+	Description      MultiLanguageText
+	SubmitButtonText MultiLanguageText
+	Fields           []Field
+	Pages            []Page
+	Quiz             *Quiz
+	// Messages are the texts sent once a response is stored, such as email receipts
+	Messages []Message
+}
+
+// Message is a text rendered after submission, checked under Path. Like quiz result
+// messages it may show any answer that can be piped, calculated ones and the score too.
+type Message struct {
+	Path string
+	Text MultiLanguageText
+}
+
+// Error is one problem found in a definition
+type Error struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Errors lists every problem found in a definition, in document order
+type Errors []Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Path + ": " + err.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Add records a problem at path
+func (e *Errors) Add(path, format string, args ...interface{}) {
+	*e = append(*e, Error{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Options tune validation
+type Options struct {
+	// Strict requires every text to be translated into each of Locales
+	Strict  bool
+	Locales []string
+}
+
+// Validate returns every structural problem in def, or nil if there are none
+func Validate(def Definition, opts Options) Errors {
+	var errs Errors
+	v := validator{opts: opts, errs: &errs}
+
+	v.text("title", def.Title, true)
+	v.text("description", def.Description, false)
+	v.text("submitButtonText", def.SubmitButtonText, false)
+
+	v.fields("fields", def.Fields)
+	v.pages(def.Pages, def.Fields)
+	v.calculations(def.Fields)
+	v.quiz(def.Quiz, def.Fields)
+	v.capacity(def.Fields)
+	v.shuffle(def.Fields)
+	v.piping(def)
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+type validator struct {
+	opts Options
+	errs *Errors
+	// dropRules deletes validation rules the field type doesn't accept instead of
+	// reporting them
+	dropRules bool
+}
+
+// DropUnknownRules deletes, in place, the validation rules each field's type does not
+// accept. Definitions saved before the per-type rule lists may still carry them and
+// would otherwise fail validation on their next save.
+func DropUnknownRules(fields []Field) {
+	var errs Errors
+	v := validator{errs: &errs, dropRules: true}
+	v.fields("fields", fields)
+}
+
+// fields checks a list of fields found at path, whose IDs must be unique within it
+func (v validator) fields(path string, fields []Field) {
+	seen := make(map[string]int)
+	for i, field := range fields {
+		fieldPath := fmt.Sprintf("%s[%d]", path, i)
+		if strings.TrimSpace(field.ID) == "" {
+			v.errs.Add(fieldPath+".id", "is required")
+		} else if first, ok := seen[field.ID]; ok {
+			v.errs.Add(fieldPath+".id", "duplicates %s[%d].id %q", path, first, field.ID)
+		} else {
+			seen[field.ID] = i
+		}
+		v.text(fieldPath+".label", field.Label, true)
+		v.text(fieldPath+".placeholder", field.Placeholder, false)
+		TypeOf(field).ValidateDefinition(field, Check{path: fieldPath, v: v})
+	}
+}
+
+// text checks a translatable value. Required text needs some language; in strict mode
+// any text that is present must be present in every locale.
+func (v validator) text(path string, m MultiLanguageText, required bool) {
+	if m.empty() {
+		if required {
+			v.errs.Add(path, "is required")
+		}
+		return
+	}
+	if !v.opts.Strict {
+		return
+	}
+	for _, locale := range v.opts.Locales {
+		if strings.TrimSpace(m[locale]) == "" {
+			v.errs.Add(path+"."+locale, "translation is missing")
+		}
+	}
+}
+
+// rules checks a field's validation rules: min and max must be numbers in order and
+// pattern must be a regular expression that compiles. Rules not in allowed are rejected.
+func (v validator) rules(path string, rules map[string]interface{}, allowed []string) {
+	min, hasMin := 0.0, false
+	max, hasMax := 0.0, false
+	if contains(allowed, "min") {
+		min, hasMin = v.number(path+".min", rules, "min")
+	}
+	if contains(allowed, "max") {
+		max, hasMax = v.number(path+".max", rules, "max")
+	}
+	if hasMin && hasMax && min > max {
+		v.errs.Add(path+".min", "must not be greater than max")
+	}
+	if raw, ok := rules["pattern"]; ok && contains(allowed, "pattern") {
+		if pattern, isString := raw.(string); !isString {
+			v.errs.Add(path+".pattern", "must be a string")
+		} else if _, err := regexp.Compile(pattern); err != nil {
+			v.errs.Add(path+".pattern", "does not compile: %v", err)
+		}
+	}
+	var unknown []string
+	for key := range rules {
+		if !contains(allowed, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	if v.dropRules {
+		for _, key := range unknown {
+			delete(rules, key)
+		}
+		return
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		v.errs.Add(path+"."+key, "is not a validation rule for this field type")
+	}
+}
+
+func (v validator) number(path string, rules map[string]interface{}, key string) (float64, bool) {
+	raw, ok := rules[key]
+	if !ok || raw == nil {
+		return 0, false
+	}
+	switch n := raw.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		if f, err := n.Float64(); err == nil {
+			return f, true
+		}
+	}
+	v.errs.Add(path, "must be a number")
+	return 0, false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package formdef
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDropUnknownRules(t *testing.T) {
+	label := MultiLanguageText{"en": "Q"}
+	fields := []Field{
+		{ID: "when", Type: "date", Label: label, Validation: map[string]interface{}{"pattern": "x", "min": 1.0}},
+		{ID: "mail", Type: "email", Label: label, Validation: map[string]interface{}{"min": 1.0, "max": 5.0, "pattern": "@"}},
+		{ID: "age", Type: "number", Label: label, Validation: map[string]interface{}{"min": 1.0, "max": 99.0}},
+		{ID: "people", Type: "group", Label: label, Group: &Group{Min: 1, Max: 2, Fields: []Field{
+			{ID: "name", Type: "text", Label: label, Validation: map[string]interface{}{"pattern": ".+", "other": true}},
+		}}},
+	}
+	DropUnknownRules(fields)
+
+	tests := []struct {
+		name  string
+		rules map[string]interface{}
+		want  map[string]interface{}
+	}{
+		{"date keeps nothing", fields[0].Validation, map[string]interface{}{}},
+		{"email keeps pattern", fields[1].Validation, map[string]interface{}{"pattern": "@"}},
+		{"number keeps min and max", fields[2].Validation, map[string]interface{}{"min": 1.0, "max": 99.0}},
+		{"group children are cleaned", fields[3].Group.Fields[0].Validation, map[string]interface{}{"pattern": ".+"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !reflect.DeepEqual(tt.rules, tt.want) {
+				t.Fatalf("rules = %v, want %v", tt.rules, tt.want)
+			}
+		})
+	}
+	if errs := Validate(Definition{Title: label, Fields: fields}, Options{}); len(errs) > 0 {
+		t.Fatalf("cleaned fields still fail validation: %v", errs)
+	}
+}
@@ -0,0 +1,216 @@
+package formdef
+
+import (
+	"errors"
+	"fmt"
+)
+
+func init() {
+	Register(groupType{})
+}
+
+// maxGroupRepeats bounds how often a group can repeat; Max also sets how many instances
+// the wide export has room for
+const maxGroupRepeats = 50
+
+// Group is a set of fields the respondent fills in Min to Max times, e.g. once per
+// household member
+type Group struct {
+	Fields   []Field           `json:"fields"`
+	Min      int               `json:"min"`
+	Max      int               `json:"max"`
+	AddLabel MultiLanguageText `json:"addLabel,omitempty"`
+}
+
+// groupType stores answers as an array with one object per instance, keyed by child
+// field ID. Every instance is validated like a small form of its own.
+type groupType struct{ basicType }
+
+func (groupType) Name() string { return "group" }
+
+func (groupType) Describe() Description { return Description{Kind: "group", Identifying: true} }
+
+func (groupType) ValidateDefinition(field Field, c Check) {
+	g := field.Group
+	if g == nil {
+		c.Errorf(".group", "is required")
+		return
+	}
+	if g.Min < 0 {
+		c.Errorf(".group.min", "must not be negative")
+	}
+	if g.Max < 1 || g.Max > maxGroupRepeats {
+		c.Errorf(".group.max", "must be between 1 and %d", maxGroupRepeats)
+	} else if g.Min > g.Max {
+		c.Errorf(".group.min", "must not be greater than max")
+	}
+	c.Text(".group.addLabel", g.AddLabel, false)
+	if len(g.Fields) == 0 {
+		c.Errorf(".group.fields", "a group needs at least one field")
+	}
+	for i, child := range g.Fields {
+		// Sensitive answers are encrypted per top-level field, so only the whole group can be
+		if child.Sensitive {
+			c.Errorf(fmt.Sprintf(".group.fields[%d].sensitive", i), "mark the group sensitive instead")
+		}
+		if child.Page != "" {
+			c.Errorf(fmt.Sprintf(".group.fields[%d].page", i), "entries are shown on the group's page")
+		}
+		if child.Type == "group" {
+			c.Errorf(fmt.Sprintf(".group.fields[%d].type", i), "groups cannot be nested")
+		}
+		if child.Type == "hidden" || child.Type == "calculated" {
+			c.Errorf(fmt.Sprintf(".group.fields[%d].type", i), "%s fields are filled per response, not per entry", child.Type)
+		}
+		if child.Quiz != nil {
+			c.Errorf(fmt.Sprintf(".group.fields[%d].quiz", i), "quiz questions cannot be repeated")
+		}
+		if child.ShuffleOptions {
+			c.Errorf(fmt.Sprintf(".group.fields[%d].shuffleOptions", i), "options of repeated fields keep their order")
+		}
+		if len(child.Capacity) > 0 {
+			c.Errorf(fmt.Sprintf(".group.fields[%d].capacity", i), "each entry would take its own place; limit the group instead")
+		}
+	}
+	c.Fields(".group.fields", g.Fields)
+	c.Rules(field.Validation)
+}
+
+// groupInstances reads a stored or submitted group answer
+func groupInstances(value interface{}) ([]map[string]interface{}, bool) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	instances := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		if instances[i], ok = item.(map[string]interface{}); !ok {
+			return nil, false
+		}
+	}
+	return instances, true
+}
+
+func (groupType) Normalize(field Field, value interface{}) (interface{}, error) {
+	g := field.Group
+	if g == nil {
+		return nil, errors.New("field has no group")
+	}
+	instances, ok := groupInstances(value)
+	if !ok {
+		return nil, errors.New("must be a list of entries")
+	}
+	if len(instances) < g.Min {
+		return nil, fmt.Errorf("needs at least %d entries", g.Min)
+	}
+	if len(instances) > g.Max {
+		return nil, fmt.Errorf("allows at most %d entries", g.Max)
+	}
+	var errs Errors
+	normalized := make([]interface{}, len(instances))
+	for i, instance := range instances {
+		errs = append(errs, normalizeAnswers(g.Fields, instance, fmt.Sprintf("[%d]", i))...)
+		normalized[i] = instance
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return normalized, nil
+}
+
+// ExportColumns makes room for Max instances side by side (the wide layout)
+func (groupType) ExportColumns(field Field, language string) []string {
+	g := field.Group
+	if g == nil {
+		return []string{""}
+	}
+	var columns []string
+	children := ExportHeader(g.Fields, language)
+	for i := 1; i <= g.Max; i++ {
+		for _, child := range children {
+			columns = append(columns, fmt.Sprintf("#%d %s", i, child))
+		}
+	}
+	return columns
+}
+
+func (t groupType) Export(field Field, value interface{}) []string {
+	g := field.Group
+	instances, ok := groupInstances(value)
+	if g == nil || !ok {
+		return []string{FormatValue(value)}
+	}
+	var cells []string
+	for _, instance := range instances {
+		cells = append(cells, ExportRow(g.Fields, instance)...)
+	}
+	return cells
+}
+
+// Aggregate reports how many entries respondents added and summarizes each child field
+// across every entry
+func (groupType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	g := field.Group
+	if g == nil {
+		return map[string]interface{}{}
+	}
+	var counts []interface{}
+	var all []map[string]interface{}
+	for _, value := range values {
+		instances, ok := groupInstances(value)
+		if !ok {
+			continue
+		}
+		counts = append(counts, float64(len(instances)))
+		all = append(all, instances...)
+	}
+	return map[string]interface{}{
+		"entries": numberStats(counts),
+		"fields":  Analyze(g.Fields, all, nil),
+	}
+}
+
+// GroupField returns the group field called id, or the first group when id is empty
+func GroupField(fields []Field, id string) (Field, bool) {
+	for _, field := range fields {
+		if field.Type == "group" && field.Group != nil && (id == "" || field.ID == id) {
+			return field, true
+		}
+	}
+	return Field{}, false
+}
+
+// ExportLongHeader names the columns of the long layout, where each entry of group gets
+// its own row: the entry number and the group's child fields follow the other fields
+func ExportLongHeader(fields []Field, group Field, language string) []string {
+	header := ExportHeader(withoutField(fields, group.ID), language)
+	header = append(header, "#")
+	return append(header, ExportHeader(group.Group.Fields, language)...)
+}
+
+// ExportLongRows flattens one response into a row per entry of group, repeating the
+// other answers on each. A response without entries still gets one row.
+func ExportLongRows(fields []Field, group Field, data map[string]interface{}) [][]string {
+	base := ExportRow(withoutField(fields, group.ID), data)
+	instances, _ := groupInstances(data[group.ID])
+	if len(instances) == 0 {
+		row := append(append([]string{}, base...), "")
+		return [][]string{append(row, make([]string, len(ExportHeader(group.Group.Fields, "")))...)}
+	}
+	rows := make([][]string, len(instances))
+	for i, instance := range instances {
+		row := append(append([]string{}, base...), fmt.Sprint(i+1))
+		rows[i] = append(row, ExportRow(group.Group.Fields, instance)...)
+	}
+	return rows
+}
+
+func withoutField(fields []Field, id string) []Field {
+	kept := make([]Field, 0, len(fields))
+	for _, field := range fields {
+		if field.ID != id {
+			kept = append(kept, field)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,46 @@
+package formdef
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+func init() {
+	Register(hiddenType{})
+}
+
+// MaxHiddenLength bounds a hidden answer, which arrives in the link rather than from
+// the respondent
+const MaxHiddenLength = 500
+
+// hiddenType is never shown to the respondent; the public form fills it from a query
+// parameter of the same name as the field ID, such as a campaign or referral code
+type hiddenType struct{ basicType }
+
+func (hiddenType) Name() string { return "hidden" }
+
+func (hiddenType) Describe() Description {
+	return Description{Kind: "text", Searchable: true, Identifying: true}
+}
+
+func (hiddenType) ValidateDefinition(field Field, c Check) {
+	if field.Sensitive {
+		c.Errorf(".sensitive", "hidden fields are visible in the link and cannot be sensitive")
+	}
+	c.Rules(field.Validation, "pattern")
+}
+
+func (hiddenType) Normalize(field Field, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be text")
+	}
+	if utf8.RuneCountInString(s) > MaxHiddenLength {
+		return nil, fmt.Errorf("must be at most %d characters", MaxHiddenLength)
+	}
+	if err := matchPattern(field, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
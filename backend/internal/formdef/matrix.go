@@ -0,0 +1,213 @@
+package formdef
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+func init() {
+	Register(matrixType{})
+}
+
+// Matrix asks every row the same question, answered by picking one column per row or,
+// with Multiple, any number of columns
+type Matrix struct {
+	Rows     []MatrixRow         `json:"rows"`
+	Columns  []MultiLanguageText `json:"columns"`
+	Multiple bool                `json:"multiple,omitempty"`
+}
+
+// MatrixRow is one statement or aspect of a matrix. Required rows must be answered even
+// when the matrix itself is optional.
+type MatrixRow struct {
+	ID       string            `json:"id"`
+	Label    MultiLanguageText `json:"label"`
+	Required bool              `json:"required,omitempty"`
+}
+
+// matrixType stores answers as {"rowId": "column"} or, for multiple choice,
+// {"rowId": ["column", ...]}. As with choice fields, any translation of a column counts.
+type matrixType struct{ basicType }
+
+func (matrixType) Name() string { return "matrix" }
+
+func (matrixType) Describe() Description { return Description{Kind: "matrix"} }
+
+func (matrixType) ValidateDefinition(field Field, c Check) {
+	m := field.Matrix
+	if m == nil {
+		c.Errorf(".matrix", "is required")
+		return
+	}
+	if len(m.Rows) == 0 {
+		c.Errorf(".matrix.rows", "a matrix needs at least one row")
+	}
+	seen := make(map[string]int)
+	for i, row := range m.Rows {
+		sub := fmt.Sprintf(".matrix.rows[%d]", i)
+		if strings.TrimSpace(row.ID) == "" {
+			c.Errorf(sub+".id", "is required")
+		} else if first, dup := seen[row.ID]; dup {
+			c.Errorf(sub+".id", "duplicates matrix.rows[%d].id %q", first, row.ID)
+		} else {
+			seen[row.ID] = i
+		}
+		c.Text(sub+".label", row.Label, true)
+	}
+	if len(m.Columns) == 0 {
+		c.Errorf(".matrix.columns", "a matrix needs at least one column")
+	}
+	for j, column := range m.Columns {
+		c.Text(fmt.Sprintf(".matrix.columns[%d]", j), column, true)
+	}
+	c.Rules(field.Validation)
+}
+
+func (matrixType) PartsRequired(field Field) bool {
+	if field.Matrix == nil {
+		return false
+	}
+	for _, row := range field.Matrix.Rows {
+		if row.Required {
+			return true
+		}
+	}
+	return false
+}
+
+// columnIndex returns the index of the column with s as one of its translations, or -1
+func columnIndex(m *Matrix, s string) int {
+	return optionIndex(Field{Options: m.Columns}, s)
+}
+
+func (matrixType) Normalize(field Field, value interface{}) (interface{}, error) {
+	m := field.Matrix
+	if m == nil {
+		return nil, errors.New("field has no matrix")
+	}
+	answers, ok := value.(map[string]interface{})
+	if value != nil && !ok {
+		return nil, errors.New("must map each row to its answer")
+	}
+
+	var errs Errors
+	normalized := make(map[string]interface{})
+	rows := make(map[string]bool, len(m.Rows))
+	for _, row := range m.Rows {
+		rows[row.ID] = true
+		answer := answers[row.ID]
+		if emptyAnswer(answer) {
+			if row.Required {
+				errs.Add(row.ID, "is required")
+			}
+			continue
+		}
+		if !m.Multiple {
+			s, _ := answer.(string)
+			if columnIndex(m, s) < 0 {
+				errs.Add(row.ID, "must be one of the columns")
+				continue
+			}
+			normalized[row.ID] = s
+			continue
+		}
+		items, isList := answer.([]interface{})
+		if !isList {
+			items = []interface{}{answer}
+		}
+		picked := make([]interface{}, 0, len(items))
+		seen := make(map[int]bool)
+		for _, item := range items {
+			s, _ := item.(string)
+			j := columnIndex(m, s)
+			if j < 0 {
+				errs.Add(row.ID, "must only contain the columns")
+				picked = nil
+				break
+			}
+			if !seen[j] {
+				seen[j] = true
+				picked = append(picked, s)
+			}
+		}
+		if picked != nil {
+			normalized[row.ID] = picked
+		}
+	}
+	var unknown []string
+	for key := range answers {
+		if !rows[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		errs.Add(key, "is not a row of this matrix")
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return normalized, nil
+}
+
+// ExportColumns gives every row its own column
+func (matrixType) ExportColumns(field Field, language string) []string {
+	if field.Matrix == nil {
+		return []string{""}
+	}
+	columns := make([]string, len(field.Matrix.Rows))
+	for i, row := range field.Matrix.Rows {
+		columns[i] = TextIn(row.Label, language)
+		if columns[i] == "" {
+			columns[i] = row.ID
+		}
+	}
+	return columns
+}
+
+func (matrixType) Export(field Field, value interface{}) []string {
+	answers, ok := value.(map[string]interface{})
+	if field.Matrix == nil || !ok {
+		return []string{FormatValue(value)}
+	}
+	cells := make([]string, len(field.Matrix.Rows))
+	for i, row := range field.Matrix.Rows {
+		cells[i] = FormatValue(answers[row.ID])
+	}
+	return cells
+}
+
+// Aggregate counts, for every row, how often each column was picked
+func (matrixType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	m := field.Matrix
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	columns := Field{Options: m.Columns}
+	rows := make([]map[string]interface{}, 0, len(m.Rows))
+	for _, row := range m.Rows {
+		var picks []interface{}
+		answered := 0
+		for _, value := range values {
+			answers, _ := value.(map[string]interface{})
+			answer := answers[row.ID]
+			if emptyAnswer(answer) {
+				continue
+			}
+			answered++
+			if items, ok := answer.([]interface{}); ok {
+				picks = append(picks, items...)
+			} else {
+				picks = append(picks, answer)
+			}
+		}
+		stats := optionCounts(columns, picks)
+		stats["id"] = row.ID
+		stats["label"] = row.Label
+		stats["answered"] = answered
+		rows = append(rows, stats)
+	}
+	return map[string]interface{}{"rows": rows}
+}
@@ -0,0 +1,85 @@
+package formdef
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Page is one step of a multi-page form. Pages are shown in order; each field names the
+// page it is on with Field.Page.
+type Page struct {
+	ID    string            `json:"id"`
+	Title MultiLanguageText `json:"title"`
+	// ShuffleFields shows the page's fields in a different order to each respondent
+	ShuffleFields bool `json:"shuffleFields,omitempty"`
+}
+
+// pages checks the page list and that every field is on one of the pages
+func (v validator) pages(pages []Page, fields []Field) {
+	seen := make(map[string]int)
+	for i, page := range pages {
+		path := fmt.Sprintf("pages[%d]", i)
+		if strings.TrimSpace(page.ID) == "" {
+			v.errs.Add(path+".id", "is required")
+		} else if first, ok := seen[page.ID]; ok {
+			v.errs.Add(path+".id", "duplicates pages[%d].id %q", first, page.ID)
+		} else {
+			seen[page.ID] = i
+		}
+		v.text(path+".title", page.Title, true)
+	}
+	for i, field := range fields {
+		if field.Page == "" {
+			continue
+		}
+		if len(pages) == 0 {
+			v.errs.Add(fmt.Sprintf("fields[%d].page", i), "the form has no pages")
+		} else if _, ok := seen[field.Page]; !ok {
+			v.errs.Add(fmt.Sprintf("fields[%d].page", i), "unknown page %q", field.Page)
+		}
+	}
+}
+
+// PageOf returns the ID of the page field is on. Fields that don't name a page are on
+// the first one; a form without pages is a single page with an empty ID.
+func PageOf(field Field, pages []Page) string {
+	if field.Page != "" || len(pages) == 0 {
+		return field.Page
+	}
+	return pages[0].ID
+}
+
+// PageFields returns the fields on the page called id, in form order, and whether the
+// page exists
+func PageFields(fields []Field, pages []Page, id string) ([]Field, bool) {
+	found := len(pages) == 0 && id == ""
+	for _, page := range pages {
+		if page.ID == id {
+			found = true
+		}
+	}
+	if !found {
+		return nil, false
+	}
+	var onPage []Field
+	for _, field := range fields {
+		if PageOf(field, pages) == id {
+			onPage = append(onPage, field)
+		}
+	}
+	return onPage, true
+}
+
+// DisplayOrder returns fields in the order respondents meet them: page by page, and in
+// form order within a page
+func DisplayOrder(fields []Field, pages []Page) []Field {
+	if len(pages) == 0 {
+		return fields
+	}
+	ordered := make([]Field, 0, len(fields))
+	for _, page := range pages {
+		onPage, _ := PageFields(fields, pages, page.ID)
+		ordered = append(ordered, onPage...)
+	}
+	return ordered
+}
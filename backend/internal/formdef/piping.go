@@ -0,0 +1,220 @@
+package formdef
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// pipePattern matches an answer reference in text: {{fieldId}}, or {{fieldId|fallback}}
+// to show fallback while the field is unanswered
+var pipePattern = regexp.MustCompile(`\{\{\s*([^{}|]+?)\s*(?:\|([^{}]*))?\}\}`)
+
+// quizVariables can be piped into quiz result messages, which are rendered after scoring
+var quizVariables = []string{"score", "maxScore"}
+
+// PipedRefs lists the field IDs referenced in any translation of text
+func PipedRefs(text MultiLanguageText) []string {
+	var refs []string
+	seen := make(map[string]bool)
+	for _, locale := range sortedLocales(text) {
+		for _, match := range pipePattern.FindAllStringSubmatch(text[locale], -1) {
+			if id := match[1]; !seen[id] {
+				seen[id] = true
+				refs = append(refs, id)
+			}
+		}
+	}
+	return refs
+}
+
+// Render replaces the answer references in text with the answers in data, with picked
+// options shown in language. Unanswered and unknown references show their fallback,
+// or nothing.
+func Render(text string, fields []Field, data map[string]interface{}, language string) string {
+	return pipePattern.ReplaceAllStringFunc(text, func(ref string) string {
+		match := pipePattern.FindStringSubmatch(ref)
+		id, fallback := match[1], strings.TrimSpace(match[2])
+		value, ok := data[id]
+		if !ok || emptyAnswer(value) {
+			return fallback
+		}
+		for _, field := range fields {
+			if field.ID == id {
+				return pipedAnswer(field, value, language)
+			}
+		}
+		return FormatValue(value)
+	})
+}
+
+// RenderText renders every translation of text
+func RenderText(text MultiLanguageText, fields []Field, data map[string]interface{}) MultiLanguageText {
+	if text == nil {
+		return nil
+	}
+	rendered := make(MultiLanguageText, len(text))
+	for locale, s := range text {
+		rendered[locale] = Render(s, fields, data, locale)
+	}
+	return rendered
+}
+
+// pipedAnswer shows an answer inside text: options in language, several picks joined
+// with commas and multi-column answers such as date ranges joined with a dash
+func pipedAnswer(field Field, value interface{}, language string) string {
+	option := func(item interface{}) string {
+		s, _ := item.(string)
+		if i := optionIndex(field, s); i >= 0 {
+			return TextIn(field.Options[i], language)
+		}
+		return FormatValue(item)
+	}
+	switch TypeOf(field).Describe().Kind {
+	case "choice":
+		return option(value)
+	case "multichoice":
+		items, _ := value.([]interface{})
+		labels := make([]string, len(items))
+		for i, item := range items {
+			labels[i] = option(item)
+		}
+		return strings.Join(labels, ", ")
+	}
+	var parts []string
+	for _, cell := range TypeOf(field).Export(field, value) {
+		if cell != "" {
+			parts = append(parts, cell)
+		}
+	}
+	return strings.Join(parts, " – ")
+}
+
+// pipeable reports why answers to field can't be shown in other texts, or "" if they can
+func pipeable(field Field) string {
+	switch {
+	case field.Sensitive:
+		return "is sensitive"
+	case field.Type == "password", field.Type == "file", field.Type == "group", field.Type == "matrix":
+		return fmt.Sprintf("is a %s field", field.Type)
+	}
+	return ""
+}
+
+// piping checks the answer references in every text of the form. A text may only show
+// answers respondents have already given when they read it: the title and description
+// none, a page title those on earlier pages and a field those before it. Calculated
+// answers only exist once the response is submitted, so only quiz result messages and
+// other messages can show them, along with {{score}} and {{maxScore}}.
+func (v validator) piping(def Definition) {
+	byID := make(map[string]Field, len(def.Fields))
+	for _, field := range def.Fields {
+		byID[field.ID] = field
+	}
+	position := make(map[string]int, len(def.Fields))
+	for i, field := range DisplayOrder(def.Fields, def.Pages) {
+		position[field.ID] = i
+	}
+	check := func(path string, text MultiLanguageText, before int, self string, afterSubmit bool) {
+		for _, id := range PipedRefs(text) {
+			ref, ok := byID[id]
+			switch {
+			case afterSubmit && contains(quizVariables, id):
+			case !ok:
+				v.errs.Add(path, "refers to unknown field %q", id)
+			case id == self:
+				v.errs.Add(path, "refers to its own answer")
+			case pipeable(ref) != "":
+				v.errs.Add(path, "refers to %q, which %s and can't be shown", id, pipeable(ref))
+			case ref.Type == "calculated" && !afterSubmit:
+				v.errs.Add(path, "refers to %q, which is only calculated on submit", id)
+			case position[id] >= before:
+				v.errs.Add(path, "refers to %q, which is not asked before it", id)
+			}
+		}
+	}
+
+	check("title", def.Title, 0, "", false)
+	check("description", def.Description, 0, "", false)
+	check("submitButtonText", def.SubmitButtonText, len(def.Fields), "", false)
+	asked := 0
+	for i, page := range def.Pages {
+		check(fmt.Sprintf("pages[%d].title", i), page.Title, asked, "", false)
+		onPage, _ := PageFields(def.Fields, def.Pages, page.ID)
+		asked += len(onPage)
+	}
+	// On a shuffled page any field may come first, so its fields can't show each other
+	pageStart := make(map[string]int)
+	for _, page := range def.Pages {
+		if !page.ShuffleFields {
+			continue
+		}
+		if onPage, _ := PageFields(def.Fields, def.Pages, page.ID); len(onPage) > 0 {
+			pageStart[page.ID] = position[onPage[0].ID]
+		}
+	}
+	for i, field := range def.Fields {
+		before, ok := position[field.ID]
+		if !ok {
+			continue // the field is on an unknown page, which is reported already
+		}
+		if start, shuffled := pageStart[PageOf(field, def.Pages)]; shuffled {
+			before = start
+		}
+		for _, t := range fieldTexts(fmt.Sprintf("fields[%d]", i), field) {
+			check(t.path, t.text, before, field.ID, false)
+		}
+	}
+	if def.Quiz != nil {
+		for i, band := range def.Quiz.Bands {
+			check(fmt.Sprintf("quiz.bands[%d].message", i), band.Message, len(def.Fields), "", true)
+		}
+	}
+	for _, message := range def.Messages {
+		check(message.Path, message.Text, len(def.Fields), "", true)
+	}
+}
+
+// pathText is one translatable text of a field and where it is
+type pathText struct {
+	path string
+	text MultiLanguageText
+}
+
+// fieldTexts lists every translatable text of a field, including those of its rows,
+// columns, scale ends and group children
+func fieldTexts(path string, field Field) []pathText {
+	texts := []pathText{{path + ".label", field.Label}, {path + ".placeholder", field.Placeholder}}
+	for i, option := range field.Options {
+		texts = append(texts, pathText{fmt.Sprintf("%s.options[%d]", path, i), option})
+	}
+	if s := field.Scale; s != nil {
+		texts = append(texts, pathText{path + ".scale.minLabel", s.MinLabel}, pathText{path + ".scale.maxLabel", s.MaxLabel})
+	}
+	if m := field.Matrix; m != nil {
+		for i, row := range m.Rows {
+			texts = append(texts, pathText{fmt.Sprintf("%s.matrix.rows[%d].label", path, i), row.Label})
+		}
+		for i, column := range m.Columns {
+			texts = append(texts, pathText{fmt.Sprintf("%s.matrix.columns[%d]", path, i), column})
+		}
+	}
+	if g := field.Group; g != nil {
+		texts = append(texts, pathText{path + ".group.addLabel", g.AddLabel})
+		for i, child := range g.Fields {
+			texts = append(texts, fieldTexts(fmt.Sprintf("%s.group.fields[%d]", path, i), child)...)
+		}
+	}
+	return texts
+}
+
+// sortedLocales returns the locales of text in a stable order
+func sortedLocales(text MultiLanguageText) []string {
+	locales := make([]string, 0, len(text))
+	for locale := range text {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
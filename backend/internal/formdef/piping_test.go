@@ -0,0 +1,203 @@
+package formdef
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	fields := []Field{
+		{ID: "name", Type: "text"},
+		{ID: "rating", Type: "number"},
+		{ID: "colour", Type: "radio", Options: []MultiLanguageText{{"en": "Red", "ar": "أحمر"}, {"en": "Blue", "ar": "أزرق"}}},
+		{ID: "extras", Type: "checkbox", Options: []MultiLanguageText{{"en": "Wifi", "ar": "واي فاي"}, {"en": "Parking", "ar": "موقف"}}},
+	}
+	data := map[string]interface{}{
+		"name":   "Sara",
+		"rating": 4.5,
+		"colour": "Red",
+		"extras": []interface{}{"Wifi", "موقف"},
+		"blank":  "  ",
+		"score":  7.0,
+	}
+	tests := []struct {
+		name     string
+		text     string
+		language string
+		want     string
+	}{
+		{"plain text", "Thanks!", "en", "Thanks!"},
+		{"answer", "Hi {{name}}!", "en", "Hi Sara!"},
+		{"spaces inside braces", "Hi {{ name }}", "en", "Hi Sara"},
+		{"number", "You rated {{rating}} stars", "en", "You rated 4.5 stars"},
+		{"option in the reader's language", "{{colour}}", "ar", "أحمر"},
+		{"picks joined", "{{extras}}", "en", "Wifi, Parking"},
+		{"picks in Arabic", "{{extras}}", "ar", "واي فاي, موقف"},
+		{"fallback when unanswered", "Hi {{missing|there}}", "en", "Hi there"},
+		{"fallback when blank", "Hi {{blank| there }}", "en", "Hi there"},
+		{"nothing without a fallback", "Hi {{missing}}!", "en", "Hi !"},
+		{"value that is not a field", "{{score}}/10", "en", "7/10"},
+		{"repeated references", "{{name}} and {{name}}", "en", "Sara and Sara"},
+		{"unbalanced braces are left alone", "{{name} {name}}", "en", "{{name} {name}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.text, fields, data, tt.language); got != tt.want {
+				t.Fatalf("Render = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	fields := []Field{{ID: "name", Type: "text"}}
+	data := map[string]interface{}{"name": "Sara"}
+	got := RenderText(MultiLanguageText{"en": "Hi {{name}}", "ar": "مرحبا {{name}}"}, fields, data)
+	if want := (MultiLanguageText{"en": "Hi Sara", "ar": "مرحبا Sara"}); !reflect.DeepEqual(got, want) {
+		t.Fatalf("RenderText = %v, want %v", got, want)
+	}
+	if RenderText(nil, fields, data) != nil {
+		t.Fatal("RenderText(nil) is not nil")
+	}
+}
+
+func TestPipedRefs(t *testing.T) {
+	text := MultiLanguageText{"en": "{{a}} {{b|x}}", "ar": "{{b}} {{c}}"}
+	// Locales are read in a fixed order, so refs come out the same every time
+	if got, want := PipedRefs(text), []string{"b", "c", "a"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("PipedRefs = %v, want %v", got, want)
+	}
+}
+
+func TestValidatePiping(t *testing.T) {
+	text := func(s string) MultiLanguageText { return MultiLanguageText{"en": s} }
+	field := func(id, typ, label, page string) Field {
+		return Field{ID: id, Type: typ, Label: text(label), Page: page}
+	}
+	pages := []Page{{ID: "p1", Title: text("One")}, {ID: "p2", Title: text("About {{name}}"), ShuffleFields: true}, {ID: "p3", Title: text("Three")}}
+	tests := []struct {
+		name     string
+		def      Definition
+		wantErrs []string
+	}{
+		{
+			name: "earlier answers",
+			def: Definition{Fields: []Field{
+				field("name", "text", "Name", ""),
+				field("why", "text", "Why, {{name}}?", ""),
+			}},
+		},
+		{
+			name: "forward reference",
+			def: Definition{Fields: []Field{
+				field("why", "text", "Why, {{name}}?", ""),
+				field("name", "text", "Name", ""),
+			}},
+			wantErrs: []string{`fields[0].label: refers to "name", which is not asked before it`},
+		},
+		{
+			name:     "own answer",
+			def:      Definition{Fields: []Field{field("name", "text", "{{name}}", "")}},
+			wantErrs: []string{"fields[0].label: refers to its own answer"},
+		},
+		{
+			name:     "unknown field",
+			def:      Definition{Fields: []Field{field("a", "text", "{{nope}}", "")}},
+			wantErrs: []string{`fields[0].label: refers to unknown field "nope"`},
+		},
+		{
+			name: "title shows no answers",
+			def: Definition{Title: text("Hi {{name}}"), Fields: []Field{
+				field("name", "text", "Name", ""),
+			}},
+			wantErrs: []string{`title: refers to "name", which is not asked before it`},
+		},
+		{
+			name: "options and placeholders are checked too",
+			def: Definition{Fields: []Field{
+				{ID: "pick", Type: "radio", Label: text("Pick"), Placeholder: text("{{later}}"), Options: []MultiLanguageText{text("{{later}}")}},
+				field("later", "text", "Later", ""),
+			}},
+			wantErrs: []string{"fields[0].placeholder", "fields[0].options[0]"},
+		},
+		{
+			name: "sensitive answers",
+			def: Definition{Fields: []Field{
+				{ID: "id", Type: "text", Label: text("Civil ID"), Sensitive: true},
+				field("ok", "text", "Is {{id}} right?", ""),
+			}},
+			wantErrs: []string{`fields[1].label: refers to "id", which is sensitive and can't be shown`},
+		},
+		{
+			name: "calculated answers only after submit",
+			def: Definition{Fields: []Field{
+				field("n", "number", "N", ""),
+				calculated("double", "n * 2"),
+				field("show", "text", "Twice is {{double}}", ""),
+			}},
+			wantErrs: []string{`fields[2].label: refers to "double", which is only calculated on submit`},
+		},
+		{
+			name: "later pages see earlier pages",
+			def: Definition{Pages: pages, Fields: []Field{
+				field("name", "text", "Name", "p1"),
+				field("a", "text", "{{name}}", "p2"),
+				field("b", "text", "B", "p2"),
+				field("c", "text", "{{a}} {{b}}", "p3"),
+			}},
+		},
+		{
+			name: "fields on a shuffled page can't show each other",
+			def: Definition{Pages: pages, Fields: []Field{
+				field("name", "text", "Name", "p1"),
+				field("a", "text", "A", "p2"),
+				field("b", "text", "After {{a}}", "p2"),
+			}},
+			wantErrs: []string{`fields[2].label: refers to "a", which is not asked before it`},
+		},
+		{
+			name: "page titles see earlier pages only",
+			def: Definition{Pages: []Page{{ID: "p1", Title: text("{{name}}")}, {ID: "p2", Title: text("Two")}}, Fields: []Field{
+				field("name", "text", "Name", "p1"),
+			}},
+			wantErrs: []string{`pages[0].title: refers to "name", which is not asked before it`},
+		},
+		{
+			name: "messages see every answer and the score",
+			def: Definition{Fields: []Field{
+				field("n", "number", "N", ""),
+				calculated("double", "n * 2"),
+			}, Messages: []Message{{Path: "settings.receipt.body", Text: text("{{n}} twice is {{double}}; score {{score}}/{{maxScore}}")}}},
+		},
+		{
+			name: "messages can't show sensitive or unknown answers",
+			def: Definition{Fields: []Field{
+				{ID: "id", Type: "text", Label: text("Civil ID"), Sensitive: true},
+			}, Messages: []Message{{Path: "settings.receipt.subject", Text: text("{{id}} {{nope}}")}}},
+			wantErrs: []string{
+				`settings.receipt.subject: refers to "id", which is sensitive`,
+				`settings.receipt.subject: refers to unknown field "nope"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.def.Title == nil {
+				tt.def.Title = text("Form")
+			}
+			errs := Validate(tt.def, Options{})
+			if len(tt.wantErrs) == 0 {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(errs.Error(), want) {
+					t.Fatalf("errors %q lack %q", errs.Error(), want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package formdef
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Quiz makes a form a scored quiz. Each field with a Question adds its points to the
+// score when answered correctly, and the band with the highest Min the score reaches
+// gives the message shown with the result.
+type Quiz struct {
+	Bands []ScoreBand `json:"bands,omitempty"`
+}
+
+// ScoreBand is the result message for scores of at least Min
+type ScoreBand struct {
+	Min     float64           `json:"min"`
+	Message MultiLanguageText `json:"message"`
+}
+
+// Question is the answer key of one quiz question. Choice questions list the correct
+// options (all of them, for a multiple choice); other questions list every accepted
+// answer, compared as numbers or case-insensitively as text.
+type Question struct {
+	Correct []string `json:"correct,omitempty"`
+	Points  float64  `json:"points"`
+}
+
+// QuizResult is the score of one response
+type QuizResult struct {
+	Score    float64           `json:"score"`
+	MaxScore float64           `json:"maxScore"`
+	Message  MultiLanguageText `json:"message,omitempty"`
+}
+
+// questionTypes are the field types that can be quiz questions
+var questionTypes = []string{"select", "radio", "checkbox", "text", "number", "rating", "scale", "nps"}
+
+// quiz checks the quiz settings and the answer key of every question
+func (v validator) quiz(quiz *Quiz, fields []Field) {
+	questions := 0
+	for i, field := range fields {
+		if field.Quiz == nil {
+			continue
+		}
+		questions++
+		path := fmt.Sprintf("fields[%d].quiz", i)
+		if quiz == nil {
+			v.errs.Add(path, "the form is not a quiz")
+			continue
+		}
+		if !contains(questionTypes, field.Type) {
+			v.errs.Add(path, "%s fields cannot be quiz questions", field.Type)
+			continue
+		}
+		if field.Quiz.Points <= 0 {
+			v.errs.Add(path+".points", "must be positive")
+		}
+		if len(field.Quiz.Correct) == 0 {
+			v.errs.Add(path+".correct", "needs at least one correct answer")
+		}
+		kind := TypeOf(field).Describe().Kind
+		for j, answer := range field.Quiz.Correct {
+			switch {
+			case kind == "choice" || kind == "multichoice":
+				if optionIndex(field, answer) < 0 {
+					v.errs.Add(fmt.Sprintf("%s.correct[%d]", path, j), "is not one of the options")
+				}
+			case field.Type != "text":
+				if _, ok := toNumber(answer); !ok {
+					v.errs.Add(fmt.Sprintf("%s.correct[%d]", path, j), "must be a number")
+				}
+			case strings.TrimSpace(answer) == "":
+				v.errs.Add(fmt.Sprintf("%s.correct[%d]", path, j), "is required")
+			}
+		}
+	}
+	if quiz == nil {
+		return
+	}
+	if questions == 0 {
+		v.errs.Add("quiz", "a quiz needs at least one question")
+	}
+	for i, band := range quiz.Bands {
+		path := fmt.Sprintf("quiz.bands[%d]", i)
+		if i > 0 && band.Min <= quiz.Bands[i-1].Min {
+			v.errs.Add(path+".min", "must be greater than bands[%d].min", i-1)
+		}
+		v.text(path+".message", band.Message, true)
+	}
+}
+
+// HasQuestions reports whether any field is a quiz question
+func HasQuestions(fields []Field) bool {
+	for _, field := range fields {
+		if field.Quiz != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Score marks a normalized response against the answer key. It returns nil when the
+// form is not a quiz. The band message shows the answers it refers to, so data must
+// not be encrypted yet.
+func Score(quiz *Quiz, fields []Field, data map[string]interface{}) *QuizResult {
+	if quiz == nil {
+		return nil
+	}
+	result := &QuizResult{}
+	for _, field := range fields {
+		if field.Quiz == nil {
+			continue
+		}
+		result.MaxScore += field.Quiz.Points
+		if value, ok := data[field.ID]; ok && !emptyAnswer(value) && correctAnswer(field, value) {
+			result.Score += field.Quiz.Points
+		}
+	}
+	answers := make(map[string]interface{}, len(data)+len(quizVariables))
+	for id, value := range data {
+		answers[id] = value
+	}
+	answers["score"], answers["maxScore"] = result.Score, result.MaxScore
+	result.Message = RenderText(quiz.Band(result.Score), fields, answers)
+	return result
+}
+
+// Band returns the message of the band score falls in, or nil below every band
+func (q *Quiz) Band(score float64) MultiLanguageText {
+	var message MultiLanguageText
+	for _, band := range q.Bands {
+		if score >= band.Min {
+			message = band.Message
+		}
+	}
+	return message
+}
+
+// correctAnswer compares one answer with the field's answer key
+func correctAnswer(field Field, value interface{}) bool {
+	switch TypeOf(field).Describe().Kind {
+	case "multichoice":
+		items, ok := value.([]interface{})
+		if !ok {
+			return false
+		}
+		picked := make(map[int]bool)
+		for _, item := range items {
+			s, _ := item.(string)
+			picked[optionIndex(field, s)] = true
+		}
+		expected := make(map[int]bool)
+		for _, answer := range field.Quiz.Correct {
+			expected[optionIndex(field, answer)] = true
+		}
+		if len(picked) != len(expected) {
+			return false
+		}
+		for i := range picked {
+			if !expected[i] {
+				return false
+			}
+		}
+		return true
+	case "choice":
+		s, _ := value.(string)
+		i := optionIndex(field, s)
+		for _, answer := range field.Quiz.Correct {
+			if i >= 0 && optionIndex(field, answer) == i {
+				return true
+			}
+		}
+		return false
+	}
+	if field.Type == "text" {
+		s, _ := value.(string)
+		for _, answer := range field.Quiz.Correct {
+			if strings.EqualFold(strings.TrimSpace(s), strings.TrimSpace(answer)) {
+				return true
+			}
+		}
+		return false
+	}
+	n, ok := toNumber(value)
+	if !ok {
+		return false
+	}
+	for _, answer := range field.Quiz.Correct {
+		if expected, ok := toNumber(answer); ok && expected == n {
+			return true
+		}
+	}
+	return false
+}
+
+// RespondentView returns a copy of fields safe to show respondents: without answer
+// keys or the expressions of calculated fields
+func RespondentView(fields []Field) []Field {
+	view := make([]Field, len(fields))
+	for i, field := range fields {
+		if field.Quiz != nil {
+			field.Quiz = &Question{Points: field.Quiz.Points}
+		}
+		field.Expression = ""
+		view[i] = field
+	}
+	return view
+}
@@ -0,0 +1,153 @@
+package formdef
+
+import (
+	"strings"
+	"testing"
+)
+
+func quizFields() []Field {
+	label := MultiLanguageText{"en": "Q"}
+	return []Field{
+		{ID: "capital", Type: "select", Label: label, Options: []MultiLanguageText{
+			{"en": "Kuwait City", "ar": "مدينة الكويت"},
+			{"en": "Jahra", "ar": "الجهراء"},
+		}, Quiz: &Question{Correct: []string{"Kuwait City"}, Points: 2}},
+		{ID: "primes", Type: "checkbox", Label: label, Options: []MultiLanguageText{
+			{"en": "2"}, {"en": "3"}, {"en": "4"},
+		}, Quiz: &Question{Correct: []string{"2", "3"}, Points: 3}},
+		{ID: "word", Type: "text", Label: label, Quiz: &Question{Correct: []string{"Hello", "Hi"}, Points: 1}},
+		{ID: "answer", Type: "number", Label: label, Quiz: &Question{Correct: []string{"42"}, Points: 4}},
+		{ID: "name", Type: "text", Label: label},
+	}
+}
+
+func TestScore(t *testing.T) {
+	quiz := &Quiz{Bands: []ScoreBand{
+		{Min: 0, Message: MultiLanguageText{"en": "{{name|You}} scored {{score}}/{{maxScore}}"}},
+		{Min: 5, Message: MultiLanguageText{"en": "Good"}},
+		{Min: 10, Message: MultiLanguageText{"en": "Perfect"}},
+	}}
+	tests := []struct {
+		name      string
+		data      map[string]interface{}
+		wantScore float64
+		wantMsg   string
+	}{
+		{"nothing answered", map[string]interface{}{}, 0, "You scored 0/10"},
+		{"all correct", map[string]interface{}{
+			"capital": "مدينة الكويت",
+			"primes":  []interface{}{"3", "2"},
+			"word":    " hello ",
+			"answer":  "42.0",
+		}, 10, "Perfect"},
+		{"exactly on a band", map[string]interface{}{
+			"capital": "Kuwait City",
+			"primes":  []interface{}{"2", "3"},
+		}, 5, "Good"},
+		{"just below a band", map[string]interface{}{"answer": 42.0, "name": "Sara"}, 4, "Sara scored 4/10"},
+		{"wrong choice", map[string]interface{}{"capital": "Jahra"}, 0, "You scored 0/10"},
+		{"too few picks", map[string]interface{}{"primes": []interface{}{"2"}}, 0, "You scored 0/10"},
+		{"too many picks", map[string]interface{}{"primes": []interface{}{"2", "3", "4"}}, 0, "You scored 0/10"},
+		{"unknown pick", map[string]interface{}{"primes": []interface{}{"2", "3", "5"}}, 0, "You scored 0/10"},
+		{"blank text", map[string]interface{}{"word": "  "}, 0, "You scored 0/10"},
+		{"number as text", map[string]interface{}{"answer": "forty-two"}, 0, "You scored 0/10"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Score(quiz, quizFields(), tt.data)
+			if result.Score != tt.wantScore || result.MaxScore != 10 {
+				t.Fatalf("score = %v/%v, want %v/10", result.Score, result.MaxScore, tt.wantScore)
+			}
+			if result.Message["en"] != tt.wantMsg {
+				t.Fatalf("message = %q, want %q", result.Message["en"], tt.wantMsg)
+			}
+		})
+	}
+	if result := Score(nil, quizFields(), map[string]interface{}{}); result != nil {
+		t.Fatalf("Score without a quiz = %+v, want nil", result)
+	}
+}
+
+func TestBand(t *testing.T) {
+	quiz := &Quiz{Bands: []ScoreBand{
+		{Min: 1, Message: MultiLanguageText{"en": "low"}},
+		{Min: 5, Message: MultiLanguageText{"en": "high"}},
+	}}
+	tests := []struct {
+		score float64
+		want  string
+	}{
+		{-1, ""},
+		{0.99, ""},
+		{1, "low"},
+		{4.99, "low"},
+		{5, "high"},
+		{500, "high"},
+	}
+	for _, tt := range tests {
+		if got := quiz.Band(tt.score)["en"]; got != tt.want {
+			t.Errorf("Band(%v) = %q, want %q", tt.score, got, tt.want)
+		}
+	}
+	if got := (&Quiz{}).Band(10); got != nil {
+		t.Errorf("Band without bands = %v, want nil", got)
+	}
+}
+
+func TestValidateQuiz(t *testing.T) {
+	fields := quizFields()
+	withQuestion := func(i int, q *Question) []Field {
+		changed := quizFields()
+		changed[i].Quiz = q
+		return changed
+	}
+	message := MultiLanguageText{"en": "Done"}
+	tests := []struct {
+		name   string
+		quiz   *Quiz
+		fields []Field
+		want   string
+	}{
+		{"valid", &Quiz{Bands: []ScoreBand{{Min: 0, Message: message}, {Min: 5, Message: message}}}, fields, ""},
+		{"questions without a quiz", nil, fields, "fields[0].quiz: the form is not a quiz"},
+		{"quiz without questions", &Quiz{}, fields[4:], "quiz: a quiz needs at least one question"},
+		{"bands out of order", &Quiz{Bands: []ScoreBand{{Min: 5, Message: message}, {Min: 5, Message: message}}}, fields, "quiz.bands[1].min: must be greater than bands[0].min"},
+		{"band without message", &Quiz{Bands: []ScoreBand{{Min: 0}}}, fields, "quiz.bands[0].message"},
+		{"no points", &Quiz{}, withQuestion(2, &Question{Correct: []string{"a"}}), "fields[2].quiz.points: must be positive"},
+		{"no correct answer", &Quiz{}, withQuestion(2, &Question{Points: 1}), "fields[2].quiz.correct: needs at least one correct answer"},
+		{"answer not an option", &Quiz{}, withQuestion(0, &Question{Correct: []string{"Salmiya"}, Points: 1}), "fields[0].quiz.correct[0]: is not one of the options"},
+		{"answer not a number", &Quiz{}, withQuestion(3, &Question{Correct: []string{"many"}, Points: 1}), "fields[3].quiz.correct[0]: must be a number"},
+		{"blank text answer", &Quiz{}, withQuestion(2, &Question{Correct: []string{" "}, Points: 1}), "fields[2].quiz.correct[0]: is required"},
+		{"type can't be a question", &Quiz{}, []Field{{ID: "d", Type: "date", Label: message, Quiz: &Question{Correct: []string{"x"}, Points: 1}}}, "fields[0].quiz: date fields cannot be quiz questions"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Validate(Definition{Title: message, Fields: tt.fields, Quiz: tt.quiz}, Options{})
+			if tt.want == "" {
+				if len(errs) > 0 {
+					t.Fatalf("unexpected errors: %v", errs)
+				}
+				return
+			}
+			if !strings.Contains(errs.Error(), tt.want) {
+				t.Fatalf("errors %q lack %q", errs.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRespondentView(t *testing.T) {
+	fields := append(quizFields(), calculated("c", "answer * 2"))
+	view := RespondentView(fields)
+	if fields[0].Quiz.Correct == nil || fields[5].Expression == "" {
+		t.Fatal("RespondentView changed the fields it was given")
+	}
+	for _, field := range view {
+		if field.Quiz != nil && (field.Quiz.Correct != nil || field.Quiz.Points == 0) {
+			t.Errorf("%s: quiz = %+v, want points only", field.ID, field.Quiz)
+		}
+		if field.Expression != "" {
+			t.Errorf("%s: expression %q left in", field.ID, field.Expression)
+		}
+	}
+}
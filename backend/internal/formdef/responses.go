@@ -0,0 +1,159 @@
+package formdef
+
+import (
+	"errors"
+	"strings"
+)
+
+// emptyAnswer reports whether an answer counts as not given
+func emptyAnswer(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		// A compound answer like a date range is empty when all its parts are
+		if len(v) == 0 {
+			return false
+		}
+		for _, part := range v {
+			if !emptyAnswer(part) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// NormalizeResponse checks the answers in data against fields and replaces them with
+// their normalized form. Answers to unknown keys are left alone. The returned errors
+// are addressed as "responseData.<fieldId>".
+func NormalizeResponse(fields []Field, data map[string]interface{}) Errors {
+	return normalizeAnswers(fields, data, "responseData")
+}
+
+// normalizeAnswers normalizes the answers to fields in data, addressing errors under base
+func normalizeAnswers(fields []Field, data map[string]interface{}, base string) Errors {
+	var errs Errors
+	for _, field := range fields {
+		path := joinPath(base, field.ID)
+		t := TypeOf(field)
+		value, ok := data[field.ID]
+		if !ok || emptyAnswer(value) {
+			if field.Required {
+				errs.Add(path, "is required")
+				continue
+			}
+			// Required parts still need answers when the field as a whole is optional
+			if p, isCompound := t.(PartsRequirer); !isCompound || !p.PartsRequired(field) {
+				continue
+			}
+			value = nil
+		}
+		normalized, err := t.Normalize(field, value)
+		var partErrs Errors
+		if errors.As(err, &partErrs) {
+			for _, e := range partErrs {
+				errs.Add(joinPath(path, e.Path), "%s", e.Message)
+			}
+			continue
+		} else if err != nil {
+			errs.Add(path, "%s", err.Error())
+			continue
+		}
+		data[field.ID] = normalized
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// joinPath appends a relative path to path
+func joinPath(path, sub string) string {
+	switch {
+	case sub == "":
+		return path
+	case path == "":
+		return sub
+	case strings.HasPrefix(sub, "["):
+		return path + sub
+	}
+	return path + "." + sub
+}
+
+// ExportHeader names the export columns for fields, labelling each with its text in
+// language (falling back to any translation, then the field ID)
+func ExportHeader(fields []Field, language string) []string {
+	var header []string
+	for _, field := range fields {
+		label := TextIn(field.Label, language)
+		if label == "" {
+			label = field.ID
+		}
+		for _, suffix := range TypeOf(field).ExportColumns(field, language) {
+			if suffix == "" {
+				header = append(header, label)
+			} else {
+				header = append(header, label+" ("+suffix+")")
+			}
+		}
+	}
+	return header
+}
+
+// ExportRow flattens one response's answers into cells matching ExportHeader
+func ExportRow(fields []Field, data map[string]interface{}) []string {
+	var row []string
+	for _, field := range fields {
+		t := TypeOf(field)
+		columns := len(t.ExportColumns(field, ""))
+		cells := make([]string, columns)
+		if value, ok := data[field.ID]; ok && !emptyAnswer(value) {
+			copy(cells, t.Export(field, value))
+		}
+		row = append(row, cells...)
+	}
+	return row
+}
+
+// FieldSummary is the analytics for one field
+type FieldSummary struct {
+	FieldID  string                 `json:"fieldId"`
+	Type     string                 `json:"type"`
+	Kind     string                 `json:"kind"`
+	Label    MultiLanguageText      `json:"label"`
+	Answered int                    `json:"answered"`
+	Stats    map[string]interface{} `json:"stats,omitempty"`
+}
+
+// Analyze summarizes the answers of every field across responses. Fields listed in
+// withhold are counted but not aggregated, e.g. sensitive fields the caller may not read.
+func Analyze(fields []Field, responses []map[string]interface{}, withhold map[string]bool) []FieldSummary {
+	summaries := make([]FieldSummary, 0, len(fields))
+	for _, field := range fields {
+		t := TypeOf(field)
+		var values []interface{}
+		for _, data := range responses {
+			if value, ok := data[field.ID]; ok && !emptyAnswer(value) {
+				values = append(values, value)
+			}
+		}
+		summary := FieldSummary{
+			FieldID:  field.ID,
+			Type:     field.Type,
+			Kind:     t.Describe().Kind,
+			Label:    field.Label,
+			Answered: len(values),
+		}
+		if !withhold[field.ID] {
+			summary.Stats = t.Aggregate(field, values)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
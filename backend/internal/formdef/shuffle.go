@@ -0,0 +1,124 @@
+package formdef
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+)
+
+// Order is the order one respondent was shown shuffled questions and options in: the
+// field IDs of each shuffled page and the option labels of each field with shuffled
+// options
+type Order struct {
+	Pages   map[string][]string `json:"pages,omitempty"`
+	Options map[string][]string `json:"options,omitempty"`
+}
+
+// shuffle checks the shuffle settings of fields
+func (v validator) shuffle(fields []Field) {
+	for i, field := range fields {
+		path := fmt.Sprintf("fields[%d]", i)
+		if field.PinLast != 0 && !field.ShuffleOptions {
+			v.errs.Add(path+".pinLast", "only applies when options are shuffled")
+		}
+		if !field.ShuffleOptions {
+			continue
+		}
+		if kind := TypeOf(field).Describe().Kind; kind != "choice" && kind != "multichoice" {
+			v.errs.Add(path+".shuffleOptions", "%s fields have no options to shuffle", field.Type)
+			continue
+		}
+		if field.PinLast < 0 || field.PinLast > len(field.Options)-2 {
+			v.errs.Add(path+".pinLast", "must leave at least two options to shuffle")
+		}
+	}
+}
+
+// Shuffled reports whether a form shows anything in a different order to each respondent
+func Shuffled(fields []Field, pages []Page) bool {
+	for _, page := range pages {
+		if page.ShuffleFields {
+			return true
+		}
+	}
+	for _, field := range fields {
+		if field.ShuffleOptions {
+			return true
+		}
+	}
+	return false
+}
+
+// Shuffle returns fields as the respondent session seed sees them: the fields of shuffled
+// pages and the options of fields with shuffled options reordered, everything else in
+// place. The same seed always gives the same order, which is also returned. Without a
+// seed, or anything to shuffle, fields are returned unchanged with a nil Order.
+func Shuffle(fields []Field, pages []Page, seed string) ([]Field, *Order) {
+	if seed == "" || !Shuffled(fields, pages) {
+		return fields, nil
+	}
+	shuffled := make([]Field, len(fields))
+	copy(shuffled, fields)
+	order := &Order{}
+	for _, page := range pages {
+		if !page.ShuffleFields {
+			continue
+		}
+		// The page's fields trade places among the slots they take in the form
+		var slots []int
+		for i, field := range shuffled {
+			if PageOf(field, pages) == page.ID {
+				slots = append(slots, i)
+			}
+		}
+		onPage := make([]Field, len(slots))
+		for j, i := range slots {
+			onPage[j] = shuffled[i]
+		}
+		seeded(seed, "page:"+page.ID).Shuffle(len(onPage), func(a, b int) {
+			onPage[a], onPage[b] = onPage[b], onPage[a]
+		})
+		ids := make([]string, len(onPage))
+		for j, i := range slots {
+			shuffled[i] = onPage[j]
+			ids[j] = onPage[j].ID
+		}
+		if order.Pages == nil {
+			order.Pages = make(map[string][]string)
+		}
+		order.Pages[page.ID] = ids
+	}
+	for i, field := range shuffled {
+		if !field.ShuffleOptions || len(field.Options) < 2 {
+			continue
+		}
+		options := make([]MultiLanguageText, len(field.Options))
+		copy(options, field.Options)
+		free := len(options) - field.PinLast
+		if free > len(options) || free < 0 {
+			free = len(options)
+		}
+		seeded(seed, "field:"+field.ID).Shuffle(free, func(a, b int) {
+			options[a], options[b] = options[b], options[a]
+		})
+		labels := make([]string, len(options))
+		for j, option := range options {
+			labels[j] = OptionLabel(option)
+		}
+		field.Options = options
+		shuffled[i] = field
+		if order.Options == nil {
+			order.Options = make(map[string][]string)
+		}
+		order.Options[field.ID] = labels
+	}
+	return shuffled, order
+}
+
+// seeded returns a random source fixed by the respondent's seed and what is shuffled,
+// so each page and field gets its own order
+func seeded(seed, scope string) *rand.Rand {
+	sum := sha256.Sum256([]byte(seed + "\x00" + scope))
+	return rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(sum[:8]))))
+}
@@ -0,0 +1,316 @@
+package formdef
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"4SaleBackendSkeleton/internal/phone"
+)
+
+// Field types for feedback surveys
+func init() {
+	Register(ratingType{})
+	Register(scaleType{})
+	Register(npsType{})
+	Register(urlType{})
+	Register(phoneType{})
+	Register(dateRangeType{})
+}
+
+// maxScalePoints bounds rating and scale fields so they still fit on a phone screen
+const maxScalePoints = 11
+
+// defaultRatingStars is used when a rating field doesn't say how many stars it has
+const defaultRatingStars = 5
+
+// ratingType is a 1–N star rating
+type ratingType struct{ basicType }
+
+func (ratingType) Name() string { return "rating" }
+
+func (ratingType) Describe() Description { return Description{Kind: "rating"} }
+
+// ratingRange returns the stars of a rating field
+func ratingRange(field Field) (int, int) {
+	if field.Scale == nil || field.Scale.Max == 0 {
+		return 1, defaultRatingStars
+	}
+	return 1, field.Scale.Max
+}
+
+func (ratingType) ValidateDefinition(field Field, c Check) {
+	if s := field.Scale; s != nil {
+		if s.Min != 0 && s.Min != 1 {
+			c.Errorf(".scale.min", "ratings start at 1")
+		}
+		if s.Max != 0 && (s.Max < 2 || s.Max > 10) {
+			c.Errorf(".scale.max", "must be between 2 and 10 stars")
+		}
+	}
+	c.Rules(field.Validation)
+}
+
+func (ratingType) Normalize(field Field, value interface{}) (interface{}, error) {
+	min, max := ratingRange(field)
+	return scalePoint(value, min, max)
+}
+
+func (ratingType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	min, max := ratingRange(field)
+	return pointStats(values, min, max)
+}
+
+// scaleType is a linear scale such as 1–5 or 0–10 with labelled ends
+type scaleType struct{ basicType }
+
+func (scaleType) Name() string { return "scale" }
+
+func (scaleType) Describe() Description { return Description{Kind: "scale"} }
+
+func (scaleType) ValidateDefinition(field Field, c Check) {
+	s := field.Scale
+	if s == nil {
+		c.Errorf(".scale", "is required")
+		return
+	}
+	if s.Min != 0 && s.Min != 1 {
+		c.Errorf(".scale.min", "must be 0 or 1")
+	}
+	if s.Max <= s.Min {
+		c.Errorf(".scale.max", "must be greater than min")
+	} else if s.Max-s.Min+1 > maxScalePoints {
+		c.Errorf(".scale.max", "a scale has at most %d points", maxScalePoints)
+	}
+	c.Text(".scale.minLabel", s.MinLabel, false)
+	c.Text(".scale.maxLabel", s.MaxLabel, false)
+	c.Rules(field.Validation)
+}
+
+func (scaleType) Normalize(field Field, value interface{}) (interface{}, error) {
+	if field.Scale == nil {
+		return nil, errors.New("field has no scale")
+	}
+	return scalePoint(value, field.Scale.Min, field.Scale.Max)
+}
+
+func (scaleType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	if field.Scale == nil {
+		return map[string]interface{}{}
+	}
+	return pointStats(values, field.Scale.Min, field.Scale.Max)
+}
+
+// npsType is a Net Promoter Score question, always scored 0–10
+type npsType struct{ basicType }
+
+func (npsType) Name() string { return "nps" }
+
+func (npsType) Describe() Description { return Description{Kind: "nps"} }
+
+func (npsType) ValidateDefinition(field Field, c Check) {
+	if s := field.Scale; s != nil {
+		if s.Min != 0 || (s.Max != 0 && s.Max != 10) {
+			c.Errorf(".scale", "NPS is always scored 0 to 10; only the labels can be set")
+		}
+		c.Text(".scale.minLabel", s.MinLabel, false)
+		c.Text(".scale.maxLabel", s.MaxLabel, false)
+	}
+	c.Rules(field.Validation)
+}
+
+func (npsType) Normalize(field Field, value interface{}) (interface{}, error) {
+	return scalePoint(value, 0, 10)
+}
+
+// Aggregate adds the NPS score: the share of promoters (9–10) minus the share of
+// detractors (0–6), from -100 to 100
+func (npsType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	stats := pointStats(values, 0, 10)
+	promoters, passives, detractors := 0, 0, 0
+	for _, value := range values {
+		n, ok := toNumber(value)
+		if !ok {
+			continue
+		}
+		switch {
+		case n >= 9:
+			promoters++
+		case n >= 7:
+			passives++
+		default:
+			detractors++
+		}
+	}
+	stats["promoters"] = promoters
+	stats["passives"] = passives
+	stats["detractors"] = detractors
+	if total := promoters + passives + detractors; total > 0 {
+		stats["score"] = math.Round(float64(promoters-detractors) / float64(total) * 100)
+	}
+	return stats
+}
+
+// scalePoint accepts a whole number between min and max
+func scalePoint(value interface{}, min, max int) (interface{}, error) {
+	n, ok := toNumber(value)
+	if !ok || n != math.Trunc(n) || n < float64(min) || n > float64(max) {
+		return nil, fmt.Errorf("must be a whole number from %d to %d", min, max)
+	}
+	return n, nil
+}
+
+// pointStats summarizes scale answers with their range, mean and how often each point
+// was picked
+func pointStats(values []interface{}, min, max int) map[string]interface{} {
+	stats := numberStats(values)
+	distribution := make(map[string]int, max-min+1)
+	for point := min; point <= max; point++ {
+		distribution[strconv.Itoa(point)] = 0
+	}
+	for _, value := range values {
+		if n, ok := toNumber(value); ok {
+			distribution[strconv.FormatFloat(n, 'f', -1, 64)]++
+		}
+	}
+	stats["distribution"] = distribution
+	return stats
+}
+
+// urlType is a web address; a missing scheme is taken to be https
+type urlType struct{ basicType }
+
+func (urlType) Name() string { return "url" }
+
+func (urlType) Describe() Description {
+	return Description{Kind: "url", Searchable: true, Identifying: true}
+}
+
+func (urlType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation, "pattern")
+}
+
+func (urlType) Normalize(field Field, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be a web address")
+	}
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !strings.Contains(u.Hostname(), ".") {
+		return nil, errors.New("must be a web address")
+	}
+	if err := matchPattern(field, u.String()); err != nil {
+		return nil, err
+	}
+	return u.String(), nil
+}
+
+// phoneType is a contact number other than the respondent's own, stored in E.164 form
+type phoneType struct{ basicType }
+
+func (phoneType) Name() string { return "phone" }
+
+func (phoneType) Describe() Description { return Description{Kind: "phone", Identifying: true} }
+
+func (phoneType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation)
+}
+
+func (phoneType) Normalize(field Field, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return nil, errors.New("must be a phone number")
+	}
+	number := phone.Normalize(s)
+	// E.164 allows at most 15 digits; local numbers have 8
+	if digits := len(number) - 1; digits < 8 || digits > 15 {
+		return nil, errors.New("must be a phone number")
+	}
+	return number, nil
+}
+
+// dateRangeType is a start and end date, exported as two columns
+type dateRangeType struct{ basicType }
+
+func (dateRangeType) Name() string { return "daterange" }
+
+func (dateRangeType) Describe() Description { return Description{Kind: "daterange"} }
+
+func (dateRangeType) ValidateDefinition(field Field, c Check) {
+	c.Rules(field.Validation)
+}
+
+// dateRange reads a stored or submitted {"start": ..., "end": ...} answer
+func dateRange(value interface{}) (start, end time.Time, err error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return start, end, errors.New("must have a start and end date")
+	}
+	startText, _ := m["start"].(string)
+	endText, _ := m["end"].(string)
+	if start, err = time.Parse(dateLayout, strings.TrimSpace(startText)); err != nil {
+		return start, end, errors.New("start must be a date (YYYY-MM-DD)")
+	}
+	if end, err = time.Parse(dateLayout, strings.TrimSpace(endText)); err != nil {
+		return start, end, errors.New("end must be a date (YYYY-MM-DD)")
+	}
+	return start, end, nil
+}
+
+func (dateRangeType) Normalize(field Field, value interface{}) (interface{}, error) {
+	start, end, err := dateRange(value)
+	if err != nil {
+		return nil, err
+	}
+	if end.Before(start) {
+		return nil, errors.New("end must not be before start")
+	}
+	return map[string]interface{}{"start": start.Format(dateLayout), "end": end.Format(dateLayout)}, nil
+}
+
+func (dateRangeType) ExportColumns(field Field, language string) []string {
+	return []string{"start", "end"}
+}
+
+func (dateRangeType) Export(field Field, value interface{}) []string {
+	start, end, err := dateRange(value)
+	if err != nil {
+		return []string{FormatValue(value), ""}
+	}
+	return []string{start.Format(dateLayout), end.Format(dateLayout)}
+}
+
+// Aggregate reports the span the ranges cover and their mean length in days, counting
+// both ends
+func (dateRangeType) Aggregate(field Field, values []interface{}) map[string]interface{} {
+	var starts, ends []string
+	totalDays := 0.0
+	for _, value := range values {
+		start, end, err := dateRange(value)
+		if err != nil {
+			continue
+		}
+		starts = append(starts, start.Format(dateLayout))
+		ends = append(ends, end.Format(dateLayout))
+		totalDays += end.Sub(start).Hours()/24 + 1
+	}
+	if len(starts) == 0 {
+		return map[string]interface{}{}
+	}
+	sort.Strings(starts)
+	sort.Strings(ends)
+	return map[string]interface{}{
+		"earliestStart": starts[0],
+		"latestEnd":     ends[len(ends)-1],
+		"meanDays":      totalDays / float64(len(starts)),
+	}
+}
@@ -0,0 +1,121 @@
+package jsonpatch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	doc := `{"a":1,"list":["x","y"],"settings":{"closesAt":"2026-01-01","retention":{"days":30}},"a/b":2,"m~n":3}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{"replace with null", `[{"op":"replace","path":"/settings/closesAt","value":null}]`,
+			`{"a":1,"a/b":2,"list":["x","y"],"m~n":3,"settings":{"closesAt":null,"retention":{"days":30}}}`},
+		{"add null", `[{"op":"add","path":"/settings/retention","value":null}]`,
+			`{"a":1,"a/b":2,"list":["x","y"],"m~n":3,"settings":{"closesAt":"2026-01-01","retention":null}}`},
+		{"test null then clear", `[{"op":"replace","path":"/a","value":null},{"op":"test","path":"/a","value":null},{"op":"remove","path":"/a"}]`,
+			`{"a/b":2,"list":["x","y"],"m~n":3,"settings":{"closesAt":"2026-01-01","retention":{"days":30}}}`},
+		{"append with -", `[{"op":"add","path":"/list/-","value":"z"}]`,
+			`{"a":1,"a/b":2,"list":["x","y","z"],"m~n":3,"settings":{"closesAt":"2026-01-01","retention":{"days":30}}}`},
+		{"insert before an index", `[{"op":"add","path":"/list/0","value":"w"}]`,
+			`{"a":1,"a/b":2,"list":["w","x","y"],"m~n":3,"settings":{"closesAt":"2026-01-01","retention":{"days":30}}}`},
+		{"~1 escapes a slash", `[{"op":"replace","path":"/a~1b","value":20}]`,
+			`{"a":1,"a/b":20,"list":["x","y"],"m~n":3,"settings":{"closesAt":"2026-01-01","retention":{"days":30}}}`},
+		{"~0 escapes a tilde", `[{"op":"remove","path":"/m~0n"}]`,
+			`{"a":1,"a/b":2,"list":["x","y"],"settings":{"closesAt":"2026-01-01","retention":{"days":30}}}`},
+		{"move to a sibling", `[{"op":"move","from":"/settings/retention","path":"/retention"}]`,
+			`{"a":1,"a/b":2,"list":["x","y"],"m~n":3,"retention":{"days":30},"settings":{"closesAt":"2026-01-01"}}`},
+		{"move onto itself", `[{"op":"move","from":"/a","path":"/a"}]`,
+			`{"a":1,"a/b":2,"list":["x","y"],"m~n":3,"settings":{"closesAt":"2026-01-01","retention":{"days":30}}}`},
+		{"copies don't share nested values", `[{"op":"copy","from":"/settings/retention","path":"/r"},{"op":"replace","path":"/r/days","value":7}]`,
+			`{"a":1,"a/b":2,"list":["x","y"],"m~n":3,"r":{"days":7},"settings":{"closesAt":"2026-01-01","retention":{"days":30}}}`},
+		{"test compares numbers by value", `[{"op":"test","path":"/a","value":1.0}]`,
+			`{"a":1,"a/b":2,"list":["x","y"],"m~n":3,"settings":{"closesAt":"2026-01-01","retention":{"days":30}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(doc), []byte(tt.patch))
+			if err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Apply = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	doc := `{"a":{"b":1},"list":["x"]}`
+	tests := []struct {
+		name    string
+		patch   string
+		index   int
+		wantErr string
+	}{
+		{"missing value", `[{"op":"replace","path":"/a"}]`, 0, "missing value"},
+		{"move into itself", `[{"op":"move","from":"/a","path":"/a/c"}]`, 0, "cannot move a value into itself"},
+		{"- only appends", `[{"op":"replace","path":"/list/-","value":1}]`, 0, `invalid array index "-"`},
+		{"index past the end", `[{"op":"add","path":"/list/2","value":1}]`, 0, "out of range"},
+		{"leading zero", `[{"op":"remove","path":"/list/00"}]`, 0, "invalid array index"},
+		{"replace a missing member", `[{"op":"replace","path":"/nope","value":1}]`, 0, "does not exist"},
+		{"unknown op", `[{"op":"merge","path":"/a","value":1}]`, 0, `unknown op "merge"`},
+		{"failed test", `[{"op":"add","path":"/c","value":1},{"op":"test","path":"/a/b","value":null}]`, 1, "test operation failed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Apply([]byte(doc), []byte(tt.patch))
+			if err == nil {
+				t.Fatalf("Apply = %s, want an error", got)
+			}
+			var opErr *OperationError
+			if !errors.As(err, &opErr) || opErr.Index != tt.index {
+				t.Fatalf("error %v is not from operation %d", err, tt.index)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error %q lacks %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A failing operation throws away the ones before it
+func TestApplyIsAtomic(t *testing.T) {
+	doc := []byte(`{"a":1,"list":["x"]}`)
+	patch := `[{"op":"replace","path":"/a","value":2},{"op":"add","path":"/list/-","value":"y"},{"op":"test","path":"/a","value":1}]`
+	got, err := Apply(doc, []byte(patch))
+	if !errors.Is(err, ErrTestFailed) {
+		t.Fatalf("Apply = %s, %v; want ErrTestFailed", got, err)
+	}
+	if got != nil {
+		t.Fatalf("Apply returned %s alongside its error", got)
+	}
+	if string(doc) != `{"a":1,"list":["x"]}` {
+		t.Fatalf("doc changed to %s", doc)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, want string
+	}{
+		{`{"a":1,"b":{"c":2,"d":3}}`, `{"b":{"c":null,"e":4}}`, `{"a":1,"b":{"d":3,"e":4}}`},
+		{`{"a":1}`, `{"a":null}`, `{}`},
+		{`{"a":[1,2]}`, `{"a":[3]}`, `{"a":[3]}`},
+		{`{"a":"x"}`, `{"a":{"b":1}}`, `{"a":{"b":1}}`},
+		{`{"a":1}`, `["x"]`, `["x"]`},
+	}
+	for _, tt := range tests {
+		got, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+		if err != nil {
+			t.Fatalf("MergePatch(%s, %s): %v", tt.doc, tt.patch, err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("MergePatch(%s, %s) = %s, want %s", tt.doc, tt.patch, got, tt.want)
+		}
+	}
+}
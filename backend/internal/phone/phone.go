@@ -0,0 +1,43 @@
+// Package phone normalizes respondent phone numbers so the same number typed in
+// different ways (spaces, dashes, Arabic-Indic digits, 00 or + prefixes) compares equal.
+package phone
+
+import "strings"
+
+// DefaultCountryCode is assumed for local numbers entered without a country prefix
+const DefaultCountryCode = "965"
+
+// localNumberLength is the length of a Kuwaiti subscriber number without country code
+const localNumberLength = 8
+
+// Normalize returns the number in E.164 form (e.g. "+96590000000"), or "" if it
+// contains no digits. Eastern Arabic (٠-٩) and Persian (۰-۹) digits are accepted.
+func Normalize(raw string) string {
+	var digits strings.Builder
+	plus := false
+	for _, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r >= '٠' && r <= '٩':
+			digits.WriteRune('0' + (r - '٠'))
+		case r >= '۰' && r <= '۹':
+			digits.WriteRune('0' + (r - '۰'))
+		case r == '+' && digits.Len() == 0:
+			plus = true
+		}
+	}
+
+	number := digits.String()
+	if number == "" {
+		return ""
+	}
+	switch {
+	case plus:
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case len(number) == localNumberLength:
+		number = DefaultCountryCode + number
+	}
+	return "+" + number
+}
@@ -0,0 +1,90 @@
+// Package slug turns form titles into URL-safe slugs. Arabic titles are transliterated
+// to Latin letters so links stay readable when shared.
+package slug
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MaxLength bounds generated slugs, leaving room for a uniqueness suffix
+const MaxLength = 80
+
+// arabicLatin is a simplified transliteration of Arabic letters. Short vowels
+// (tashkeel) are not written, so words come out in their consonantal form.
+var arabicLatin = map[rune]string{
+	'ا': "a", 'أ': "a", 'إ': "i", 'آ': "aa", 'ٱ': "a",
+	'ب': "b", 'ت': "t", 'ث': "th", 'ج': "j", 'ح': "h", 'خ': "kh",
+	'د': "d", 'ذ': "dh", 'ر': "r", 'ز': "z", 'س': "s", 'ش': "sh",
+	'ص': "s", 'ض': "d", 'ط': "t", 'ظ': "z", 'ع': "a", 'غ': "gh",
+	'ف': "f", 'ق': "q", 'ك': "k", 'ل': "l", 'م': "m", 'ن': "n",
+	'ه': "h", 'ة': "a", 'و': "w", 'ؤ': "w", 'ي': "y", 'ى': "a", 'ئ': "y",
+	'پ': "p", 'چ': "ch", 'گ': "g", 'ڤ': "v", 'ء': "",
+}
+
+// latinFolds strips accents from common Latin letters
+var latinFolds = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ä': "a", 'ã': "a", 'å': "a",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'ö': "o", 'õ': "o",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'ÿ': "y", 'ß': "ss",
+}
+
+// Make returns the slug for s: lowercase ASCII letters and digits separated by single
+// hyphens. It returns "" when nothing in s can be represented.
+func Make(s string) string {
+	var b strings.Builder
+	hyphen := false
+	write := func(part string) {
+		if part == "" {
+			return
+		}
+		if hyphen && b.Len() > 0 {
+			b.WriteByte('-')
+		}
+		hyphen = false
+		b.WriteString(part)
+	}
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z' || r >= '0' && r <= '9':
+			write(string(r))
+		case r >= '٠' && r <= '٩':
+			write(string('0' + r - '٠'))
+		case r >= '۰' && r <= '۹':
+			write(string('0' + r - '۰'))
+		case arabicLatin[r] != "" || r == 'ء':
+			write(arabicLatin[r])
+		case latinFolds[r] != "":
+			write(latinFolds[r])
+		case unicode.Is(unicode.Mn, r) || r == 'ـ':
+			// Tashkeel and tatweel do not separate words
+		default:
+			hyphen = true
+		}
+	}
+	out := b.String()
+	if len(out) > MaxLength {
+		out = strings.TrimRight(out[:MaxLength], "-")
+	}
+	return out
+}
+
+// Valid reports whether s is a well-formed slug. All-digit slugs are rejected so they
+// can never be mistaken for a numeric form ID.
+func Valid(s string) bool {
+	if s == "" || len(s) > MaxLength+10 || s[0] == '-' || s[len(s)-1] == '-' || strings.Contains(s, "--") {
+		return false
+	}
+	digits := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return false
+		}
+		if r < '0' || r > '9' {
+			digits = false
+		}
+	}
+	return !digits
+}
@@ -18,7 +18,8 @@ export const forms = mysqlTable('forms', {
 export const formResponses = mysqlTable('form_responses', {
   id: int('id').primaryKey().autoincrement(),
   formId: int('form_id').notNull().references(() => forms.id),
-  phoneNumber: text('phone_number').notNull(), // Required field for all forms
+  phoneNumber: text('phone_number'), // Set when the form's identity mode asks for it
+  email: text('email'), // Set when the form's identity mode asks for it
   responseData: json('response_data').notNull(), // JSON object with field_name: value pairs
   submittedAt: timestamp('submitted_at').defaultNow(),
 });
@@ -2,7 +2,7 @@ import React, { useState, useEffect } from 'react';
 import { Link, useNavigate } from 'react-router-dom';
 import { Button } from '../presentation/components/ui/core/Button';
 import { apiService } from '../services/api';
-import { Form, MultiLanguageText } from '../types/form';
+import { Form, MultiLanguageText, publicFormPath } from '../types/form';
 
 export const Dashboard: React.FC = () => {
   const navigate = useNavigate();
@@ -10,6 +10,8 @@ export const Dashboard: React.FC = () => {
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
   const [deletingFormId, setDeletingFormId] = useState<number | null>(null);
+  // Submissions received live since the dashboard was opened, per form
+  const [newResponses, setNewResponses] = useState<Record<number, number>>({});
   
   // Pagination state
   const [currentPage] = useState(1);
@@ -21,6 +23,12 @@ export const Dashboard: React.FC = () => {
     loadForms();
   }, [currentPage]);
 
+  useEffect(() => {
+    return apiService.subscribeToResponses((response) => {
+      setNewResponses(prev => ({ ...prev, [response.formId]: (prev[response.formId] || 0) + 1 }));
+    });
+  }, []);
+
   const loadForms = async () => {
     try {
       setLoading(true);
@@ -65,12 +73,12 @@ export const Dashboard: React.FC = () => {
     }
   };
 
-  const copyFormLink = async (formId: number) => {
-    const url = `${window.location.origin}/form/${formId}`;
+  const copyFormLink = async (form: Form) => {
+    const url = `${window.location.origin}${publicFormPath(form)}`;
     try {
       await navigator.clipboard.writeText(url);
       // Simple feedback without external toast library
-      const button = document.getElementById(`copy-btn-${formId}`);
+      const button = document.getElementById(`copy-btn-${form.id}`);
       if (button) {
         const originalText = button.textContent;
         button.textContent = 'Copied!';
@@ -203,7 +211,7 @@ export const Dashboard: React.FC = () => {
                     <Button
                       variant="outline"
                       size="sm"
-                      onClick={() => copyFormLink(form.id)}
+                      onClick={() => copyFormLink(form)}
                       id={`copy-btn-${form.id}`}
                       className="border-gray-300 text-gray-700 text-xs"
                     >
@@ -216,7 +224,7 @@ export const Dashboard: React.FC = () => {
                     <Button
                       variant="outline"
                       size="sm"
-                      onClick={() => window.open(`/form/${form.id}`, '_blank')}
+                      onClick={() => window.open(publicFormPath(form), '_blank')}
                       className="border-gray-300 text-gray-700 text-xs"
                     >
                       <svg className="w-3 h-3 mr-1" fill="currentColor" viewBox="0 0 24 24">
@@ -263,6 +271,11 @@ export const Dashboard: React.FC = () => {
                         <path d="M21 6h-2l-1-2H6L5 6H3c-.55 0-1 .45-1 1s.45 1 1 1h1v11c0 1.1.9 2 2 2h12c1.1 0 2-.9 2-2V8h1c.55 0 1-.45 1-1s-.45-1-1-1z"/>
                       </svg>
                       View Responses
+                      {newResponses[form.id] > 0 && (
+                        <span className="ml-2 px-1.5 py-0.5 rounded-full bg-blue-600 text-white text-[10px]">
+                          +{newResponses[form.id]}
+                        </span>
+                      )}
                     </Button>
                   </div>
                 </div>
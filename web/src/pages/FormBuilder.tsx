@@ -1,10 +1,14 @@
 import React, { useState, useEffect } from 'react';
 import { useNavigate, useParams } from 'react-router-dom';
 import { Button } from '../presentation/components/ui/core/Button';
-import { apiService } from '../services/api';
-import { FormField, FieldType, FormDefinition, MultiLanguageText } from '../types/form';
+import { ApiError, apiService } from '../services/api';
+import { FormField, FieldScale, FieldType, FormDefinition, FormPage, FormSettings, IdentityMode, MultiLanguageText, OptionSeats, Quiz, publicFormPath } from '../types/form';
 import { DualLanguageField } from '../presentation/components/DualLanguageField';
 import { MultiLanguageOptions } from '../presentation/components/MultiLanguageOptions';
+import { MatrixRowsEditor } from '../presentation/components/MatrixRowsEditor';
+import { GroupFieldsEditor } from '../presentation/components/GroupFieldsEditor';
+import { PagesEditor } from '../presentation/components/PagesEditor';
+import { ScoreBandsEditor } from '../presentation/components/ScoreBandsEditor';
 
 const FIELD_TYPES: { value: FieldType; label: string; description: string }[] = [
   { value: 'text', label: 'Text', description: 'Single line text input' },
@@ -17,9 +21,34 @@ const FIELD_TYPES: { value: FieldType; label: string; description: string }[] =
   { value: 'select', label: 'Select', description: 'Dropdown selection' },
   { value: 'radio', label: 'Radio', description: 'Single choice from options' },
   { value: 'checkbox', label: 'Checkbox', description: 'Multiple choices' },
-  { value: 'file', label: 'File', description: 'File upload' }
+  { value: 'file', label: 'File', description: 'File upload' },
+  { value: 'rating', label: 'Rating', description: 'Star rating' },
+  { value: 'scale', label: 'Linear Scale', description: 'Pick a point on a labelled scale' },
+  { value: 'nps', label: 'NPS', description: 'Net Promoter Score (0-10)' },
+  { value: 'url', label: 'URL', description: 'Web address' },
+  { value: 'phone', label: 'Phone', description: 'Contact phone number' },
+  { value: 'daterange', label: 'Date Range', description: 'Start and end dates' },
+  { value: 'matrix', label: 'Matrix', description: 'Rate several rows on the same columns' },
+  { value: 'group', label: 'Repeatable Group', description: 'Fields filled in once per entry' },
+  { value: 'hidden', label: 'Hidden', description: 'Filled from the link, e.g. a campaign code' },
+  { value: 'calculated', label: 'Calculated', description: 'Worked out from other answers on submit' }
 ];
 
+// Types that can be scored quiz questions; choices are marked by option, the rest by typed answers
+const QUESTION_TYPES: FieldType[] = ['select', 'radio', 'checkbox', 'text', 'number', 'rating', 'scale', 'nps'];
+
+// Types a group's child fields can use: no nesting, nothing needing extra settings
+const GROUP_CHILD_TYPES = FIELD_TYPES.filter((t) =>
+  ['text', 'textarea', 'email', 'number', 'date', 'time', 'select', 'radio', 'checkbox', 'url', 'phone'].includes(t.value)
+);
+
+// Starting range for new rating, scale and NPS fields
+const DEFAULT_SCALES: Partial<Record<FieldType, FieldScale>> = {
+  rating: { min: 1, max: 5 },
+  scale: { min: 1, max: 5, minLabel: { en: '', ar: '' }, maxLabel: { en: '', ar: '' } },
+  nps: { min: 0, max: 10, minLabel: { en: 'Not likely', ar: 'غير محتمل' }, maxLabel: { en: 'Very likely', ar: 'محتمل جداً' } },
+};
+
 // Helper to ensure MultiLanguageText
 const toMultiLanguage = (val: string | MultiLanguageText | undefined): MultiLanguageText => {
   if (!val) return { en: '', ar: '' };
@@ -75,8 +104,17 @@ export const FormBuilder: React.FC = () => {
   const [formDescription, setFormDescription] = useState<MultiLanguageText>({ en: '', ar: '' });
   const [submitButtonText, setSubmitButtonText] = useState<MultiLanguageText>({ en: '', ar: '' });
   const [heroImageUrl, setHeroImageUrl] = useState('');
+  const [previewPath, setPreviewPath] = useState<string | null>(null);
+  const [formVersion, setFormVersion] = useState(0);
   const [isUploadingImage, setIsUploadingImage] = useState(false);
   const [fields, setFields] = useState<FormField[]>([]);
+  const [pages, setPages] = useState<FormPage[]>([]);
+  // Sent back whole on save so settings edited elsewhere, like retention, are kept
+  const [settings, setSettings] = useState<FormSettings>({});
+  // Null when the form is not a quiz
+  const [quiz, setQuiz] = useState<Quiz | null>(null);
+  // Places left in options with a capacity, as of loading the form
+  const [seats, setSeats] = useState<OptionSeats[]>([]);
   const [editingField, setEditingField] = useState<FormField | null>(null);
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [error, setError] = useState<string | null>(null);
@@ -95,6 +133,12 @@ export const FormBuilder: React.FC = () => {
       setFormDescription(parseMultiLangField(form.description));
       setSubmitButtonText(parseMultiLangField(form.submitButtonText));
       setHeroImageUrl(form.heroImageUrl || '');
+      setPreviewPath(publicFormPath(form));
+      setFormVersion(form.version);
+      setPages(form.pages || []);
+      setSettings(form.settings || {});
+      setQuiz(form.quiz || null);
+      setSeats(form.seats || []);
       // Ensure all fields use MultiLanguageText for label/placeholder/options
       setFields(form.fields.map(f => ({
         ...f,
@@ -124,9 +168,35 @@ export const FormBuilder: React.FC = () => {
       options: ['select', 'radio', 'checkbox'].includes(type)
         ? [{ en: 'Option 1', ar: 'خيار 1' }, { en: 'Option 2', ar: 'خيار 2' }]
         : [],
+      ...(DEFAULT_SCALES[type] ? { scale: DEFAULT_SCALES[type] } : {}),
+      ...(type === 'group' ? {
+        group: {
+          min: 1,
+          max: 5,
+          addLabel: { en: 'Add another', ar: 'إضافة آخر' },
+          fields: [{ id: generateFieldId(), type: 'text' as FieldType, label: { en: 'Name', ar: 'الاسم' }, placeholder: { en: '', ar: '' }, required: true, options: [] }],
+        },
+      } : {}),
+      ...(type === 'matrix' ? {
+        matrix: {
+          rows: [{ id: 'row_1', label: { en: 'Row 1', ar: 'صف 1' } }],
+          columns: [1, 2, 3, 4, 5].map((n) => ({ en: String(n), ar: String(n) })),
+        },
+      } : {}),
     };
     setFields([...fields, newField]);
     setEditingField(newField);
+    // A hidden field has no other way to get a value than the link
+    if (type === 'hidden') {
+      setPrefill(newField.id, true);
+    }
+  };
+
+  const setPrefill = (fieldId: string, allowed: boolean) => {
+    setSettings((prev) => {
+      const prefill = (prev.prefill || []).filter((id) => id !== fieldId);
+      return { ...prev, prefill: allowed ? [...prefill, fieldId] : prefill };
+    });
   };
 
   const updateField = (updatedField: FormField) => {
@@ -136,6 +206,7 @@ export const FormBuilder: React.FC = () => {
 
   const removeField = (fieldId: string) => {
     setFields(fields.filter(field => field.id !== fieldId));
+    setPrefill(fieldId, false);
     if (editingField && editingField.id === fieldId) {
       setEditingField(null);
     }
@@ -192,6 +263,22 @@ export const FormBuilder: React.FC = () => {
     }
   };
 
+  // Fields on a removed page move to the first page
+  const updatePages = (newPages: FormPage[]) => {
+    const ids = new Set(newPages.map((page) => page.id));
+    const keep = (field: FormField): FormField => (field.page && !ids.has(field.page) ? { ...field, page: undefined } : field);
+    setPages(newPages);
+    setFields(fields.map(keep));
+    if (editingField) {
+      setEditingField(keep(editingField));
+    }
+  };
+
+  const pageTitle = (field: FormField): string => {
+    const index = Math.max(0, pages.findIndex((page) => page.id === field.page));
+    return pages[index].title.en || pages[index].title.ar || `Page ${index + 1}`;
+  };
+
   const saveForm = async () => {
     if (!formTitle.en.trim() && !formTitle.ar.trim()) {
       setError('Form title is required in at least one language');
@@ -214,23 +301,58 @@ export const FormBuilder: React.FC = () => {
           label: sanitizeMultiLangField(field.label),
           placeholder: sanitizeMultiLangField(field.placeholder),
           options: field.options ? field.options.map(sanitizeMultiLangField) : [],
+          // Answer keys are dropped along with quiz mode
+          quiz: quiz ? field.quiz : undefined,
+          capacity: optionCapacity(field),
         })),
+        pages,
+        quiz,
+        settings,
         heroImageUrl: heroImageUrl || ''
       };
       console.log('Outgoing formData (sanitized):', formData);
       if (isEditing && formId) {
-        await apiService.updateForm(parseInt(formId), formData);
+        await apiService.updateForm(parseInt(formId), formData, formVersion);
       } else {
         await apiService.createForm(formData);
       }
       navigate('/');
     } catch (err) {
+      if (err instanceof ApiError && err.status === 412) {
+        setError('Someone else saved this form while you were editing. Reload the page to get their changes, then reapply yours.');
+        return;
+      }
+      if (err instanceof ApiError && err.status === 422) {
+        try {
+          const { errors } = JSON.parse(err.body) as { errors: { path: string; message: string }[] };
+          setError(errors.map((e) => `${e.path}: ${e.message}`).join('; '));
+          return;
+        } catch {
+          // Fall through to the generic message
+        }
+      }
       setError(`Failed to ${isEditing ? 'update' : 'create'} form. Please try again.`);
     } finally {
       setIsSubmitting(false);
     }
   };
 
+  // Capacities of the options the field still has; an edited option's old text is dropped
+  const optionCapacity = (field: FormField): Record<string, number> | undefined => {
+    if (!field.capacity || !['select', 'radio'].includes(field.type)) return undefined;
+    const texts = new Set((field.options || []).flatMap((o) => Object.values(toMultiLanguage(o))).filter(Boolean));
+    const kept = Object.entries(field.capacity).filter(([option, n]) => texts.has(option) && n > 0);
+    return kept.length > 0 ? Object.fromEntries(kept) : undefined;
+  };
+
+  const setCapacity = (field: FormField, option: string, value: string) => {
+    const capacity = { ...(field.capacity || {}) };
+    const n = parseInt(value, 10);
+    if (n > 0) capacity[option] = n;
+    else delete capacity[option];
+    setEditingField({ ...field, capacity });
+  };
+
   const renderFieldEditor = (field: FormField) => (
     <div className="bg-gray-50 rounded-lg p-6 border border-gray-200">
       <div className="grid grid-cols-1 md:grid-cols-2 gap-4">
@@ -241,6 +363,9 @@ export const FormBuilder: React.FC = () => {
             onChange={(val) => setEditingField({ ...field, label: toMultiLanguage(val) })}
             required
           />
+          <p className="text-xs text-gray-500 mt-1">
+            Show an earlier answer with {'{{fieldId}}'}, or {'{{fieldId|fallback}}'} while it is unanswered.
+          </p>
         </div>
         <div>
           <DualLanguageField
@@ -251,6 +376,113 @@ export const FormBuilder: React.FC = () => {
         </div>
       </div>
 
+      {pages.length > 0 && (
+        <div className="mt-4">
+          <label className="block text-sm font-semibold text-gray-900 mb-2">Page</label>
+          <select
+            value={field.page || pages[0].id}
+            onChange={(e) => setEditingField({ ...field, page: e.target.value })}
+            className="w-full px-3 py-2 border border-gray-300 rounded-md"
+          >
+            {pages.map((page, idx) => (
+              <option key={page.id} value={page.id}>{page.title.en || page.title.ar || `Page ${idx + 1}`}</option>
+            ))}
+          </select>
+        </div>
+      )}
+
+      {field.type === 'calculated' && (
+        <div className="mt-4">
+          <label className="block text-sm font-semibold text-gray-900 mb-2">Formula</label>
+          <textarea
+            value={field.expression || ''}
+            onChange={(e) => setEditingField({ ...field, expression: e.target.value })}
+            rows={3}
+            className="w-full px-3 py-2 border border-gray-300 rounded-md font-mono text-sm"
+            placeholder={'price * quantity\nage >= 18 ? "eligible" : "not eligible"\nscore(colour, {"Red": 1, "Blue": 2})'}
+          />
+          <p className="text-xs text-gray-500 mt-1">
+            Refer to other fields by ID: {fields.filter((f) => f.id !== field.id).map((f) => f.id).join(', ') || 'none yet'}.
+            Functions: if, min, max, sum, count, round, floor, ceil, abs, contains, answered, score, lookup, number, text.
+          </p>
+        </div>
+      )}
+
+      {quiz && QUESTION_TYPES.includes(field.type) && (
+        <div className="mt-4 space-y-3">
+          <label className="flex items-center text-sm font-medium text-gray-700">
+            <input
+              type="checkbox"
+              checked={!!field.quiz}
+              onChange={(e) => setEditingField({ ...field, quiz: e.target.checked ? { correct: [], points: 1 } : undefined })}
+              className="h-4 w-4 mr-3 text-blue-600 border-gray-300 rounded"
+            />
+            Scored quiz question
+          </label>
+          {field.quiz && (
+            <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
+              <label className="text-sm font-medium text-gray-700">
+                Points
+                <input
+                  type="number"
+                  min={0}
+                  step="any"
+                  value={field.quiz.points}
+                  onChange={(e) => setEditingField({ ...field, quiz: { ...field.quiz!, points: parseFloat(e.target.value) || 0 } })}
+                  className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md"
+                />
+              </label>
+              <div className="md:col-span-2 text-sm font-medium text-gray-700">
+                Correct {['select', 'radio', 'checkbox'].includes(field.type) ? 'options' : 'answers (comma separated)'}
+                {['select', 'radio', 'checkbox'].includes(field.type) ? (
+                  field.options.map((option, idx) => {
+                    const value = option.en || option.ar;
+                    const correct = field.quiz!.correct || [];
+                    return (
+                      <label key={idx} className="flex items-center mt-1 font-normal">
+                        <input
+                          type="checkbox"
+                          checked={correct.includes(value)}
+                          onChange={(e) => setEditingField({
+                            ...field,
+                            quiz: { ...field.quiz!, correct: e.target.checked ? [...correct, value] : correct.filter((c) => c !== value) },
+                          })}
+                          className="h-4 w-4 mr-2 text-blue-600 border-gray-300 rounded"
+                        />
+                        {value}
+                      </label>
+                    );
+                  })
+                ) : (
+                  <input
+                    type="text"
+                    value={(field.quiz.correct || []).join(', ')}
+                    onChange={(e) => setEditingField({
+                      ...field,
+                      quiz: { ...field.quiz!, correct: e.target.value.split(',').map((c) => c.trim()).filter(Boolean) },
+                    })}
+                    className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md"
+                  />
+                )}
+              </div>
+            </div>
+          )}
+        </div>
+      )}
+
+      {field.type !== 'group' && field.type !== 'file' && field.type !== 'calculated' && (
+        <label className="mt-4 flex items-center text-sm font-medium text-gray-700">
+          <input
+            type="checkbox"
+            checked={field.type === 'hidden' || !!settings.prefill?.includes(field.id)}
+            disabled={field.type === 'hidden'}
+            onChange={(e) => setPrefill(field.id, e.target.checked)}
+            className="h-4 w-4 mr-3 text-blue-600 border-gray-300 rounded"
+          />
+          Can be filled from the link with <code className="ml-1">?{field.id}=…</code>
+        </label>
+      )}
+
       {(['select', 'radio', 'checkbox'].includes(field.type)) && (
         <div className="mt-4">
           <MultiLanguageOptions
@@ -261,18 +493,177 @@ export const FormBuilder: React.FC = () => {
         </div>
       )}
 
-      <div className="mt-4 flex items-center">
-        <input
-          type="checkbox"
-          id={`required-${field.id}`}
-          checked={field.required}
-          onChange={(e) => setEditingField({ ...field, required: e.target.checked })}
-          className="h-4 w-4 text-blue-600 focus:ring-blue-500 border-gray-300 rounded"
-        />
-        <label htmlFor={`required-${field.id}`} className="ml-3 text-sm font-medium text-gray-700">
-          Required field
-        </label>
-      </div>
+      {(['select', 'radio', 'checkbox'].includes(field.type)) && (
+        <div className="mt-4 flex items-center gap-6 text-sm text-gray-700">
+          <label className="flex items-center">
+            <input
+              type="checkbox"
+              checked={!!field.shuffleOptions}
+              onChange={(e) => setEditingField({ ...field, shuffleOptions: e.target.checked, pinLast: e.target.checked ? field.pinLast : undefined })}
+              className="h-4 w-4 mr-2 text-blue-600 border-gray-300 rounded"
+            />
+            Shuffle options for each respondent
+          </label>
+          {field.shuffleOptions && (
+            <label className="flex items-center">
+              Keep the last
+              <input
+                type="number"
+                min={0}
+                value={field.pinLast || 0}
+                onChange={(e) => setEditingField({ ...field, pinLast: parseInt(e.target.value, 10) || undefined })}
+                className="w-16 mx-2 px-2 py-1 border border-gray-300 rounded-md"
+              />
+              in place (e.g. "Other")
+            </label>
+          )}
+        </div>
+      )}
+
+      {(['select', 'radio'].includes(field.type)) && field.options.length > 0 && (
+        <div className="mt-4">
+          <label className="block text-sm font-semibold text-gray-900 mb-1">Places per option</label>
+          <p className="text-xs text-gray-500 mb-2">Leave blank for no limit. Full options can't be picked.</p>
+          {field.options.map((option, idx) => {
+            const value = option.en || option.ar;
+            if (!value) return null;
+            const seat = seats.find((s) => s.fieldId === field.id && s.option === value);
+            return (
+              <div key={idx} className="flex items-center gap-3 mt-1 text-sm text-gray-700">
+                <span className="flex-1">{value}</span>
+                <input
+                  type="number"
+                  min={1}
+                  value={field.capacity?.[value] ?? ''}
+                  onChange={(e) => setCapacity(field, value, e.target.value)}
+                  className="w-24 px-3 py-1 border border-gray-300 rounded-md"
+                />
+                {seat && <span className="w-28 text-gray-500">{seat.remaining} left</span>}
+              </div>
+            );
+          })}
+        </div>
+      )}
+
+      {field.type === 'group' && field.group && (
+        <div className="mt-4 space-y-4">
+          <div className="grid grid-cols-2 gap-4">
+            <label className="text-sm font-medium text-gray-700">
+              Minimum entries
+              <input
+                type="number"
+                min={0}
+                value={field.group.min}
+                onChange={(e) => setEditingField({ ...field, group: { ...field.group!, min: parseInt(e.target.value) || 0 } })}
+                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md"
+              />
+            </label>
+            <label className="text-sm font-medium text-gray-700">
+              Maximum entries
+              <input
+                type="number"
+                min={1}
+                max={50}
+                value={field.group.max}
+                onChange={(e) => setEditingField({ ...field, group: { ...field.group!, max: parseInt(e.target.value) || 0 } })}
+                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md"
+              />
+            </label>
+          </div>
+          <DualLanguageField
+            label="Add another button"
+            value={toMultiLanguage(field.group.addLabel)}
+            onChange={(val) => setEditingField({ ...field, group: { ...field.group!, addLabel: toMultiLanguage(val) } })}
+          />
+          <GroupFieldsEditor
+            fields={field.group.fields}
+            types={GROUP_CHILD_TYPES}
+            onChange={(fields) => setEditingField({ ...field, group: { ...field.group!, fields } })}
+          />
+        </div>
+      )}
+
+      {field.type === 'matrix' && field.matrix && (
+        <div className="mt-4 space-y-4">
+          <MatrixRowsEditor
+            rows={field.matrix.rows}
+            onChange={(rows) => setEditingField({ ...field, matrix: { ...field.matrix!, rows } })}
+          />
+          <MultiLanguageOptions
+            options={field.matrix.columns}
+            onChange={(columns) => setEditingField({ ...field, matrix: { ...field.matrix!, columns } })}
+            label="Columns"
+          />
+          <label className="flex items-center text-sm font-medium text-gray-700">
+            <input
+              type="checkbox"
+              checked={!!field.matrix.multiple}
+              onChange={(e) => setEditingField({ ...field, matrix: { ...field.matrix!, multiple: e.target.checked } })}
+              className="h-4 w-4 mr-3 text-blue-600 border-gray-300 rounded"
+            />
+            Allow several columns per row
+          </label>
+        </div>
+      )}
+
+      {field.scale && (field.type === 'rating' || field.type === 'scale') && (
+        <div className="mt-4 grid grid-cols-2 gap-4">
+          {field.type === 'scale' && (
+            <label className="text-sm font-medium text-gray-700">
+              Lowest value
+              <select
+                value={field.scale.min}
+                onChange={(e) => setEditingField({ ...field, scale: { ...field.scale!, min: parseInt(e.target.value) } })}
+                className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md"
+              >
+                <option value={0}>0</option>
+                <option value={1}>1</option>
+              </select>
+            </label>
+          )}
+          <label className="text-sm font-medium text-gray-700">
+            {field.type === 'rating' ? 'Stars' : 'Highest value'}
+            <input
+              type="number"
+              min={field.type === 'rating' ? 2 : field.scale.min + 1}
+              max={10}
+              value={field.scale.max}
+              onChange={(e) => setEditingField({ ...field, scale: { ...field.scale!, max: parseInt(e.target.value) || 0 } })}
+              className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md"
+            />
+          </label>
+        </div>
+      )}
+
+      {field.scale && (field.type === 'scale' || field.type === 'nps') && (
+        <div className="mt-4 grid grid-cols-1 md:grid-cols-2 gap-4">
+          <DualLanguageField
+            label="Low end label"
+            value={toMultiLanguage(field.scale.minLabel)}
+            onChange={(val) => setEditingField({ ...field, scale: { ...field.scale!, minLabel: toMultiLanguage(val) } })}
+          />
+          <DualLanguageField
+            label="High end label"
+            value={toMultiLanguage(field.scale.maxLabel)}
+            onChange={(val) => setEditingField({ ...field, scale: { ...field.scale!, maxLabel: toMultiLanguage(val) } })}
+          />
+        </div>
+      )}
+
+      {field.type !== 'calculated' && (
+        <div className="mt-4 flex items-center">
+          <input
+            type="checkbox"
+            id={`required-${field.id}`}
+            checked={field.required}
+            onChange={(e) => setEditingField({ ...field, required: e.target.checked })}
+            className="h-4 w-4 text-blue-600 focus:ring-blue-500 border-gray-300 rounded"
+          />
+          <label htmlFor={`required-${field.id}`} className="ml-3 text-sm font-medium text-gray-700">
+            Required field
+          </label>
+        </div>
+      )}
 
       <div className="mt-6 flex gap-3">
         <Button
@@ -412,6 +803,82 @@ export const FormBuilder: React.FC = () => {
               </div>
             </div>
 
+            {/* Pages Section */}
+            <div className="bg-white rounded-xl shadow-sm border border-gray-200 p-8">
+              <div className="flex justify-between items-center mb-6">
+                <h2 className="text-xl font-semibold text-gray-900">Pages</h2>
+                <span className="text-sm text-gray-500">
+                  {pages.length === 0 ? 'Single page' : `${pages.length} page${pages.length !== 1 ? 's' : ''}`}
+                </span>
+              </div>
+              <PagesEditor pages={pages} onChange={updatePages} />
+            </div>
+
+            {/* Quiz Section */}
+            <div className="bg-white rounded-xl shadow-sm border border-gray-200 p-8">
+              <div className="flex justify-between items-center mb-4">
+                <h2 className="text-xl font-semibold text-gray-900">Quiz</h2>
+                <label className="flex items-center text-sm font-medium text-gray-700">
+                  <input
+                    type="checkbox"
+                    checked={!!quiz}
+                    onChange={(e) => setQuiz(e.target.checked ? { bands: [] } : null)}
+                    className="h-4 w-4 mr-2 text-blue-600 border-gray-300 rounded"
+                  />
+                  Score responses
+                </label>
+              </div>
+              {quiz ? (
+                <>
+                  <p className="text-sm text-gray-500 mb-4">
+                    Mark questions as scored in each field's settings. Respondents see their score and the message for it after submitting.
+                  </p>
+                  <ScoreBandsEditor bands={quiz.bands || []} onChange={(bands) => setQuiz({ ...quiz, bands })} />
+                </>
+              ) : (
+                <p className="text-sm text-gray-500">Turn on to give questions correct answers and points.</p>
+              )}
+            </div>
+
+            {/* Attribution Section */}
+            <div className="bg-white rounded-xl shadow-sm border border-gray-200 p-8">
+              <h2 className="text-xl font-semibold text-gray-900 mb-2">Attribution</h2>
+              <p className="text-sm text-gray-500 mb-4">
+                Every response records the link's utm_* parameters, the referrer and the browser. Choose how the respondent's IP address is kept; anonymous forms keep neither the address nor the browser.
+              </p>
+              <select
+                value={settings.identity === 'anonymous' ? 'none' : settings.ipAddresses || 'hash'}
+                onChange={(e) => setSettings({ ...settings, ipAddresses: e.target.value as FormSettings['ipAddresses'] })}
+                disabled={settings.identity === 'anonymous'}
+                className="w-full px-3 py-2 border border-gray-300 rounded-md disabled:bg-gray-100"
+              >
+                <option value="hash">Hashed (groups responses from one address)</option>
+                <option value="full">Full address</option>
+                <option value="none">Not recorded</option>
+              </select>
+            </div>
+
+            {/* Identity Section */}
+            <div className="bg-white rounded-xl shadow-sm border border-gray-200 p-8">
+              <h2 className="text-xl font-semibold text-gray-900 mb-2">Respondent identity</h2>
+              <p className="text-sm text-gray-500 mb-4">
+                The contact details respondents must give. Duplicate checks and privacy requests match on them, so anonymous forms can't block repeat responses.
+              </p>
+              <select
+                value={settings.identity || 'phone'}
+                onChange={(e) => {
+                  const identity = e.target.value as IdentityMode;
+                  setSettings({ ...settings, identity, ...(identity === 'anonymous' ? { preventDuplicates: false, ipAddresses: 'none' } : {}) });
+                }}
+                className="w-full px-3 py-2 border border-gray-300 rounded-md"
+              >
+                <option value="phone">Phone number</option>
+                <option value="email">Email</option>
+                <option value="phone+email">Phone number and email</option>
+                <option value="anonymous">Anonymous</option>
+              </select>
+            </div>
+
             {/* Fields Section */}
             <div className="bg-white rounded-xl shadow-sm border border-gray-200 p-8">
               <div className="flex justify-between items-center mb-6">
@@ -436,6 +903,8 @@ export const FormBuilder: React.FC = () => {
                           </h3>
                           <p className="text-sm text-gray-500">
                             {field.type} • {field.required ? 'Required' : 'Optional'}
+                            {quiz && field.quiz && ` • ${field.quiz.points} pt${field.quiz.points !== 1 ? 's' : ''}`}
+                            {pages.length > 1 && ` • ${pageTitle(field)}`}
                           </p>
                         </div>
                         <div className="flex items-center gap-2">
@@ -545,7 +1014,7 @@ export const FormBuilder: React.FC = () => {
               <Button
                 onClick={() => {
                   if (isEditing && formId) {
-                    window.open(`/form/${formId}`, '_blank');
+                    window.open(previewPath || `/form/${formId}`, '_blank');
                   } else {
                     alert('Please save the form first to preview it');
                   }
@@ -1,7 +1,7 @@
-import React, { useState, useEffect } from 'react';
+import React, { useState, useEffect, useRef } from 'react';
 import { useParams } from 'react-router-dom';
-import { apiService } from '../services/api';
-import { Form, FormField, FormSubmission, MultiLanguageText } from '../types/form';
+import { apiService, ApiError } from '../services/api';
+import { Attribution, DraftSave, Form, FormDraft, FormField, FormPage, FormSubmission, MultiLanguageText, QuizResult } from '../types/form';
 import { Button } from '../presentation/components/ui/core/Button';
 
 export const PublicForm: React.FC = () => {
@@ -10,9 +10,18 @@ export const PublicForm: React.FC = () => {
   const [isLoading, setIsLoading] = useState(true);
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [isSubmitted, setIsSubmitted] = useState(false);
+  const [quizResult, setQuizResult] = useState<QuizResult | null>(null);
   const [error, setError] = useState<string | null>(null);
   const [phoneNumber, setPhoneNumber] = useState('');
+  const [email, setEmail] = useState('');
   const [formData, setFormData] = useState<Record<string, any>>({});
+  const [pageIndex, setPageIndex] = useState(0);
+  const [isSaving, setIsSaving] = useState(false);
+  // Token of the server-side draft; kept in localStorage so a reload resumes it
+  const [resumeToken, setResumeToken] = useState<string | null>(null);
+  const [resumeStep, setResumeStep] = useState<'closed' | 'phone' | 'code'>('closed');
+  const [resumeCode, setResumeCode] = useState('');
+  const [resumeMessage, setResumeMessage] = useState<string | null>(null);
 
   // Default to English if no language specified
   const currentLanguage = language || 'en';
@@ -28,24 +37,124 @@ export const PublicForm: React.FC = () => {
     return multiLangText?.[currentLanguage] || multiLangText?.en || '';
   };
 
+  // An earlier answer as it reads inside another text: picked options in the current
+  // language, several picks joined with commas and date ranges with a dash
+  const answerText = (fieldId: string): string => {
+    const field = form?.fields.find((f) => f.id === fieldId);
+    const value = formData[fieldId];
+    if (isEmptyAnswer(value)) return '';
+    const optionText = (item: any): string => {
+      const option = field?.options?.find((o) => Object.values(o).includes(item));
+      return option ? getText(option) : String(item);
+    };
+    if (Array.isArray(value)) return value.map(optionText).join(', ');
+    if (field?.type === 'daterange') return [value.start, value.end].filter(Boolean).join(' – ');
+    return optionText(value);
+  };
+
+  // getText with answers piped in: {{fieldId}} shows that field's answer and
+  // {{fieldId|fallback}} shows fallback until it is answered
+  const pipe = (text: MultiLanguageText | string | undefined): string =>
+    getText(text ?? '').replace(/\{\{\s*([^{}|]+?)\s*(?:\|([^{}]*))?\}\}/g, (_, id: string, fallback?: string) =>
+      answerText(id) || (fallback ?? '').trim());
+
   // Labels for phone number field in both languages
   const phoneLabel = currentLanguage === 'ar' ? 'رقم الهاتف' : 'Phone Number';
   const phonePlaceholder = currentLanguage === 'ar' ? '+965 9000 0000' : '+965 9000 0000';
+  const emailLabel = currentLanguage === 'ar' ? 'البريد الإلكتروني' : 'Email';
+
+  // Contact details the form's identity mode asks for: the phone number on the first
+  // page and the email with the submit button
+  const identity = form?.settings?.identity || 'phone';
+  const asksPhone = identity === 'phone' || identity === 'phone+email';
+  const asksEmail = identity === 'email' || identity === 'phone+email';
+
   const submitButtonText = currentLanguage === 'ar' ? 'إرسال' : 'Submit';
   const loadingText = currentLanguage === 'ar' ? 'جاري التحميل...' : 'Loading form...';
   const errorNotFound = currentLanguage === 'ar' ? 'النموذج غير موجود أو غير متاح' : 'Form not found or unavailable';
 
   useEffect(() => {
     if (formId) {
-      loadForm(parseInt(formId));
+      loadForm(formId);
     }
   }, [formId]);
 
-  const loadForm = async (id: number) => {
+  const draftStorageKey = `form-draft:${formId}`;
+
+  // Random per-tab ID for funnel analytics; it identifies the visit, not the respondent
+  const [sessionId] = useState<string>(() => {
+    const key = `form-session:${formId}`;
+    let id = sessionStorage.getItem(key);
+    if (!id) {
+      id = `${Date.now().toString(36)}${Math.random().toString(36).substring(2, 12)}`;
+      sessionStorage.setItem(key, id);
+    }
+    return id;
+  });
+  const lastTrackedField = useRef<string | null>(null);
+
+  // Campaign parameters and referrer of the page as it was opened, sent with the response
+  const [attribution] = useState<Attribution>(() => {
+    const params = new URLSearchParams(window.location.search);
+    const found: Attribution = {};
+    for (const key of ['utm_source', 'utm_medium', 'utm_campaign', 'utm_term', 'utm_content'] as const) {
+      const value = params.get(key);
+      if (value) found[key] = value;
+    }
+    if (document.referrer) found.referrer = document.referrer;
+    return found;
+  });
+
+  // Answers carried by the link for the fields the form allows to be prefilled
+  const prefillFromUrl = (f: Form): Record<string, any> => {
+    const params = new URLSearchParams(window.location.search);
+    const allowed = f.settings?.prefill || [];
+    const values: Record<string, any> = {};
+    for (const field of f.fields) {
+      const value = params.get(field.id);
+      if (value === null || !allowed.includes(field.id)) continue;
+      if (field.type === 'checkbox') {
+        values[field.id] = value.split(',').map((item) => item.trim()).filter(Boolean);
+      } else if (['number', 'rating', 'scale', 'nps'].includes(field.type)) {
+        if (value.trim() !== '' && !isNaN(Number(value))) values[field.id] = Number(value);
+      } else {
+        values[field.id] = value;
+      }
+    }
+    return values;
+  };
+  // Options whose places are all taken can't be picked, unless they already are
+  const isFull = (field: FormField, option: MultiLanguageText | string): boolean => {
+    const texts = typeof option === 'string' ? [option] : Object.values(option);
+    return !!form?.seats?.some((s) => s.fieldId === field.id && s.full && texts.includes(s.option));
+  };
+  const fullSuffix = currentLanguage === 'ar' ? ' (مكتمل)' : ' (full)';
+
+  const trackEvent = (f: Form, type: 'view' | 'start' | 'field', fieldId?: string) =>
+    apiService.trackFormEvent({ formId: f.id, formRef: formId, sessionId, type, fieldId });
+
+  // A form without pages is a single page holding every field
+  const pagesOf = (f: Form): FormPage[] => (f.pages && f.pages.length > 0 ? f.pages : [{ id: '', title: {} }]);
+  const fieldsOnPage = (f: Form, page: FormPage): FormField[] =>
+    f.fields.filter((field) => (field.page || pagesOf(f)[0].id) === page.id);
+
+  // formId is whatever the link carried: share token, slug or legacy numeric ID
+  const loadForm = async (ref: string) => {
     try {
       setIsLoading(true);
-      const formData = await apiService.getForm(id);
+      const formData = await apiService.getPublicForm(ref, sessionId);
       setForm(formData);
+      setFormData(prefillFromUrl(formData));
+      trackEvent(formData, 'view');
+      // ?resume= carries a token from a "continue later" link
+      const token = new URLSearchParams(window.location.search).get('resume') || localStorage.getItem(draftStorageKey);
+      if (token) {
+        try {
+          applyDraft(formData, await apiService.getDraft(token), token);
+        } catch {
+          localStorage.removeItem(draftStorageKey);
+        }
+      }
     } catch (err) {
       setError(errorNotFound);
     } finally {
@@ -53,57 +162,205 @@ export const PublicForm: React.FC = () => {
     }
   };
 
+  // Restores saved answers and continues after the last saved page
+  const applyDraft = (f: Form, draft: FormDraft, token: string) => {
+    setResumeToken(token);
+    localStorage.setItem(draftStorageKey, token);
+    // Saved answers win over the link's prefill
+    setFormData(prev => ({ ...prev, ...(draft.responseData || {}) }));
+    if (draft.phoneNumber) {
+      setPhoneNumber(draft.phoneNumber);
+    }
+    const pages = pagesOf(f);
+    const saved = pages.findIndex((page) => page.id === draft.pageId);
+    setPageIndex(Math.min(saved + 1, pages.length - 1));
+  };
+
   const handleFieldChange = (fieldId: string, value: any) => {
+    if (form && lastTrackedField.current !== fieldId) {
+      // Without a phone number to type, the first answer starts the form
+      if (!asksPhone && lastTrackedField.current === null) trackEvent(form, 'start');
+      lastTrackedField.current = fieldId;
+      trackEvent(form, 'field', fieldId);
+    }
     setFormData(prev => ({
       ...prev,
       [fieldId]: value
     }));
   };
 
-  const validateForm = (): boolean => {
-    if (!phoneNumber.trim()) {
+  // NPS and scale answers can be 0, and a date range is only answered once both ends are
+  const isEmptyAnswer = (value: any): boolean => {
+    if (value === undefined || value === null || value === '') return true;
+    if (Array.isArray(value)) return value.length === 0;
+    if (value instanceof File) return false;
+    if (typeof value === 'object' && ('start' in value || 'end' in value)) return !value.start || !value.end;
+    if (typeof value === 'object') return Object.values(value).every(isEmptyAnswer);
+    return false;
+  };
+
+  // Checks the given fields, and the phone number and email when they are on the page
+  // being checked
+  const validateForm = (fields: FormField[], checkPhone: boolean, checkEmail = false): boolean => {
+    if (checkPhone && asksPhone && !phoneNumber.trim()) {
       setError(currentLanguage === 'ar' ? 'رقم الهاتف مطلوب' : 'Phone number is required');
       return false;
     }
+    if (checkEmail && asksEmail && !/^[^\s@]+@[^\s@]+$/.test(email.trim())) {
+      setError(currentLanguage === 'ar' ? 'البريد الإلكتروني غير صحيح' : 'Please enter a valid email');
+      return false;
+    }
 
     if (!form) return false;
 
-    for (const field of form.fields) {
-      if (field.required && (!formData[field.id] || formData[field.id] === '')) {
-        const fieldLabel = getText(field.label);
+    for (const field of fields) {
+      if (field.required && isEmptyAnswer(formData[field.id])) {
+        const fieldLabel = pipe(field.label);
         setError(currentLanguage === 'ar' ? `${fieldLabel} مطلوب` : `${fieldLabel} is required`);
         return false;
       }
+      if (field.group) {
+        const entries: Record<string, any>[] = formData[field.id] || [];
+        if (entries.length > 0 && entries.length < field.group.min) {
+          setError(currentLanguage === 'ar'
+            ? `${pipe(field.label)}: ${field.group.min} إدخالات على الأقل`
+            : `${pipe(field.label)} needs at least ${field.group.min} entries`);
+          return false;
+        }
+        for (const [index, entry] of entries.entries()) {
+          const missingChild = field.group.fields.find((child) => child.required && isEmptyAnswer(entry[child.id]));
+          if (missingChild) {
+            const childLabel = `${pipe(field.label)} #${index + 1}: ${pipe(missingChild.label)}`;
+            setError(currentLanguage === 'ar' ? `${childLabel} مطلوب` : `${childLabel} is required`);
+            return false;
+          }
+        }
+      }
+      const missingRow = field.matrix?.rows.find((row) => row.required && isEmptyAnswer(formData[field.id]?.[row.id]));
+      if (missingRow) {
+        const rowLabel = `${pipe(field.label)}: ${pipe(missingRow.label)}`;
+        setError(currentLanguage === 'ar' ? `${rowLabel} مطلوب` : `${rowLabel} is required`);
+        return false;
+      }
     }
 
     setError(null);
     return true;
   };
 
+  // Saves the current page to the draft and moves on; the server validates only that page
+  const nextPage = async () => {
+    if (!form) return;
+    const page = pagesOf(form)[pageIndex];
+    const fields = fieldsOnPage(form, page);
+    if (!validateForm(fields, pageIndex === 0)) return;
+
+    const draft: DraftSave = {
+      formId: form.id,
+      formRef: formId,
+      pageId: page.id,
+      phoneNumber: (asksPhone && phoneNumber.trim()) || undefined,
+      responseData: Object.fromEntries(fields.filter((f) => f.id in formData).map((f) => [f.id, formData[f.id]])),
+      language: currentLanguage,
+    };
+    try {
+      setIsSaving(true);
+      let saved: FormDraft | null = null;
+      if (resumeToken) {
+        try {
+          saved = await apiService.saveDraft(resumeToken, draft);
+        } catch (err) {
+          // The draft expired or was submitted elsewhere; start a new one
+          if (!(err instanceof ApiError) || err.status !== 404) throw err;
+        }
+      }
+      if (!saved) {
+        saved = await apiService.createDraft(draft);
+        setResumeToken(saved.resumeToken!);
+        localStorage.setItem(draftStorageKey, saved.resumeToken!);
+      }
+      setPageIndex(pageIndex + 1);
+      window.scrollTo(0, 0);
+    } catch (err) {
+      setError(currentLanguage === 'ar' ? 'تعذر حفظ الصفحة. تحقق من إجاباتك.' : 'Could not save this page. Please check your answers.');
+    } finally {
+      setIsSaving(false);
+    }
+  };
+
+  const requestResumeCode = async () => {
+    if (!formId || !phoneNumber.trim()) return;
+    try {
+      await apiService.requestDraftCode(formId, phoneNumber.trim());
+      setResumeStep('code');
+      setResumeMessage(currentLanguage === 'ar' ? 'إذا كانت لديك مسودة، فقد أرسلنا رمزاً إلى هاتفك.' : 'If you saved a draft, we sent a code to your phone.');
+    } catch (err) {
+      setResumeMessage(currentLanguage === 'ar' ? 'تعذر إرسال الرمز.' : 'Could not send a code.');
+    }
+  };
+
+  const verifyResumeCode = async () => {
+    if (!form || !formId) return;
+    try {
+      const draft = await apiService.verifyDraftCode(formId, phoneNumber.trim(), resumeCode.trim());
+      applyDraft(form, draft, draft.resumeToken!);
+      setResumeStep('closed');
+      setResumeMessage(null);
+    } catch (err) {
+      setResumeMessage(err instanceof ApiError && err.status === 404
+        ? (currentLanguage === 'ar' ? 'لا توجد مسودة محفوظة لهذا الرقم.' : 'There is no saved draft for this number.')
+        : (currentLanguage === 'ar' ? 'الرمز غير صحيح أو منتهي الصلاحية.' : 'The code is wrong or has expired.'));
+    }
+  };
+
   const submitForm = async () => {
-    if (!validateForm() || !form) return;
+    if (!form) return;
+    const page = pagesOf(form)[pageIndex];
+    if (!validateForm(fieldsOnPage(form, page), pageIndex === 0, true)) return;
+    // Earlier pages were checked when they were saved, but a reload may have skipped them
+    if (!resumeToken && !validateForm(form.fields, true, true)) return;
 
     try {
       setIsSubmitting(true);
       const submission: FormSubmission = {
         formId: form.id,
-        phoneNumber: phoneNumber.trim(),
+        formRef: formId,
+        ...(asksPhone ? { phoneNumber: phoneNumber.trim() } : {}),
+        ...(asksEmail ? { email: email.trim() } : {}),
         responseData: formData,
-        language: currentLanguage
+        language: currentLanguage,
+        sessionId,
+        attribution,
+        ...(resumeToken ? { resumeToken } : {}),
       };
 
-      await apiService.submitForm(submission);
+      const response = await apiService.submitForm(submission);
+      setQuizResult(response.result || null);
+      localStorage.removeItem(draftStorageKey);
       setIsSubmitted(true);
     } catch (err) {
+      if (err instanceof ApiError && err.status === 422 && err.body.includes('"is full"')) {
+        // Someone took the last place first; show what is still available
+        setError(currentLanguage === 'ar' ? 'اكتمل أحد الخيارات التي اخترتها. يرجى اختيار خيار آخر.' : 'An option you picked has just filled up. Please choose another.');
+        apiService.getPublicForm(formId!, sessionId).then((latest) => setForm({ ...form, seats: latest.seats })).catch(() => {});
+        return;
+      }
       setError(currentLanguage === 'ar' ? 'فشل في إرسال النموذج. حاول مرة أخرى.' : 'Failed to submit form. Please try again.');
     } finally {
       setIsSubmitting(false);
     }
   };
 
-  const renderField = (field: FormField) => {
+  // Group entries render their child fields with the entry's own value and change handler;
+  // inputName keeps radio groups in different entries apart
+  const renderField = (
+    field: FormField,
+    value: any = formData[field.id],
+    onChange: (value: any) => void = (v) => handleFieldChange(field.id, v),
+    inputName: string = field.id,
+  ): React.ReactNode => {
     // const fieldLabel = getText(field.label);
-    const fieldPlaceholder = field.placeholder ? getText(field.placeholder) : '';
+    const fieldPlaceholder = field.placeholder ? pipe(field.placeholder) : '';
     
     const baseClasses = `w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-all ${isRTL ? 'text-right' : 'text-left'}`;
     const rtlClass = isRTL ? 'force-rtl' : '';
@@ -116,8 +373,8 @@ export const PublicForm: React.FC = () => {
         return (
           <input
             type={field.type}
-            value={formData[field.id] || ''}
-            onChange={(e) => handleFieldChange(field.id, e.target.value)}
+            value={value || ''}
+            onChange={(e) => onChange(e.target.value)}
             className={`${baseClasses} ${rtlClass}`}
             placeholder={fieldPlaceholder}
             required={field.required}
@@ -129,8 +386,8 @@ export const PublicForm: React.FC = () => {
       case 'textarea':
         return (
           <textarea
-            value={formData[field.id] || ''}
-            onChange={(e) => handleFieldChange(field.id, e.target.value)}
+            value={value || ''}
+            onChange={(e) => onChange(e.target.value)}
             className={`${baseClasses} min-h-24 resize-y ${rtlClass}`}
             placeholder={fieldPlaceholder}
             required={field.required}
@@ -143,8 +400,8 @@ export const PublicForm: React.FC = () => {
       case 'select':
         return (
           <select
-            value={formData[field.id] || ''}
-            onChange={(e) => handleFieldChange(field.id, e.target.value)}
+            value={value || ''}
+            onChange={(e) => onChange(e.target.value)}
             className={`${baseClasses} ${rtlClass}`}
             required={field.required}
             dir={isRTL ? 'rtl' : 'ltr'}
@@ -152,8 +409,8 @@ export const PublicForm: React.FC = () => {
           >
             <option value="">{fieldPlaceholder || (currentLanguage === 'ar' ? 'اختر خيار' : 'Select an option')}</option>
             {field.options?.map((option, index) => (
-              <option key={index} value={getText(option)}>
-                {getText(option)}
+              <option key={index} value={getText(option)} disabled={isFull(field, option) && value !== getText(option)}>
+                {pipe(option)}{isFull(field, option) ? fullSuffix : ''}
               </option>
             ))}
           </select>
@@ -166,14 +423,15 @@ export const PublicForm: React.FC = () => {
               <label key={index} className={`flex items-center ${isRTL ? 'flex-row-reverse' : ''}`}>
                 <input
                   type="radio"
-                  name={field.id}
+                  name={inputName}
                   value={getText(option)}
-                  checked={formData[field.id] === getText(option)}
-                  onChange={(e) => handleFieldChange(field.id, e.target.value)}
+                  checked={value === getText(option)}
+                  disabled={isFull(field, option) && value !== getText(option)}
+                  onChange={(e) => onChange(e.target.value)}
                   className={`h-4 w-4 text-blue-600 focus:ring-blue-500 border-gray-300 ${isRTL ? 'ml-3' : 'mr-3'}`}
                   required={field.required}
                 />
-                <span className="text-gray-700">{getText(option)}</span>
+                <span className="text-gray-700">{pipe(option)}{isFull(field, option) ? fullSuffix : ''}</span>
               </label>
             ))}
           </div>
@@ -187,19 +445,19 @@ export const PublicForm: React.FC = () => {
                 <input
                   type="checkbox"
                   value={getText(option)}
-                  checked={(formData[field.id] || []).includes(getText(option))}
+                  checked={(value || []).includes(getText(option))}
                   onChange={(e) => {
-                    const currentValues = formData[field.id] || [];
+                    const currentValues = value || [];
                     const optionValue = getText(option);
                     if (e.target.checked) {
-                      handleFieldChange(field.id, [...currentValues, optionValue]);
+                      onChange([...currentValues, optionValue]);
                     } else {
-                      handleFieldChange(field.id, currentValues.filter((v: string) => v !== optionValue));
+                      onChange(currentValues.filter((v: string) => v !== optionValue));
                     }
                   }}
                   className={`h-4 w-4 text-blue-600 focus:ring-blue-500 border-gray-300 rounded ${isRTL ? 'ml-3' : 'mr-3'}`}
                 />
-                <span className="text-gray-700">{getText(option)}</span>
+                <span className="text-gray-700">{pipe(option)}</span>
               </label>
             ))}
           </div>
@@ -209,19 +467,207 @@ export const PublicForm: React.FC = () => {
         return (
           <input
             type="file"
-            onChange={(e) => handleFieldChange(field.id, e.target.files?.[0])}
+            onChange={(e) => onChange(e.target.files?.[0])}
             className={baseClasses}
             required={field.required}
           />
         );
 
+      case 'url':
+      case 'phone':
+        return (
+          <input
+            type={field.type === 'phone' ? 'tel' : 'url'}
+            value={value || ''}
+            onChange={(e) => onChange(e.target.value)}
+            className={baseClasses}
+            placeholder={fieldPlaceholder}
+            required={field.required}
+            dir="ltr"
+          />
+        );
+
+      case 'rating': {
+        const stars = field.scale?.max || 5;
+        return (
+          <div className={`flex gap-1 ${isRTL ? 'flex-row-reverse justify-end' : ''}`}>
+            {Array.from({ length: stars }, (_, i) => i + 1).map((star) => (
+              <button
+                key={star}
+                type="button"
+                onClick={() => onChange(star)}
+                className={`text-3xl ${star <= (value || 0) ? 'text-yellow-400' : 'text-gray-300'}`}
+                aria-label={`${star}`}
+              >
+                ★
+              </button>
+            ))}
+          </div>
+        );
+      }
+
+      case 'scale':
+      case 'nps': {
+        const min = field.type === 'nps' ? 0 : field.scale?.min ?? 1;
+        const max = field.type === 'nps' ? 10 : field.scale?.max ?? 5;
+        return (
+          <div>
+            <div className="flex flex-wrap gap-2" dir="ltr">
+              {Array.from({ length: max - min + 1 }, (_, i) => min + i).map((point) => (
+                <button
+                  key={point}
+                  type="button"
+                  onClick={() => onChange(point)}
+                  className={`w-10 h-10 rounded-md border text-sm font-medium ${
+                    value === point ? 'bg-blue-600 text-white border-blue-600' : 'bg-white text-gray-700 border-gray-300'
+                  }`}
+                >
+                  {point}
+                </button>
+              ))}
+            </div>
+            {(field.scale?.minLabel || field.scale?.maxLabel) && (
+              <div className="flex justify-between text-xs text-gray-500 mt-1" dir="ltr">
+                <span>{field.scale?.minLabel ? pipe(field.scale.minLabel) : ''}</span>
+                <span>{field.scale?.maxLabel ? pipe(field.scale.maxLabel) : ''}</span>
+              </div>
+            )}
+          </div>
+        );
+      }
+
+      case 'daterange': {
+        const range = value || { start: '', end: '' };
+        return (
+          <div className={`flex items-center gap-3 ${isRTL ? 'flex-row-reverse' : ''}`}>
+            <input
+              type="date"
+              value={range.start}
+              max={range.end || undefined}
+              onChange={(e) => onChange({ ...range, start: e.target.value })}
+              className={baseClasses}
+              required={field.required}
+            />
+            <span className="text-gray-500">{currentLanguage === 'ar' ? 'إلى' : 'to'}</span>
+            <input
+              type="date"
+              value={range.end}
+              min={range.start || undefined}
+              onChange={(e) => onChange({ ...range, end: e.target.value })}
+              className={baseClasses}
+              required={field.required}
+            />
+          </div>
+        );
+      }
+
+      case 'matrix': {
+        const matrix = field.matrix;
+        if (!matrix) return null;
+        const answers = value || {};
+        const setRow = (rowId: string, value: string | string[]) =>
+          onChange({ ...answers, [rowId]: value });
+        return (
+          <div className="overflow-x-auto">
+            <table className="min-w-full text-sm" dir={isRTL ? 'rtl' : 'ltr'}>
+              <thead>
+                <tr>
+                  <th />
+                  {matrix.columns.map((column, j) => (
+                    <th key={j} className="px-2 py-1 font-medium text-gray-600 text-center">{pipe(column)}</th>
+                  ))}
+                </tr>
+              </thead>
+              <tbody>
+                {matrix.rows.map((row) => (
+                  <tr key={row.id} className="border-t border-gray-100">
+                    <td className="py-2 pr-2 text-gray-700">
+                      {pipe(row.label)}
+                      {row.required && <span className="text-red-500 ml-1">*</span>}
+                    </td>
+                    {matrix.columns.map((column, j) => {
+                      const value = getText(column);
+                      const picked: string[] = matrix.multiple ? answers[row.id] || [] : [];
+                      return (
+                        <td key={j} className="px-2 py-2 text-center">
+                          {matrix.multiple ? (
+                            <input
+                              type="checkbox"
+                              checked={picked.includes(value)}
+                              onChange={(e) => setRow(row.id, e.target.checked ? [...picked, value] : picked.filter((v) => v !== value))}
+                              className="h-4 w-4 text-blue-600 border-gray-300 rounded"
+                            />
+                          ) : (
+                            <input
+                              type="radio"
+                              name={`${inputName}-${row.id}`}
+                              checked={answers[row.id] === value}
+                              onChange={() => setRow(row.id, value)}
+                              className="h-4 w-4 text-blue-600 border-gray-300"
+                            />
+                          )}
+                        </td>
+                      );
+                    })}
+                  </tr>
+                ))}
+              </tbody>
+            </table>
+          </div>
+        );
+      }
+
+      case 'group': {
+        const group = field.group;
+        if (!group) return null;
+        const entries: Record<string, any>[] = value || [];
+        const setEntry = (index: number, childId: string, childValue: any) =>
+          onChange(entries.map((entry, i) => (i === index ? { ...entry, [childId]: childValue } : entry)));
+        return (
+          <div className="space-y-4">
+            {entries.map((entry, index) => (
+              <div key={index} className="border border-gray-200 rounded-lg p-4 space-y-4">
+                <div className="flex items-center justify-between">
+                  <span className="text-sm font-medium text-gray-500">#{index + 1}</span>
+                  <button
+                    type="button"
+                    onClick={() => onChange(entries.filter((_, i) => i !== index))}
+                    className="text-sm text-red-600 hover:text-red-700"
+                  >
+                    {currentLanguage === 'ar' ? 'إزالة' : 'Remove'}
+                  </button>
+                </div>
+                {group.fields.map((child) => (
+                  <div key={child.id}>
+                    <label className="block text-sm font-semibold text-gray-900 mb-2">
+                      {pipe(child.label)}
+                      {child.required && <span className="text-red-500 ml-1">*</span>}
+                    </label>
+                    {renderField(child, entry[child.id], (v) => setEntry(index, child.id, v), `${inputName}-${index}-${child.id}`)}
+                  </div>
+                ))}
+              </div>
+            ))}
+            {entries.length < group.max && (
+              <button
+                type="button"
+                onClick={() => onChange([...entries, {}])}
+                className="px-4 py-2 bg-blue-100 text-blue-700 rounded hover:bg-blue-200"
+              >
+                {group.addLabel && pipe(group.addLabel) ? pipe(group.addLabel) : (currentLanguage === 'ar' ? '+ إضافة' : '+ Add another')}
+              </button>
+            )}
+          </div>
+        );
+      }
+
       case 'date':
       case 'time':
         return (
           <input
             type={field.type}
-            value={formData[field.id] || ''}
-            onChange={(e) => handleFieldChange(field.id, e.target.value)}
+            value={value || ''}
+            onChange={(e) => onChange(e.target.value)}
             className={baseClasses}
             required={field.required}
           />
@@ -250,6 +696,13 @@ export const PublicForm: React.FC = () => {
                 ? 'تم إرسال ردك بنجاح. سيتواصل معك فريقنا قريباً.'
                 : 'Your response has been submitted successfully. Our team will contact you soon.'}
             </p>
+            {quizResult && (
+              <div className="mt-6 p-4 bg-blue-50 rounded-lg">
+                <p className="text-sm text-gray-600">{currentLanguage === 'ar' ? 'نتيجتك' : 'Your score'}</p>
+                <p className="text-3xl font-bold text-blue-600">{quizResult.score} / {quizResult.maxScore}</p>
+                {quizResult.message && <p className="text-gray-800 mt-2">{getText(quizResult.message)}</p>}
+              </div>
+            )}
             
             {/* Powered by 4Sale */}
                       <div className="mt-8 pt-6 border-t border-gray-200">
@@ -289,6 +742,9 @@ export const PublicForm: React.FC = () => {
 
         {(() => {
           if (!form || isSubmitted) return null;
+          const pages = pagesOf(form);
+          const page = pages[pageIndex];
+          const isLastPage = pageIndex === pages.length - 1;
           
           return (
             <div className="bg-white rounded-xl shadow-sm border border-gray-200 overflow-hidden">
@@ -314,7 +770,27 @@ export const PublicForm: React.FC = () => {
                   )}
                 </div>
 
-              {/* Phone Number Field - Always Required */}
+              {pages.length > 1 && (
+                <div className="mb-6">
+                  <div className="flex justify-between text-sm text-gray-500 mb-2">
+                    <span>{pipe(page.title)}</span>
+                    <span>
+                      {currentLanguage === 'ar'
+                        ? `صفحة ${pageIndex + 1} من ${pages.length}`
+                        : `Page ${pageIndex + 1} of ${pages.length}`}
+                    </span>
+                  </div>
+                  <div className="w-full h-2 bg-gray-200 rounded-full">
+                    <div
+                      className="h-2 bg-blue-600 rounded-full transition-all"
+                      style={{ width: `${((pageIndex + 1) / pages.length) * 100}%` }}
+                    />
+                  </div>
+                </div>
+              )}
+
+              {/* Phone Number Field - asked on the first page when the identity mode wants it */}
+              {pageIndex === 0 && asksPhone && (
               <div className="mb-6">
                 <label className="block text-sm font-semibold text-gray-900 mb-2">
                   {phoneLabel} *
@@ -322,7 +798,10 @@ export const PublicForm: React.FC = () => {
                 <input
                   type="tel"
                   value={phoneNumber}
-                  onChange={(e) => setPhoneNumber(e.target.value)}
+                  onChange={(e) => {
+                    if (!phoneNumber) trackEvent(form, 'start');
+                    setPhoneNumber(e.target.value);
+                  }}
                   className={`w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-all text-gray-900 placeholder-gray-500 ${isRTL ? 'text-right' : 'text-left'}`}
                   placeholder={phonePlaceholder}
                   required
@@ -333,14 +812,45 @@ export const PublicForm: React.FC = () => {
                     ? 'مطلوب للتواصل معك من قبل فريقنا'
                     : 'Required for our agent to contact you'}
                 </p>
+                {pages.length > 1 && !resumeToken && (
+                  <div className="mt-3 text-sm">
+                    {resumeStep === 'closed' && (
+                      <button type="button" onClick={() => setResumeStep('phone')} className="text-blue-600 hover:underline">
+                        {currentLanguage === 'ar' ? 'بدأت هذا النموذج من قبل؟ تابع من حيث توقفت' : 'Started this form before? Continue where you left off'}
+                      </button>
+                    )}
+                    {resumeStep === 'phone' && (
+                      <button type="button" onClick={requestResumeCode} disabled={!phoneNumber.trim()} className="text-blue-600 hover:underline disabled:text-gray-400">
+                        {currentLanguage === 'ar' ? 'أرسل رمز التحقق إلى هذا الرقم' : 'Send a verification code to this number'}
+                      </button>
+                    )}
+                    {resumeStep === 'code' && (
+                      <div className="flex gap-2">
+                        <input
+                          type="text"
+                          inputMode="numeric"
+                          value={resumeCode}
+                          onChange={(e) => setResumeCode(e.target.value)}
+                          className="flex-1 px-3 py-2 border border-gray-300 rounded-lg"
+                          placeholder={currentLanguage === 'ar' ? 'رمز التحقق' : 'Verification code'}
+                        />
+                        <button type="button" onClick={verifyResumeCode} className="px-3 py-2 bg-blue-100 text-blue-700 rounded-lg hover:bg-blue-200">
+                          {currentLanguage === 'ar' ? 'متابعة' : 'Continue'}
+                        </button>
+                      </div>
+                    )}
+                    {resumeMessage && <p className="text-gray-600 mt-2">{resumeMessage}</p>}
+                  </div>
+                )}
               </div>
+              )}
 
               {/* Dynamic Form Fields */}
               <div className="space-y-6 mb-8">
-                {form.fields.map((field) => (
+                {fieldsOnPage(form, page).filter((field) => field.type !== 'hidden' && field.type !== 'calculated').map((field) => (
                   <div key={field.id}>
                     <label className="block text-sm font-semibold text-gray-900 mb-2">
-                      {getText(field.label)}
+                      {pipe(field.label)}
                       {field.required && <span className="text-red-500 ml-1">*</span>}
                     </label>
                     {renderField(field)}
@@ -348,14 +858,59 @@ export const PublicForm: React.FC = () => {
                 ))}
               </div>
 
-              {/* Submit Button */}
-              <Button
-                onClick={submitForm}
-                loading={isSubmitting}
-                className="w-full bg-blue-600 hover:bg-blue-700 text-white border-0 py-4 text-lg font-semibold rounded-xl"
-              >
-                {form.submitButtonText ? getText(form.submitButtonText) : submitButtonText}
-              </Button>
+              {/* Email Field - asked with the submit button; drafts never keep it */}
+              {isLastPage && asksEmail && (
+              <div className="mb-6">
+                <label className="block text-sm font-semibold text-gray-900 mb-2">
+                  {emailLabel} *
+                </label>
+                <input
+                  type="email"
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                  className={`w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-all text-gray-900 placeholder-gray-500 ${isRTL ? 'text-right' : 'text-left'}`}
+                  placeholder="name@example.com"
+                  required
+                  dir="ltr"
+                />
+              </div>
+              )}
+
+              {/* Page navigation; each Next saves the page to the draft */}
+              <div className="flex gap-3">
+                {pageIndex > 0 && (
+                  <Button
+                    onClick={() => setPageIndex(pageIndex - 1)}
+                    className="flex-1 bg-gray-100 hover:bg-gray-200 text-gray-700 border-0 py-4 text-lg font-semibold rounded-xl"
+                  >
+                    {currentLanguage === 'ar' ? 'السابق' : 'Back'}
+                  </Button>
+                )}
+                {isLastPage ? (
+                  <Button
+                    onClick={submitForm}
+                    loading={isSubmitting}
+                    className="flex-1 w-full bg-blue-600 hover:bg-blue-700 text-white border-0 py-4 text-lg font-semibold rounded-xl"
+                  >
+                    {form.submitButtonText ? pipe(form.submitButtonText) : submitButtonText}
+                  </Button>
+                ) : (
+                  <Button
+                    onClick={nextPage}
+                    loading={isSaving}
+                    className="flex-1 w-full bg-blue-600 hover:bg-blue-700 text-white border-0 py-4 text-lg font-semibold rounded-xl"
+                  >
+                    {currentLanguage === 'ar' ? 'التالي' : 'Next'}
+                  </Button>
+                )}
+              </div>
+              {resumeToken && (
+                <p className="text-xs text-gray-500 mt-3 text-center">
+                  {currentLanguage === 'ar'
+                    ? 'تم حفظ تقدمك. يمكنك العودة لاحقاً من هذا الجهاز أو برقم هاتفك.'
+                    : 'Your progress is saved. Come back later on this device or with your phone number.'}
+                </p>
+              )}
 
               {/* Powered by 4Sale */}
               <div className="mt-8 pt-6 border-t border-gray-200 text-center">
@@ -1,13 +1,39 @@
-import React, { useState, useEffect } from 'react';
+import React, { useState, useEffect, useRef } from 'react';
 import { useParams, Link } from 'react-router-dom';
 import { Button } from '../presentation/components/ui/core/Button';
 import { apiService } from '../services/api';
-import { Form, FormResponse, MultiLanguageText } from '../types/form';
+import { Form, FormFunnel, FormResponse, MultiLanguageText, ResponseFilters, ResponseMetadata, publicFormPath } from '../types/form';
+
+// matchesFilters mirrors the server's list filters so streamed responses only join the
+// list when the loaded page would have included them
+const matchesFilters = (response: FormResponse, filters: ResponseFilters): boolean => {
+  const metadata: Record<string, unknown> = { ...response.metadata };
+  const text = (key: string) => String(metadata[key] ?? '').toLowerCase();
+  return Object.entries(filters).every(([key, value]) => {
+    if (!value) return true;
+    switch (key) {
+      case 'status':
+        return response.status === value;
+      case 'assignee':
+        return value === 'unassigned' ? !response.assignee : response.assignee === value;
+      case 'tag':
+        return (response.tags || []).includes(value.trim().toLowerCase());
+      case 'referrer':
+        return text('referrer').includes(value.toLowerCase());
+      default:
+        return text(key) === value.toLowerCase();
+    }
+  });
+};
 
 export const ResponsesPage: React.FC = () => {
   const { formId } = useParams<{ formId: string }>();
   const [form, setForm] = useState<Form | null>(null);
   const [responses, setResponses] = useState<FormResponse[]>([]);
+  const [funnel, setFunnel] = useState<FormFunnel | null>(null);
+  const [filters, setFilters] = useState<ResponseFilters>({});
+  // The filters the shown list was loaded with, as opposed to the ones being edited
+  const appliedFilters = useRef<ResponseFilters>({});
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
 
@@ -17,16 +43,27 @@ export const ResponsesPage: React.FC = () => {
     }
   }, [formId]);
 
+  useEffect(() => {
+    if (!formId) return;
+    return apiService.subscribeToResponses((response) => {
+      if (!matchesFilters(response, appliedFilters.current)) return;
+      setResponses(prev => prev.some(r => r.id === response.id) ? prev : [response, ...prev]);
+    }, parseInt(formId));
+  }, [formId]);
+
   const loadFormAndResponses = async () => {
     try {
       setLoading(true);
       setError(null);
       
       const formData = await apiService.getForm(parseInt(formId!));
-      const responsesData = await apiService.getFormResponses(parseInt(formId!));
+      const responsesData = await apiService.getFormResponses(parseInt(formId!), filters);
       
       setForm(formData);
       setResponses(responsesData);
+      appliedFilters.current = filters;
+      // The funnel is a nice-to-have; the page works without it
+      apiService.getFormFunnel(parseInt(formId!)).then(setFunnel).catch(() => setFunnel(null));
     } catch (err) {
       setError('Failed to load form responses');
       console.error('Error loading form and responses:', err);
@@ -35,45 +72,38 @@ export const ResponsesPage: React.FC = () => {
     }
   };
 
-  const exportToCSV = () => {
+  // The server flattens each answer by its field type, so every type exports the same way
+  const exportToCSV = async () => {
     if (!form || responses.length === 0) return;
+    try {
+      const blob = await apiService.exportResponsesCsv(form.id, 'en', filters);
+      const link = document.createElement('a');
+      const url = URL.createObjectURL(blob);
+      link.setAttribute('href', url);
+      link.setAttribute('download', `${getText(form.title).replace(/[^a-zA-Z0-9]/g, '_')}_responses.csv`);
+      link.style.visibility = 'hidden';
+      document.body.appendChild(link);
+      link.click();
+      document.body.removeChild(link);
+      URL.revokeObjectURL(url);
+    } catch (err) {
+      setError('Failed to export responses');
+      console.error('Error exporting responses:', err);
+    }
+  };
 
-    // Create CSV headers
-    const headers = ['Response ID', 'Phone Number', 'Submitted At'];
-    form.fields.forEach(field => {
-      headers.push(getText(field.label));
-    });
-
-    // Create CSV rows
-    const csvRows = [headers.join(',')];
-    
-    responses.forEach(response => {
-      const row = [
-        response.id.toString(),
-        `"${response.phoneNumber}"`,
-        `"${new Date(response.submittedAt).toLocaleString()}"`
-      ];
-      
-      form.fields.forEach(field => {
-        const value = response.responseData[field.id] || '';
-        const cellValue = Array.isArray(value) ? value.join('; ') : String(value);
-        row.push(`"${cellValue.replace(/"/g, '""')}"`);
-      });
-      
-      csvRows.push(row.join(','));
-    });
-
-    // Download CSV
-    const csvContent = csvRows.join('\n');
-    const blob = new Blob([csvContent], { type: 'text/csv;charset=utf-8;' });
-    const link = document.createElement('a');
-    const url = URL.createObjectURL(blob);
-    link.setAttribute('href', url);
-    link.setAttribute('download', `${getText(form.title).replace(/[^a-zA-Z0-9]/g, '_')}_responses.csv`);
-    link.style.visibility = 'hidden';
-    document.body.appendChild(link);
-    link.click();
-    document.body.removeChild(link);
+  // Campaign and referrer of a response in one line, or null when it has neither
+  const describeSource = (meta?: ResponseMetadata): string | null => {
+    if (!meta) return null;
+    const parts = [meta.utm_source, meta.utm_medium, meta.utm_campaign].filter(Boolean);
+    if (meta.referrer) {
+      try {
+        parts.push(`via ${new URL(meta.referrer).hostname}`);
+      } catch {
+        parts.push(`via ${meta.referrer}`);
+      }
+    }
+    return parts.length > 0 ? parts.join(' / ') : null;
   };
 
   const getText = (text: string | MultiLanguageText): string => {
@@ -174,6 +204,64 @@ export const ResponsesPage: React.FC = () => {
           </div>
         </div>
 
+        {/* Funnel for the last 30 days */}
+        {funnel && funnel.views > 0 && (
+          <div className="bg-white rounded-lg shadow-sm border border-gray-200 p-6 mb-6">
+            <div className="flex justify-between items-center mb-4">
+              <h2 className="text-lg font-semibold text-gray-900">Funnel</h2>
+              <span className="text-sm text-gray-500">{funnel.from} – {funnel.to}</span>
+            </div>
+            <div className="grid grid-cols-2 md:grid-cols-5 gap-4 text-center">
+              <div>
+                <p className="text-sm text-gray-500">Views</p>
+                <p className="text-xl font-bold text-gray-900">{funnel.views}</p>
+              </div>
+              <div>
+                <p className="text-sm text-gray-500">Started</p>
+                <p className="text-xl font-bold text-gray-900">{funnel.starts}</p>
+                <p className="text-xs text-gray-500">{Math.round(funnel.startRate * 100)}% of views</p>
+              </div>
+              <div>
+                <p className="text-sm text-gray-500">Submitted</p>
+                <p className="text-xl font-bold text-gray-900">{funnel.submissions}</p>
+                <p className="text-xs text-gray-500">{Math.round(funnel.completionRate * 100)}% of starts</p>
+              </div>
+              <div>
+                <p className="text-sm text-gray-500">Median time</p>
+                <p className="text-xl font-bold text-gray-900">
+                  {funnel.medianCompletionSeconds === null ? '–' : `${Math.round(funnel.medianCompletionSeconds / 60)} min`}
+                </p>
+              </div>
+              <div>
+                <p className="text-sm text-gray-500">Most abandoned at</p>
+                <p className="text-sm font-semibold text-gray-900">
+                  {funnel.mostAbandonedField
+                    ? `${getText(funnel.mostAbandonedField.label)} (${funnel.mostAbandonedField.abandoned})`
+                    : '–'}
+                </p>
+              </div>
+            </div>
+          </div>
+        )}
+
+        {/* Attribution filters; the export uses them too */}
+        <div className="bg-white rounded-lg shadow-sm border border-gray-200 p-4 mb-6 flex flex-wrap items-end gap-4">
+          {(['utm_source', 'utm_medium', 'utm_campaign', 'referrer'] as const).map((key) => (
+            <label key={key} className="text-sm font-medium text-gray-700">
+              {key === 'referrer' ? 'Referrer contains' : key}
+              <input
+                type="text"
+                value={filters[key] || ''}
+                onChange={(e) => setFilters({ ...filters, [key]: e.target.value })}
+                className="mt-1 block px-3 py-2 border border-gray-300 rounded-md"
+              />
+            </label>
+          ))}
+          <Button onClick={loadFormAndResponses} variant="outline" className="border-gray-300 text-gray-700">
+            Apply filters
+          </Button>
+        </div>
+
         {/* Responses */}
         {responses.length === 0 ? (
           <div className="bg-white rounded-lg shadow-sm border border-gray-200 p-12 text-center">
@@ -187,7 +275,7 @@ export const ResponsesPage: React.FC = () => {
               This form hasn't received any responses yet. Share the form link to start collecting responses.
             </p>
             <Button
-              onClick={() => window.open(`/form/${form.id}`, '_blank')}
+              onClick={() => window.open(publicFormPath(form), '_blank')}
               variant="outline"
               className="border-gray-300 text-gray-700"
             >
@@ -210,7 +298,17 @@ export const ResponsesPage: React.FC = () => {
                       </div>
                       <div>
                         <p className="text-sm text-gray-500">Response ID: {response.id}</p>
-                        <p className="font-medium text-gray-900">Phone: {response.phoneNumber}</p>
+                        {response.phoneNumber && <p className="font-medium text-gray-900">Phone: {response.phoneNumber}</p>}
+                        {response.email && <p className="font-medium text-gray-900">Email: {response.email}</p>}
+                        {response.score !== undefined && (
+                          <p className="text-sm text-blue-600 font-medium">Score: {response.score}</p>
+                        )}
+                        {describeSource(response.metadata) && (
+                          <p className="text-xs text-gray-500">Source: {describeSource(response.metadata)}</p>
+                        )}
+                        {response.displayOrder?.options && Object.entries(response.displayOrder.options).map(([fieldId, order]) => (
+                          <p key={fieldId} className="text-xs text-gray-500">Options of {fieldId} shown as: {order.join(', ')}</p>
+                        ))}
                       </div>
                     </div>
                     <div className="text-right">
@@ -226,7 +324,21 @@ export const ResponsesPage: React.FC = () => {
                   <div className="grid grid-cols-1 md:grid-cols-2 gap-6">
                     {form.fields.map((field) => {
                       const value = response.responseData[field.id];
-                      const displayValue = Array.isArray(value) ? value.join(', ') : String(value || 'Not answered');
+                      const displayValue = Array.isArray(value) && field.group
+                        ? value.map((entry: Record<string, any>, i: number) => `#${i + 1} ` + field.group!.fields
+                            .filter((child) => entry[child.id] !== undefined && entry[child.id] !== '')
+                            .map((child) => `${getText(child.label)}: ${Array.isArray(entry[child.id]) ? entry[child.id].join(', ') : entry[child.id]}`)
+                            .join(', ')).join(' | ')
+                        : Array.isArray(value)
+                        ? value.join(', ')
+                        : value && typeof value === 'object' && 'start' in value
+                          ? `${value.start} – ${value.end}`
+                          : value && typeof value === 'object' && field.matrix
+                            ? field.matrix.rows
+                                .filter((row) => value[row.id] !== undefined)
+                                .map((row) => `${getText(row.label)}: ${Array.isArray(value[row.id]) ? value[row.id].join(', ') : value[row.id]}`)
+                                .join(' | ')
+                            : value === 0 ? '0' : String(value || 'Not answered');
                       
                       return (
                         <div key={field.id} className="border-l-4 border-blue-200 pl-4">
@@ -0,0 +1,96 @@
+import React from 'react';
+import { FieldType, FormField, MultiLanguageText } from '../../types/form';
+import { DualLanguageField } from './DualLanguageField';
+import { MultiLanguageOptions } from './MultiLanguageOptions';
+
+interface GroupFieldsEditorProps {
+  fields: FormField[];
+  types: { value: FieldType; label: string }[];
+  onChange: (fields: FormField[]) => void;
+}
+
+const CHOICE_TYPES: FieldType[] = ['select', 'radio', 'checkbox'];
+
+const newChildId = () => `field_${Date.now()}_${Math.random().toString(36).substring(2, 8)}`;
+
+// Edits the fields repeated in each entry of a group. Only simple types are offered;
+// groups can't nest and matrix/scale settings are edited at the top level.
+export const GroupFieldsEditor: React.FC<GroupFieldsEditorProps> = ({ fields, types, onChange }) => {
+  const updateChild = (index: number, child: FormField) => {
+    const newFields = [...fields];
+    newFields[index] = child;
+    onChange(newFields);
+  };
+
+  const changeType = (child: FormField, type: FieldType): FormField => ({
+    ...child,
+    type,
+    options: CHOICE_TYPES.includes(type)
+      ? (child.options.length ? child.options : [{ en: 'Option 1', ar: 'خيار 1' }])
+      : [],
+  });
+
+  return (
+    <div className="space-y-4">
+      <label className="block text-sm font-semibold text-gray-900">Fields in each entry</label>
+      {fields.map((child, idx) => (
+        <div key={child.id} className="border border-gray-200 rounded-lg p-4 space-y-3">
+          <div className="flex items-center gap-3">
+            <select
+              value={child.type}
+              onChange={(e) => updateChild(idx, changeType(child, e.target.value as FieldType))}
+              className="px-3 py-2 border border-gray-300 rounded-md text-sm"
+            >
+              {types.map((t) => (
+                <option key={t.value} value={t.value}>{t.label}</option>
+              ))}
+            </select>
+            <label className="flex items-center text-sm text-gray-700">
+              <input
+                type="checkbox"
+                checked={child.required}
+                onChange={(e) => updateChild(idx, { ...child, required: e.target.checked })}
+                className="h-4 w-4 mr-2 text-blue-600 border-gray-300 rounded"
+              />
+              Required
+            </label>
+            <button
+              type="button"
+              onClick={() => onChange(fields.filter((_, i) => i !== idx))}
+              className="ml-auto px-2 py-1 bg-red-100 text-red-600 rounded hover:bg-red-200"
+              aria-label="Remove field"
+              disabled={fields.length <= 1}
+            >
+              &times;
+            </button>
+          </div>
+          <DualLanguageField
+            label="Label"
+            value={child.label}
+            onChange={(val) => updateChild(idx, { ...child, label: val as MultiLanguageText })}
+          />
+          {CHOICE_TYPES.includes(child.type) && (
+            <MultiLanguageOptions
+              options={child.options}
+              onChange={(options) => updateChild(idx, { ...child, options })}
+            />
+          )}
+        </div>
+      ))}
+      <button
+        type="button"
+        onClick={() => onChange([...fields, {
+          id: newChildId(),
+          type: 'text',
+          label: { en: '', ar: '' },
+          placeholder: { en: '', ar: '' },
+          required: false,
+          options: [],
+        }])}
+        className="mt-2 px-4 py-2 bg-blue-100 text-blue-700 rounded hover:bg-blue-200"
+      >
+        + Add Field
+      </button>
+    </div>
+  );
+};
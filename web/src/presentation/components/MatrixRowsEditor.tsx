@@ -0,0 +1,61 @@
+import React from 'react';
+import { MatrixRow, MultiLanguageText } from '../../types/form';
+import { DualLanguageField } from './DualLanguageField';
+
+interface MatrixRowsEditorProps {
+  rows: MatrixRow[];
+  onChange: (rows: MatrixRow[]) => void;
+}
+
+// Rows keep their IDs when relabelled or removed so stored answers stay attached
+const newRowId = () => `row_${Date.now()}_${Math.random().toString(36).substring(2, 8)}`;
+
+export const MatrixRowsEditor: React.FC<MatrixRowsEditorProps> = ({ rows, onChange }) => {
+  const updateRow = (index: number, row: MatrixRow) => {
+    const newRows = [...rows];
+    newRows[index] = row;
+    onChange(newRows);
+  };
+
+  return (
+    <div className="space-y-4">
+      <label className="block text-sm font-semibold text-gray-900">Rows</label>
+      {rows.map((row, idx) => (
+        <div key={row.id} className="flex items-start space-x-2">
+          <div className="flex-1">
+            <DualLanguageField
+              label={`Row ${idx + 1}`}
+              value={row.label}
+              onChange={(val) => updateRow(idx, { ...row, label: val as MultiLanguageText })}
+            />
+            <label className="mt-2 flex items-center text-sm text-gray-700">
+              <input
+                type="checkbox"
+                checked={!!row.required}
+                onChange={(e) => updateRow(idx, { ...row, required: e.target.checked })}
+                className="h-4 w-4 mr-2 text-blue-600 border-gray-300 rounded"
+              />
+              Required row
+            </label>
+          </div>
+          <button
+            type="button"
+            onClick={() => onChange(rows.filter((_, i) => i !== idx))}
+            className="mt-8 ml-2 px-2 py-1 bg-red-100 text-red-600 rounded hover:bg-red-200"
+            aria-label="Remove row"
+            disabled={rows.length <= 1}
+          >
+            &times;
+          </button>
+        </div>
+      ))}
+      <button
+        type="button"
+        onClick={() => onChange([...rows, { id: newRowId(), label: { en: '', ar: '' } }])}
+        className="mt-2 px-4 py-2 bg-blue-100 text-blue-700 rounded hover:bg-blue-200"
+      >
+        + Add Row
+      </button>
+    </div>
+  );
+};
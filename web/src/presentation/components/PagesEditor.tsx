@@ -0,0 +1,85 @@
+import React from 'react';
+import { FormPage, MultiLanguageText } from '../../types/form';
+import { DualLanguageField } from './DualLanguageField';
+
+interface PagesEditorProps {
+  pages: FormPage[];
+  onChange: (pages: FormPage[]) => void;
+}
+
+// Pages keep their IDs when renamed so fields and saved drafts stay attached
+const newPageId = () => `page_${Date.now()}_${Math.random().toString(36).substring(2, 8)}`;
+
+// Edits the ordered pages of a multi-page form. No pages means a single-page form;
+// adding the first one puts every field on it.
+export const PagesEditor: React.FC<PagesEditorProps> = ({ pages, onChange }) => {
+  const updatePage = (index: number, page: FormPage) => {
+    const newPages = [...pages];
+    newPages[index] = page;
+    onChange(newPages);
+  };
+
+  const movePage = (index: number, offset: number) => {
+    const newPages = [...pages];
+    [newPages[index], newPages[index + offset]] = [newPages[index + offset], newPages[index]];
+    onChange(newPages);
+  };
+
+  return (
+    <div className="space-y-4">
+      {pages.map((page, idx) => (
+        <div key={page.id} className="flex items-start space-x-2">
+          <div className="flex-1">
+            <DualLanguageField
+              label={`Page ${idx + 1} title`}
+              value={page.title}
+              onChange={(val) => updatePage(idx, { ...page, title: val as MultiLanguageText })}
+            />
+            <label className="flex items-center mt-2 text-sm text-gray-700">
+              <input
+                type="checkbox"
+                checked={!!page.shuffleFields}
+                onChange={(e) => updatePage(idx, { ...page, shuffleFields: e.target.checked })}
+                className="h-4 w-4 mr-2 text-blue-600 border-gray-300 rounded"
+              />
+              Shuffle the order of this page's questions for each respondent
+            </label>
+          </div>
+          <button
+            type="button"
+            onClick={() => movePage(idx, -1)}
+            className="mt-8 px-2 py-1 bg-gray-100 text-gray-700 rounded hover:bg-gray-200"
+            aria-label="Move page up"
+            disabled={idx === 0}
+          >
+            ↑
+          </button>
+          <button
+            type="button"
+            onClick={() => movePage(idx, 1)}
+            className="mt-8 px-2 py-1 bg-gray-100 text-gray-700 rounded hover:bg-gray-200"
+            aria-label="Move page down"
+            disabled={idx === pages.length - 1}
+          >
+            ↓
+          </button>
+          <button
+            type="button"
+            onClick={() => onChange(pages.filter((_, i) => i !== idx))}
+            className="mt-8 px-2 py-1 bg-red-100 text-red-600 rounded hover:bg-red-200"
+            aria-label="Remove page"
+          >
+            &times;
+          </button>
+        </div>
+      ))}
+      <button
+        type="button"
+        onClick={() => onChange([...pages, { id: newPageId(), title: { en: `Page ${pages.length + 1}`, ar: `صفحة ${pages.length + 1}` } }])}
+        className="mt-2 px-4 py-2 bg-blue-100 text-blue-700 rounded hover:bg-blue-200"
+      >
+        + Add Page
+      </button>
+    </div>
+  );
+};
@@ -0,0 +1,61 @@
+import React from 'react';
+import { MultiLanguageText, ScoreBand } from '../../types/form';
+import { DualLanguageField } from './DualLanguageField';
+
+interface ScoreBandsEditorProps {
+  bands: ScoreBand[];
+  onChange: (bands: ScoreBand[]) => void;
+}
+
+// Edits the result messages of a quiz. A score gets the message of the last band whose
+// minimum it reaches, so bands are kept sorted by their minimum.
+export const ScoreBandsEditor: React.FC<ScoreBandsEditorProps> = ({ bands, onChange }) => {
+  const updateBand = (index: number, band: ScoreBand) => {
+    const newBands = [...bands];
+    newBands[index] = band;
+    onChange(newBands);
+  };
+
+  const sortBands = () => onChange([...bands].sort((a, b) => a.min - b.min));
+
+  return (
+    <div className="space-y-4">
+      {bands.map((band, idx) => (
+        <div key={idx} className="flex items-start space-x-2">
+          <label className="w-28 text-sm font-medium text-gray-700">
+            From score
+            <input
+              type="number"
+              value={band.min}
+              onChange={(e) => updateBand(idx, { ...band, min: parseFloat(e.target.value) || 0 })}
+              onBlur={sortBands}
+              className="mt-1 block w-full px-3 py-2 border border-gray-300 rounded-md"
+            />
+          </label>
+          <div className="flex-1">
+            <DualLanguageField
+              label="Result message"
+              value={band.message}
+              onChange={(val) => updateBand(idx, { ...band, message: val as MultiLanguageText })}
+            />
+          </div>
+          <button
+            type="button"
+            onClick={() => onChange(bands.filter((_, i) => i !== idx))}
+            className="mt-8 px-2 py-1 bg-red-100 text-red-600 rounded hover:bg-red-200"
+            aria-label="Remove band"
+          >
+            &times;
+          </button>
+        </div>
+      ))}
+      <button
+        type="button"
+        onClick={() => onChange([...bands, { min: bands.length > 0 ? bands[bands.length - 1].min + 1 : 0, message: { en: '', ar: '' } }])}
+        className="mt-2 px-4 py-2 bg-blue-100 text-blue-700 rounded hover:bg-blue-200"
+      >
+        + Add Result Message
+      </button>
+    </div>
+  );
+};
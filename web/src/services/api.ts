@@ -1,4 +1,4 @@
-import { Form, FormDefinition, FormResponse, FormSubmission } from '../types/form';
+import { DraftSave, Form, FormAnalytics, FormDefinition, FormDraft, FormEvent, FormField, FormFunnel, FormResponse, FormSubmission, ResponseFilters } from '../types/form';
 
 export interface PaginatedResponse<T> {
   data: T[];
@@ -11,6 +11,36 @@ export interface PaginatedResponse<T> {
 // Use environment variable for API URL in production, fallback to proxy for development
 const API_BASE_URL = import.meta.env.VITE_API_URL || '/api';
 
+// Workspace the admin UI acts on; the backend falls back to the default workspace
+const WORKSPACE_STORAGE_KEY = 'workspaceId';
+
+export const getWorkspaceId = (): string | null => localStorage.getItem(WORKSPACE_STORAGE_KEY);
+
+export const setWorkspaceId = (workspaceId: number | null) => {
+  if (workspaceId === null) {
+    localStorage.removeItem(WORKSPACE_STORAGE_KEY);
+  } else {
+    localStorage.setItem(WORKSPACE_STORAGE_KEY, String(workspaceId));
+  }
+};
+
+// filterQuery turns the non-empty filters into query parameters, starting with prefix
+const filterQuery = (filters: ResponseFilters, prefix: string): string => {
+  const params = new URLSearchParams();
+  Object.entries(filters).forEach(([key, value]) => {
+    if (value) params.set(key, value);
+  });
+  const query = params.toString();
+  return query ? prefix + query : '';
+};
+
+// ApiError carries the HTTP status so callers can react to e.g. a 412 save conflict
+export class ApiError extends Error {
+  constructor(public status: number, public body: string) {
+    super(`API Error: ${status} - ${body}`);
+  }
+}
+
 class ApiService {
   private async request<T>(
     endpoint: string,
@@ -22,11 +52,12 @@ class ApiService {
       console.log('Making API request to:', url);
       
       const response = await fetch(url, {
+        ...options,
         headers: {
           'Content-Type': 'application/json',
+          ...(getWorkspaceId() ? { 'X-Workspace-ID': getWorkspaceId()! } : {}),
           ...options.headers,
         },
-        ...options,
       });
 
       console.log('API response status:', response.status);
@@ -34,7 +65,7 @@ class ApiService {
       if (!response.ok) {
         const errorText = await response.text();
         console.error('API Error:', response.status, errorText);
-        throw new Error(`API Error: ${response.status} - ${errorText}`);
+        throw new ApiError(response.status, errorText);
       }
 
       const data = await response.json();
@@ -62,6 +93,17 @@ class ApiService {
     return this.request<Form>(`/forms/${id}`);
   }
 
+  // Respondent view of a form by share token, slug or numeric ID; the funnel session
+  // seeds the order of shuffled questions and options
+  async getPublicForm(ref: string, sessionId?: string): Promise<Form> {
+    const query = sessionId ? `?session=${encodeURIComponent(sessionId)}` : '';
+    return this.request<Form>(`/public/forms/${encodeURIComponent(ref)}${query}`);
+  }
+
+  async rotateFormToken(formId: number): Promise<Form> {
+    return this.request<Form>(`/forms/${formId}/token/rotate`, { method: 'POST' });
+  }
+
   // Form submissions
   async submitForm(submission: FormSubmission): Promise<FormResponse> {
     return this.request<FormResponse>('/submit', {
@@ -70,22 +112,175 @@ class ApiService {
     });
   }
 
-  async getFormResponses(formId: number): Promise<FormResponse[]> {
-    return this.request<FormResponse[]>(`/forms/${formId}/responses`);
+  // Drafts: answers saved page by page and resumed with the token from createDraft
+  async createDraft(draft: DraftSave): Promise<FormDraft> {
+    return this.request<FormDraft>('/drafts', {
+      method: 'POST',
+      body: JSON.stringify(draft),
+    });
+  }
+
+  async getDraft(resumeToken: string): Promise<FormDraft> {
+    return this.request<FormDraft>(`/drafts/${encodeURIComponent(resumeToken)}`);
+  }
+
+  async saveDraft(resumeToken: string, draft: DraftSave): Promise<FormDraft> {
+    return this.request<FormDraft>(`/drafts/${encodeURIComponent(resumeToken)}`, {
+      method: 'PUT',
+      body: JSON.stringify(draft),
+    });
+  }
+
+  // Sends a code to a phone number that saved a draft on the form
+  async requestDraftCode(formRef: string, phoneNumber: string): Promise<void> {
+    await this.request<{ status: string }>('/drafts/otp', {
+      method: 'POST',
+      body: JSON.stringify({ formRef, phoneNumber }),
+    });
+  }
+
+  // Exchanges the code for the latest draft, with a new resume token
+  async verifyDraftCode(formRef: string, phoneNumber: string, code: string): Promise<FormDraft> {
+    return this.request<FormDraft>('/drafts/otp/verify', {
+      method: 'POST',
+      body: JSON.stringify({ formRef, phoneNumber, code }),
+    });
+  }
+
+  // Funnel beacons must not hold up the page or fail loudly; sendBeacon survives unload
+  trackFormEvent(event: FormEvent): void {
+    const body = JSON.stringify(event);
+    if (navigator.sendBeacon && navigator.sendBeacon(`${API_BASE_URL}/events`, body)) return;
+    fetch(`${API_BASE_URL}/events`, { method: 'POST', body, keepalive: true }).catch(() => undefined);
+  }
+
+  async getFormFunnel(formId: number, from?: string, to?: string): Promise<FormFunnel> {
+    const params = new URLSearchParams();
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+    const query = params.toString();
+    return this.request<FormFunnel>(`/forms/${formId}/funnel${query ? `?${query}` : ''}`);
   }
 
-  async updateForm(formId: number, formData: FormDefinition): Promise<Form> {
+  async getFormResponses(formId: number, filters: ResponseFilters = {}): Promise<FormResponse[]> {
+    return this.request<FormResponse[]>(`/forms/${formId}/responses${filterQuery(filters, '?')}`);
+  }
+
+  // version is the form version the edit started from; the save fails with a 412
+  // ApiError if someone else saved in the meantime
+  async updateForm(formId: number, formData: FormDefinition, version: number): Promise<Form> {
     return this.request<Form>(`/forms/${formId}`, {
       method: 'PUT',
+      headers: { 'If-Match': `"v${version}"` },
       body: JSON.stringify(formData)
     });
   }
 
+  // Partial edits; version is optional and, when given, guards the edit like updateForm
+  async patchForm(formId: number, patch: object, version?: number): Promise<Form> {
+    const contentType = Array.isArray(patch) ? 'application/json-patch+json' : 'application/merge-patch+json';
+    return this.request<Form>(`/forms/${formId}`, {
+      method: 'PATCH',
+      headers: { 'Content-Type': contentType, ...(version ? { 'If-Match': `"v${version}"` } : {}) },
+      body: JSON.stringify(patch)
+    });
+  }
+
+  async addField(formId: number, field: FormField, position?: number): Promise<Form> {
+    const query = position === undefined ? '' : `?position=${position}`;
+    return this.request<Form>(`/forms/${formId}/fields${query}`, {
+      method: 'POST',
+      body: JSON.stringify(field)
+    });
+  }
+
+  async updateField(formId: number, field: FormField): Promise<Form> {
+    return this.request<Form>(`/forms/${formId}/fields/${encodeURIComponent(field.id)}`, {
+      method: 'PUT',
+      body: JSON.stringify(field)
+    });
+  }
+
+  async removeField(formId: number, fieldId: string): Promise<Form> {
+    return this.request<Form>(`/forms/${formId}/fields/${encodeURIComponent(fieldId)}`, {
+      method: 'DELETE'
+    });
+  }
+
+  async reorderFields(formId: number, order: string[]): Promise<Form> {
+    return this.request<Form>(`/forms/${formId}/fields/reorder`, {
+      method: 'POST',
+      body: JSON.stringify({ order })
+    });
+  }
+
+  // CSV of every response, flattened by field type; column labels use language
+  async exportResponsesCsv(formId: number, language: 'en' | 'ar' = 'en', filters: ResponseFilters = {}): Promise<Blob> {
+    const workspaceId = getWorkspaceId();
+    const response = await fetch(`${API_BASE_URL}/forms/${formId}/responses/export?language=${language}${filterQuery(filters, '&')}`, {
+      headers: workspaceId ? { 'X-Workspace-ID': workspaceId } : {},
+    });
+    if (!response.ok) {
+      throw new ApiError(response.status, await response.text());
+    }
+    return response.blob();
+  }
+
+  async getFormAnalytics(formId: number): Promise<FormAnalytics> {
+    return this.request<FormAnalytics>(`/forms/${formId}/analytics`);
+  }
+
   async deleteForm(formId: number): Promise<void> {
     await this.request<void>(`/forms/${formId}`, {
       method: 'DELETE'
     });
   }
+
+  // Live submissions over Server-Sent Events; omit formId to follow every form.
+  // EventSource cannot send the admin headers, so each connection opens with a
+  // short-lived stream token. EventSource retries network drops itself; once the server
+  // turns it away (e.g. the token expired) a fresh token resumes from the last event.
+  subscribeToResponses(onResponse: (response: FormResponse) => void, formId?: number): () => void {
+    const endpoint = formId ? `/forms/${formId}/responses/stream` : '/responses/stream';
+    let source: EventSource | null = null;
+    let lastEventId = '';
+    let closed = false;
+
+    const connect = async () => {
+      try {
+        const { token } = await this.request<{ token: string }>('/responses/stream-token', { method: 'POST' });
+        if (closed) return;
+        const params = new URLSearchParams({ token });
+        const workspaceId = getWorkspaceId();
+        if (workspaceId) params.set('workspaceId', workspaceId);
+        if (lastEventId) params.set('lastEventId', lastEventId);
+        source = new EventSource(`${API_BASE_URL}${endpoint}?${params}`);
+        source.addEventListener('response.created', (event) => {
+          const message = event as MessageEvent;
+          lastEventId = message.lastEventId || lastEventId;
+          try {
+            onResponse(JSON.parse(message.data));
+          } catch (error) {
+            console.error('Invalid response event:', error);
+          }
+        });
+        source.onerror = () => {
+          if (source?.readyState === EventSource.CLOSED && !closed) {
+            setTimeout(connect, 3000);
+          }
+        };
+      } catch (error) {
+        console.error('Error opening response stream:', error);
+        if (!closed) setTimeout(connect, 3000);
+      }
+    };
+
+    connect();
+    return () => {
+      closed = true;
+      source?.close();
+    };
+  }
 }
 
 export const apiService = new ApiService();
\ No newline at end of file
@@ -1,5 +1,7 @@
 // Form field types
-export type FieldType = 'text' | 'textarea' | 'email' | 'password' | 'number' | 'date' | 'time' | 'select' | 'radio' | 'checkbox' | 'file';
+export type FieldType = 'text' | 'textarea' | 'email' | 'password' | 'number' | 'date' | 'time' | 'select' | 'radio' | 'checkbox' | 'file'
+  | 'rating' | 'scale' | 'nps' | 'url' | 'phone' | 'daterange' | 'matrix' | 'group' | 'hidden'
+  | 'calculated';
 
 // Multi-language text interface
 export interface MultiLanguageText {
@@ -20,16 +22,114 @@ export interface FormField {
     max?: number;
     pattern?: string;
   };
+  // Range of rating, scale and NPS fields; ratings use 1..max stars, NPS is always 0..10
+  scale?: FieldScale;
+  // Rows and shared columns of a matrix field; answers map row IDs to column text
+  matrix?: FieldMatrix;
+  // Child fields of a repeatable group; answers are an array with one object per entry
+  group?: FieldGroup;
+  // ID of the page the field is on; fields without one are on the first page
+  page?: string;
+  // Formula of a calculated field, e.g. price * quantity; evaluated by the server on submit
+  expression?: string;
+  // Answer key of a quiz question; correct is never sent to respondents
+  quiz?: QuizQuestion;
+  // Most responses that may pick each option of a select or radio field, keyed by the
+  // option's text; unlisted options are unlimited
+  capacity?: Record<string, number>;
+  // Show the options in a different order to each respondent, keeping the last pinLast
+  // (e.g. "Other") at the end
+  shuffleOptions?: boolean;
+  pinLast?: number;
+}
+
+// Order a respondent saw shuffled fields (by page ID) and options (by field ID) in
+export interface DisplayOrder {
+  pages?: Record<string, string[]>;
+  options?: Record<string, string[]>;
+}
+
+// How full an option with a capacity is; capacity and remaining are only sent to admins
+export interface OptionSeats {
+  fieldId: string;
+  option: string;
+  capacity?: number;
+  remaining?: number;
+  full: boolean;
+}
+
+export interface QuizQuestion {
+  correct?: string[];
+  points: number;
+}
+
+// Quiz mode of a form; a score gets the message of the last band whose min it reaches
+export interface Quiz {
+  bands?: ScoreBand[];
+}
+
+export interface ScoreBand {
+  min: number;
+  message: MultiLanguageText;
+}
+
+export interface QuizResult {
+  score: number;
+  maxScore: number;
+  message?: MultiLanguageText;
+}
+
+// One step of a multi-page form; pages are shown in order
+export interface FormPage {
+  id: string;
+  title: MultiLanguageText;
+  // Show the page's fields in a different order to each respondent
+  shuffleFields?: boolean;
+}
+
+export interface FieldGroup {
+  fields: FormField[];
+  min: number;
+  max: number;
+  addLabel?: MultiLanguageText;
+}
+
+export interface MatrixRow {
+  id: string;
+  label: MultiLanguageText;
+  required?: boolean;
+}
+
+export interface FieldMatrix {
+  rows: MatrixRow[];
+  columns: MultiLanguageText[];
+  multiple?: boolean;
+}
+
+export interface FieldScale {
+  min: number;
+  max: number;
+  minLabel?: MultiLanguageText;
+  maxLabel?: MultiLanguageText;
 }
 
 // Multi-language form interface
 export interface Form {
   id: number;
+  slug?: string;
+  // Secret part of the unguessable share link; only returned to admins
+  publicToken?: string;
+  // Bumped on every save; sent back as If-Match when updating
+  version: number;
   title: MultiLanguageText;
   description?: MultiLanguageText;
   fields: FormField[];
+  pages?: FormPage[];
+  quiz?: Quiz;
   submitButtonText?: MultiLanguageText;
   heroImageUrl?: string;
+  settings?: FormSettings;
+  seats?: OptionSeats[];
   isActive: boolean;
   createdAt: string;
   updatedAt: string;
@@ -40,22 +140,157 @@ export interface FormDefinition {
   title: string | MultiLanguageText;
   description?: string | MultiLanguageText;
   fields: FormField[];
+  pages?: FormPage[];
+  quiz?: Quiz | null;
   submitButtonText?: string | MultiLanguageText;
   heroImageUrl?: string;
+  settings?: FormSettings;
+}
+
+// Per-form behavior; only the settings the web app edits are typed, the rest are kept as-is
+export interface FormSettings {
+  // Fields the link may fill with ?<fieldId>=value; every hidden field must be listed
+  prefill?: string[];
+  // How the respondent's IP is kept with each response; hash is the default, anonymous forms keep none
+  ipAddresses?: 'hash' | 'full' | 'none';
+  // Contact details respondents give; phone is the default
+  identity?: IdentityMode;
+  // Emailed to the respondent and to notifyEmails; may show answers with {{fieldId}}
+  receipt?: EmailTemplate;
+  notification?: EmailTemplate;
+  notifyEmails?: string[];
+  [setting: string]: any;
+}
+
+export interface EmailTemplate {
+  subject: MultiLanguageText;
+  body: MultiLanguageText;
 }
 
+export type IdentityMode = 'anonymous' | 'phone' | 'email' | 'phone+email';
+
 export interface FormResponse {
   id: number;
   formId: number;
-  phoneNumber: string;
+  // Only the contact details the form's identity mode asks for
+  phoneNumber?: string;
+  email?: string;
   responseData: Record<string, any>;
   language: 'en' | 'ar'; // Track which language was used for submission
   submittedAt: string;
+  status?: string;
+  assignee?: string;
+  tags?: string[];
+  metadata?: ResponseMetadata;
+  score?: number;
+  // Only on the response to a quiz submission
+  result?: QuizResult;
+  displayOrder?: DisplayOrder;
+}
+
+// Where a response came from; ip is a hash unless the form keeps full addresses
+export interface ResponseMetadata {
+  utm_source?: string;
+  utm_medium?: string;
+  utm_campaign?: string;
+  utm_term?: string;
+  utm_content?: string;
+  referrer?: string;
+  userAgent?: string;
+  ip?: string;
+  ipHashed?: boolean;
+}
+
+// Optional filters of the responses list and export; referrer matches part of the URL
+export type ResponseFilters = Partial<Record<'status' | 'assignee' | 'tag' | 'utm_source' | 'utm_medium' | 'utm_campaign' | 'utm_term' | 'utm_content' | 'referrer', string>>;
+
+// Attribution the public form reads from its own URL and sends with the submission
+export type Attribution = Partial<Record<'utm_source' | 'utm_medium' | 'utm_campaign' | 'utm_term' | 'utm_content' | 'referrer', string>>;
+
+// Per-field summary from the analytics endpoint; stats depend on the field type
+export interface FieldSummary {
+  fieldId: string;
+  type: FieldType;
+  kind: string;
+  label: MultiLanguageText;
+  answered: number;
+  stats?: Record<string, any>;
+}
+
+export interface FormAnalytics {
+  formId: number;
+  responses: number;
+  fields: FieldSummary[];
 }
 
 export interface FormSubmission {
   formId: number;
-  phoneNumber: string;
+  // Slug or share token from the public link
+  formRef?: string;
+  phoneNumber?: string;
+  email?: string;
+  responseData: Record<string, any>;
+  language: 'en' | 'ar';
+  // Submits the saved draft, with responseData laid over its answers
+  resumeToken?: string;
+  // Funnel session of the respondent, marking it submitted
+  sessionId?: string;
+  attribution?: Attribution;
+}
+
+// Funnel beacon sent by the public form; carries no answers or personal data
+export interface FormEvent {
+  formId: number;
+  formRef?: string;
+  sessionId: string;
+  type: 'view' | 'start' | 'field';
+  fieldId?: string;
+}
+
+export interface FieldDropOff {
+  fieldId: string;
+  label: MultiLanguageText;
+  abandoned: number;
+}
+
+// View → start → submit funnel over a date range (YYYY-MM-DD, inclusive)
+export interface FormFunnel {
+  formId: number;
+  from: string;
+  to: string;
+  views: number;
+  starts: number;
+  submissions: number;
+  startRate: number;
+  completionRate: number;
+  conversionRate: number;
+  medianCompletionSeconds: number | null;
+  abandoned: number;
+  dropOff: FieldDropOff[];
+  mostAbandonedField: FieldDropOff | null;
+}
+
+// Answers to one page, saved so the respondent can resume later
+export interface DraftSave {
+  formId: number;
+  formRef?: string;
+  pageId: string;
+  phoneNumber?: string;
+  responseData: Record<string, any>;
+  language: 'en' | 'ar';
+}
+
+export interface FormDraft {
+  formId: number;
+  pageId: string;
+  phoneNumber?: string;
   responseData: Record<string, any>;
   language: 'en' | 'ar';
-}
\ No newline at end of file
+  updatedAt: string;
+  expiresAt: string;
+  // Only present when the draft is created or resumed by phone
+  resumeToken?: string;
+}
+// Public link for a form: the share token when known, otherwise the slug
+export const publicFormPath = (form: Pick<Form, 'id' | 'slug' | 'publicToken'>): string =>
+  `/form/${form.publicToken || form.slug || form.id}`;